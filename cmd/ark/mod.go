@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/magicdrive/ark/internal/commandline"
 	"github.com/magicdrive/ark/internal/core"
@@ -21,6 +22,105 @@ func Execute(version string) {
 			os.Exit(0)
 		}
 		mcp.RunMCPServe(opt.RootDir, opt)
+	} else if len(os.Args) >= 2 && os.Args[1] == "restore" {
+		_, opt, err := commandline.RestoreOptParse(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Faital Error: %v\n", err)
+		}
+		if opt.HelpFlag {
+			opt.FlagSet.Usage()
+			os.Exit(0)
+		}
+		restored, err := core.RestoreDump(opt.DumpFilenames, opt.OutputDir, opt.ForceFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Restored %d files into %s\n", len(restored), opt.OutputDir)
+	} else if len(os.Args) >= 2 && os.Args[1] == "scan-secrets" {
+		_, opt, err := commandline.ScanSecretsOptParse(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Faital Error: %v\n", err)
+		}
+		if opt.HelpFlag {
+			opt.FlagSet.Usage()
+			os.Exit(0)
+		}
+		if !DirExists(opt.GeneralOption.TargetDirname) {
+			fmt.Fprintf(os.Stderr, "Error: a directory not found: %s\n", opt.GeneralOption.TargetDirname)
+			os.Exit(2)
+		}
+		// exit 1 when secrets are found, 2 when the scan itself fails
+		matches, err := core.ScanSecrets(opt.GeneralOption)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if err := core.WriteSecretReport(os.Stdout, matches, opt.Format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if len(matches) > 0 {
+			os.Exit(1)
+		}
+	} else if len(os.Args) >= 2 && os.Args[1] == "encodings" {
+		_, opt, err := commandline.EncodingsOptParse(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Faital Error: %v\n", err)
+		}
+		if opt.HelpFlag {
+			opt.FlagSet.Usage()
+			os.Exit(0)
+		}
+		if !DirExists(opt.GeneralOption.TargetDirname) {
+			fmt.Fprintf(os.Stderr, "Error: a directory not found: %s\n", opt.GeneralOption.TargetDirname)
+			os.Exit(2)
+		}
+		// exit 1 when files need cleanup, 2 when the scan itself fails
+		findings, err := core.ScanEncodings(opt.GeneralOption)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if err := core.WriteEncodingReport(os.Stdout, findings, opt.Format); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		if len(findings) > 0 {
+			os.Exit(1)
+		}
+	} else if len(os.Args) >= 2 && os.Args[1] == "unmask" {
+		_, opt, err := commandline.UnmaskOptParse(os.Args[2:])
+		if err != nil {
+			log.Fatalf("Faital Error: %v\n", err)
+		}
+		if opt.HelpFlag {
+			opt.FlagSet.Usage()
+			os.Exit(0)
+		}
+		result, err := core.UnmaskFiles(opt.Filenames, opt.Vault, os.Stdin, os.Stdout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// stdout may carry the unmasked text, so report on stderr
+		fmt.Fprintf(os.Stderr, "Unmasked %d secrets in %d files\n", result.Placeholders, len(result.Files))
+		if len(result.Unknown) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: placeholders not found in %s: %s\n", opt.Vault.Path, strings.Join(result.Unknown, ", "))
+			os.Exit(1)
+		}
+	} else if len(os.Args) >= 2 && os.Args[1] == "config" {
+		if len(os.Args) < 3 || os.Args[2] != "show" {
+			fmt.Println("Error: usage: ark config show [OPTIONS] [dirname]")
+			os.Exit(1)
+		}
+		_, opt, err := commandline.GeneralOptParse(os.Args[3:])
+		if err != nil {
+			log.Fatalf("Faital Error: %v\n", err)
+		}
+		if opt.HelpFlag {
+			opt.FlagSet.Usage()
+			os.Exit(0)
+		}
+		commandline.WriteConfig(os.Stdout, opt.FlagSet, opt.ConfigFiles, opt.ProfileName)
 	} else {
 		_, opt, err := commandline.GeneralOptParse(os.Args[1:])
 		if err != nil {
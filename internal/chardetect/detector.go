@@ -40,6 +40,9 @@ func (d *Detector) WithSampleSize(size int) *Detector {
 	return d
 }
 
+// scoreOrder is the order in which Detect breaks ties between scores.
+var scoreOrder = []Encoding{UTF8, ShiftJIS, EUCJP, CP932, ISO2022JP, GB18030, Big5, EUCKR, Windows1252}
+
 // Detect detects the character encoding of the given data.
 func (d *Detector) Detect(data []byte) *Result {
 	if len(data) == 0 {
@@ -53,10 +56,23 @@ func (d *Detector) Detect(data []byte) *Result {
 
 	// Step 1: Check for BOM (instant identification)
 	if encoding, found := s.detectBOM(); found {
+		language := ""
+		if encoding == UTF8 {
+			language = "ja"
+		}
 		return &Result{
 			Encoding:   encoding,
 			Confidence: 1.0,
-			Language:   "ja",
+			Language:   language,
+			BOM:        true,
+		}
+	}
+
+	// UTF-16 and UTF-32 without a BOM are full of zero bytes
+	if encoding, score := s.detectWideUnicode(); encoding != Unknown {
+		return &Result{
+			Encoding:   encoding,
+			Confidence: score,
 		}
 	}
 
@@ -87,17 +103,29 @@ func (d *Detector) Detect(data []byte) *Result {
 	scores[UTF8] = s.scoreUTF8()
 	scores[ShiftJIS] = s.scoreShiftJIS()
 	scores[EUCJP] = s.scoreEUCJP()
+	scores[GB18030] = s.scoreGB18030()
+	scores[Big5] = s.scoreBig5()
+	scores[EUCKR] = s.scoreEUCKR()
+	scores[Windows1252] = s.scoreWindows1252()
+
+	// The CJK encodings overlap, so their structure is weighed by how well
+	// the decoded text reads
+	for _, enc := range []Encoding{ShiftJIS, EUCJP, GB18030, Big5, EUCKR} {
+		if scores[enc] > 0 {
+			scores[enc] *= s.plausibility(enc)
+		}
+	}
 
 	// CP932 is essentially Shift-JIS with extensions
 	// Use Shift-JIS score with slight adjustment
 	scores[CP932] = scores[ShiftJIS] * 0.95
 
-	// Step 4: Find the highest score
+	// Step 4: Find the highest score, preferring the earlier encoding on a tie
 	var bestEncoding Encoding = Unknown
 	var bestScore float64 = 0.0
 
-	for enc, score := range scores {
-		if score > bestScore {
+	for _, enc := range scoreOrder {
+		if score := scores[enc]; score > bestScore {
 			bestScore = score
 			bestEncoding = enc
 		}
@@ -116,6 +144,10 @@ func (d *Detector) Detect(data []byte) *Result {
 	if bestEncoding == ShiftJIS || bestEncoding == EUCJP ||
 		bestEncoding == ISO2022JP || bestEncoding == CP932 {
 		language = "ja"
+	} else if bestEncoding == GB18030 || bestEncoding == Big5 {
+		language = "zh"
+	} else if bestEncoding == EUCKR {
+		language = "ko"
 	} else if bestEncoding == UTF8 && bestScore > 0.7 {
 		language = "ja" // Likely Japanese UTF-8
 	}
@@ -381,3 +381,75 @@ func TestEncoding_IsValid(t *testing.T) {
 		}
 	}
 }
+
+func TestDetect_WideUnicode(t *testing.T) {
+	tests := []struct {
+		filename     string
+		wantEncoding Encoding
+	}{
+		{"utf16le_bom.txt", UTF16LE},
+		{"utf16be_bom.txt", UTF16BE},
+		{"utf16le.txt", UTF16LE},
+		{"utf16be.txt", UTF16BE},
+		{"utf32le_bom.txt", UTF32LE},
+		{"utf32be.txt", UTF32BE},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.filename))
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+
+			result := Detect(data)
+
+			if result.Encoding != tt.wantEncoding {
+				t.Errorf("Detect() encoding = %v, want %v", result.Encoding, tt.wantEncoding)
+			}
+			if result.Confidence < 0.7 {
+				t.Errorf("Detect() confidence = %v, want >= 0.7", result.Confidence)
+			}
+			if result.Language != "" {
+				t.Errorf("Detect() language = %v, want none", result.Language)
+			}
+		})
+	}
+}
+
+func TestDetect_LegacyEncodings(t *testing.T) {
+	tests := []struct {
+		filename     string
+		wantEncoding Encoding
+		expectedLang string
+	}{
+		{"windows-1252.txt", Windows1252, ""},
+		{"gb18030.txt", GB18030, "zh"},
+		{"big5.txt", Big5, "zh"},
+		{"euc-kr.txt", EUCKR, "ko"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.filename))
+			if err != nil {
+				t.Fatalf("Failed to read test file: %v", err)
+			}
+
+			result := Detect(data)
+
+			if result.Encoding != tt.wantEncoding {
+				t.Errorf("Detect() encoding = %v, want %v", result.Encoding, tt.wantEncoding)
+			}
+			if result.Confidence < 0.7 {
+				t.Errorf("Detect() confidence = %v, want >= 0.7", result.Confidence)
+			}
+			if result.Language != tt.expectedLang {
+				t.Errorf("Detect() language = %v, want %v", result.Language, tt.expectedLang)
+			}
+
+			t.Logf("Detected: %s (confidence: %.2f, language: %s)",
+				result.Encoding, result.Confidence, result.Language)
+		})
+	}
+}
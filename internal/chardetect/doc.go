@@ -3,7 +3,9 @@ Package chardetect provides character encoding detection for text files,
 with a focus on Japanese encodings.
 
 It supports UTF-8, Shift-JIS, EUC-JP, ISO-2022-JP, and CP932 (Windows-31J)
-with high accuracy through statistical analysis and pattern matching.
+with high accuracy through statistical analysis and pattern matching, and
+also recognizes UTF-16/32, Windows-1252 and the Chinese and Korean legacy
+encodings.
 
 # Supported Encodings
 
@@ -13,6 +15,11 @@ with high accuracy through statistical analysis and pattern matching.
   - ISO-2022-JP (Japanese email/network encoding)
   - CP932 (Windows-31J, Microsoft's extension of Shift-JIS)
   - ASCII (7-bit ASCII)
+  - UTF-16LE, UTF-16BE, UTF-32LE, UTF-32BE (with and without BOM)
+  - Windows-1252 (a superset of ISO-8859-1)
+  - GB18030 (including GBK and GB2312)
+  - Big5
+  - EUC-KR (including the CP949 extensions)
 
 # Basic Usage
 
@@ -52,8 +59,10 @@ The detection process uses multiple strategies:
 
  1. BOM (Byte Order Mark) detection for instant identification
  2. Escape sequence detection for ISO-2022-JP
- 3. Byte pattern analysis for Shift-JIS and EUC-JP
- 4. Statistical scoring for final determination
+ 3. Zero-byte patterns for UTF-16 and UTF-32 without a BOM
+ 4. Byte pattern analysis for the multi-byte encodings
+ 5. Decoding each candidate to check the text reads as its language
+ 6. Statistical scoring for final determination
 
 This multi-stage approach ensures high accuracy even with small samples
 or ambiguous content.
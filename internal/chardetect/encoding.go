@@ -20,6 +20,30 @@ const (
 	// CP932 represents CP932/Windows-31J encoding (Microsoft's extension of Shift-JIS).
 	CP932 Encoding = "CP932"
 
+	// UTF16LE represents little-endian UTF-16 encoding (with or without BOM).
+	UTF16LE Encoding = "UTF-16LE"
+
+	// UTF16BE represents big-endian UTF-16 encoding (with or without BOM).
+	UTF16BE Encoding = "UTF-16BE"
+
+	// UTF32LE represents little-endian UTF-32 encoding (with or without BOM).
+	UTF32LE Encoding = "UTF-32LE"
+
+	// UTF32BE represents big-endian UTF-32 encoding (with or without BOM).
+	UTF32BE Encoding = "UTF-32BE"
+
+	// Windows1252 represents Windows-1252 encoding, a superset of the printable ISO-8859-1 (Latin-1).
+	Windows1252 Encoding = "Windows-1252"
+
+	// GB18030 represents GB18030 encoding (Simplified Chinese), a superset of GBK and GB2312.
+	GB18030 Encoding = "GB18030"
+
+	// Big5 represents Big5 encoding (Traditional Chinese).
+	Big5 Encoding = "Big5"
+
+	// EUCKR represents EUC-KR encoding (Korean), read with the CP949 extensions.
+	EUCKR Encoding = "EUC-KR"
+
 	// ASCII represents 7-bit ASCII encoding.
 	ASCII Encoding = "ASCII"
 
@@ -35,7 +59,8 @@ func (e Encoding) String() string {
 // IsValid returns true if the encoding is a known, supported encoding.
 func (e Encoding) IsValid() bool {
 	switch e {
-	case UTF8, ShiftJIS, EUCJP, ISO2022JP, CP932, ASCII:
+	case UTF8, ShiftJIS, EUCJP, ISO2022JP, CP932, ASCII,
+		UTF16LE, UTF16BE, UTF32LE, UTF32BE, Windows1252, GB18030, Big5, EUCKR:
 		return true
 	default:
 		return false
@@ -59,6 +84,9 @@ type Result struct {
 	// Language is the detected language (e.g., "ja" for Japanese, "en" for English).
 	// This may be empty if language detection is not applicable.
 	Language string
+
+	// BOM reports whether the data starts with a byte order mark.
+	BOM bool
 }
 
 // BOM (Byte Order Mark) signatures for various encodings.
@@ -66,6 +94,8 @@ var (
 	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
 	bomUTF16LE = []byte{0xFF, 0xFE}
 	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF32LE = []byte{0xFF, 0xFE, 0x00, 0x00}
+	bomUTF32BE = []byte{0x00, 0x00, 0xFE, 0xFF}
 )
 
 // ISO-2022-JP escape sequences.
@@ -109,4 +139,37 @@ const (
 
 	// ASCII range
 	asciiHigh = 0x7F
+
+	// GB18030 byte ranges: two-byte GBK sequences and four-byte sequences
+	// with a digit as second and fourth byte
+	gbLeadLow    = 0x81
+	gbLeadHigh   = 0xFE
+	gbTrail1Low  = 0x40
+	gbTrail1High = 0x7E
+	gbTrail2Low  = 0x80
+	gbTrail2High = 0xFE
+	gbDigitLow   = 0x30
+	gbDigitHigh  = 0x39
+
+	// Big5 byte ranges
+	big5LeadLow    = 0xA1
+	big5LeadHigh   = 0xF9
+	big5Trail1Low  = 0x40
+	big5Trail1High = 0x7E
+	big5Trail2Low  = 0xA1
+	big5Trail2High = 0xFE
+
+	// EUC-KR (KS X 1001) byte ranges
+	euckrLow  = 0xA1
+	euckrHigh = 0xFE
+
+	// CP949 (Unified Hangul Code) extends EUC-KR with these lead and trail bytes
+	uhcLeadLow    = 0x81
+	uhcLeadHigh   = 0xFE
+	uhcTrail1Low  = 0x41
+	uhcTrail1High = 0x5A
+	uhcTrail2Low  = 0x61
+	uhcTrail2High = 0x7A
+	uhcTrail3Low  = 0x81
+	uhcTrail3High = 0xFE
 )
@@ -0,0 +1,133 @@
+package chardetect
+
+import (
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// The CJK encodings share most of their byte ranges, so a structural score
+// alone cannot tell them apart. The sample is decoded with each candidate and
+// the text is checked against the language the encoding is used for.
+
+// commonSimplified holds frequent characters of simplified Chinese.
+const commonSimplified = "的一是不了在人有我他这个们中来上大为和国地到以说时要就出会可也你对生能而子那得于着下自之年过发后作里用道行所然家种事成方多经么去法学如都同现当没动面起看定天分还进好小部其些主样理心她本前开但因只从想实日军者意无力它与长把机十民第公此已工使情明性知全三又关点正业外将两高间由问很最重并物手应战向头文体政美相见被利什二等产或新己制身果加西斯月话合回特代内信表化老给世位次度门任常先海通教儿原东声提立及比员解水名真论处走义各入几口认条平系气题活尔更别打女变四神总何电数安少报才结反受目太量再感建务做接必场件计管期市直德资命山金指克许统区保至队形社便空决治展马科司五基眼书非则听白却界达光放强即像难且权思王象完设式色路记南品住告类求据程北边死张该交规万取拉格望觉术领共确传师观清今切院让识候带导争运笑飞风步改收根干造言联持组每济车亲极林服快办议往元英士证近失转夫令准布始怎呢存未远叫台单影具罗字爱击流备兵连调深商算质团集百需价花党华城石级整府离况亚请技际约示复病息究线似官火断精满支视消越器容照须九增研写称企八功吗包片史委乎查轻易早曾除农找装广显吧"
+
+// commonTraditional holds frequent characters of traditional Chinese.
+const commonTraditional = "的一是不了在人有我他這個們中來上大為和國地到以說時要就出會可也你對生能而子那得於著下自之年過發後作裡用道行所然家種事成方多經麼去法學如都同現當沒動面起看定天分還進好小部其些主樣理心她本前開但因只從想實日軍者意無力它與長把機十民第公此已工使情明性知全三又關點正業外將兩高間由問很最重並物手應戰向頭文體政美相見被利什二等產或新己制身果加西斯月話合回特代內信表化老給世位次度門任常先海通教兒原東聲提立及比員解水名真論處走義各入幾口認條平系氣題活爾更別打女變四神總何電數安少報才結反受目太量再感建務做接必場件計管期市直德資命山金指克許統區保至隊形社便空決治展馬科司五基眼書非則聽白卻界達光放強即像難且權思王象完設式色路記南品住告類求據程北邊死張該交規萬取拉格望覺術領共確傳師觀清今切院讓識候帶導爭運笑飛風步改收根乾造言聯持組每濟車親極林服快辦議往元英士證近失轉夫令準布始怎呢存未遠叫台單影具羅字愛擊流備兵連調深商算質團集百需價花黨華城石級整府離況亞請技際約示復病息究線似官火斷精滿支視消越器容照須九增研寫稱企八功嗎包片史委乎查輕易早曾除農找裝廣顯吧"
+
+// commonHangul holds frequent Hangul syllables of Korean.
+const commonHangul = "이의다는에가을를하고한지서기로사도어리자대인수그나게아시것들요있일정해주전보만제상국부구장오스우과여비소미내면원유조무마드모위계성적연개관신방경세등없않던했니었라용동문회학화물공실업생중간분되더반발야저합통까교치터말선차당금행점외료목결후각호재음러입감편실심와단명진체강산식은함통현애거속알작번친할습람배많때또날안께며래운집왜군의영석판봄님불울역말바월년민법록초속표확복력향최품평"
+
+// kanaRatio returns the ratio of hiragana and katakana among the letters of s.
+// Half-width katakana is not counted: Shift-JIS maps single high bytes to it,
+// so any Western text decodes to some.
+func kanaRatio(s string) float64 {
+	letters, kana := 0, 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) || r < 0x80 {
+			continue
+		}
+		letters++
+		if unicode.In(r, unicode.Hiragana, unicode.Katakana) && !isHalfWidthKatakana(r) {
+			kana++
+		}
+	}
+	if letters == 0 {
+		return 0.0
+	}
+	return float64(kana) / float64(letters)
+}
+
+// commonRatio returns the ratio of the characters of common among the
+// letters of s outside ASCII and kana.
+func commonRatio(s string, common map[rune]bool) float64 {
+	letters, hits := 0, 0
+	for _, r := range s {
+		if !unicode.IsLetter(r) || r < 0x80 || unicode.In(r, unicode.Hiragana, unicode.Katakana) {
+			continue
+		}
+		letters++
+		if common[r] {
+			hits++
+		}
+	}
+	if letters == 0 {
+		return 0.0
+	}
+	return float64(hits) / float64(letters)
+}
+
+func isHalfWidthKatakana(r rune) bool {
+	return r >= 0xFF61 && r <= 0xFF9F
+}
+
+var (
+	commonSimplifiedSet  = runeSet(commonSimplified)
+	commonTraditionalSet = runeSet(commonTraditional)
+	commonHangulSet      = runeSet(commonHangul)
+)
+
+func runeSet(s string) map[rune]bool {
+	set := make(map[rune]bool)
+	for _, r := range s {
+		set[r] = true
+	}
+	return set
+}
+
+// decodeSample decodes the sample with enc. Invalid sequences become U+FFFD,
+// which counts as neither kana nor a common character.
+func (s *scorer) decodeSample(enc encoding.Encoding) string {
+	decoded, _, _ := transform.Bytes(enc.NewDecoder(), s.data)
+	return string(decoded)
+}
+
+// plausibility returns how much the sample decoded as candidate reads like
+// the language it is used for, from 0.0 to 1.0.
+func (s *scorer) plausibility(candidate Encoding) float64 {
+	switch candidate {
+	case ShiftJIS:
+		return japanesePlausibility(s.decodeSample(japanese.ShiftJIS))
+	case EUCJP:
+		return japanesePlausibility(s.decodeSample(japanese.EUCJP))
+	case GB18030:
+		return chinesePlausibility(s.decodeSample(simplifiedchinese.GB18030), commonSimplifiedSet)
+	case Big5:
+		return chinesePlausibility(s.decodeSample(traditionalchinese.Big5), commonTraditionalSet)
+	case EUCKR:
+		return koreanPlausibility(s.decodeSample(korean.EUCKR))
+	}
+	return 1.0
+}
+
+// japanesePlausibility trusts text with kana. Kanji-only text is possible
+// but rare, so it keeps half of its score.
+func japanesePlausibility(text string) float64 {
+	if kanaRatio(text) >= 0.05 {
+		return 1.0
+	}
+	return 0.5
+}
+
+// chinesePlausibility scores text by its share of common characters. Kana
+// means the bytes are Japanese, which GB2312 can represent as well.
+func chinesePlausibility(text string, common map[rune]bool) float64 {
+	if kanaRatio(text) >= 0.05 {
+		return 0.3
+	}
+	return min(1.0, commonRatio(text, common)/0.3)
+}
+
+// koreanPlausibility scores text by its share of common Hangul syllables.
+func koreanPlausibility(text string) float64 {
+	if kanaRatio(text) >= 0.05 {
+		return 0.3
+	}
+	return min(1.0, commonRatio(text, commonHangulSet)/0.3)
+}
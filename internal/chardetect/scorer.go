@@ -33,9 +33,20 @@ func (s *scorer) detectBOM() (Encoding, bool) {
 		return UTF8, true
 	}
 
-	// UTF-16 BOMs (not Japanese, but good to detect)
-	if bytes.HasPrefix(s.data, bomUTF16LE) || bytes.HasPrefix(s.data, bomUTF16BE) {
-		return UTF8, true // Treat as UTF-8 for simplicity
+	// UTF-32 BOMs first: the UTF-32LE BOM starts with the UTF-16LE one
+	if bytes.HasPrefix(s.data, bomUTF32LE) {
+		return UTF32LE, true
+	}
+	if bytes.HasPrefix(s.data, bomUTF32BE) {
+		return UTF32BE, true
+	}
+
+	// UTF-16 BOMs
+	if bytes.HasPrefix(s.data, bomUTF16LE) {
+		return UTF16LE, true
+	}
+	if bytes.HasPrefix(s.data, bomUTF16BE) {
+		return UTF16BE, true
 	}
 
 	return Unknown, false
@@ -320,6 +331,228 @@ func (s *scorer) scoreISO2022JP() float64 {
 	return 0.0
 }
 
+// detectWideUnicode recognizes UTF-16 and UTF-32 without a BOM by the zero
+// bytes that characters below U+0100 leave in every code unit.
+func (s *scorer) detectWideUnicode() (Encoding, float64) {
+	data := s.data
+	if len(data) < 4 || bytes.IndexByte(data, 0x00) < 0 {
+		return Unknown, 0.0
+	}
+
+	// UTF-32: one non-zero byte and three zero bytes per ASCII-range character
+	units, le32, be32 := 0, 0, 0
+	for i := 0; i+3 < len(data); i += 4 {
+		units++
+		if data[i] != 0 && data[i+1] == 0 && data[i+2] == 0 && data[i+3] == 0 {
+			le32++
+		}
+		if data[i] == 0 && data[i+1] == 0 && data[i+2] == 0 && data[i+3] != 0 {
+			be32++
+		}
+	}
+	if units > 0 && float64(le32)/float64(units) >= 0.9 {
+		return UTF32LE, 0.9
+	}
+	if units > 0 && float64(be32)/float64(units) >= 0.9 {
+		return UTF32BE, 0.9
+	}
+
+	// UTF-16: a zero high byte for ASCII-range characters, and never a zero code unit
+	units, le16, be16 := 0, 0, 0
+	for i := 0; i+1 < len(data); i += 2 {
+		units++
+		switch {
+		case data[i] == 0 && data[i+1] == 0:
+			return Unknown, 0.0
+		case data[i] != 0 && data[i+1] == 0:
+			le16++
+		case data[i] == 0 && data[i+1] != 0:
+			be16++
+		}
+	}
+	if le16 > 0 && be16 == 0 && float64(le16)/float64(units) >= 0.6 && validUTF16(data, false) {
+		return UTF16LE, 0.7 + 0.25*float64(le16)/float64(units)
+	}
+	if be16 > 0 && le16 == 0 && float64(be16)/float64(units) >= 0.6 && validUTF16(data, true) {
+		return UTF16BE, 0.7 + 0.25*float64(be16)/float64(units)
+	}
+	return Unknown, 0.0
+}
+
+// validUTF16 reports whether every surrogate in data is paired. A code unit
+// cut off at the end of the sample is ignored.
+func validUTF16(data []byte, bigEndian bool) bool {
+	unit := func(i int) uint16 {
+		if bigEndian {
+			return uint16(data[i])<<8 | uint16(data[i+1])
+		}
+		return uint16(data[i+1])<<8 | uint16(data[i])
+	}
+	for i := 0; i+1 < len(data); i += 2 {
+		u := unit(i)
+		switch {
+		case u >= 0xD800 && u <= 0xDBFF:
+			if i+3 >= len(data) {
+				return true
+			}
+			if next := unit(i + 2); next < 0xDC00 || next > 0xDFFF {
+				return false
+			}
+			i += 2
+		case u >= 0xDC00 && u <= 0xDFFF:
+			return false
+		}
+	}
+	return true
+}
+
+// scoreWindows1252 scores the data as Windows-1252 / ISO-8859-1. Western
+// text has its accented letters alone between ASCII letters, while the
+// multi-byte encodings put high bytes in runs.
+func (s *scorer) scoreWindows1252() float64 {
+	if len(s.data) == 0 || utf8.Valid(s.data) {
+		return 0.0
+	}
+
+	highBytes := 0
+	isolated := 0
+	for i, b := range s.data {
+		switch {
+		case b == 0x81 || b == 0x8D || b == 0x8F || b == 0x90 || b == 0x9D:
+			// unassigned in Windows-1252
+			return 0.0
+		case b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f', b == 0x7F:
+			// control characters do not appear in legacy text files
+			return 0.0
+		case b > asciiHigh:
+			highBytes++
+			if (i == 0 || s.data[i-1] <= asciiHigh) && (i == len(s.data)-1 || s.data[i+1] <= asciiHigh) {
+				isolated++
+			}
+		}
+	}
+	if highBytes == 0 {
+		return 0.0
+	}
+
+	ratio := float64(isolated) / float64(highBytes)
+	if float64(highBytes)/float64(len(s.data)) > 0.3 {
+		// too dense for Western text
+		ratio /= 2
+	}
+
+	if ratio > 0.9 {
+		return 0.90
+	} else if ratio > 0.7 {
+		return 0.80
+	} else if ratio > 0.5 {
+		return 0.60
+	}
+
+	return ratio * 0.5
+}
+
+// scoreGB18030 scores the data as GB18030 (and so GBK and GB2312).
+func (s *scorer) scoreGB18030() float64 {
+	return s.scoreMultiByte(func(data []byte, i int) int {
+		b := data[i]
+		if b < gbLeadLow || b > gbLeadHigh || i+1 >= len(data) {
+			return 0
+		}
+		trail := data[i+1]
+		if (trail >= gbTrail1Low && trail <= gbTrail1High) || (trail >= gbTrail2Low && trail <= gbTrail2High) {
+			return 2
+		}
+		if trail >= gbDigitLow && trail <= gbDigitHigh && i+3 < len(data) &&
+			data[i+2] >= gbLeadLow && data[i+2] <= gbLeadHigh &&
+			data[i+3] >= gbDigitLow && data[i+3] <= gbDigitHigh {
+			return 4
+		}
+		return 0
+	})
+}
+
+// scoreBig5 scores the data as Big5.
+func (s *scorer) scoreBig5() float64 {
+	return s.scoreMultiByte(func(data []byte, i int) int {
+		b := data[i]
+		if b < big5LeadLow || b > big5LeadHigh || i+1 >= len(data) {
+			return 0
+		}
+		trail := data[i+1]
+		if (trail >= big5Trail1Low && trail <= big5Trail1High) || (trail >= big5Trail2Low && trail <= big5Trail2High) {
+			return 2
+		}
+		return 0
+	})
+}
+
+// scoreEUCKR scores the data as EUC-KR, including the CP949 extensions.
+func (s *scorer) scoreEUCKR() float64 {
+	return s.scoreMultiByte(func(data []byte, i int) int {
+		b := data[i]
+		if i+1 >= len(data) {
+			return 0
+		}
+		trail := data[i+1]
+		if b >= euckrLow && b <= euckrHigh && trail >= euckrLow && trail <= euckrHigh {
+			return 2
+		}
+		if b >= uhcLeadLow && b <= uhcLeadHigh &&
+			((trail >= uhcTrail1Low && trail <= uhcTrail1High) ||
+				(trail >= uhcTrail2Low && trail <= uhcTrail2High) ||
+				(trail >= uhcTrail3Low && trail <= uhcTrail3High)) {
+			return 2
+		}
+		return 0
+	})
+}
+
+// scoreMultiByte scores the data for a multi-byte encoding. sequence returns
+// the length of the valid sequence starting at a non-ASCII byte, or 0.
+func (s *scorer) scoreMultiByte(sequence func(data []byte, i int) int) float64 {
+	validSequences := 0
+	invalidSequences := 0
+	for i := 0; i < len(s.data); {
+		if s.data[i] <= asciiHigh {
+			i++
+			continue
+		}
+		if n := sequence(s.data, i); n > 0 {
+			validSequences++
+			i += n
+		} else {
+			invalidSequences++
+			i++
+		}
+	}
+	return sequenceScore(validSequences, invalidSequences)
+}
+
+// sequenceScore turns counts of valid and invalid multi-byte sequences into a score.
+func sequenceScore(validSequences, invalidSequences int) float64 {
+	if validSequences == 0 {
+		return 0.0
+	}
+
+	ratio := float64(validSequences) / float64(validSequences+invalidSequences)
+
+	// High ratio = high confidence
+	if ratio > 0.95 && validSequences > 10 {
+		return 0.95
+	} else if ratio > 0.90 && validSequences > 5 {
+		return 0.90
+	} else if ratio > 0.80 {
+		return 0.80
+	} else if ratio > 0.70 {
+		return 0.70
+	} else if ratio > 0.50 {
+		return 0.50
+	}
+
+	return ratio * 0.5
+}
+
 // isASCII checks if data contains only ASCII characters.
 func (s *scorer) isASCII() bool {
 	for _, b := range s.data {
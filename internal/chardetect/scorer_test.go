@@ -17,6 +17,30 @@ func TestScorer_detectBOM(t *testing.T) {
 			wantEncoding: UTF8,
 			wantFound:    true,
 		},
+		{
+			name:         "UTF-16LE BOM",
+			data:         append(bomUTF16LE, 't', 0x00),
+			wantEncoding: UTF16LE,
+			wantFound:    true,
+		},
+		{
+			name:         "UTF-16BE BOM",
+			data:         append(bomUTF16BE, 0x00, 't'),
+			wantEncoding: UTF16BE,
+			wantFound:    true,
+		},
+		{
+			name:         "UTF-32LE BOM",
+			data:         append(bomUTF32LE, 't', 0x00, 0x00, 0x00),
+			wantEncoding: UTF32LE,
+			wantFound:    true,
+		},
+		{
+			name:         "UTF-32BE BOM",
+			data:         append(bomUTF32BE, 0x00, 0x00, 0x00, 't'),
+			wantEncoding: UTF32BE,
+			wantFound:    true,
+		},
 		{
 			name:         "No BOM",
 			data:         []byte("test"),
@@ -201,3 +225,27 @@ func TestNewScorer_SampleSize(t *testing.T) {
 		})
 	}
 }
+
+func TestScorer_scoreWindows1252(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		wantZero bool
+	}{
+		{"accented letters", []byte("caf\xe9 na\xefve r\xe9sum\xe9 \xfcber"), false},
+		{"valid UTF-8", []byte("café"), true},
+		{"undefined byte", []byte("caf\xe9 \x81"), true},
+		{"control bytes", []byte("caf\xe9\x01\x02"), true},
+		{"ASCII only", []byte("cafe"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScorer(tt.data, 0)
+			score := s.scoreWindows1252()
+			if (score == 0) != tt.wantZero {
+				t.Errorf("scoreWindows1252() = %v, want zero = %v", score, tt.wantZero)
+			}
+		})
+	}
+}
@@ -6,7 +6,14 @@ import (
 	"strings"
 	"testing"
 
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
 	"golang.org/x/text/transform"
 )
 
@@ -39,6 +46,18 @@ Numbers: 123, 456, 789
 	// ASCII only
 	asciiOnly = "This is a pure ASCII text file.\nIt contains no Japanese characters.\nOnly English letters, numbers, and symbols."
 
+	// Western European text with accented letters
+	latinText = "Le cœur a ses raisons que la raison ne connaît point.\nÄrger über die Größe der Straße.\nEl niño está aquí, señor.\nÇa coûte 20 €."
+
+	// Simplified Chinese
+	simplifiedChinese = "我们的国家有很多人。这是一个关于中国文化的文件，大家都可以看到。他说今天的天气很好，我们一起去学校吧。"
+
+	// Traditional Chinese
+	traditionalChinese = "我們的國家有很多人。這是一個關於中國文化的文件，大家都可以看到。他說今天的天氣很好，我們一起去學校吧。"
+
+	// Korean
+	koreanText = "대한민국은 민주공화국이다. 이것은 한국어 문서입니다. 오늘은 날씨가 좋아서 학교에 갔습니다. 우리는 함께 공부를 합니다."
+
 	// Long text (repeated for large file)
 	longTextUnit = "日本語のテキストが続きます。This is followed by English text. 繰り返しパターン。Repeating pattern. "
 )
@@ -56,7 +75,7 @@ func generateTestData(t *testing.T) {
 	tests := []struct {
 		filename string
 		content  string
-		encoding string // "utf8", "shift-jis", "euc-jp", "iso-2022-jp"
+		encoding string // "utf8", "shift-jis", "euc-jp", "iso-2022-jp", "utf-16le", ...
 		withBOM  bool
 	}{
 		// UTF-8
@@ -79,6 +98,22 @@ func generateTestData(t *testing.T) {
 		// ISO-2022-JP
 		{"iso-2022-jp.txt", literaryJapanese, "iso-2022-jp", false},
 
+		// UTF-16 / UTF-32
+		{"utf16le_bom.txt", mixedContent, "utf-16le", true},
+		{"utf16be_bom.txt", mixedContent, "utf-16be", true},
+		{"utf16le.txt", asciiOnly, "utf-16le", false},
+		{"utf16be.txt", asciiOnly, "utf-16be", false},
+		{"utf32le_bom.txt", mixedContent, "utf-32le", true},
+		{"utf32be.txt", asciiOnly, "utf-32be", false},
+
+		// Windows-1252 / ISO-8859-1
+		{"windows-1252.txt", latinText, "windows-1252", false},
+
+		// Chinese and Korean
+		{"gb18030.txt", simplifiedChinese, "gb18030", false},
+		{"big5.txt", traditionalChinese, "big5", false},
+		{"euc-kr.txt", koreanText, "euc-kr", false},
+
 		// ASCII
 		{"ascii.txt", asciiOnly, "utf8", false},
 
@@ -128,6 +163,13 @@ func writeEncodedFile(path, content, encoding string, withBOM bool) error {
 			return err
 		}
 
+	case "utf-16le", "utf-16be", "utf-32le", "utf-32be",
+		"windows-1252", "gb18030", "big5", "euc-kr":
+		encoded, _, err = transform.Bytes(goldenEncoding(encoding, withBOM).NewEncoder(), []byte(content))
+		if err != nil {
+			return err
+		}
+
 	default:
 		encoded = []byte(content)
 	}
@@ -135,6 +177,36 @@ func writeEncodedFile(path, content, encoding string, withBOM bool) error {
 	return os.WriteFile(path, encoded, 0644)
 }
 
+// goldenEncoding returns the x/text encoding that writes the named golden files.
+func goldenEncoding(name string, withBOM bool) encoding.Encoding {
+	bom := unicode.IgnoreBOM
+	bom32 := utf32.IgnoreBOM
+	if withBOM {
+		bom = unicode.UseBOM
+		bom32 = utf32.UseBOM
+	}
+
+	switch name {
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, bom)
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, bom)
+	case "utf-32le":
+		return utf32.UTF32(utf32.LittleEndian, bom32)
+	case "utf-32be":
+		return utf32.UTF32(utf32.BigEndian, bom32)
+	case "windows-1252":
+		return charmap.Windows1252
+	case "gb18030":
+		return simplifiedchinese.GB18030
+	case "big5":
+		return traditionalchinese.Big5
+	case "euc-kr":
+		return korean.EUCKR
+	}
+	return encoding.Nop
+}
+
 // generateLongText creates a long text for testing large files.
 func generateLongText() string {
 	// Generate ~10KB of text
@@ -3,6 +3,11 @@ package commandline
 import (
 	"flag"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/secrets"
 
 	_ "embed"
 )
@@ -16,3 +21,60 @@ func OverRideHelp(fs *flag.FlagSet) *flag.FlagSet {
 	}
 	return fs
 }
+
+// parseInterspersed parses args with fs and returns the positional
+// arguments. Options may also follow them, as in "ark . -o -", but everything
+// after a "--" is positional.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := args[:len(args)-len(rest)]; len(consumed) > 0 && consumed[len(consumed)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// LoadSecretRules reads the secret rules file at path or, when path is empty,
+// the .arksecrets.toml of targetDir if there is one. Without a rules file the
+// built-in rules apply. A file found in targetDir belongs to the tree being
+// masked, so it may only add rules: its disable and allow keys are ignored
+// with a notice, and only honoured when passed as path.
+func LoadSecretRules(path string, targetDir string) (*secrets.RuleSet, error) {
+	if path != "" {
+		return secrets.LoadRuleSet(path)
+	}
+	candidate := filepath.Join(targetDir, secrets.RulesFilename)
+	if info, err := os.Stat(candidate); err != nil || info.IsDir() {
+		return secrets.DefaultRuleSet(), nil
+	}
+	rules, ignored, err := secrets.LoadAdditiveRuleSet(candidate)
+	if err != nil {
+		return nil, err
+	}
+	if len(ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "ark: ignoring %s in %s; rules that mask less are only read from --secret-rules\n", strings.Join(ignored, ", "), candidate)
+	}
+	return rules, nil
+}
+
+// OpenSecretVault opens the vault of reversible masking at path, or at the
+// default location in the user config directory when path is empty.
+func OpenSecretVault(path string) (*secrets.Vault, error) {
+	if path == "" {
+		p, err := secrets.DefaultVaultPath()
+		if err != nil {
+			return nil, err
+		}
+		path = p
+	}
+	return secrets.OpenVault(path)
+}
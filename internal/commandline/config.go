@@ -0,0 +1,179 @@
+package commandline
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/config"
+)
+
+// ConfigFlagNames are the options that select a config and cannot be set
+// from one.
+var ConfigFlagNames = []string{"config", "profile", "help", "version"}
+
+// ProjectOptionNames are the options a project config, found in the directory
+// being dumped or served, may set. The others can write or read outside the
+// tree, or show what masking and .gitignore hide, so only the user config,
+// --config and the command line set them.
+var ProjectOptionNames = []string{
+	"with-line-number", "output-format", "ignore-dotfile", "pattern-regex",
+	"include-ext", "exclude-file-regex", "exclude-dir-regex", "exclude-ext",
+	"exclude-dir", "compless", "skip-non-utf8", "silent", "delete-comment",
+	"keep-doc-comments", "compact-go", "max-file-size", "max-lines-per-file",
+	"skip-generated", "binary-metadata", "embed-images", "max-tokens",
+	"tokenizer", "budget-policy", "order", "order-globs", "token-report",
+	"split-size", "split-tokens", "arklite-lossless", "since", "staged",
+	"diff-content", "jobs", "scan-buffer", "http-port",
+}
+
+// loadConfig discovers the config files for targetDir (or reads configPath),
+// and sets every option they define that was not given on the command line.
+// The root table applies first, then [mcp-server] for the server, then the
+// selected profile; at each step the project config wins over the user
+// config. Options a project config may not set are ignored with a notice.
+// It returns the config files that were read.
+func loadConfig(fs *flag.FlagSet, configPath string, profile string, targetDir string, server bool) ([]string, error) {
+	userFiles, projectFiles, err := config.Discover(configPath, targetDir)
+	if err != nil {
+		return nil, err
+	}
+	userCfg, err := config.Load(userFiles...)
+	if err != nil {
+		return nil, err
+	}
+	projectCfg, err := config.Load(projectFiles...)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	var ignored []string
+	merge := func(table string, src func(*config.Config) map[string]string, strict bool) error {
+		for _, cfg := range []*config.Config{userCfg, projectCfg} {
+			dropped, err := mergeConfigValues(fs, values, src(cfg), strict, cfg == projectCfg)
+			if err != nil {
+				if table != "" {
+					return fmt.Errorf("[%s] %w", table, err)
+				}
+				return err
+			}
+			ignored = append(ignored, dropped...)
+		}
+		return nil
+	}
+
+	// mcp-server shares most options with ark, so it skips the ones it does
+	// not have rather than rejecting them; its own table is strict.
+	if err := merge("", func(c *config.Config) map[string]string { return c.Values("") }, !server); err != nil {
+		return nil, err
+	}
+	if server {
+		if err := merge(config.ServerTable, func(c *config.Config) map[string]string { return c.Values(config.ServerTable) }, true); err != nil {
+			return nil, err
+		}
+	}
+	if profile != "" {
+		table := config.ProfilePrefix + profile
+		if userCfg.Values(table) == nil && projectCfg.Values(table) == nil {
+			// list the profiles of both configs in the error
+			all, err := config.Load(append(userFiles, projectFiles...)...)
+			if err != nil {
+				return nil, err
+			}
+			_, err = all.Profile(profile)
+			return nil, err
+		}
+		if err := merge(table, func(c *config.Config) map[string]string { return c.Values(table) }, !server); err != nil {
+			return nil, err
+		}
+	}
+	if len(ignored) > 0 {
+		slices.Sort(ignored)
+		fmt.Fprintf(os.Stderr, "ark: ignoring %s in %s; a project config cannot set them, use the user config or the command line\n", strings.Join(slices.Compact(ignored), ", "), projectFiles[0])
+	}
+
+	// options given on the command line always win; long and short names
+	// share the same variable, so compare by what they point to
+	given := map[uintptr]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		given[flagTarget(f)] = true
+	})
+	for _, name := range slices.Sorted(maps.Keys(values)) {
+		f := fs.Lookup(name)
+		if given[flagTarget(f)] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return nil, fmt.Errorf("config %s: %w", name, err)
+		}
+	}
+	return append(userCfg.Files, projectCfg.Files...), nil
+}
+
+// mergeConfigValues copies src into dst. Values of a project config that it
+// may not set are left out and returned.
+func mergeConfigValues(fs *flag.FlagSet, dst map[string]string, src map[string]string, strict bool, project bool) (ignored []string, err error) {
+	for name, value := range src {
+		if slices.Contains(ConfigFlagNames, name) {
+			return nil, fmt.Errorf("%s cannot be set in a config file", name)
+		}
+		if len(name) == 1 {
+			return nil, fmt.Errorf("use the long option name instead of %q", name)
+		}
+		if fs.Lookup(name) == nil {
+			if strict {
+				return nil, fmt.Errorf("unknown option %q", name)
+			}
+			continue
+		}
+		if project && !slices.Contains(ProjectOptionNames, name) {
+			ignored = append(ignored, name)
+			continue
+		}
+		dst[name] = value
+	}
+	return ignored, nil
+}
+
+func flagTarget(f *flag.Flag) uintptr {
+	return reflect.ValueOf(f.Value).Pointer()
+}
+
+// WriteConfig prints the effective options of fs, after config files and the
+// command line are merged, as a config file.
+func WriteConfig(w io.Writer, fs *flag.FlagSet, files []string, profile string) {
+	if len(files) == 0 {
+		fmt.Fprintln(w, "# config: (none)")
+	}
+	for _, file := range files {
+		fmt.Fprintf(w, "# config: %s\n", file)
+	}
+	if profile != "" {
+		fmt.Fprintf(w, "# profile: %s\n", profile)
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		if len(f.Name) == 1 || slices.Contains(ConfigFlagNames, f.Name) {
+			return
+		}
+		fmt.Fprintf(w, "%s = %s\n", f.Name, tomlValue(f))
+	})
+}
+
+func tomlValue(f *flag.Flag) string {
+	if getter, ok := f.Value.(flag.Getter); ok {
+		switch v := getter.Get().(type) {
+		case bool:
+			return strconv.FormatBool(v)
+		case int:
+			return strconv.Itoa(v)
+		}
+	}
+	return strconv.Quote(f.Value.String())
+}
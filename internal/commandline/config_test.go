@@ -0,0 +1,243 @@
+package commandline_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+)
+
+const testConfig = `
+output-format = "md"
+exclude-ext = ["png", "jpg"]
+max-tokens = 100000
+silent = true
+
+[mcp-server]
+http-port = 9000
+
+[profile.review]
+since = "main"
+diff-content = "both"
+
+[profile.llm-small]
+output-format = "arklite"
+max-tokens = 32000
+`
+
+func writeTestConfig(t *testing.T, content string) string {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ark.toml"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestOptParse_Config(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+
+	_, opt, err := commandline.GeneralOptParse([]string{dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputFormat.String() != "markdown" {
+		t.Errorf("Expected OutputFormat = markdown, got %s", opt.OutputFormat.String())
+	}
+	if opt.ExcludeExt != "png,jpg" {
+		t.Errorf("Expected ExcludeExt = png,jpg, got %s", opt.ExcludeExt)
+	}
+	if opt.MaxTokens != 100000 || !opt.SilentFlag {
+		t.Errorf("Expected MaxTokens = 100000 and SilentFlag, got %d %t", opt.MaxTokens, opt.SilentFlag)
+	}
+	if len(opt.ConfigFiles) != 1 || opt.ConfigFiles[0] != filepath.Join(dir, "ark.toml") {
+		t.Errorf("Expected the project config to be read, got %v", opt.ConfigFiles)
+	}
+}
+
+func TestOptParse_ConfigFlagsOverride(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+
+	// both the long and the short name of an option override the file
+	for _, args := range [][]string{
+		{"--output-format", "xml", "--max-tokens", "500", dir},
+		{"-f", "xml", "--max-tokens", "500", dir},
+	} {
+		_, opt, err := commandline.GeneralOptParse(args)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if opt.OutputFormat.String() != "xml" {
+			t.Errorf("%v: Expected OutputFormat = xml, got %s", args, opt.OutputFormat.String())
+		}
+		if opt.MaxTokens != 500 {
+			t.Errorf("%v: Expected MaxTokens = 500, got %d", args, opt.MaxTokens)
+		}
+		if opt.ExcludeExt != "png,jpg" {
+			t.Errorf("%v: Expected ExcludeExt from the file, got %s", args, opt.ExcludeExt)
+		}
+	}
+}
+
+func TestOptParse_ConfigProfile(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+
+	_, opt, err := commandline.GeneralOptParse([]string{"--profile", "llm-small", dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputFormat.String() != "arklite" || opt.MaxTokens != 32000 {
+		t.Errorf("Expected the profile values, got %s %d", opt.OutputFormat.String(), opt.MaxTokens)
+	}
+	if opt.ExcludeExt != "png,jpg" {
+		t.Errorf("Expected root values under a profile, got %s", opt.ExcludeExt)
+	}
+
+	_, opt, err = commandline.GeneralOptParse([]string{"--profile", "review", "--diff-content", "diff", dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.SinceRef != "main" || opt.DiffContent.String() != "diff" {
+		t.Errorf("Expected since from the profile and diff-content from the flag, got %s %s", opt.SinceRef, opt.DiffContent.String())
+	}
+
+	if _, _, err := commandline.GeneralOptParse([]string{"--profile", "missing", dir}); err == nil {
+		t.Errorf("Expected error for an unknown profile")
+	}
+}
+
+func TestOptParse_ConfigExplicit(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+	explicit := filepath.Join(t.TempDir(), "custom.toml")
+	os.WriteFile(explicit, []byte("output-format = \"json\"\n"), 0644)
+
+	_, opt, err := commandline.GeneralOptParse([]string{"--config", explicit, dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputFormat.String() != "json" {
+		t.Errorf("Expected OutputFormat = json, got %s", opt.OutputFormat.String())
+	}
+	if opt.MaxTokens != 0 {
+		t.Errorf("--config must replace the project config, got MaxTokens %d", opt.MaxTokens)
+	}
+}
+
+func TestOptParse_ConfigProjectAllowList(t *testing.T) {
+	userDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	os.MkdirAll(filepath.Join(userDir, "ark"), 0755)
+	os.WriteFile(filepath.Join(userDir, "ark", "ark.toml"), []byte("output-filename = \"mine.txt\"\n"), 0644)
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ark.toml"), []byte(`
+output-format = "md"
+output-filename = "/tmp/elsewhere.txt"
+symlinks = "follow"
+additionally-ignorerule = "/etc/passwd"
+
+[profile.review]
+tokenizer-file = "/tmp/ranks"
+`), 0644)
+
+	_, opt, err := commandline.GeneralOptParse([]string{"--profile", "review", dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputFormat.String() != "markdown" {
+		t.Errorf("Expected the project output-format, got %s", opt.OutputFormat.String())
+	}
+	if opt.OutputFilename != "mine.txt" {
+		t.Errorf("a project config must not choose the output file, got %s", opt.OutputFilename)
+	}
+	if opt.Symlinks.String() != "list" || opt.AdditionallyIgnoreRuleFilenames != "" || opt.TokenizerFile != "" {
+		t.Errorf("a project config must not set symlinks, additionally-ignorerule or tokenizer-file, got %s %q %q",
+			opt.Symlinks.String(), opt.AdditionallyIgnoreRuleFilenames, opt.TokenizerFile)
+	}
+
+	// the same file passed with --config is the user's choice
+	_, opt, err = commandline.GeneralOptParse([]string{"--config", filepath.Join(dir, "ark.toml"), dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputFilename != "/tmp/elsewhere.txt" {
+		t.Errorf("--config must set any option, got %s", opt.OutputFilename)
+	}
+}
+
+func TestOptParse_ConfigErrors(t *testing.T) {
+	for _, content := range []string{
+		"output-fromat = \"md\"\n",
+		"o = \"out.txt\"\n",
+		"profile = \"review\"\n",
+		"http-port = 9000\n",
+		"max-tokens = \"many\"\n",
+	} {
+		dir := writeTestConfig(t, content)
+		if _, _, err := commandline.GeneralOptParse([]string{dir}); err == nil {
+			t.Errorf("Expected error for config %q", content)
+		}
+	}
+}
+
+func TestServerOptParse_Config(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+
+	_, opt, err := commandline.ServerOptParse("test", []string{"--root", dir, "--profile", "review"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.HttpPort != "9000" {
+		t.Errorf("Expected HttpPort = 9000, got %s", opt.HttpPort)
+	}
+	if opt.GeneralOption.ExcludeExt != "png,jpg" {
+		t.Errorf("Expected shared options from the file, got %s", opt.GeneralOption.ExcludeExt)
+	}
+
+	_, opt, err = commandline.ServerOptParse("test", []string{"--root", dir, "-p", "8000"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.HttpPort != "8000" {
+		t.Errorf("Expected HttpPort = 8000, got %s", opt.HttpPort)
+	}
+
+	dir = writeTestConfig(t, "[mcp-server]\noutput-fromat = \"md\"\n")
+	if _, _, err := commandline.ServerOptParse("test", []string{"--root", dir}); err == nil {
+		t.Errorf("Expected error for an unknown [mcp-server] key")
+	}
+}
+
+func TestWriteConfig(t *testing.T) {
+	dir := writeTestConfig(t, testConfig)
+
+	_, opt, err := commandline.GeneralOptParse([]string{"--profile", "review", "-n", "on", dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	var b strings.Builder
+	commandline.WriteConfig(&b, opt.FlagSet, opt.ConfigFiles, opt.ProfileName)
+	out := b.String()
+
+	for _, want := range []string{
+		"# config: " + filepath.Join(dir, "ark.toml") + "\n",
+		"# profile: review\n",
+		"output-format = \"md\"\n",
+		"exclude-ext = \"png,jpg\"\n",
+		"since = \"main\"\n",
+		"with-line-number = \"on\"\n",
+		"max-tokens = 100000\n",
+		"silent = true\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"\nf = ", "\nconfig = ", "\nprofile = ", "\nhelp = "} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q in:\n%s", unwanted, out)
+		}
+	}
+}
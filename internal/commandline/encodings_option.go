@@ -0,0 +1,142 @@
+package commandline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/common"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// EncodingsOption defines options for reporting the non-UTF-8 and mixed line
+// ending files under a directory
+type EncodingsOption struct {
+	FormatValue   string
+	Format        model.EncodingReportFormat
+	HelpFlag      bool
+	GeneralOption *Option
+	FlagSet       *flag.FlagSet
+}
+
+func EncodingsOptParse(args []string) (int, *EncodingsOption, error) {
+
+	optLength := len(args)
+
+	fs := flag.NewFlagSet("ark-encodings", flag.ExitOnError)
+
+	// --format
+	formatOpt := fs.String("format", "text", "Specify the report format.")
+	fs.StringVar(formatOpt, "f", "text", "Specify the report format.")
+
+	// --allow-gitignore
+	allowGitignoreFlagOpt := fs.String("allow-gitignore", "on", "Specify enable .gitignore.")
+	fs.StringVar(allowGitignoreFlagOpt, "a", "on", "Specify enable .gitignore.")
+
+	// --additionally-ignorerule
+	additionallyIgnoreRuleFilenamesOpt := fs.String("additionally-ignorerule", "", "Specify a file containing additional ignore rules.")
+	fs.StringVar(additionallyIgnoreRuleFilenamesOpt, "A", "", "Specify a file containing additional ignore rules.")
+
+	// --ignore-dotfile
+	ignoreDotfileFlagValueOpt := fs.String("ignore-dotfile", "off", "Specify ignore dot files.")
+	fs.StringVar(ignoreDotfileFlagValueOpt, "d", "off", "Specify ignore dot files.")
+
+	// --pattern-regex
+	patternRegexOpt := fs.String("pattern-regex", "", "Specify watch file pattern regexp (optional)")
+	fs.StringVar(patternRegexOpt, "x", "", "Specify watch file pattern regexp (optional)")
+
+	// --include-ext
+	includeExtOpt := fs.String("include-ext", "", "Specify watch file extension (optional)")
+	fs.StringVar(includeExtOpt, "i", "", "Specify watch file extension (optional)")
+
+	// --exclude-file-regexp
+	excludeFileRegexpOpt := fs.String("exclude-file-regex", "", "Specify watch file ignore pattern regexp (optional)")
+	fs.StringVar(excludeFileRegexpOpt, "g", "", "Specify watch file ignore pattern regexp (optional)")
+
+	// --exclude-dir-regexp
+	excludeDirRegexpOpt := fs.String("exclude-dir-regex", "", "Specify watch dir ignore pattern regexp (optional)")
+	fs.StringVar(excludeDirRegexpOpt, "G", "", "Specify watch file ignore pattern regexp (optional)")
+
+	// --exclude-ext
+	excludeExtOpt := fs.String("exclude-ext", "", "Specify watch exclude file extension (optional)")
+	fs.StringVar(excludeExtOpt, "e", "", "Specify watch exclude file extension (optional)")
+
+	// --exclude-dir
+	excludeDirOpt := fs.String("exclude-dir", "", "Specify watch exclude directory (optional)")
+	fs.StringVar(excludeDirOpt, "E", "", "Specify watch exclude directory (optional)")
+
+	// --help
+	helpFlagOpt := fs.Bool("help", false, "Show help message.")
+	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "\nHelpOption:")
+		fmt.Fprintln(os.Stderr, "    ark --help")
+	}
+	_args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return optLength, nil, err
+	}
+
+	currentDir := common.GetCurrentDir()
+	targetDirname := currentDir
+	if len(_args) > 0 {
+		targetDirname = _args[0]
+	}
+
+	generalOpt := &Option{
+		WorkingDir:                      currentDir,
+		TargetDirname:                   targetDirname,
+		ScanBufferValue:                 "10M",
+		MaskSecretsFlagValue:            "off",
+		AllowGitignoreFlagValue:         *allowGitignoreFlagOpt,
+		AdditionallyIgnoreRuleFilenames: *additionallyIgnoreRuleFilenamesOpt,
+		IgnoreDotFileFlagValue:          *ignoreDotfileFlagValueOpt,
+		PatternRegexpString:             *patternRegexOpt,
+		IncludeExt:                      *includeExtOpt,
+		ExcludeDirRegexpString:          *excludeDirRegexpOpt,
+		ExcludeFileRegexpString:         *excludeFileRegexpOpt,
+		ExcludeExt:                      *excludeExtOpt,
+		ExcludeDir:                      *excludeDirOpt,
+		WithLineNumberFlagValue:         "off",
+		OutputFormatValue:               "auto",
+		FlagSet:                         fs,
+	}
+
+	result := &EncodingsOption{
+		FormatValue:   *formatOpt,
+		HelpFlag:      *helpFlagOpt,
+		GeneralOption: generalOpt,
+		FlagSet:       fs,
+	}
+
+	OverRideHelp(fs)
+
+	if result.HelpFlag {
+		return optLength, result, nil
+	}
+
+	if err := common.JoinErrors(result.Normalize(), generalOpt.Normalize()); err != nil {
+		return optLength, nil, err
+	}
+
+	return optLength, result, nil
+}
+
+func (cr *EncodingsOption) Normalize() error {
+
+	var errorMessages = []string{}
+
+	// --format
+	if err := cr.Format.Set(cr.FormatValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--format %s", err.Error()))
+	}
+
+	if len(errorMessages) == 0 {
+		return nil
+	} else {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+}
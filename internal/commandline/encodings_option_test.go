@@ -0,0 +1,35 @@
+package commandline_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+)
+
+func TestEncodingsOptParse(t *testing.T) {
+	_, opt, err := commandline.EncodingsOptParse([]string{"-f", "json", "-E", "vendor", "./example"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.Format.String() != "json" {
+		t.Errorf("Expected Format = json, got %s", opt.Format.String())
+	}
+	if opt.GeneralOption.TargetDirname != "./example" {
+		t.Errorf("Expected TargetDirname = ./example, got %s", opt.GeneralOption.TargetDirname)
+	}
+	if len(opt.GeneralOption.ExcludeDirList) != 1 || opt.GeneralOption.ExcludeDirList[0] != "vendor" {
+		t.Errorf("Expected ExcludeDirList = [vendor], got %v", opt.GeneralOption.ExcludeDirList)
+	}
+
+	_, opt, err = commandline.EncodingsOptParse([]string{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.Format.String() != "text" || opt.GeneralOption.TargetDirname == "" {
+		t.Errorf("Expected text format for the current directory, got %s %q", opt.Format.String(), opt.GeneralOption.TargetDirname)
+	}
+
+	if _, _, err := commandline.EncodingsOptParse([]string{"-f", "sarif"}); err == nil {
+		t.Errorf("Expected error for invalid format")
+	}
+}
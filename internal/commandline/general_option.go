@@ -10,10 +10,15 @@ import (
 	"strings"
 
 	"github.com/magicdrive/ark/internal/common"
+	"github.com/magicdrive/ark/internal/gitdiff"
 	"github.com/magicdrive/ark/internal/libgitignore"
 	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/secrets"
 )
 
+// StdoutFilename is the --output-filename that streams the dump to stdout.
+const StdoutFilename = "-"
+
 type Option struct {
 	WorkingDir                         string
 	TargetDirname                      string
@@ -48,6 +53,48 @@ type Option struct {
 	ComplessFlag                       bool
 	SkipNonUTF8Flag                    bool
 	DeleteCommentsFlag                 bool
+	KeepDocCommentsFlag                bool
+	CompactGoValue                     string
+	CompactGo                          model.CompactGo
+	MaxFileSizeValue                   string
+	MaxFileSize                        model.ByteString
+	MaxLinesPerFile                    int
+	SymlinksValue                      string
+	Symlinks                           model.SymlinkPolicy
+	SymlinkRoot                        string
+	SkipGeneratedFlag                  bool
+	BinaryMetadataFlag                 bool
+	EmbedImagesValue                   string
+	EmbedImages                        model.ByteString
+	MaxTokens                          int
+	TokenizerValue                     string
+	Tokenizer                          model.TokenizerType
+	TokenizerFile                      string
+	BudgetPolicyValue                  string
+	BudgetPolicy                       model.BudgetPolicy
+	OrderValue                         string
+	Order                              model.FileOrder
+	OrderGlobs                         string
+	OrderGlobList                      []string
+	TokenReportFlag                    bool
+	SplitSizeValue                     string
+	SplitSize                          model.ByteString
+	SplitTokens                        int
+	ArkliteLosslessFlag                bool
+	SinceRef                           string
+	StagedFlag                         bool
+	DiffContentValue                   string
+	DiffContent                        model.DiffContent
+	GitChanges                         *gitdiff.ChangeSet
+	Jobs                               int
+	ConfigFilename                     string
+	ProfileName                        string
+	ConfigFiles                        []string
+	SecretRulesFilename                string
+	SecretRules                        *secrets.RuleSet
+	MaskModeValue                      string
+	MaskMode                           model.MaskMode
+	SecretVaultFilename                string
 	SilentFlag                         bool
 	HelpFlag                           bool
 	VersionFlag                        bool
@@ -132,6 +179,87 @@ func GeneralOptParse(args []string) (int, *Option, error) {
 	deleteCommentsFlagOpt := fs.Bool("delete-comment", false, "Specify flag delete code comments.")
 	fs.BoolVar(deleteCommentsFlagOpt, "D", false, "Specify flag delete code comments.")
 
+	// --keep-doc-comments
+	keepDocCommentsFlagOpt := fs.Bool("keep-doc-comments", false, "Specify flag keep doc comments when deleting comments.")
+
+	// --compact-go
+	compactGoOpt := fs.String("compact-go", "off", "Specify how Go files are compacted.")
+
+	// --max-file-size
+	maxFileSizeOpt := fs.String("max-file-size", "", "Specify the byte size above which files are skipped.")
+
+	// --max-lines-per-file
+	maxLinesPerFileOpt := fs.Int("max-lines-per-file", 0, "Specify the line count above which files are truncated.")
+
+	// --symlinks
+	symlinksOpt := fs.String("symlinks", "list", "Specify how symbolic links are handled.")
+
+	// --skip-generated
+	skipGeneratedFlagOpt := fs.Bool("skip-generated", false, "Specify flag skip generated and vendored files.")
+
+	// --binary-metadata
+	binaryMetadataFlagOpt := fs.Bool("binary-metadata", false, "Specify flag dump a metadata stub for binary and image files.")
+
+	// --embed-images
+	embedImagesOpt := fs.String("embed-images", "", "Specify embed images up to this size as data URIs in markdown and xml.")
+
+	// --max-tokens
+	maxTokensOpt := fs.Int("max-tokens", 0, "Specify the token budget of the output.")
+
+	// --tokenizer
+	tokenizerOpt := fs.String("tokenizer", "cl100k", "Specify the tokenizer used to count tokens.")
+
+	// --tokenizer-file
+	tokenizerFileOpt := fs.String("tokenizer-file", "", "Specify a tiktoken rank file for exact BPE token counting.")
+
+	// --budget-policy
+	budgetPolicyOpt := fs.String("budget-policy", "truncate", "Specify how files are dropped or truncated to fit the token budget.")
+
+	// --order
+	orderOpt := fs.String("order", "tree", "Specify the order files are dumped in.")
+
+	// --order-globs
+	orderGlobsOpt := fs.String("order-globs", "", "Specify the comma separated globs that rank files for --order custom.")
+
+	// --token-report
+	tokenReportFlagOpt := fs.Bool("token-report", false, "Specify flag print a per-file token report.")
+
+	// --split-size
+	splitSizeOpt := fs.String("split-size", "", "Specify the maximum byte size of each output chunk.")
+
+	// --split-tokens
+	splitTokensOpt := fs.Int("split-tokens", 0, "Specify the maximum token count of each output chunk.")
+
+	// --arklite-lossless
+	arkliteLosslessFlagOpt := fs.Bool("arklite-lossless", false, "Specify flag write arklite that restores byte-exact.")
+
+	// --since
+	sinceRefOpt := fs.String("since", "", "Specify dump only files changed since the git ref.")
+
+	// --staged
+	stagedFlagOpt := fs.Bool("staged", false, "Specify flag dump only files staged in the git index.")
+
+	// --diff-content
+	diffContentOpt := fs.String("diff-content", "full", "Specify what is written for changed files in diff mode.")
+
+	// --jobs
+	jobsOpt := fs.Int("jobs", 0, "Specify the number of files processed in parallel. 0 means the number of CPUs.")
+
+	// --secret-rules
+	secretRulesOpt := fs.String("secret-rules", "", "Specify the secret rules file.")
+
+	// --mask-mode
+	maskModeOpt := fs.String("mask-mode", "redact", "Specify how secrets are masked.")
+
+	// --secret-vault
+	secretVaultOpt := fs.String("secret-vault", "", "Specify the vault file of reversible masking.")
+
+	// --config
+	configOpt := fs.String("config", "", "Specify the config file to read instead of the discovered ones.")
+
+	// --profile
+	profileOpt := fs.String("profile", "", "Specify the config profile to apply.")
+
 	// --help
 	helpFlagOpt := fs.Bool("help", false, "Show help message.")
 	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
@@ -144,7 +272,7 @@ func GeneralOptParse(args []string) (int, *Option, error) {
 		fmt.Fprintln(os.Stderr, "\nHelpOption:")
 		fmt.Fprintln(os.Stderr, "    ark --help")
 	}
-	err := fs.Parse(args)
+	_args, err := parseInterspersed(fs, args)
 	if err != nil {
 		return optLength, nil, err
 	}
@@ -152,7 +280,6 @@ func GeneralOptParse(args []string) (int, *Option, error) {
 	currentDir := common.GetCurrentDir()
 
 	var targetDirname = ""
-	_args := fs.Args()
 	if len(_args) > 0 {
 		targetDirname = _args[0]
 	}
@@ -161,6 +288,14 @@ func GeneralOptParse(args []string) (int, *Option, error) {
 		targetDirname = currentDir
 	}
 
+	// config files fill in every option not given on the command line
+	var configFiles []string
+	if !*helpFlagOpt && !*versionFlagOpt {
+		if configFiles, err = loadConfig(fs, *configOpt, *profileOpt, targetDirname, false); err != nil {
+			return optLength, nil, err
+		}
+	}
+
 	result := &Option{
 		WorkingDir:                      currentDir,
 		TargetDirname:                   targetDirname,
@@ -182,6 +317,34 @@ func GeneralOptParse(args []string) (int, *Option, error) {
 		SkipNonUTF8Flag:                 *skipNonUTF8FlagOpt,
 		SilentFlag:                      *silentFlagOpt,
 		DeleteCommentsFlag:              *deleteCommentsFlagOpt,
+		KeepDocCommentsFlag:             *keepDocCommentsFlagOpt,
+		CompactGoValue:                  *compactGoOpt,
+		MaxFileSizeValue:                *maxFileSizeOpt,
+		MaxLinesPerFile:                 *maxLinesPerFileOpt,
+		SymlinksValue:                   *symlinksOpt,
+		SkipGeneratedFlag:               *skipGeneratedFlagOpt,
+		BinaryMetadataFlag:              *binaryMetadataFlagOpt,
+		EmbedImagesValue:                *embedImagesOpt,
+		MaxTokens:                       *maxTokensOpt,
+		TokenizerValue:                  *tokenizerOpt,
+		TokenizerFile:                   *tokenizerFileOpt,
+		BudgetPolicyValue:               *budgetPolicyOpt,
+		OrderValue:                      *orderOpt,
+		OrderGlobs:                      *orderGlobsOpt,
+		TokenReportFlag:                 *tokenReportFlagOpt,
+		SplitSizeValue:                  *splitSizeOpt,
+		SplitTokens:                     *splitTokensOpt,
+		ArkliteLosslessFlag:             *arkliteLosslessFlagOpt,
+		SinceRef:                        *sinceRefOpt,
+		StagedFlag:                      *stagedFlagOpt,
+		DiffContentValue:                *diffContentOpt,
+		Jobs:                            *jobsOpt,
+		ConfigFilename:                  *configOpt,
+		ProfileName:                     *profileOpt,
+		ConfigFiles:                     configFiles,
+		SecretRulesFilename:             *secretRulesOpt,
+		MaskModeValue:                   *maskModeOpt,
+		SecretVaultFilename:             *secretVaultOpt,
 		HelpFlag:                        *helpFlagOpt,
 		VersionFlag:                     *versionFlagOpt,
 		FlagSet:                         fs,
@@ -239,10 +402,129 @@ func (cr *Option) Normalize() error {
 		errorMessages = append(errorMessages, fmt.Sprintf("--output-format %s", err.Error()))
 	}
 
+	// --max-tokens
+	if cr.MaxTokens < 0 {
+		errorMessages = append(errorMessages, fmt.Sprintf("--max-tokens must not be negative: %d", cr.MaxTokens))
+	}
+
+	// --tokenizer
+	if cr.TokenizerValue == "" {
+		cr.TokenizerValue = model.TokenizerCl100k
+	}
+	if err := cr.Tokenizer.Set(cr.TokenizerValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--tokenizer %s", err.Error()))
+	}
+
+	// --budget-policy
+	if cr.BudgetPolicyValue == "" {
+		cr.BudgetPolicyValue = model.BudgetPolicyTruncate
+	}
+	if err := cr.BudgetPolicy.Set(cr.BudgetPolicyValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--budget-policy %s", err.Error()))
+	}
+
+	// --order, --order-globs
+	if cr.OrderValue == "" {
+		cr.OrderValue = model.FileOrderTree
+	}
+	if cr.OrderGlobs != "" {
+		cr.OrderGlobList = common.CommaSeparated2StringList(cr.OrderGlobs)
+	}
+	if err := cr.Order.Set(cr.OrderValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--order %s", err.Error()))
+	} else if cr.Order.String() == model.FileOrderCustom && len(cr.OrderGlobList) == 0 {
+		errorMessages = append(errorMessages, "--order custom requires --order-globs")
+	}
+
+	// --split-size, --split-tokens
+	if cr.SplitSizeValue != "" {
+		if err := cr.SplitSize.Set(cr.SplitSizeValue); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("--split-size %s", err.Error()))
+		}
+	}
+	if cr.SplitTokens < 0 {
+		errorMessages = append(errorMessages, fmt.Sprintf("--split-tokens must not be negative: %d", cr.SplitTokens))
+	}
+	if cr.SplitSizeValue != "" && cr.SplitTokens > 0 {
+		errorMessages = append(errorMessages, "--split-size and --split-tokens cannot be used together")
+	}
+
+	// --jobs
+	if cr.Jobs < 0 {
+		errorMessages = append(errorMessages, fmt.Sprintf("--jobs must not be negative: %d", cr.Jobs))
+	}
+
+	// --diff-content
+	if cr.DiffContentValue == "" {
+		cr.DiffContentValue = model.DiffContentFull
+	}
+	if err := cr.DiffContent.Set(cr.DiffContentValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--diff-content %s", err.Error()))
+	} else if cr.DiffContent.String() != model.DiffContentFull && !cr.DiffModeEnabled() {
+		errorMessages = append(errorMessages, "--diff-content requires --since or --staged")
+	}
+
+	// --secret-rules
+	if rules, err := LoadSecretRules(cr.SecretRulesFilename, cr.TargetDirname); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--secret-rules %s", err.Error()))
+	} else {
+		cr.SecretRules = rules
+	}
+
+	// --compact-go
+	if cr.CompactGoValue == "" {
+		cr.CompactGoValue = model.CompactGoOff
+	}
+	if err := cr.CompactGo.Set(cr.CompactGoValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--compact-go %s", err.Error()))
+	}
+
+	// --max-file-size, --max-lines-per-file
+	if cr.MaxFileSizeValue != "" {
+		if err := cr.MaxFileSize.Set(cr.MaxFileSizeValue); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("--max-file-size %s", err.Error()))
+		}
+	}
+	if cr.MaxLinesPerFile < 0 {
+		errorMessages = append(errorMessages, fmt.Sprintf("--max-lines-per-file must not be negative: %d", cr.MaxLinesPerFile))
+	}
+
+	// --symlinks
+	if cr.SymlinksValue == "" {
+		cr.SymlinksValue = model.SymlinkList
+	}
+	if err := cr.Symlinks.Set(cr.SymlinksValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--symlinks %s", err.Error()))
+	}
+
+	// --embed-images
+	if cr.EmbedImagesValue != "" {
+		if err := cr.EmbedImages.Set(cr.EmbedImagesValue); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("--embed-images %s", err.Error()))
+		}
+		if !cr.BinaryMetadataFlag {
+			errorMessages = append(errorMessages, "--embed-images requires --binary-metadata")
+		}
+	}
+
+	// --mask-mode
+	if cr.MaskModeValue == "" {
+		cr.MaskModeValue = model.MaskModeRedact
+	}
+	if err := cr.MaskMode.Set(cr.MaskModeValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--mask-mode %s", err.Error()))
+	} else if cr.MaskMode.String() == model.MaskModeReversible && cr.SecretRules != nil {
+		if vault, err := OpenSecretVault(cr.SecretVaultFilename); err != nil {
+			errorMessages = append(errorMessages, fmt.Sprintf("--secret-vault %s", err.Error()))
+		} else {
+			cr.SecretRules.Vault = vault
+		}
+	}
+
 	// output-format
 
 	if cr.OutputFormat.String() == model.Auto {
-		if cr.OutputFilename != "" {
+		if cr.OutputFilename != "" && !cr.IsStdout() {
 			ext := filepath.Ext(cr.OutputFilename)
 			ditectFormatValue := model.Ext2OutputFormat(ext)
 
@@ -266,6 +548,10 @@ func (cr *Option) Normalize() error {
 			cr.OutputFilename = "ark-output.xml"
 		case model.Arklite:
 			cr.OutputFilename = "ark-output.arklite.txt"
+		case model.JSON:
+			cr.OutputFilename = "ark-output.json"
+		case model.JSONL:
+			cr.OutputFilename = "ark-output.jsonl"
 		default:
 			cr.OutputFilename = "ark-output.txt"
 		}
@@ -274,7 +560,14 @@ func (cr *Option) Normalize() error {
 	// compless
 	if cr.OutputFormat.CanCompless() && cr.ComplessFlag {
 		cr.DeleteCommentsFlag = true
-		cr.OutputFilename = fmt.Sprintf("%s%s", cr.OutputFilename, ".arklite.txt")
+		if !cr.IsStdout() {
+			cr.OutputFilename = fmt.Sprintf("%s%s", cr.OutputFilename, ".arklite.txt")
+		}
+	}
+
+	// stdout
+	if cr.IsStdout() && (cr.SplitSizeValue != "" || cr.SplitTokens > 0) {
+		errorMessages = append(errorMessages, "--split-size and --split-tokens cannot be used with --output-filename -")
 	}
 
 	// gitignorerule
@@ -323,3 +616,22 @@ func (cr *Option) Normalize() error {
 		return errors.New(strings.Join(errorMessages, "\n"))
 	}
 }
+
+// DiffModeEnabled reports whether only changed files are dumped (--since or --staged).
+func (cr *Option) DiffModeEnabled() bool {
+	return cr.SinceRef != "" || cr.StagedFlag
+}
+
+// SecretRuleSet returns the secret rules to mask with: the loaded ones, or the
+// built-in ones when the option was not normalized.
+func (cr *Option) SecretRuleSet() *secrets.RuleSet {
+	if cr.SecretRules == nil {
+		return secrets.DefaultRuleSet()
+	}
+	return cr.SecretRules
+}
+
+// IsStdout reports whether the dump is streamed to stdout (--output-filename -).
+func (cr *Option) IsStdout() bool {
+	return cr.OutputFilename == StdoutFilename
+}
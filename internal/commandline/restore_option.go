@@ -0,0 +1,85 @@
+package commandline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RestoreOption defines options for rebuilding a directory from a dump
+type RestoreOption struct {
+	DumpFilenames []string
+	OutputDir     string
+	ForceFlag     bool
+	HelpFlag      bool
+	FlagSet       *flag.FlagSet
+}
+
+func RestoreOptParse(args []string) (int, *RestoreOption, error) {
+
+	optLength := len(args)
+
+	fs := flag.NewFlagSet("ark-restore", flag.ExitOnError)
+
+	// --output-dir
+	outputDirOpt := fs.String("output-dir", ".", "Specify the directory to restore files into.")
+	fs.StringVar(outputDirOpt, "o", ".", "Specify the directory to restore files into.")
+
+	// --force
+	forceFlagOpt := fs.Bool("force", false, "Specify flag overwrite existing files.")
+	fs.BoolVar(forceFlagOpt, "F", false, "Specify flag overwrite existing files.")
+
+	// --help
+	helpFlagOpt := fs.Bool("help", false, "Show help message.")
+	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "\nHelpOption:")
+		fmt.Fprintln(os.Stderr, "    ark --help")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return optLength, nil, err
+	}
+
+	result := &RestoreOption{
+		DumpFilenames: fs.Args(),
+		OutputDir:     *outputDirOpt,
+		ForceFlag:     *forceFlagOpt,
+		HelpFlag:      *helpFlagOpt,
+		FlagSet:       fs,
+	}
+
+	OverRideHelp(fs)
+
+	if result.HelpFlag {
+		return optLength, result, nil
+	}
+
+	if err := result.Normalize(); err != nil {
+		return optLength, nil, err
+	}
+
+	return optLength, result, nil
+}
+
+func (cr *RestoreOption) Normalize() error {
+
+	var errorMessages = []string{}
+
+	if len(cr.DumpFilenames) == 0 {
+		errorMessages = append(errorMessages, "a dump filename is required")
+	}
+
+	if cr.OutputDir == "" {
+		errorMessages = append(errorMessages, "--output-dir must not be empty")
+	}
+
+	if len(errorMessages) == 0 {
+		return nil
+	} else {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+}
@@ -0,0 +1,48 @@
+package commandline_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+)
+
+func TestRestoreOptParse_Basic(t *testing.T) {
+	_, opt, err := commandline.RestoreOptParse([]string{"--output-dir", "out", "--force", "a.001.txt", "a.002.txt"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputDir != "out" {
+		t.Errorf("OutputDir mismatch. got=%s", opt.OutputDir)
+	}
+	if !opt.ForceFlag {
+		t.Errorf("ForceFlag should be true")
+	}
+	if !reflect.DeepEqual(opt.DumpFilenames, []string{"a.001.txt", "a.002.txt"}) {
+		t.Errorf("DumpFilenames mismatch. got=%v", opt.DumpFilenames)
+	}
+}
+
+func TestRestoreOptParse_Defaults(t *testing.T) {
+	_, opt, err := commandline.RestoreOptParse([]string{"-o", "restored", "dump.md"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputDir != "restored" || opt.ForceFlag {
+		t.Errorf("unexpected option: %+v", opt)
+	}
+
+	_, opt, err = commandline.RestoreOptParse([]string{"dump.md"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.OutputDir != "." {
+		t.Errorf("OutputDir should default to '.', got=%s", opt.OutputDir)
+	}
+}
+
+func TestRestoreOptParse_MissingDump(t *testing.T) {
+	if _, _, err := commandline.RestoreOptParse([]string{"-o", "out"}); err == nil {
+		t.Errorf("Expected error when no dump filename is given")
+	}
+}
@@ -0,0 +1,154 @@
+package commandline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/common"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// ScanSecretsOption defines options for reporting secrets found under a directory
+type ScanSecretsOption struct {
+	FormatValue   string
+	Format        model.ScanReportFormat
+	HelpFlag      bool
+	GeneralOption *Option
+	FlagSet       *flag.FlagSet
+}
+
+func ScanSecretsOptParse(args []string) (int, *ScanSecretsOption, error) {
+
+	optLength := len(args)
+
+	fs := flag.NewFlagSet("ark-scan-secrets", flag.ExitOnError)
+
+	// --format
+	formatOpt := fs.String("format", "text", "Specify the report format.")
+	fs.StringVar(formatOpt, "f", "text", "Specify the report format.")
+
+	// --scan-buffer
+	scanBufferValueOpt := fs.String("scan-buffer", "10M", "Specify the line scan buffer size.")
+	fs.StringVar(scanBufferValueOpt, "b", "10M", "Specify the line scan buffer size.")
+
+	// --allow-gitignore
+	allowGitignoreFlagOpt := fs.String("allow-gitignore", "on", "Specify enable .gitignore.")
+	fs.StringVar(allowGitignoreFlagOpt, "a", "on", "Specify enable .gitignore.")
+
+	// --additionally-ignorerule
+	additionallyIgnoreRuleFilenamesOpt := fs.String("additionally-ignorerule", "", "Specify a file containing additional ignore rules.")
+	fs.StringVar(additionallyIgnoreRuleFilenamesOpt, "A", "", "Specify a file containing additional ignore rules.")
+
+	// --ignore-dotfile
+	ignoreDotfileFlagValueOpt := fs.String("ignore-dotfile", "off", "Specify ignore dot files.")
+	fs.StringVar(ignoreDotfileFlagValueOpt, "d", "off", "Specify ignore dot files.")
+
+	// --pattern-regex
+	patternRegexOpt := fs.String("pattern-regex", "", "Specify watch file pattern regexp (optional)")
+	fs.StringVar(patternRegexOpt, "x", "", "Specify watch file pattern regexp (optional)")
+
+	// --include-ext
+	includeExtOpt := fs.String("include-ext", "", "Specify watch file extension (optional)")
+	fs.StringVar(includeExtOpt, "i", "", "Specify watch file extension (optional)")
+
+	// --exclude-file-regexp
+	excludeFileRegexpOpt := fs.String("exclude-file-regex", "", "Specify watch file ignore pattern regexp (optional)")
+	fs.StringVar(excludeFileRegexpOpt, "g", "", "Specify watch file ignore pattern regexp (optional)")
+
+	// --exclude-dir-regexp
+	excludeDirRegexpOpt := fs.String("exclude-dir-regex", "", "Specify watch dir ignore pattern regexp (optional)")
+	fs.StringVar(excludeDirRegexpOpt, "G", "", "Specify watch file ignore pattern regexp (optional)")
+
+	// --exclude-ext
+	excludeExtOpt := fs.String("exclude-ext", "", "Specify watch exclude file extension (optional)")
+	fs.StringVar(excludeExtOpt, "e", "", "Specify watch exclude file extension (optional)")
+
+	// --exclude-dir
+	excludeDirOpt := fs.String("exclude-dir", "", "Specify watch exclude directory (optional)")
+	fs.StringVar(excludeDirOpt, "E", "", "Specify watch exclude directory (optional)")
+
+	// --skik-non-utf8
+	skipNonUTF8FlagOpt := fs.Bool("skip-non-utf8", false, "Specify ignore files that do not have utf8 charset.")
+	fs.BoolVar(skipNonUTF8FlagOpt, "s", false, "Specify ignore files that do not have utf8 charset.")
+
+	// --secret-rules
+	secretRulesOpt := fs.String("secret-rules", "", "Specify the secret rules file.")
+
+	// --help
+	helpFlagOpt := fs.Bool("help", false, "Show help message.")
+	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "\nHelpOption:")
+		fmt.Fprintln(os.Stderr, "    ark --help")
+	}
+	_args, err := parseInterspersed(fs, args)
+	if err != nil {
+		return optLength, nil, err
+	}
+
+	currentDir := common.GetCurrentDir()
+	targetDirname := currentDir
+	if len(_args) > 0 {
+		targetDirname = _args[0]
+	}
+
+	generalOpt := &Option{
+		WorkingDir:                      currentDir,
+		TargetDirname:                   targetDirname,
+		ScanBufferValue:                 *scanBufferValueOpt,
+		MaskSecretsFlagValue:            "on",
+		AllowGitignoreFlagValue:         *allowGitignoreFlagOpt,
+		AdditionallyIgnoreRuleFilenames: *additionallyIgnoreRuleFilenamesOpt,
+		IgnoreDotFileFlagValue:          *ignoreDotfileFlagValueOpt,
+		PatternRegexpString:             *patternRegexOpt,
+		IncludeExt:                      *includeExtOpt,
+		ExcludeDirRegexpString:          *excludeDirRegexpOpt,
+		ExcludeFileRegexpString:         *excludeFileRegexpOpt,
+		ExcludeExt:                      *excludeExtOpt,
+		ExcludeDir:                      *excludeDirOpt,
+		SecretRulesFilename:             *secretRulesOpt,
+		SkipNonUTF8Flag:                 *skipNonUTF8FlagOpt,
+		WithLineNumberFlagValue:         "off",
+		OutputFormatValue:               "auto",
+		FlagSet:                         fs,
+	}
+
+	result := &ScanSecretsOption{
+		FormatValue:   *formatOpt,
+		HelpFlag:      *helpFlagOpt,
+		GeneralOption: generalOpt,
+		FlagSet:       fs,
+	}
+
+	OverRideHelp(fs)
+
+	if result.HelpFlag {
+		return optLength, result, nil
+	}
+
+	if err := common.JoinErrors(result.Normalize(), generalOpt.Normalize()); err != nil {
+		return optLength, nil, err
+	}
+
+	return optLength, result, nil
+}
+
+func (cr *ScanSecretsOption) Normalize() error {
+
+	var errorMessages = []string{}
+
+	// --format
+	if err := cr.Format.Set(cr.FormatValue); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--format %s", err.Error()))
+	}
+
+	if len(errorMessages) == 0 {
+		return nil
+	} else {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+}
@@ -0,0 +1,50 @@
+package commandline_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+)
+
+func TestScanSecretsOptParse(t *testing.T) {
+	_, opt, err := commandline.ScanSecretsOptParse([]string{"-f", "sarif", "-E", "vendor", "./example"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.Format.String() != "sarif" {
+		t.Errorf("Expected Format = sarif, got %s", opt.Format.String())
+	}
+	if opt.GeneralOption.TargetDirname != "./example" {
+		t.Errorf("Expected TargetDirname = ./example, got %s", opt.GeneralOption.TargetDirname)
+	}
+	if len(opt.GeneralOption.ExcludeDirList) != 1 || opt.GeneralOption.ExcludeDirList[0] != "vendor" {
+		t.Errorf("Expected ExcludeDirList = [vendor], got %v", opt.GeneralOption.ExcludeDirList)
+	}
+}
+
+func TestScanSecretsOptParse_Defaults(t *testing.T) {
+	_, opt, err := commandline.ScanSecretsOptParse([]string{"./example", "--format", "json"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.Format.String() != "json" {
+		t.Errorf("Expected Format = json, got %s", opt.Format.String())
+	}
+	if opt.GeneralOption.AllowGitignoreFlag.String() != "on" {
+		t.Errorf("Expected AllowGitignoreFlag = on, got %s", opt.GeneralOption.AllowGitignoreFlag.String())
+	}
+
+	_, opt, err = commandline.ScanSecretsOptParse([]string{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.Format.String() != "text" || opt.GeneralOption.TargetDirname == "" {
+		t.Errorf("Expected text format for the current directory, got %s %q", opt.Format.String(), opt.GeneralOption.TargetDirname)
+	}
+}
+
+func TestScanSecretsOptParse_InvalidFormat(t *testing.T) {
+	if _, _, err := commandline.ScanSecretsOptParse([]string{"-f", "xml"}); err == nil {
+		t.Errorf("Expected error for invalid format")
+	}
+}
@@ -19,6 +19,8 @@ type ServeOption struct {
 	McpServerType      model.McpSreverType
 	McpServerTypeValue string
 	HttpPort           string
+	ProfileName        string
+	ConfigFiles        []string
 	GeneralOption      *Option
 }
 
@@ -94,6 +96,39 @@ func ServerOptParse(version string, args []string) (int, *ServeOption, error) {
 	deleteCommentsFlagOpt := fs.Bool("delete-comment", false, "Specify flag delete code comments.")
 	fs.BoolVar(deleteCommentsFlagOpt, "D", false, "Specify flag delete code comments.")
 
+	// --keep-doc-comments
+	keepDocCommentsFlagOpt := fs.Bool("keep-doc-comments", false, "Specify flag keep doc comments when deleting comments.")
+
+	// --compact-go
+	compactGoOpt := fs.String("compact-go", "off", "Specify how Go files are compacted.")
+
+	// --max-file-size
+	maxFileSizeOpt := fs.String("max-file-size", "", "Specify the byte size above which files are skipped.")
+
+	// --max-lines-per-file
+	maxLinesPerFileOpt := fs.Int("max-lines-per-file", 0, "Specify the line count above which files are truncated.")
+
+	// --symlinks
+	symlinksOpt := fs.String("symlinks", "list", "Specify how symbolic links are handled.")
+
+	// --skip-generated
+	skipGeneratedFlagOpt := fs.Bool("skip-generated", false, "Specify flag skip generated and vendored files.")
+
+	// --secret-rules
+	secretRulesOpt := fs.String("secret-rules", "", "Specify the secret rules file.")
+
+	// --mask-mode
+	maskModeOpt := fs.String("mask-mode", "redact", "Specify how secrets are masked.")
+
+	// --secret-vault
+	secretVaultOpt := fs.String("secret-vault", "", "Specify the vault file of reversible masking.")
+
+	// --config
+	configOpt := fs.String("config", "", "Specify the config file to read instead of the discovered ones.")
+
+	// --profile
+	profileOpt := fs.String("profile", "", "Specify the config profile to apply.")
+
 	// --help
 	helpFlagOpt := fs.Bool("help", false, "Show help message.")
 	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
@@ -111,7 +146,19 @@ func ServerOptParse(version string, args []string) (int, *ServeOption, error) {
 		return optLength, nil, err
 	}
 
+	// config files fill in every option not given on the command line
+	var configFiles []string
+	if !*helpFlagOpt && !*versionFlagOpt {
+		if configFiles, err = loadConfig(fs, *configOpt, *profileOpt, *rootDirOpt, true); err != nil {
+			return optLength, nil, err
+		}
+	}
+
 	generalOpt := &Option{
+		TargetDirname:                   *rootDirOpt,
+		SecretRulesFilename:             *secretRulesOpt,
+		MaskModeValue:                   *maskModeOpt,
+		SecretVaultFilename:             *secretVaultOpt,
 		ScanBufferValue:                 *scanBufferValueOpt,
 		MaskSecretsFlagValue:            *maskSecretsFlagOpt,
 		AllowGitignoreFlagValue:         *allowGitignoreFlagOpt,
@@ -125,6 +172,12 @@ func ServerOptParse(version string, args []string) (int, *ServeOption, error) {
 		ExcludeDir:                      *excludeDirOpt,
 		SkipNonUTF8Flag:                 *skipNonUTF8FlagOpt,
 		DeleteCommentsFlag:              *deleteCommentsFlagOpt,
+		KeepDocCommentsFlag:             *keepDocCommentsFlagOpt,
+		CompactGoValue:                  *compactGoOpt,
+		MaxFileSizeValue:                *maxFileSizeOpt,
+		MaxLinesPerFile:                 *maxLinesPerFileOpt,
+		SymlinksValue:                   *symlinksOpt,
+		SkipGeneratedFlag:               *skipGeneratedFlagOpt,
 		WithLineNumberFlagValue:         "off",
 		OutputFormatValue:               "auto",
 		FlagSet:                         fs,
@@ -135,6 +188,8 @@ func ServerOptParse(version string, args []string) (int, *ServeOption, error) {
 		RootDir:            *rootDirOpt,
 		McpServerTypeValue: *mcpServerTypeOpt,
 		HttpPort:           strconv.Itoa(*httpPortOpt),
+		ProfileName:        *profileOpt,
+		ConfigFiles:        configFiles,
 		GeneralOption:      generalOpt,
 	}
 
@@ -0,0 +1,86 @@
+package commandline
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/secrets"
+)
+
+// UnmaskOption defines options for restoring the secrets of reversible masking
+type UnmaskOption struct {
+	Filenames           []string
+	SecretVaultFilename string
+	Vault               *secrets.Vault
+	HelpFlag            bool
+	FlagSet             *flag.FlagSet
+}
+
+func UnmaskOptParse(args []string) (int, *UnmaskOption, error) {
+
+	optLength := len(args)
+
+	fs := flag.NewFlagSet("ark-unmask", flag.ExitOnError)
+
+	// --secret-vault
+	secretVaultOpt := fs.String("secret-vault", "", "Specify the vault file of reversible masking.")
+
+	// --help
+	helpFlagOpt := fs.Bool("help", false, "Show help message.")
+	fs.BoolVar(helpFlagOpt, "h", false, "Show help message.")
+
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "\nHelpOption:")
+		fmt.Fprintln(os.Stderr, "    ark --help")
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return optLength, nil, err
+	}
+
+	result := &UnmaskOption{
+		Filenames:           fs.Args(),
+		SecretVaultFilename: *secretVaultOpt,
+		HelpFlag:            *helpFlagOpt,
+		FlagSet:             fs,
+	}
+
+	OverRideHelp(fs)
+
+	if result.HelpFlag {
+		return optLength, result, nil
+	}
+
+	if err := result.Normalize(); err != nil {
+		return optLength, nil, err
+	}
+
+	return optLength, result, nil
+}
+
+func (cr *UnmaskOption) Normalize() error {
+
+	var errorMessages = []string{}
+
+	if len(cr.Filenames) == 0 {
+		errorMessages = append(errorMessages, "a filename is required ('-' reads stdin)")
+	}
+
+	// --secret-vault
+	if vault, err := OpenSecretVault(cr.SecretVaultFilename); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--secret-vault %s", err.Error()))
+	} else if _, err := os.Stat(vault.Path); err != nil {
+		errorMessages = append(errorMessages, fmt.Sprintf("--secret-vault secret vault not found: %s", vault.Path))
+	} else {
+		cr.Vault = vault
+	}
+
+	if len(errorMessages) == 0 {
+		return nil
+	} else {
+		return errors.New(strings.Join(errorMessages, "\n"))
+	}
+}
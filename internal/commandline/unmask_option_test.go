@@ -0,0 +1,65 @@
+package commandline_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+)
+
+func TestUnmaskOptParse(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.json")
+	os.WriteFile(vaultPath, []byte(`{"version":1,"secrets":{}}`), 0600)
+
+	_, opt, err := commandline.UnmaskOptParse([]string{"--secret-vault", vaultPath, "a.go", "-"})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !reflect.DeepEqual(opt.Filenames, []string{"a.go", "-"}) {
+		t.Errorf("Filenames mismatch. got=%v", opt.Filenames)
+	}
+	if opt.Vault == nil || opt.Vault.Path != vaultPath {
+		t.Errorf("Expected the vault at %s to be opened", vaultPath)
+	}
+}
+
+func TestUnmaskOptParse_Errors(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	if _, _, err := commandline.UnmaskOptParse([]string{"a.go"}); err == nil {
+		t.Errorf("Expected error for a missing default vault")
+	}
+
+	vaultPath := filepath.Join(t.TempDir(), "vault.json")
+	os.WriteFile(vaultPath, []byte(`{"version":1,"secrets":{}}`), 0600)
+	if _, _, err := commandline.UnmaskOptParse([]string{"--secret-vault", vaultPath}); err == nil {
+		t.Errorf("Expected error without a filename")
+	}
+}
+
+func TestOptParse_MaskMode(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	dir := t.TempDir()
+
+	_, opt, err := commandline.GeneralOptParse([]string{dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if opt.MaskMode.String() != "redact" || opt.SecretRuleSet().Vault != nil {
+		t.Errorf("Expected redact masking by default, got %s", opt.MaskMode.String())
+	}
+
+	vaultPath := filepath.Join(t.TempDir(), "vault.json")
+	_, opt, err = commandline.GeneralOptParse([]string{"--mask-mode", "reversible", "--secret-vault", vaultPath, dir})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if vault := opt.SecretRuleSet().Vault; vault == nil || vault.Path != vaultPath {
+		t.Errorf("Expected reversible masking with the vault at %s", vaultPath)
+	}
+
+	if _, _, err := commandline.GeneralOptParse([]string{"--mask-mode", "hide", dir}); err == nil {
+		t.Errorf("Expected error for an invalid --mask-mode")
+	}
+}
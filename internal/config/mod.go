@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Filenames are the project config files looked up in the target directory,
+// in order of preference.
+var Filenames = []string{"ark.toml", ".arkrc"}
+
+const (
+	// ServerTable holds the keys only mcp-server understands.
+	ServerTable = "mcp-server"
+	// ProfilePrefix starts the name of every profile table: [profile.review]
+	ProfilePrefix = "profile."
+)
+
+// Config is one or more config files merged together. Keys are long flag
+// names and values are in the form the flag accepts.
+type Config struct {
+	Files  []string
+	tables map[string]map[string]string
+}
+
+// Discover returns the config files that apply to targetDir: the user config
+// (<user config dir>/ark/ark.toml) and the project config found in targetDir,
+// whose values win. The project config belongs to the tree being dumped, so it
+// is returned apart and trusted less. An explicit path replaces the lookup,
+// must exist and counts as a user config.
+func Discover(explicit string, targetDir string) (user []string, project []string, err error) {
+	if explicit != "" {
+		if _, err := os.Stat(explicit); err != nil {
+			return nil, nil, fmt.Errorf("config file not found: %s", explicit)
+		}
+		return []string{explicit}, nil, nil
+	}
+
+	if dir, err := os.UserConfigDir(); err == nil {
+		if p := filepath.Join(dir, "ark", "ark.toml"); isFile(p) {
+			user = append(user, p)
+		}
+	}
+	for _, name := range Filenames {
+		if p := filepath.Join(targetDir, name); isFile(p) {
+			project = append(project, p)
+			break
+		}
+	}
+	return user, project, nil
+}
+
+func isFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// Load reads and merges files; later files override earlier ones key by key.
+func Load(files ...string) (*Config, error) {
+	c := &Config{tables: map[string]map[string]string{}}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tables, err := ParseTOML(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		for name, values := range tables {
+			profile, isProfile := strings.CutPrefix(name, ProfilePrefix)
+			if name != "" && name != ServerTable && (!isProfile || strings.Contains(profile, ".")) {
+				return nil, fmt.Errorf("%s: unknown table [%s]", file, name)
+			}
+			if c.tables[name] == nil {
+				c.tables[name] = map[string]string{}
+			}
+			for k, v := range values {
+				c.tables[name][k] = v.String()
+			}
+		}
+		c.Files = append(c.Files, file)
+	}
+	return c, nil
+}
+
+// Values returns the keys of the root table, or of table when it is given.
+func (c *Config) Values(table string) map[string]string {
+	if c == nil {
+		return nil
+	}
+	return c.tables[table]
+}
+
+// Profile returns the keys of the named profile.
+func (c *Config) Profile(name string) (map[string]string, error) {
+	if values := c.Values(ProfilePrefix + name); values != nil {
+		return values, nil
+	}
+	if names := c.ProfileNames(); len(names) > 0 {
+		return nil, fmt.Errorf("profile %q not found. Available profiles are %s", name, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("profile %q not found: no profile is defined", name)
+}
+
+// ProfileNames lists the defined profiles in alphabetical order.
+func (c *Config) ProfileNames() []string {
+	if c == nil {
+		return nil
+	}
+	var names []string
+	for name := range c.tables {
+		if after, ok := strings.CutPrefix(name, ProfilePrefix); ok {
+			names = append(names, after)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,155 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/config"
+)
+
+func writeConfig(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestLoad_Values(t *testing.T) {
+	p := writeConfig(t, t.TempDir(), "ark.toml", `
+# comment
+output-format = "md"   # trailing comment
+pattern-regex = "^.*\\.go$"
+exclude-dir-regex = '^\.cache$'
+"quoted-key" = "has # hash"
+max-tokens = 100_000
+ratio = 4.50
+silent = true
+include-ext = [
+  "go",
+  "md", # docs
+]
+
+[mcp-server]
+http-port = 9000
+
+[profile.review]
+since = "main"
+
+[profile."llm-small"]
+max-tokens = 32000
+`)
+	cfg, err := config.Load(p)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := map[string]string{
+		"output-format":     "md",
+		"pattern-regex":     `^.*\.go$`,
+		"exclude-dir-regex": `^\.cache$`,
+		"quoted-key":        "has # hash",
+		"max-tokens":        "100000",
+		"ratio":             "4.5",
+		"silent":            "true",
+		"include-ext":       "go,md",
+	}
+	if got := cfg.Values(""); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+	if got := cfg.Values(config.ServerTable)["http-port"]; got != "9000" {
+		t.Errorf("http-port = %q", got)
+	}
+	if got := cfg.ProfileNames(); !reflect.DeepEqual(got, []string{"llm-small", "review"}) {
+		t.Errorf("ProfileNames() = %v", got)
+	}
+	review, err := cfg.Profile("review")
+	if err != nil || review["since"] != "main" {
+		t.Errorf("Profile(review) = %v, %v", review, err)
+	}
+	if _, err := cfg.Profile("missing"); err == nil || !strings.Contains(err.Error(), "llm-small, review") {
+		t.Errorf("expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestLoad_Merge(t *testing.T) {
+	dir := t.TempDir()
+	user := writeConfig(t, dir, "user.toml", "output-format = \"md\"\njobs = 2\n[profile.review]\nsince = \"main\"\n")
+	project := writeConfig(t, dir, "project.toml", "output-format = \"xml\"\n[profile.review]\nstaged = true\n")
+
+	cfg, err := config.Load(user, project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Values(""); got["output-format"] != "xml" || got["jobs"] != "2" {
+		t.Errorf("project values must override user values key by key: %v", got)
+	}
+	if got, _ := cfg.Profile("review"); got["since"] != "main" || got["staged"] != "true" {
+		t.Errorf("profiles must merge key by key: %v", got)
+	}
+	if !reflect.DeepEqual(cfg.Files, []string{user, project}) {
+		t.Errorf("Files = %v", cfg.Files)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	cases := map[string]string{
+		"no value":          "output-format\n",
+		"unterminated":      "output-format = \"md\n",
+		"duplicate key":     "jobs = 1\njobs = 2\n",
+		"duplicate table":   "[profile.a]\n[profile.a]\n",
+		"unknown table":     "[output]\nformat = \"md\"\n",
+		"nested profile":    "[profile.a.b]\n",
+		"array of tables":   "[[profile]]\n",
+		"unsupported value": "jobs = 1.2.3\n",
+		"invalid key":       "out put = 1\n",
+	}
+	for name, content := range cases {
+		t.Run(name, func(t *testing.T) {
+			p := writeConfig(t, t.TempDir(), "ark.toml", content)
+			if _, err := config.Load(p); err == nil {
+				t.Errorf("expected an error for %q", content)
+			}
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	userDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	target := t.TempDir()
+
+	user, project, err := config.Discover("", target)
+	if err != nil || len(user) != 0 || len(project) != 0 {
+		t.Fatalf("Discover() = %v, %v, %v; want nothing", user, project, err)
+	}
+
+	userFile := writeConfig(t, userDir, "ark/ark.toml", "")
+	arkrc := writeConfig(t, target, ".arkrc", "")
+	user, project, _ = config.Discover("", target)
+	if !reflect.DeepEqual(user, []string{userFile}) || !reflect.DeepEqual(project, []string{arkrc}) {
+		t.Errorf("Discover() = %v, %v", user, project)
+	}
+
+	projectFile := writeConfig(t, target, "ark.toml", "")
+	_, project, _ = config.Discover("", target)
+	if !reflect.DeepEqual(project, []string{projectFile}) {
+		t.Errorf("ark.toml must be preferred over .arkrc: %v", project)
+	}
+
+	explicit := writeConfig(t, t.TempDir(), "custom.toml", "")
+	user, project, _ = config.Discover(explicit, target)
+	if !reflect.DeepEqual(user, []string{explicit}) || len(project) != 0 {
+		t.Errorf("an explicit config must replace discovery: %v, %v", user, project)
+	}
+	if _, _, err := config.Discover(filepath.Join(target, "missing.toml"), target); err == nil {
+		t.Errorf("expected an error for a missing explicit config")
+	}
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Value is a parsed TOML value in its string form. Integers and booleans are
+// kept as written; Items holds the elements of an array.
+type Value struct {
+	Items []string
+	Array bool
+}
+
+// String returns the flag form of v: arrays become comma separated lists.
+func (v Value) String() string {
+	return strings.Join(v.Items, ",")
+}
+
+// ParseTOML reads the subset of TOML that ark config files use: tables,
+// bare or quoted keys, and string, integer, float, boolean and array values.
+// The result maps a dotted table name ("" for the root table) to its keys.
+func ParseTOML(src string) (map[string]map[string]Value, error) {
+	tables := map[string]map[string]Value{"": {}}
+	current := ""
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineno := i + 1
+		line := strings.TrimSpace(stripComment(lines[i]))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", lineno)
+			}
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated table header", lineno)
+			}
+			name, err := parseTableName(strings.TrimSpace(line[1 : len(line)-1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineno, err)
+			}
+			if _, ok := tables[name]; ok && name != "" {
+				return nil, fmt.Errorf("line %d: table [%s] defined twice", lineno, name)
+			}
+			tables[name] = map[string]Value{}
+			current = name
+			continue
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineno)
+		}
+		key, err := parseKey(strings.TrimSpace(key))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineno, err)
+		}
+		rest = strings.TrimSpace(rest)
+
+		// an array may span several lines
+		for strings.HasPrefix(rest, "[") && !arrayClosed(rest) && i+1 < len(lines) {
+			i++
+			rest += " " + strings.TrimSpace(stripComment(lines[i]))
+		}
+
+		value, err := parseValue(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s: %w", lineno, key, err)
+		}
+		if _, ok := tables[current][key]; ok {
+			return nil, fmt.Errorf("line %d: key %q defined twice", lineno, key)
+		}
+		tables[current][key] = value
+	}
+	return tables, nil
+}
+
+// stripComment cuts a # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func arrayClosed(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+func parseTableName(s string) (string, error) {
+	var parts []string
+	for part := range strings.SplitSeq(s, ".") {
+		key, err := parseKey(strings.TrimSpace(part))
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, key)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func parseKey(s string) (string, error) {
+	if s == "" {
+		return "", fmt.Errorf("empty key")
+	}
+	if s[0] == '"' || s[0] == '\'' {
+		return parseString(s)
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return "", fmt.Errorf("invalid key %q", s)
+		}
+	}
+	return s, nil
+}
+
+func parseValue(s string) (Value, error) {
+	if strings.HasPrefix(s, "[") {
+		items, err := parseArray(s)
+		return Value{Items: items, Array: true}, err
+	}
+	item, err := parseScalar(s)
+	return Value{Items: []string{item}}, err
+}
+
+func parseScalar(s string) (string, error) {
+	switch {
+	case s == "":
+		return "", fmt.Errorf("missing value")
+	case s[0] == '"' || s[0] == '\'':
+		return parseString(s)
+	case s[0] == '[':
+		return "", fmt.Errorf("nested arrays are not supported")
+	case s == "true" || s == "false":
+		return s, nil
+	}
+	number := strings.ReplaceAll(s, "_", "")
+	if n, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return strconv.FormatInt(n, 10), nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil && !strings.ContainsAny(number, "xXpP") {
+		return strconv.FormatFloat(f, 'g', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value %s", s)
+}
+
+func parseString(s string) (string, error) {
+	if len(s) < 2 || s[len(s)-1] != s[0] {
+		return "", fmt.Errorf("unterminated string %s", s)
+	}
+	if s[0] == '\'' {
+		body := s[1 : len(s)-1]
+		if strings.ContainsRune(body, '\'') {
+			return "", fmt.Errorf("invalid string %s", s)
+		}
+		return body, nil
+	}
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return value, nil
+}
+
+func parseArray(s string) ([]string, error) {
+	if !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("unterminated array %s", s)
+	}
+	body := s[1 : len(s)-1]
+
+	var items []string
+	for len(strings.TrimSpace(body)) > 0 {
+		body = strings.TrimSpace(body)
+		end := len(body)
+		var quote byte
+		for i := 0; i < len(body); i++ {
+			c := body[i]
+			if quote != 0 {
+				if c == '\\' && quote == '"' {
+					i++
+				} else if c == quote {
+					quote = 0
+				}
+				continue
+			}
+			if c == '"' || c == '\'' {
+				quote = c
+			} else if c == ',' {
+				end = i
+				break
+			}
+		}
+		item, err := parseScalar(strings.TrimSpace(body[:end]))
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if end == len(body) {
+			break
+		}
+		body = body[end+1:]
+	}
+	return items, nil
+}
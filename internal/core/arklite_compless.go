@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -12,58 +13,112 @@ import (
 	"github.com/magicdrive/ark/internal/textbank"
 )
 
+// Compless rewrites the dump at path into the arklite compless form.
 func Compless(path string, format model.OutputFormat) error {
-
-	/*-----------*/
-	/* Read file */
-	/*-----------*/
-
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
 
-	lines := bytes.Split(data, []byte("\n"))
-
-	var compact bytes.Buffer
-	for _, line := range lines {
-		trim := bytes.TrimSpace(line)
-		if len(trim) == 0 {
-			continue
-		}
-		if compact.Len() > 0 {
-			compact.WriteString(newlineToken)
-		}
-		compact.Write(trim)
-	}
-
-	/*------------*/
-	/* Write file */
-	/*------------*/
-
 	outFile, err := os.Create(path)
 	if err != nil {
 		return err
 	}
 	defer outFile.Close()
 
-	writer := bufio.NewWriter(outFile)
-	defer writer.Flush()
-	expantionFilename := strings.TrimSuffix(path, ".arklite")
+	writer := NewComplessWriter(outFile, path, format)
+	if _, err := writer.Write(data); err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// complessWriter is the streaming form of Compless: the arklite header goes
+// out first, then every line written to it is trimmed, blank lines are
+// dropped and the rest are joined with ␤. Only the current line is buffered.
+type complessWriter struct {
+	writer  *bufio.Writer
+	path    string
+	format  model.OutputFormat
+	started bool
+	wrote   bool
+	line    []byte
+}
+
+// NewComplessWriter returns a writer that compacts everything written to it
+// into w. path names the dump in the header. Close flushes the last line but
+// does not close w.
+func NewComplessWriter(w io.Writer, path string, format model.OutputFormat) io.WriteCloser {
+	return &complessWriter{
+		writer: bufio.NewWriter(w),
+		path:   path,
+		format: format,
+	}
+}
 
-	abspath, _ := filepath.Abs(path)
+func (cw *complessWriter) writeHeader() {
+	expantionFilename := strings.TrimSuffix(cw.path, ".arklite")
+
+	abspath, _ := filepath.Abs(cw.path)
 	projectName := filepath.Base(abspath)
-	fmt.Fprintf(writer, textbank.ArkliteComplessHeaderTemplate, projectName, abspath, format.String())
-	writer.WriteString("## Directory Tree (JSON)\n")
-	fmt.Fprintf(writer, `{ "type": "file", "name": "%s" }`, expantionFilename)
-	writer.WriteString("\n")
-	writer.WriteString("\n")
-	writer.WriteString("## File Dump\n")
-	writer.WriteString("@")
-	writer.WriteString(expantionFilename)
-	writer.WriteByte('\n')
-	writer.Write(compact.Bytes())
-	writer.WriteByte('\n')
+	fmt.Fprintf(cw.writer, textbank.ArkliteComplessHeaderTemplate, projectName, abspath, cw.format.String())
+	cw.writer.WriteString("## Directory Tree (JSON)\n")
+	fmt.Fprintf(cw.writer, `{ "type": "file", "name": "%s" }`, expantionFilename)
+	cw.writer.WriteString("\n")
+	cw.writer.WriteString("\n")
+	cw.writer.WriteString("## File Dump\n")
+	cw.writer.WriteString("@")
+	cw.writer.WriteString(expantionFilename)
+	cw.writer.WriteByte('\n')
+	cw.started = true
+}
+
+func (cw *complessWriter) Write(p []byte) (int, error) {
+	if !cw.started {
+		cw.writeHeader()
+	}
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			cw.line = append(cw.line, p...)
+			return n, nil
+		}
+		cw.line = append(cw.line, p[:i]...)
+		cw.flushLine()
+		p = p[i+1:]
+	}
+}
 
-	return err
+func (cw *complessWriter) flushLine() {
+	trim := bytes.TrimSpace(cw.line)
+	if len(trim) > 0 {
+		if cw.wrote {
+			cw.writer.WriteString(newlineToken)
+		}
+		cw.writer.Write(trim)
+		cw.wrote = true
+	}
+	cw.line = cw.line[:0]
+}
+
+func (cw *complessWriter) Close() error {
+	if !cw.started {
+		cw.writeHeader()
+	}
+	cw.flushLine()
+	cw.writer.WriteByte('\n')
+	return cw.writer.Flush()
+}
+
+// complessStdoutName is the name a compless dump streamed to stdout expands to.
+func complessStdoutName(format model.OutputFormat) string {
+	switch format.String() {
+	case model.Markdown:
+		return "ark-output.md"
+	case model.XML:
+		return "ark-output.xml"
+	default:
+		return "ark-output.txt"
+	}
 }
@@ -146,3 +146,41 @@ func TestCompless_FileNotExist(t *testing.T) {
 		t.Error("Compless should fail for missing file")
 	}
 }
+
+func TestComplessWriter_MatchesCompless(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.txt.arklite")
+	content := "  first line  \n\n\tsecond\r\n   \nthird ␤ token\nno newline at end"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := core.Compless(path, model.PlainText); err != nil {
+		t.Fatalf("Compless failed: %v", err)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(want), "\nfirst line␤second␤third ␤ token␤no newline at end\n") {
+		t.Errorf("unexpected compact contents: %q", want)
+	}
+
+	// the result must not depend on how the input is cut into writes
+	for _, size := range []int{1, 3, 7, len(content)} {
+		var got strings.Builder
+		w := core.NewComplessWriter(&got, path, model.PlainText)
+		for rest := []byte(content); len(rest) > 0; {
+			n := min(size, len(rest))
+			if _, err := w.Write(rest[:n]); err != nil {
+				t.Fatal(err)
+			}
+			rest = rest[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got.String() != string(want) {
+			t.Errorf("write size %d: streamed output differs:\nwant %q\ngot  %q", size, want, got.String())
+		}
+	}
+}
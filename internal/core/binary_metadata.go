@@ -0,0 +1,128 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/textbank"
+)
+
+var binaryStubRegexp = regexp.MustCompile(`^\[binary file: [^\]\n]*\]\n?$`)
+
+// binaryFile is the metadata stub that --binary-metadata dumps in place of a
+// binary or image file.
+type binaryFile struct {
+	Stub   string
+	MIME   string
+	SHA256 string
+	Width  int
+	Height int
+	// DataURI is set for images embedded with --embed-images
+	DataURI string
+}
+
+// newBinaryFile describes data. Images up to embedLimit bytes carry a base64
+// data URI; an embedLimit of 0 embeds nothing.
+func newBinaryFile(fpath string, data []byte, embedLimit int) *binaryFile {
+	sum := sha256.Sum256(data)
+	b := &binaryFile{
+		MIME:   detectMIMEType(fpath, data),
+		SHA256: hex.EncodeToString(sum[:]),
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		b.Width, b.Height = cfg.Width, cfg.Height
+	}
+
+	details := []string{b.MIME, formatByteSize(int64(len(data)))}
+	if b.Width > 0 && b.Height > 0 {
+		details = append(details, fmt.Sprintf("%dx%d px", b.Width, b.Height))
+	}
+	details = append(details, "sha256 "+b.SHA256)
+	b.Stub = "[binary file: " + strings.Join(details, ", ") + "]"
+
+	if embedLimit > 0 && len(data) <= embedLimit && strings.HasPrefix(b.MIME, "image/") {
+		b.DataURI = "data:" + b.MIME + ";base64," + base64.StdEncoding.EncodeToString(data)
+	}
+	return b
+}
+
+// content is what the token budget measures for the stub.
+func (b *binaryFile) content() string {
+	if b.DataURI == "" {
+		return b.Stub
+	}
+	return b.Stub + "\n" + b.DataURI
+}
+
+// embedded returns the data URI to write, or "" when the token budget cut it.
+func (b *binaryFile) embedded(content string) string {
+	if content != b.content() {
+		return ""
+	}
+	return b.DataURI
+}
+
+// detectMIMEType trusts the extension first and sniffs the content otherwise.
+func detectMIMEType(fpath string, data []byte) string {
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(fpath)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return mediaType
+	}
+	return mimeType
+}
+
+// isBinaryStub reports whether a restored file body is only a binary stub,
+// which has nothing to restore.
+func isBinaryStub(content string) bool {
+	return binaryStubRegexp.MatchString(content)
+}
+
+// embedImagesLimit returns the --embed-images limit in bytes for the formats
+// that can show a data URI, 0 otherwise.
+func embedImagesLimit(opt *commandline.Option) int {
+	if opt.EmbedImagesValue == "" {
+		return 0
+	}
+	switch opt.OutputFormat.String() {
+	case model.Markdown, model.XML:
+	default:
+		return 0
+	}
+	limit, err := opt.EmbedImages.Bytes()
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// binaryNote returns the note of the description header on how binary files
+// are dumped in format.
+func binaryNote(format string, opt *commandline.Option) string {
+	if format == model.PlainText {
+		if opt.BinaryMetadataFlag {
+			return textbank.BinaryNoteStubsText
+		}
+		return textbank.BinaryNoteExcludedText
+	}
+	if opt.BinaryMetadataFlag {
+		return textbank.BinaryNoteStubs
+	}
+	return textbank.BinaryNoteExcluded
+}
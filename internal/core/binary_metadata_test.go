@@ -0,0 +1,190 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createBinaryTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "logo.png"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "blob.bin"), []byte{0x00, 0x01, 0x02}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	mustWriteFile(t, filepath.Join(root, "main.go"), "package main\n")
+	return root
+}
+
+func createBinaryOption(format string, embed string) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(format),
+		WithLineNumberFlag: model.OnOffSwitch("on"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+		BinaryMetadataFlag: true,
+		EmbedImagesValue:   embed,
+		EmbedImages:        model.ByteString(embed),
+	}
+}
+
+func TestWriteAllFiles_BinaryMetadata(t *testing.T) {
+	root := createBinaryTree(t)
+	out := filepath.Join(t.TempDir(), "out.txt")
+
+	if err := core.WriteAllFiles("tree", root, out, nil, createBinaryOption(model.PlainText, "")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	output := string(data)
+
+	// ae4b3280... is the SHA-256 of the bytes 00 01 02
+	expected := "=== " + filepath.Join(root, "blob.bin") + " ===\n" +
+		"[binary file: application/octet-stream, 3 B, sha256 ae4b3280e56e2faf83f414a6e3dabe9d5fbe18976544c05fed121accb85b53fc]\n"
+	if !strings.Contains(output, expected) {
+		t.Errorf("missing stub for blob.bin:\n%s", output)
+	}
+	if !strings.Contains(output, "[binary file: image/png, ") || !strings.Contains(output, ", 3x2 px, sha256 ") {
+		t.Errorf("missing image stub:\n%s", output)
+	}
+	if !strings.Contains(output, "Binary and image files are listed as metadata stubs.") {
+		t.Errorf("the header note must mention the stubs:\n%s", output)
+	}
+
+	// without the flag the files are skipped as before
+	opt := createBinaryOption(model.PlainText, "")
+	opt.BinaryMetadataFlag = false
+	if err := core.WriteAllFiles("tree", root, out, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	if strings.Contains(string(data), "[binary file:") {
+		t.Errorf("stubs must only be written with --binary-metadata")
+	}
+	if !strings.Contains(string(data), "Note: Only UTF-8 text files are included. Binary and image files are excluded.") {
+		t.Errorf("the header note must be unchanged without --binary-metadata:\n%s", data)
+	}
+}
+
+func TestWriteAllFiles_EmbedImages(t *testing.T) {
+	root := createBinaryTree(t)
+
+	out := filepath.Join(t.TempDir(), "out.md")
+	if err := core.WriteAllFiles("tree", root, out, nil, createBinaryOption(model.Markdown, "1K")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if !strings.Contains(string(data), "\n![logo.png](data:image/png;base64,iVBORw0KGgo") {
+		t.Errorf("markdown must embed the image:\n%s", data)
+	}
+	if !strings.Contains(string(data), "**Note:** Binary files are listed as metadata stubs. Non-UTF-8 files are excluded.") {
+		t.Errorf("the markdown note must mention the stubs:\n%s", data)
+	}
+
+	out = filepath.Join(t.TempDir(), "out.xml")
+	if err := core.WriteAllFilesAsXML("tree", root, out, nil, createBinaryOption(model.XML, "1K")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	if !strings.Contains(string(data), `width="3" height="2">data:image/png;base64,`) {
+		t.Errorf("xml must embed the image:\n%s", data)
+	}
+	if !strings.Contains(string(data), "<Note>Binary files are listed as metadata stubs. Non-UTF-8 files are excluded.</Note>") {
+		t.Errorf("the xml note must mention the stubs:\n%s", data)
+	}
+	if !strings.Contains(string(data), `<binary name="blob.bin" mime="application/octet-stream" size="3" sha256="ae4b3280`) {
+		t.Errorf("xml must describe blob.bin:\n%s", data)
+	}
+
+	// images larger than the limit are described only
+	out = filepath.Join(t.TempDir(), "out.xml")
+	if err := core.WriteAllFilesAsXML("tree", root, out, nil, createBinaryOption(model.XML, "10B")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	if strings.Contains(string(data), "data:image/png") {
+		t.Errorf("an image over --embed-images must not be embedded")
+	}
+}
+
+func TestWriteAllFilesAsJSONL_BinaryMetadata(t *testing.T) {
+	root := createBinaryTree(t)
+	out := filepath.Join(t.TempDir(), "out.jsonl")
+	if err := core.WriteAllFilesAsJSONL(root, out, nil, createBinaryOption(model.JSONL, "")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+
+	var logo core.JSONFileEntry
+	for line := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+		var entry core.JSONFileEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Path == "logo.png" {
+			logo = entry
+		}
+	}
+	if !logo.Binary || logo.MIME != "image/png" || logo.Width != 3 || logo.Height != 2 || len(logo.SHA256) != 64 || logo.Content != "" {
+		t.Errorf("unexpected entry for logo.png: %+v", logo)
+	}
+}
+
+func TestDecodeDump_SkipsBinaryStubs(t *testing.T) {
+	root := createBinaryTree(t)
+
+	writers := map[string]func(out string, opt *commandline.Option) error{
+		model.PlainText: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, nil, opt)
+		},
+		model.Markdown: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, nil, opt)
+		},
+		model.XML: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsXML("tree", root, out, nil, opt)
+		},
+		model.Arklite: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsArklite("{}", root, out, nil, opt)
+		},
+		model.JSONL: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSONL(root, out, nil, opt)
+		},
+	}
+
+	for format, write := range writers {
+		out := filepath.Join(t.TempDir(), "out")
+		opt := createBinaryOption(format, "")
+		opt.WithLineNumberFlag = model.OnOffSwitch("off")
+		if err := write(out, opt); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		data, _ := os.ReadFile(out)
+		files, err := core.DecodeDump(data)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if len(files) != 1 || files[0].Path != "main.go" {
+			var paths []string
+			for _, f := range files {
+				paths = append(paths, f.Path)
+			}
+			t.Errorf("%s: only main.go must be restored, got %v", format, paths)
+		}
+	}
+}
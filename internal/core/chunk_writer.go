@@ -0,0 +1,368 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/tokenizer"
+)
+
+// ChunkManifest lists which files landed in which chunk of a split dump.
+type ChunkManifest struct {
+	Output string               `json:"output"`
+	Chunks []*ChunkManifestItem `json:"chunks"`
+}
+
+type ChunkManifestItem struct {
+	Filename string   `json:"filename"`
+	Bytes    int      `json:"bytes"`
+	Tokens   int      `json:"tokens,omitempty"`
+	Files    []string `json:"files"`
+	Split    []string `json:"split,omitempty"`
+}
+
+// chunkWriter writes a dump either to a single file or, when a split size is
+// set, to numbered chunk files that never break a file section unless the
+// section alone exceeds the chunk size.
+type chunkWriter struct {
+	outputPath string
+	root       string
+	header     string
+	reopen     func() string
+	footer     func() string
+
+	// continuation returns the text that ends a section split across chunks
+	// and the text that resumes it at the top of the next chunk.
+	continuation func(fpath string) (end string, begin string)
+	// splitAfter is the separator an oversized section may be split after.
+	splitAfter string
+	// separator is written between two sections of the same chunk.
+	separator string
+
+	limit     int
+	tokenizer tokenizer.Tokenizer
+	// limitFlag names the option the limit comes from, for error messages
+	limitFlag string
+	// checked is set once the limit is known to hold a chunk's overhead
+	checked bool
+
+	// compless compacts every chunk on the fly (--compless).
+	compless bool
+	format   model.OutputFormat
+
+	file     *os.File
+	compact  io.WriteCloser
+	writer   *bufio.Writer
+	size     int
+	empty    bool
+	manifest *ChunkManifest
+	current  *ChunkManifestItem
+}
+
+// newChunkWriter creates the first output file. header is repeated at the top
+// of every chunk, reopen is written after the header from the second chunk on,
+// and footer closes every chunk.
+func newChunkWriter(outputPath string, root string, header string, opt *commandline.Option) (*chunkWriter, error) {
+	cw := &chunkWriter{
+		outputPath: outputPath,
+		root:       root,
+		header:     header,
+		splitAfter: "\n",
+		compless:   opt.ComplessFlag && opt.OutputFormat.CanCompless(),
+		format:     opt.OutputFormat,
+		manifest:   &ChunkManifest{Output: filepath.Base(outputPath)},
+	}
+
+	if opt.SplitTokens > 0 {
+		tk, err := tokenizer.New(opt.Tokenizer.String(), opt.TokenizerFile)
+		if err != nil {
+			return nil, err
+		}
+		cw.tokenizer = tk
+		cw.limit = opt.SplitTokens
+		cw.limitFlag = fmt.Sprintf("--split-tokens %d", opt.SplitTokens)
+	} else if opt.SplitSizeValue != "" {
+		limit, err := opt.SplitSize.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		cw.limit = limit
+		cw.limitFlag = "--split-size " + opt.SplitSizeValue
+	}
+
+	if err := cw.open(); err != nil {
+		return nil, err
+	}
+	return cw, nil
+}
+
+// ChunkFilename returns the numbered name of a chunk: ark-output.txt -> ark-output.001.txt
+func ChunkFilename(outputPath string, index int) string {
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s.%03d%s", strings.TrimSuffix(outputPath, ext), index, ext)
+}
+
+// ManifestFilename returns the manifest name of a split dump: ark-output.txt -> ark-output.manifest.json
+func ManifestFilename(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".manifest.json"
+}
+
+// isStdout reports whether the dump is streamed to stdout instead of a file.
+func (cw *chunkWriter) isStdout() bool {
+	return cw.outputPath == commandline.StdoutFilename
+}
+
+func (cw *chunkWriter) isSplit() bool {
+	return cw.limit > 0
+}
+
+func (cw *chunkWriter) measure(s string) int {
+	if cw.tokenizer != nil {
+		return cw.tokenizer.Count(s)
+	}
+	return len(s)
+}
+
+func (cw *chunkWriter) open() error {
+	name := cw.outputPath
+	if cw.isSplit() {
+		name = ChunkFilename(cw.outputPath, len(cw.manifest.Chunks)+1)
+	}
+	var out io.Writer = os.Stdout
+	cw.file = nil
+	if !cw.isStdout() {
+		f, err := os.Create(name)
+		if err != nil {
+			return err
+		}
+		cw.file = f
+		out = f
+	}
+	cw.compact = nil
+	if cw.compless {
+		complessName := name
+		if cw.isStdout() {
+			complessName = complessStdoutName(cw.format)
+		}
+		cw.compact = NewComplessWriter(out, complessName, cw.format)
+		out = cw.compact
+	}
+	cw.writer = bufio.NewWriter(out)
+	cw.size = 0
+	cw.empty = true
+	cw.current = &ChunkManifestItem{Filename: filepath.Base(name), Files: []string{}}
+	cw.manifest.Chunks = append(cw.manifest.Chunks, cw.current)
+
+	cw.write(cw.header)
+	if len(cw.manifest.Chunks) > 1 && cw.reopen != nil {
+		cw.write(cw.reopen())
+	}
+	return nil
+}
+
+func (cw *chunkWriter) closeChunk() error {
+	if cw.footer != nil {
+		cw.write(cw.footer())
+	}
+	err := cw.writer.Flush()
+	if cw.compact != nil && err == nil {
+		err = cw.compact.Close()
+	}
+	if cw.file != nil {
+		if cerr := cw.file.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+func (cw *chunkWriter) rotate() error {
+	if err := cw.closeChunk(); err != nil {
+		return err
+	}
+	return cw.open()
+}
+
+func (cw *chunkWriter) write(s string) {
+	cw.writer.WriteString(s)
+	cw.current.Bytes += len(s)
+	if cw.tokenizer != nil {
+		cw.current.Tokens += cw.tokenizer.Count(s)
+	}
+	cw.size += cw.measure(s)
+}
+
+// WriteString writes structural output (tree, directory tags) to the current chunk.
+func (cw *chunkWriter) WriteString(s string) {
+	cw.write(s)
+}
+
+// WriteOpening writes open, which starts an element that footer closes with
+// close and reopen repeats once the caller has recorded it, such as an xml
+// <directory> tag. When the element does not fit with the footer, the next
+// chunk is started first.
+func (cw *chunkWriter) WriteOpening(open string, close string) error {
+	if cw.isSplit() && !cw.empty && cw.size+cw.measure(open+close)+cw.footerSize() > cw.limit {
+		if err := cw.rotate(); err != nil {
+			return err
+		}
+	}
+	cw.write(open)
+	return nil
+}
+
+// footerSize is what closing the current chunk adds to it, which every fit
+// check reserves so that a chunk stays within the limit.
+func (cw *chunkWriter) footerSize() int {
+	if cw.footer == nil {
+		return 0
+	}
+	return cw.measure(cw.footer())
+}
+
+// WriteSection writes the whole output of one file, moving to a new chunk
+// first when the section does not fit into the current one.
+func (cw *chunkWriter) WriteSection(fpath string, section string) error {
+	rel := cw.relPath(fpath)
+	if !cw.isSplit() {
+		cw.write(cw.sectionSeparator())
+		cw.write(section)
+		cw.current.Files = append(cw.current.Files, rel)
+		return nil
+	}
+
+	if err := cw.checkLimit(fpath); err != nil {
+		return err
+	}
+	footer := cw.footerSize()
+	n := cw.measure(cw.sectionSeparator() + section)
+	if cw.size+n+footer > cw.limit && !cw.empty {
+		if err := cw.rotate(); err != nil {
+			return err
+		}
+		n = cw.measure(section)
+	}
+	section = cw.sectionSeparator() + section
+
+	if cw.size+n+footer <= cw.limit {
+		cw.write(section)
+		cw.current.Files = append(cw.current.Files, rel)
+		cw.empty = false
+		return nil
+	}
+
+	// a single section larger than a chunk: split it on line boundaries,
+	// leaving room for the end marker and the footer in every chunk
+	end, begin := "", ""
+	if cw.continuation != nil {
+		end, begin = cw.continuation(fpath)
+	}
+	reserved := cw.measure(end) + footer
+	var piece strings.Builder
+	pieceSize := 0
+	hasLine := false
+	for line := range strings.SplitAfterSeq(section, cw.splitAfter) {
+		ln := cw.measure(line)
+		if hasLine && cw.size+pieceSize+ln+reserved > cw.limit {
+			piece.WriteString(end)
+			cw.write(piece.String())
+			cw.current.Files = append(cw.current.Files, rel)
+			cw.current.Split = append(cw.current.Split, rel)
+			piece.Reset()
+			if err := cw.rotate(); err != nil {
+				return err
+			}
+			piece.WriteString(begin)
+			pieceSize = cw.measure(begin)
+			hasLine = false
+		}
+		piece.WriteString(line)
+		pieceSize += ln
+		hasLine = true
+	}
+	if piece.Len() > 0 {
+		cw.write(piece.String())
+		cw.current.Files = append(cw.current.Files, rel)
+		cw.current.Split = append(cw.current.Split, rel)
+	}
+	cw.empty = false
+	return nil
+}
+
+// checkLimit makes sure a chunk can hold what every chunk repeats, the
+// header, reopen and footer text and the markers of a split section, and
+// still have room for a line. A smaller limit would start a new chunk for
+// every line.
+func (cw *chunkWriter) checkLimit(fpath string) error {
+	if cw.checked {
+		return nil
+	}
+	overhead := cw.header
+	if cw.reopen != nil {
+		overhead += cw.reopen()
+	}
+	if cw.footer != nil {
+		overhead += cw.footer()
+	}
+	if cw.continuation != nil {
+		end, begin := cw.continuation(fpath)
+		overhead += begin + end
+	}
+	if n := cw.measure(overhead); cw.limit <= n {
+		unit := "bytes"
+		if cw.tokenizer != nil {
+			unit = "tokens"
+		}
+		return fmt.Errorf("%s is too small: every chunk repeats %d %s of header and markers, so a chunk must be larger than that to hold a line", cw.limitFlag, n, unit)
+	}
+	cw.checked = true
+	return nil
+}
+
+func (cw *chunkWriter) sectionSeparator() string {
+	if len(cw.current.Files) == 0 {
+		return ""
+	}
+	return cw.separator
+}
+
+func (cw *chunkWriter) relPath(fpath string) string {
+	rel, err := filepath.Rel(cw.root, fpath)
+	if err != nil {
+		return filepath.ToSlash(fpath)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// Close finishes the last chunk and, for split dumps, writes the manifest.
+// It returns the names of every written output file.
+func (cw *chunkWriter) Close() ([]string, error) {
+	if err := cw.closeChunk(); err != nil {
+		return nil, err
+	}
+	if !cw.isSplit() {
+		return []string{cw.outputPath}, nil
+	}
+
+	dir := filepath.Dir(cw.outputPath)
+	var files []string
+	for _, c := range cw.manifest.Chunks {
+		files = append(files, filepath.Join(dir, c.Filename))
+	}
+
+	data, err := json.MarshalIndent(cw.manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(ManifestFilename(cw.outputPath), append(data, '\n'), 0644); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
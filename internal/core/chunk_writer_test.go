@@ -0,0 +1,223 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestChunkFilename(t *testing.T) {
+	if got := core.ChunkFilename("out/ark_output.txt", 2); got != "out/ark_output.002.txt" {
+		t.Errorf("ChunkFilename() = %q", got)
+	}
+	if got := core.ManifestFilename("out/ark_output.txt"); got != "out/ark_output.manifest.json" {
+		t.Errorf("ManifestFilename() = %q", got)
+	}
+}
+
+func readManifest(t *testing.T, outFile string) core.ChunkManifest {
+	t.Helper()
+	data, err := os.ReadFile(core.ManifestFilename(outFile))
+	if err != nil {
+		t.Fatalf("manifest not written: %v", err)
+	}
+	var m core.ChunkManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestWriteAllFiles_SplitSize(t *testing.T) {
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(strings.Repeat("some line of text\n", 30)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+
+	opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+	opt.SplitSizeValue = "2k"
+	opt.SplitSize = model.ByteString("2k")
+	if err := core.WriteAllFiles("tree", root, outFile, allowed, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(outFile); !os.IsNotExist(err) {
+		t.Errorf("unsplit output file must not be written")
+	}
+	m := readManifest(t, outFile)
+	if len(m.Chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(m.Chunks))
+	}
+
+	seen := map[string]int{}
+	for i, c := range m.Chunks {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(outFile), c.Filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Filename != filepath.Base(core.ChunkFilename(outFile, i+1)) {
+			t.Errorf("unexpected chunk name %s", c.Filename)
+		}
+		if len(data) > 2048 {
+			t.Errorf("chunk %s exceeds split size: %d bytes", c.Filename, len(data))
+		}
+		for _, f := range c.Files {
+			seen[f]++
+		}
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		if seen[name] == 0 {
+			t.Errorf("%s is missing from the manifest", name)
+		}
+	}
+}
+
+func TestWriteAllFiles_SplitOversizedSection(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big.txt")
+	if err := os.WriteFile(big, []byte(strings.Repeat("0123456789\n", 300)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(t.TempDir(), "out.md")
+
+	opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+	opt.OutputFormat = model.OutputFormat(model.Markdown)
+	opt.SplitSizeValue = "1k"
+	opt.SplitSize = model.ByteString("1k")
+	if err := core.WriteAllFiles("tree", root, outFile, map[string]bool{big: true}, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	m := readManifest(t, outFile)
+	if len(m.Chunks) < 3 {
+		t.Fatalf("expected the big file to span several chunks, got %d", len(m.Chunks))
+	}
+	second, err := os.ReadFile(filepath.Join(filepath.Dir(outFile), m.Chunks[1].Filename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(second), "big.txt (continued)") {
+		t.Errorf("expected continuation marker in second chunk:\n%s", second)
+	}
+	if len(m.Chunks[1].Split) == 0 {
+		t.Errorf("expected split files to be listed in the manifest")
+	}
+}
+
+func TestWriteAllFiles_SplitSizeTooSmall(t *testing.T) {
+	root := t.TempDir()
+	p := filepath.Join(root, "a.txt")
+	if err := os.WriteFile(p, []byte(strings.Repeat("some line of text\n", 300)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outDir := t.TempDir()
+	outFile := filepath.Join(outDir, "out.txt")
+
+	opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+	opt.SplitSizeValue = "100"
+	opt.SplitSize = model.ByteString("100")
+	err := core.WriteAllFiles("tree", root, outFile, map[string]bool{p: true}, opt)
+	if err == nil || !strings.Contains(err.Error(), "--split-size 100 is too small") {
+		t.Fatalf("expected a --split-size error, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outDir); len(entries) > 2 {
+		t.Errorf("a too small chunk size must not write a chunk per line, got %d files", len(entries))
+	}
+}
+
+func TestWriteAll_SplitChunksStayWithinLimit(t *testing.T) {
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for _, name := range []string{"a.txt", "src/b.txt", "src/deep/c.txt", "src/deep/er/d.txt", "z.txt"} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(p), 0755)
+		if err := os.WriteFile(p, []byte(strings.Repeat("some line of text\n", 12)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	// larger than a chunk, so it is split with end and begin markers; a json
+	// entry is a single line and cannot be split
+	big := filepath.Join(root, "src", "big.txt")
+	os.WriteFile(big, []byte(strings.Repeat("0123456789\n", 300)), 0644)
+
+	for _, format := range []string{model.XML, model.JSON, model.PlainText} {
+		allowed[big] = format != model.JSON
+		outFile := filepath.Join(t.TempDir(), "out."+format)
+		opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+		opt.OutputFormat = model.OutputFormat(format)
+		opt.SplitSizeValue = "1000"
+		opt.SplitSize = model.ByteString("1000")
+		var err error
+		switch format {
+		case model.XML:
+			err = core.WriteAllFilesAsXML("tree", root, outFile, allowed, opt)
+		case model.JSON:
+			err = core.WriteAllFilesAsJSON("{}", root, outFile, allowed, opt)
+		default:
+			err = core.WriteAllFiles("tree", root, outFile, allowed, opt)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		m := readManifest(t, outFile)
+		if len(m.Chunks) < 3 {
+			t.Fatalf("%s: expected several chunks, got %d", format, len(m.Chunks))
+		}
+		for _, c := range m.Chunks {
+			data, err := os.ReadFile(filepath.Join(filepath.Dir(outFile), c.Filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(data) > 1000 {
+				t.Errorf("%s: chunk %s is %d bytes, over --split-size 1000", format, c.Filename, len(data))
+			}
+		}
+	}
+}
+
+func TestWriteAllFilesAsXML_SplitIsWellFormed(t *testing.T) {
+	root, allowed := createBudgetTestFiles(t)
+	outFile := filepath.Join(t.TempDir(), "out.xml")
+
+	opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+	opt.OutputFormat = model.OutputFormat(model.XML)
+	opt.SplitSizeValue = "1k"
+	opt.SplitSize = model.ByteString("1k")
+	if err := core.WriteAllFilesAsXML("tree", root, outFile, allowed, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range readManifest(t, outFile).Chunks {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(outFile), c.Filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Errorf("chunk %s is not well-formed XML: %v", c.Filename, err)
+				break
+			}
+		}
+	}
+}
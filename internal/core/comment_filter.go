@@ -2,115 +2,1044 @@ package core
 
 import (
 	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
 )
 
-type commentPattern struct {
-	linePrefixes []string
-	blockDelims  []struct{ start, end string }
-}
-
-func getCommentDelimiters(lang string) commentPattern {
-	switch lang {
-	case "markdown", "text":
-		return commentPattern{}
-	case "bash", "sh", "zsh", "dockerfile", "makefile", "cmake", "groovy", "abap", "ada", "ahk", "apache", "applescript", "as", "powershell", "r", "ruby", "perl", "el", "latex":
-		return commentPattern{linePrefixes: []string{"#"}}
-	case "vim":
-		return commentPattern{linePrefixes: []string{"\""}} // Vim uses double quote for comments
-	case "python", "yaml", "toml", "ini":
-		return commentPattern{linePrefixes: []string{"#"}}
-	case "go", "java", "c", "cpp", "csharp", "javascript", "typescript", "ts", "tsx", "jsx", "rust", "kotlin", "swift", "dart", "coffeescript":
-		return commentPattern{
-			linePrefixes: []string{"//"},
-			blockDelims: []struct{ start, end string }{
-				{"/*", "*/"},
-			},
-		}
-	case "html", "xml", "vue":
-		return commentPattern{
-			blockDelims: []struct{ start, end string }{
-				{"<!--", "-->"},
-			},
-		}
-	case "php":
-		return commentPattern{
-			linePrefixes: []string{"//", "#"},
-			blockDelims: []struct{ start, end string }{
-				{"/*", "*/"},
-			},
-		}
-	case "scala", "haskell", "clojure", "lisp", "elixir":
-		return commentPattern{linePrefixes: []string{"--", ";"}}
-	case "sql":
-		return commentPattern{linePrefixes: []string{"--"}}
-	case "css", "less", "scss":
-		return commentPattern{
-			blockDelims: []struct{ start, end string }{
-				{"/*", "*/"},
-			},
-		}
-	case "json", "bf":
-		return commentPattern{}
-	case "objectivec":
-		return commentPattern{
-			linePrefixes: []string{"//"},
-			blockDelims: []struct{ start, end string }{
-				{"/*", "*/"},
-			},
-		}
-	default:
-		return commentPattern{}
-	}
-}
-
-func stripComments(data []byte, pattern commentPattern) []byte {
-	for _, d := range pattern.blockDelims {
-		data = stripBlockByDelimiter(data, d.start, d.end)
-	}
-
-	lines := bytes.Split(data, []byte("\n"))
-	var result [][]byte
-
-LINE:
-	for _, line := range lines {
-		trim := bytes.TrimSpace(line)
-		if len(trim) == 0 {
+// blockComment is a comment between two delimiters. Nested ones count their
+// openings, as in Rust, Swift and Haskell.
+type blockComment struct {
+	start, end string
+	nested     bool
+	// atLineStart blocks open and close at column 0 and run to the end of
+	// the closing line: =begin ... =end, =pod ... =cut
+	atLineStart bool
+}
+
+// stringLiteral is a string between two delimiters. escape is the byte that
+// escapes the next one, 0 when there is none.
+type stringLiteral struct {
+	start, end string
+	escape     byte
+	multiline  bool
+}
+
+type heredocStyle int
+
+const (
+	noHeredoc    heredocStyle = iota
+	shellHeredoc              // <<EOF, <<-EOF, <<'EOF'
+	rubyHeredoc               // <<~EOS, <<-EOS, <<"EOS", also Perl
+	phpHeredoc                // <<<EOT, <<<'EOT'
+)
+
+type rawStringStyle int
+
+const (
+	noRawString    rawStringStyle = iota
+	rustRawString                 // r"...", r#"..."#, br#"..."#
+	cppRawString                  // R"delim(...)delim"
+	swiftRawString                // #"..."#, #"""..."""#
+)
+
+// commentSyntax is what the comment lexer knows about a language.
+type commentSyntax struct {
+	lineComments []string
+	// lineStartComments only start a comment as the first thing on a line
+	lineStartComments []string
+	blockComments     []blockComment
+	// strings are tried in order, so longer delimiters come first
+	strings []stringLiteral
+	// docPrefixes mark the comments --keep-doc-comments keeps
+	docPrefixes []string
+	// directives are comments that change what the code means, always kept
+	directives []string
+	// wordStart line comments only start a word: $# and a#b are not comments
+	wordStart bool
+	// escapes are the bytes that escape the next one outside strings
+	escapes string
+	heredoc heredocStyle
+	raw     rawStringStyle
+	// quoteChars: ' is a char literal only when it closes right away, which
+	// tells Rust lifetimes and Haskell primes apart
+	quoteChars bool
+	// templates are JavaScript `...${expr}...` literals
+	templates bool
+	// regexps are JavaScript /re/ literals
+	regexps bool
+	// haskellDashes: -- is an operator when followed by a symbol, as in -->
+	haskellDashes bool
+	// luaBrackets are the [[ ]] strings and --[[ ]] comments of Lua
+	luaBrackets bool
+	// goDocs keeps the comments right above package and top-level declarations
+	goDocs bool
+	// yaml: | and > block scalars are literal, quotes only start a scalar
+	yaml bool
+	// phpAttributes: #[ starts an attribute, not a comment
+	phpAttributes bool
+}
+
+var (
+	cStyleBlock  = []blockComment{{start: "/*", end: "*/"}}
+	nestedBlock  = []blockComment{{start: "/*", end: "*/", nested: true}}
+	doubleQuoted = stringLiteral{start: `"`, end: `"`, escape: '\\'}
+	singleQuoted = stringLiteral{start: `'`, end: `'`, escape: '\\'}
+	tripleDouble = stringLiteral{start: `"""`, end: `"""`, escape: '\\', multiline: true}
+	tripleSingle = stringLiteral{start: `'''`, end: `'''`, escape: '\\', multiline: true}
+	cssURL       = stringLiteral{start: "url(", end: ")"}
+	cDocs        = []string{"/**", "/*!", "///", "//!"}
+	javaDocs     = []string{"/**"}
+)
+
+var cSyntax = &commentSyntax{
+	lineComments:  []string{"//"},
+	blockComments: cStyleBlock,
+	strings:       []stringLiteral{doubleQuoted, singleQuoted},
+	docPrefixes:   cDocs,
+}
+
+var cppSyntax = &commentSyntax{
+	lineComments:  []string{"//"},
+	blockComments: cStyleBlock,
+	strings:       []stringLiteral{doubleQuoted, singleQuoted},
+	docPrefixes:   cDocs,
+	raw:           cppRawString,
+}
+
+var javaScriptSyntax = &commentSyntax{
+	lineComments:  []string{"//"},
+	blockComments: cStyleBlock,
+	strings:       []stringLiteral{doubleQuoted, singleQuoted},
+	docPrefixes:   javaDocs,
+	templates:     true,
+	regexps:       true,
+}
+
+var shellSyntax = &commentSyntax{
+	lineComments: []string{"#"},
+	strings: []stringLiteral{
+		{start: `"`, end: `"`, escape: '\\', multiline: true},
+		{start: `'`, end: `'`, multiline: true},
+		{start: "`", end: "`", escape: '\\', multiline: true},
+	},
+	wordStart: true,
+	escapes:   `\`,
+	heredoc:   shellHeredoc,
+}
+
+var hashSyntax = &commentSyntax{
+	lineComments: []string{"#"},
+	strings:      []stringLiteral{doubleQuoted, singleQuoted},
+}
+
+var lispSyntax = &commentSyntax{
+	lineComments:  []string{";"},
+	blockComments: []blockComment{{start: "#|", end: "|#", nested: true}},
+	strings:       []stringLiteral{{start: `"`, end: `"`, escape: '\\', multiline: true}},
+	escapes:       `\`,
+}
+
+var markupSyntax = &commentSyntax{
+	blockComments: []blockComment{{start: "<!--", end: "-->"}},
+}
+
+// commentSyntaxes maps the language tags of detectLanguageTag to their syntax.
+// Languages without an entry keep their comments.
+var commentSyntaxes = map[string]*commentSyntax{
+	"c":          cSyntax,
+	"objectivec": cSyntax,
+	"cpp":        cppSyntax,
+	"go": {
+		lineComments:  []string{"//"},
+		blockComments: cStyleBlock,
+		strings: []stringLiteral{
+			{start: "`", end: "`", multiline: true},
+			doubleQuoted,
+			singleQuoted,
+		},
+		directives: []string{"//go:", "// +build", "//line ", "//export "},
+		goDocs:     true,
+	},
+	"java": {
+		lineComments:  []string{"//"},
+		blockComments: cStyleBlock,
+		strings:       []stringLiteral{tripleDouble, doubleQuoted, singleQuoted},
+		docPrefixes:   javaDocs,
+	},
+	"csharp": {
+		lineComments:  []string{"//"},
+		blockComments: cStyleBlock,
+		strings: []stringLiteral{
+			{start: `"""`, end: `"""`, multiline: true},
+			{start: `@$"`, end: `"`, multiline: true},
+			{start: `@"`, end: `"`, multiline: true},
+			doubleQuoted,
+			singleQuoted,
+		},
+		docPrefixes: []string{"///", "/**"},
+	},
+	"kotlin": {
+		lineComments:  []string{"//"},
+		blockComments: nestedBlock,
+		strings:       []stringLiteral{{start: `"""`, end: `"""`, multiline: true}, doubleQuoted, singleQuoted},
+		docPrefixes:   javaDocs,
+	},
+	"scala": {
+		lineComments:  []string{"//"},
+		blockComments: nestedBlock,
+		strings:       []stringLiteral{{start: `"""`, end: `"""`, multiline: true}, doubleQuoted, singleQuoted},
+		docPrefixes:   javaDocs,
+	},
+	"groovy": {
+		lineComments:  []string{"//"},
+		blockComments: cStyleBlock,
+		strings:       []stringLiteral{tripleDouble, tripleSingle, doubleQuoted, singleQuoted},
+		docPrefixes:   javaDocs,
+	},
+	"dart": {
+		lineComments:  []string{"//"},
+		blockComments: nestedBlock,
+		strings:       []stringLiteral{tripleDouble, tripleSingle, doubleQuoted, singleQuoted},
+		docPrefixes:   []string{"///", "/**"},
+	},
+	"swift": {
+		lineComments:  []string{"//"},
+		blockComments: nestedBlock,
+		strings:       []stringLiteral{tripleDouble, doubleQuoted},
+		docPrefixes:   []string{"///", "/**"},
+		raw:           swiftRawString,
+	},
+	"rust": {
+		lineComments:  []string{"//"},
+		blockComments: nestedBlock,
+		strings:       []stringLiteral{{start: `"`, end: `"`, escape: '\\', multiline: true}},
+		docPrefixes:   []string{"///", "//!", "/**", "/*!"},
+		raw:           rustRawString,
+		quoteChars:    true,
+	},
+	"javascript":   javaScriptSyntax,
+	"typescript":   javaScriptSyntax,
+	"jsx":          javaScriptSyntax,
+	"tsx":          javaScriptSyntax,
+	"actionscript": {lineComments: []string{"//"}, blockComments: cStyleBlock, strings: []stringLiteral{doubleQuoted, singleQuoted}, docPrefixes: javaDocs},
+	"php": {
+		lineComments:  []string{"//", "#"},
+		blockComments: cStyleBlock,
+		strings:       []stringLiteral{{start: `"`, end: `"`, escape: '\\', multiline: true}, {start: `'`, end: `'`, escape: '\\', multiline: true}},
+		docPrefixes:   javaDocs,
+		heredoc:       phpHeredoc,
+		phpAttributes: true,
+	},
+	"css":  {blockComments: cStyleBlock, strings: []stringLiteral{doubleQuoted, singleQuoted, cssURL}},
+	"scss": {lineComments: []string{"//"}, blockComments: cStyleBlock, strings: []stringLiteral{doubleQuoted, singleQuoted, cssURL}},
+	"less": {lineComments: []string{"//"}, blockComments: cStyleBlock, strings: []stringLiteral{doubleQuoted, singleQuoted, cssURL}},
+	"python": {
+		lineComments: []string{"#"},
+		strings:      []stringLiteral{tripleDouble, tripleSingle, doubleQuoted, singleQuoted},
+	},
+	"ruby": {
+		lineComments:  []string{"#"},
+		blockComments: []blockComment{{start: "=begin", end: "=end", atLineStart: true}},
+		strings: []stringLiteral{
+			{start: `"`, end: `"`, escape: '\\', multiline: true},
+			{start: `'`, end: `'`, escape: '\\', multiline: true},
+			{start: "`", end: "`", escape: '\\', multiline: true},
+		},
+		heredoc: rubyHeredoc,
+	},
+	"perl": {
+		lineComments: []string{"#"},
+		blockComments: []blockComment{
+			{start: "=pod", end: "=cut", atLineStart: true},
+			{start: "=head", end: "=cut", atLineStart: true},
+			{start: "=begin", end: "=cut", atLineStart: true},
+			{start: "=over", end: "=cut", atLineStart: true},
+		},
+		strings: []stringLiteral{
+			{start: `"`, end: `"`, escape: '\\', multiline: true},
+			{start: `'`, end: `'`, escape: '\\', multiline: true},
+		},
+		wordStart: true,
+		heredoc:   rubyHeredoc,
+	},
+	"bash":   shellSyntax,
+	"r":      hashSyntax,
+	"elixir": {lineComments: []string{"#"}, strings: []stringLiteral{tripleDouble, tripleSingle, doubleQuoted, singleQuoted}},
+	"coffeescript": {
+		lineComments:  []string{"#"},
+		blockComments: []blockComment{{start: "###", end: "###"}},
+		strings:       []stringLiteral{tripleDouble, tripleSingle, doubleQuoted, singleQuoted},
+	},
+	"powershell": {
+		lineComments:  []string{"#"},
+		blockComments: []blockComment{{start: "<#", end: "#>"}},
+		strings: []stringLiteral{
+			{start: `@"`, end: `"@`, multiline: true},
+			{start: `@'`, end: `'@`, multiline: true},
+			{start: `"`, end: `"`, escape: '`', multiline: true},
+			{start: `'`, end: `'`, multiline: true},
+		},
+		wordStart: true,
+		escapes:   "`",
+	},
+	"yaml": {
+		lineComments: []string{"#"},
+		strings:      []stringLiteral{doubleQuoted, {start: `'`, end: `'`}},
+		wordStart:    true,
+		yaml:         true,
+	},
+	"toml": {
+		lineComments: []string{"#"},
+		strings: []stringLiteral{
+			tripleDouble,
+			{start: `'''`, end: `'''`, multiline: true},
+			doubleQuoted,
+			{start: `'`, end: `'`},
+		},
+	},
+	"ini":        {lineStartComments: []string{";", "#"}},
+	"apache":     {lineStartComments: []string{"#"}},
+	"dockerfile": {lineStartComments: []string{"#"}},
+	"makefile":   {lineComments: []string{"#"}, escapes: `\`},
+	"cmake": {
+		lineComments:  []string{"#"},
+		blockComments: []blockComment{{start: "#[[", end: "]]"}},
+		strings:       []stringLiteral{{start: `"`, end: `"`, escape: '\\', multiline: true}},
+	},
+	"sql": {
+		lineComments:  []string{"--"},
+		blockComments: cStyleBlock,
+		strings:       []stringLiteral{{start: `'`, end: `'`, multiline: true}, {start: `"`, end: `"`}},
+	},
+	"haskell": {
+		lineComments:  []string{"--"},
+		blockComments: []blockComment{{start: "{-", end: "-}", nested: true}},
+		strings:       []stringLiteral{doubleQuoted},
+		docPrefixes:   []string{"-- |", "-- ^", "{-|", "{- |"},
+		quoteChars:    true,
+		haskellDashes: true,
+	},
+	"lua": {
+		lineComments: []string{"--"},
+		strings:      []stringLiteral{doubleQuoted, singleQuoted},
+		docPrefixes:  []string{"---"},
+		luaBrackets:  true,
+	},
+	"clojure":     lispSyntax,
+	"lisp":        lispSyntax,
+	"emacs-lisp":  lispSyntax,
+	"erlang":      {lineComments: []string{"%"}, strings: []stringLiteral{doubleQuoted}, escapes: "$"},
+	"latex":       {lineComments: []string{"%"}, escapes: `\`},
+	"vim":         {lineStartComments: []string{`"`}},
+	"ada":         {lineComments: []string{"--"}, strings: []stringLiteral{{start: `"`, end: `"`}}},
+	"abap":        {lineComments: []string{`"`}, lineStartComments: []string{"*"}, strings: []stringLiteral{{start: `'`, end: `'`}, {start: "`", end: "`"}}},
+	"applescript": {lineComments: []string{"--", "#"}, blockComments: []blockComment{{start: "(*", end: "*)", nested: true}}, strings: []stringLiteral{doubleQuoted}},
+	"autohotkey":  {lineComments: []string{";"}, blockComments: []blockComment{{start: "/*", end: "*/", atLineStart: true}}, wordStart: true, escapes: "`"},
+	"bat":         {lineStartComments: []string{"::", "REM ", "rem ", "@REM ", "@rem "}},
+	"html":        markupSyntax,
+	"xml":         markupSyntax,
+	"vue":         markupSyntax,
+}
+
+// stripComments removes the comments of data. Code keeps its indentation and
+// blank lines; a line that only held comments is dropped. Doc comments are
+// kept with keepDocs.
+func stripComments(data []byte, syntax *commentSyntax, keepDocs bool) []byte {
+	l := &commentLexer{syn: syntax, keepDocs: keepDocs, src: data, stripped: map[int]bool{}}
+	l.lexCode(false)
+	return l.result()
+}
+
+// maskLiterals returns data with its strings and comments blanked out,
+// line breaks kept, so that a scan for brackets and indentation is not misled
+// by what they hold. Offsets and line numbers stay the same.
+func maskLiterals(data []byte, syntax *commentSyntax) []byte {
+	l := &commentLexer{syn: syntax, src: data, stripped: map[int]bool{}}
+	l.lexCode(false)
+	masked := bytes.Clone(data)
+	for _, span := range l.spans {
+		for i := span[0]; i < span[1]; i++ {
+			if masked[i] != '\n' && masked[i] != '\r' {
+				masked[i] = ' '
+			}
+		}
+	}
+	return masked
+}
+
+// commentLexer copies src to out, leaving the comments out.
+type commentLexer struct {
+	syn      *commentSyntax
+	keepDocs bool
+	src      []byte
+	pos      int
+	out      []byte
+	line     int          // the output line being written
+	stripped map[int]bool // the output lines a comment was removed from
+	heredocs []heredocEnd // heredocs whose bodies start on the next line
+	spans    [][2]int     // the src ranges of the comments and literals
+}
+
+type heredocEnd struct {
+	delim    string
+	indented bool // the terminator may be indented
+	prefix   bool // the terminator may be followed by more code (PHP)
+}
+
+func (l *commentLexer) copyTo(end int) {
+	end = min(end, len(l.src))
+	l.out = append(l.out, l.src[l.pos:end]...)
+	l.line += bytes.Count(l.src[l.pos:end], []byte("\n"))
+	l.pos = end
+}
+
+// lexCode copies code up to the end of src, or up to the } that closes a
+// template literal expression when inTemplate is set.
+func (l *commentLexer) lexCode(inTemplate bool) {
+	depth := 0
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.newline()
+			continue
+		case inTemplate && c == '{':
+			depth++
+		case inTemplate && c == '}':
+			if depth == 0 {
+				return
+			}
+			depth--
+		}
+		if l.comment() || l.literal() {
+			continue
+		}
+		if strings.IndexByte(l.syn.escapes, c) >= 0 && l.pos+1 < len(l.src) && l.src[l.pos+1] != '\n' {
+			l.copyTo(l.pos + 2)
 			continue
 		}
-		for _, prefix := range pattern.linePrefixes {
-			if bytes.HasPrefix(trim, []byte(prefix)) {
-				continue LINE
+		l.copyTo(l.pos + 1)
+	}
+}
+
+// newline copies a line break, then the heredoc bodies and YAML block
+// scalars that start on the next line.
+func (l *commentLexer) newline() {
+	scalarIndent := -1
+	if l.syn.yaml {
+		scalarIndent = yamlBlockScalarIndent(l.out[bytes.LastIndexByte(l.out, '\n')+1:])
+	}
+	l.copyTo(l.pos + 1)
+
+	for _, h := range l.heredocs {
+		for l.pos < len(l.src) {
+			end := l.lineEnd(l.pos)
+			line := l.src[l.pos:end]
+			if h.indented {
+				line = bytes.TrimLeft(line, " \t")
 			}
+			if string(line) == h.delim ||
+				(h.prefix && bytes.HasPrefix(line, []byte(h.delim)) && (len(line) == len(h.delim) || !isIdentByte(line[len(h.delim)]))) {
+				// the rest of the terminator line is code again: EOT;
+				l.copyTo(end - len(line) + len(h.delim))
+				break
+			}
+			start := l.pos
+			l.copyTo(l.skipLineBreak(end))
+			l.spans = append(l.spans, [2]int{start, l.pos})
 		}
-		result = append(result, trim)
 	}
+	l.heredocs = nil
 
-	return bytes.Join(result, []byte("\n"))
+	if scalarIndent >= 0 {
+		for l.pos < len(l.src) {
+			end := l.lineEnd(l.pos)
+			line := l.src[l.pos:end]
+			body := bytes.TrimLeft(line, " ")
+			if len(bytes.TrimSpace(body)) > 0 && len(line)-len(body) <= scalarIndent {
+				break
+			}
+			l.copyTo(l.skipLineBreak(end))
+		}
+	}
 }
 
-func stripBlockByDelimiter(data []byte, start, end string) []byte {
-	var out []byte
-	inBlock := false
-	i := 0
-	for i < len(data) {
-		if !inBlock && hasPrefixAt(data, start, i) {
-			inBlock = true
-			i += len(start)
+var yamlBlockScalarRegexp = regexp.MustCompile(`(?:^|[\s:?-])[|>][+-]?[0-9]?[+-]?$`)
+
+// yamlBlockScalarIndent returns the indentation of line when it opens a | or >
+// block scalar, -1 otherwise.
+func yamlBlockScalarIndent(line []byte) int {
+	if !yamlBlockScalarRegexp.Match(bytes.TrimRight(line, " \t\r")) {
+		return -1
+	}
+	return len(line) - len(bytes.TrimLeft(line, " "))
+}
+
+// lineEnd returns the end of the line at i, before any \r\n.
+func (l *commentLexer) lineEnd(i int) int {
+	end := len(l.src)
+	if n := bytes.IndexByte(l.src[i:], '\n'); n >= 0 {
+		end = i + n
+	}
+	if end > i && l.src[end-1] == '\r' {
+		end--
+	}
+	return end
+}
+
+func (l *commentLexer) skipLineBreak(i int) int {
+	if i < len(l.src) && l.src[i] == '\r' {
+		i++
+	}
+	if i < len(l.src) && l.src[i] == '\n' {
+		i++
+	}
+	return i
+}
+
+func (l *commentLexer) atColumn0(i int) bool {
+	return i == 0 || l.src[i-1] == '\n'
+}
+
+// atLineStart reports whether only blanks precede pos on its line.
+func (l *commentLexer) atLineStart() bool {
+	for i := l.pos - 1; i >= 0 && l.src[i] != '\n'; i-- {
+		if l.src[i] != ' ' && l.src[i] != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *commentLexer) atWordStart() bool {
+	return l.pos == 0 || strings.IndexByte(" \t\r\n;|&()", l.src[l.pos-1]) >= 0
+}
+
+// comment removes the comment at pos, if there is one.
+func (l *commentLexer) comment() bool {
+	s := l.syn
+	rest := l.src[l.pos:]
+
+	for _, b := range s.blockComments {
+		if bytes.HasPrefix(rest, []byte(b.start)) && (!b.atLineStart || l.atColumn0(l.pos)) {
+			l.removeComment(l.blockEnd(b))
+			return true
+		}
+	}
+	if s.luaBrackets && bytes.HasPrefix(rest, []byte("--")) {
+		if level := luaBracketLevel(rest[2:]); level >= 0 {
+			l.removeComment(l.indexEnd(l.pos+2, "]"+strings.Repeat("=", level)+"]"))
+			return true
+		}
+	}
+	if len(s.lineStartComments) > 0 && l.atLineStart() {
+		for _, prefix := range s.lineStartComments {
+			if bytes.HasPrefix(rest, []byte(prefix)) {
+				l.removeComment(l.lineEnd(l.pos))
+				return true
+			}
+		}
+	}
+	for _, prefix := range s.lineComments {
+		if !bytes.HasPrefix(rest, []byte(prefix)) {
+			continue
+		}
+		if s.wordStart && !l.atWordStart() {
+			continue
+		}
+		if s.haskellDashes && !haskellLineComment(rest) {
+			continue
+		}
+		if s.phpAttributes && prefix == "#" && len(rest) > 1 && rest[1] == '[' {
+			continue
+		}
+		l.removeComment(l.lineEnd(l.pos))
+		return true
+	}
+	return false
+}
+
+// blockEnd returns the end of the block comment b that opens at pos.
+func (l *commentLexer) blockEnd(b blockComment) int {
+	depth := 1
+	for i := l.pos + len(b.start); i < len(l.src); {
+		switch {
+		case b.nested && hasPrefixAt(l.src, b.start, i):
+			depth++
+			i += len(b.start)
+		case hasPrefixAt(l.src, b.end, i) && (!b.atLineStart || l.atColumn0(i)):
+			depth--
+			i += len(b.end)
+			if depth == 0 {
+				if b.atLineStart {
+					i = l.lineEnd(i)
+				}
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(l.src)
+}
+
+// indexEnd returns the end of the first delim at or after i, or the end of src.
+func (l *commentLexer) indexEnd(i int, delim string) int {
+	if n := bytes.Index(l.src[i:], []byte(delim)); n >= 0 {
+		return i + n + len(delim)
+	}
+	return len(l.src)
+}
+
+// removeComment drops src[pos:end], unless it is a directive or a doc
+// comment to keep. Line breaks inside the comment stay.
+func (l *commentLexer) removeComment(end int) {
+	l.spans = append(l.spans, [2]int{l.pos, end})
+	if l.keepComment(end) {
+		l.copyTo(end)
+		return
+	}
+	text := l.src[l.pos:end]
+	l.stripped[l.line] = true
+	for i, c := range text {
+		if c != '\n' {
+			continue
+		}
+		if i > 0 && text[i-1] == '\r' {
+			l.out = append(l.out, '\r')
+		}
+		l.out = append(l.out, '\n')
+		l.line++
+		l.stripped[l.line] = true
+	}
+	// a comment between two tokens still separates them: a/**/b
+	if !bytes.ContainsRune(text, '\n') && len(l.out) > 0 && end < len(l.src) &&
+		!isBlank(l.out[len(l.out)-1]) && !isBlank(l.src[end]) {
+		l.out = append(l.out, ' ')
+	}
+	l.pos = end
+}
+
+func (l *commentLexer) keepComment(end int) bool {
+	text := l.src[l.pos:end]
+	if l.pos == 0 && bytes.HasPrefix(text, []byte("#!")) {
+		return true
+	}
+	for _, d := range l.syn.directives {
+		if bytes.HasPrefix(text, []byte(d)) {
+			return true
+		}
+	}
+	if !l.keepDocs {
+		return false
+	}
+	if l.syn.goDocs {
+		return l.atColumn0(l.pos) && l.goDocComment(end)
+	}
+	if bytes.HasPrefix(text, []byte("/**/")) {
+		return false
+	}
+	for _, p := range l.syn.docPrefixes {
+		if !bytes.HasPrefix(text, []byte(p)) {
 			continue
 		}
-		if inBlock && hasPrefixAt(data, end, i) {
-			inBlock = false
-			i += len(end)
+		// //// and /*** are not doc comments
+		if last := p[len(p)-1]; strings.IndexByte("/*-", last) >= 0 && len(text) > len(p) && text[len(p)] == last {
 			continue
 		}
-		if !inBlock {
-			out = append(out, data[i])
+		return true
+	}
+	return false
+}
+
+var goDeclKeywords = []string{"package ", "func ", "type ", "var ", "const "}
+
+// goDocComment reports whether the comment group ending at end sits right
+// above a package clause or a top-level declaration.
+func (l *commentLexer) goDocComment(end int) bool {
+	i := end
+	for {
+		n := bytes.IndexByte(l.src[i:], '\n')
+		if n < 0 {
+			return false
+		}
+		i += n + 1
+		line := l.src[i:l.lineEnd(i)]
+		switch {
+		case bytes.HasPrefix(line, []byte("//")):
+			continue
+		case bytes.HasPrefix(line, []byte("/*")):
+			i = l.indexEnd(i, "*/")
+			continue
+		}
+		for _, kw := range goDeclKeywords {
+			if bytes.HasPrefix(line, []byte(kw)) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// literal copies the string-like literal at pos, if there is one, and
+// records its span.
+func (l *commentLexer) literal() bool {
+	start := l.pos
+	if !l.lexLiteral() {
+		return false
+	}
+	l.spans = append(l.spans, [2]int{start, l.pos})
+	return true
+}
+
+func (l *commentLexer) lexLiteral() bool {
+	s := l.syn
+	rest := l.src[l.pos:]
+	c := rest[0]
+
+	if s.heredoc != noHeredoc && c == '<' && l.heredoc() {
+		return true
+	}
+	if s.raw != noRawString && l.rawString() {
+		return true
+	}
+	if s.templates && c == '`' {
+		l.template()
+		return true
+	}
+	if s.regexps && c == '/' && l.regexp() {
+		return true
+	}
+	if s.luaBrackets && c == '[' {
+		if level := luaBracketLevel(rest); level >= 0 {
+			l.copyTo(l.indexEnd(l.pos+2, "]"+strings.Repeat("=", level)+"]"))
+			return true
+		}
+	}
+	if s.quoteChars && c == '\'' {
+		return l.charLiteral()
+	}
+	if s.yaml && (c == '"' || c == '\'') && !l.yamlScalarStart() {
+		return false
+	}
+	for _, lit := range s.strings {
+		if bytes.HasPrefix(rest, []byte(lit.start)) {
+			l.copyTo(l.stringEnd(lit))
+			return true
+		}
+	}
+	return false
+}
+
+// stringEnd returns the end of the literal lit that opens at pos. A
+// single-line literal left open ends with its line.
+func (l *commentLexer) stringEnd(lit stringLiteral) int {
+	i := l.pos + len(lit.start)
+	for i < len(l.src) {
+		switch {
+		case lit.escape != 0 && l.src[i] == lit.escape:
+			i += 2
+		case hasPrefixAt(l.src, lit.end, i):
+			return i + len(lit.end)
+		case l.src[i] == '\n' && !lit.multiline:
+			return i
+		default:
+			i++
+		}
+	}
+	return len(l.src)
+}
+
+func (l *commentLexer) template() {
+	l.copyTo(l.pos + 1)
+	for l.pos < len(l.src) {
+		switch {
+		case l.src[l.pos] == '\\':
+			l.copyTo(l.pos + 2)
+		case l.src[l.pos] == '`':
+			l.copyTo(l.pos + 1)
+			return
+		case hasPrefixAt(l.src, "${", l.pos):
+			l.copyTo(l.pos + 2)
+			l.lexCode(true)
+			l.copyTo(l.pos + 1)
+		default:
+			l.copyTo(l.pos + 1)
+		}
+	}
+}
+
+var regexpKeywords = []string{"return", "typeof", "case", "do", "else", "in", "of", "new", "delete", "void", "throw", "yield", "await", "instanceof"}
+
+// regexp copies the /re/ literal at pos. A / starts one where an operand is
+// expected: after an operator, an opening bracket or a keyword like return.
+func (l *commentLexer) regexp() bool {
+	i := len(l.out) - 1
+	for i >= 0 && isBlank(l.out[i]) {
+		i--
+	}
+	if i >= 0 && strings.IndexByte("(,=:[!&|?{};+-*%<>~^", l.out[i]) < 0 {
+		j := i
+		for j >= 0 && isIdentByte(l.out[j]) {
+			j--
+		}
+		if !containsString(regexpKeywords, string(l.out[j+1:i+1])) {
+			return false
+		}
+	}
+
+	inClass := false
+	for i := l.pos + 1; i < len(l.src); i++ {
+		switch l.src[i] {
+		case '\\':
+			i++
+		case '\n':
+			return false
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '/':
+			if !inClass {
+				l.copyTo(i + 1)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// charLiteral copies 'x' or '\n' at pos; a ' that does not close right away
+// is a Rust lifetime or a Haskell prime and is left to the caller.
+func (l *commentLexer) charLiteral() bool {
+	if l.pos > 0 && isIdentByte(l.src[l.pos-1]) {
+		return false
+	}
+	i := l.pos + 1
+	if i >= len(l.src) {
+		return false
+	}
+	if l.src[i] == '\\' {
+		for j := i + 2; j < len(l.src) && j < i+12 && l.src[j] != '\n'; j++ {
+			if l.src[j] == '\'' {
+				l.copyTo(j + 1)
+				return true
+			}
+		}
+		return false
+	}
+	_, size := utf8.DecodeRune(l.src[i:])
+	if l.src[i] != '\n' && i+size < len(l.src) && l.src[i+size] == '\'' {
+		l.copyTo(i + size + 1)
+		return true
+	}
+	return false
+}
+
+// rawString copies the raw string literal at pos, whose content has no escapes.
+func (l *commentLexer) rawString() bool {
+	src, pos := l.src, l.pos
+	if pos > 0 && isIdentByte(src[pos-1]) && l.syn.raw != cppRawString {
+		return false
+	}
+
+	switch l.syn.raw {
+	case rustRawString:
+		i := pos
+		if src[i] == 'b' {
+			i++
+		}
+		if i >= len(src) || src[i] != 'r' {
+			return false
+		}
+		i++
+		hashes := 0
+		for i < len(src) && src[i] == '#' {
+			hashes++
+			i++
+		}
+		if i >= len(src) || src[i] != '"' {
+			return false
+		}
+		l.copyTo(l.indexEnd(i+1, `"`+strings.Repeat("#", hashes)))
+		return true
+
+	case cppRawString:
+		if !hasPrefixAt(src, `R"`, pos) {
+			return false
+		}
+		// only the u8, u, U and L encoding prefixes may precede R
+		start := pos
+		for start > 0 && isIdentByte(src[start-1]) {
+			start--
+		}
+		if prefix := string(src[start:pos]); prefix != "" && prefix != "u8" && prefix != "u" && prefix != "U" && prefix != "L" {
+			return false
+		}
+		open := bytes.IndexByte(src[pos+2:], '(')
+		if open < 0 || open > 16 || bytes.ContainsAny(src[pos+2:pos+2+open], " \\)\n") {
+			return false
+		}
+		delim := string(src[pos+2 : pos+2+open])
+		l.copyTo(l.indexEnd(pos+3+open, ")"+delim+`"`))
+		return true
+
+	case swiftRawString:
+		i := pos
+		for i < len(src) && src[i] == '#' {
+			i++
+		}
+		hashes := strings.Repeat("#", i-pos)
+		switch {
+		case hashes == "":
+			return false
+		case hasPrefixAt(src, `"""`, i):
+			l.copyTo(l.indexEnd(i+3, `"""`+hashes))
+		case hasPrefixAt(src, `"`, i):
+			l.copyTo(l.indexEnd(i+1, `"`+hashes))
+		default:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+var (
+	shellHeredocRegexp = regexp.MustCompile(`^<<(-?)[ \t]*(?:'([^'\n]+)'|"([^"\n]+)"|\\?([A-Za-z_][A-Za-z0-9_]*))`)
+	rubyHeredocRegexp  = regexp.MustCompile("^<<([~-]?)(?:'([^'\\n]+)'|\"([^\"\\n]+)\"|`([^`\\n]+)`|([A-Za-z_][A-Za-z0-9_]*))")
+	phpHeredocRegexp   = regexp.MustCompile(`^<<<[ \t]*(?:'([A-Za-z_][A-Za-z0-9_]*)'|"([A-Za-z_][A-Za-z0-9_]*)"|([A-Za-z_][A-Za-z0-9_]*))`)
+)
+
+// heredoc copies a heredoc opener and queues its body, which starts on the
+// next line and is copied as is.
+func (l *commentLexer) heredoc() bool {
+	rest := l.src[l.pos:]
+	var m [][]byte
+	h := heredocEnd{}
+
+	switch l.syn.heredoc {
+	case shellHeredoc:
+		if hasPrefixAt(rest, "<<<", 0) {
+			return false
+		}
+		if m = shellHeredocRegexp.FindSubmatch(rest); m == nil {
+			return false
+		}
+		h.indented = len(m[1]) > 0
+	case rubyHeredoc:
+		if m = rubyHeredocRegexp.FindSubmatch(rest); m == nil {
+			return false
+		}
+		// a bare <<name is an operator unless the name is upper case: a << b
+		if len(m[1]) == 0 && len(m[5]) > 0 && !(m[5][0] >= 'A' && m[5][0] <= 'Z') {
+			return false
+		}
+		h.indented = len(m[1]) > 0
+	case phpHeredoc:
+		if m = phpHeredocRegexp.FindSubmatch(rest); m == nil {
+			return false
+		}
+		h.indented, h.prefix = true, true
+	}
+	for _, g := range m[2:] {
+		if len(g) > 0 {
+			h.delim = string(g)
+		}
+	}
+	if l.syn.heredoc == phpHeredoc {
+		for _, g := range m[1:] {
+			if len(g) > 0 {
+				h.delim = string(g)
+			}
+		}
+	}
+
+	l.copyTo(l.pos + len(m[0]))
+	l.heredocs = append(l.heredocs, h)
+	return true
+}
+
+// yamlScalarStart reports whether a quote at pos starts a YAML scalar, so
+// that the apostrophe of it's is not a string.
+func (l *commentLexer) yamlScalarStart() bool {
+	for i := l.pos - 1; i >= 0 && l.src[i] != '\n'; i-- {
+		if l.src[i] == ' ' || l.src[i] == '\t' {
+			continue
 		}
+		return strings.IndexByte(":-[{,?", l.src[i]) >= 0
+	}
+	return true
+}
+
+// luaBracketLevel returns n for a [==[ long bracket with n equals signs at
+// the start of b, -1 when there is none.
+func luaBracketLevel(b []byte) int {
+	if len(b) == 0 || b[0] != '[' {
+		return -1
+	}
+	n := 1
+	for n < len(b) && b[n] == '=' {
+		n++
+	}
+	if n < len(b) && b[n] == '[' {
+		return n - 1
+	}
+	return -1
+}
+
+// haskellLineComment reports whether the dashes at the start of b start a
+// comment rather than an operator such as -->.
+func haskellLineComment(b []byte) bool {
+	i := 0
+	for i < len(b) && b[i] == '-' {
 		i++
 	}
+	return i == len(b) || strings.IndexByte("!#$%&*+./<=>?@\\^|~:", b[i]) < 0
+}
+
+// result drops the lines a comment was removed from when nothing but blanks
+// is left, and trims the trailing blanks of the others.
+func (l *commentLexer) result() []byte {
+	if len(l.stripped) == 0 {
+		return l.out
+	}
+	var out []byte
+	for i, line := range bytes.SplitAfter(l.out, []byte("\n")) {
+		if !l.stripped[i] {
+			out = append(out, line...)
+			continue
+		}
+		body := bytes.TrimRight(line, "\r\n")
+		eol := line[len(body):]
+		body = bytes.TrimRight(body, " \t")
+		if len(body) == 0 {
+			continue
+		}
+		out = append(out, body...)
+		out = append(out, eol...)
+	}
 	return out
 }
 
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func hasPrefixAt(data []byte, prefix string, i int) bool {
 	return i+len(prefix) <= len(data) && bytes.HasPrefix(data[i:], []byte(prefix))
 }
@@ -52,11 +52,155 @@ func TestStripComments(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			pattern := getCommentDelimiters(tt.lang)
-			output := stripComments([]byte(tt.input), pattern)
+			output := []byte(tt.input)
+			if syntax, ok := commentSyntaxes[tt.lang]; ok {
+				output = stripComments(output, syntax, false)
+			}
 			if !bytes.Equal(bytes.TrimSpace(output), []byte(tt.expected)) {
 				t.Errorf("expected %q, got %q", tt.expected, output)
 			}
 		})
 	}
 }
+
+func TestDeleteComments(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		keepDocs bool
+		input    string
+		expected string
+	}{
+		{
+			name:     "keeps indentation and blank lines",
+			path:     "main.go",
+			input:    "func f() {\n\t// note\n\tx := 1 // trailing\n\n\treturn x\n}\n",
+			expected: "func f() {\n\tx := 1\n\n\treturn x\n}\n",
+		},
+		{
+			name:     "comment markers in strings",
+			path:     "main.go",
+			input:    "s := \"http://example.com /* x */\" // c\nr := `// raw\n# not` /* c */\nc := '\\''\n",
+			expected: "s := \"http://example.com /* x */\"\nr := `// raw\n# not`\nc := '\\''\n",
+		},
+		{
+			name:     "block comment between tokens",
+			path:     "main.c",
+			input:    "int/* type */x = 1;/* a\nb */int y;\n",
+			expected: "int x = 1;\nint y;\n",
+		},
+		{
+			name:     "go directives stay",
+			path:     "main.go",
+			input:    "//go:build linux\n\npackage main\n\n//go:embed a.txt\nvar a string\n",
+			expected: "//go:build linux\n\npackage main\n\n//go:embed a.txt\nvar a string\n",
+		},
+		{
+			name:     "go doc comments",
+			path:     "main.go",
+			keepDocs: true,
+			input:    "// Package main does things.\npackage main\n\n// stray\n\n// F is documented.\nfunc F() {\n\t// inside\n}\n",
+			expected: "// Package main does things.\npackage main\n\n\n// F is documented.\nfunc F() {\n}\n",
+		},
+		{
+			name:     "rust nested comments, raw strings and lifetimes",
+			path:     "lib.rs",
+			input:    "/* a /* b */ c */\nfn f<'a>(s: &'a str) -> &'a str { let q = '\"'; r#\"// \"x\"\"# }\n",
+			expected: "fn f<'a>(s: &'a str) -> &'a str { let q = '\"'; r#\"// \"x\"\"# }\n",
+		},
+		{
+			name:     "rust doc comments",
+			path:     "lib.rs",
+			keepDocs: true,
+			input:    "//! crate docs\n//// banner\n/// item docs\n/** block docs */\n/**/\n// plain\nfn f() {}\n",
+			expected: "//! crate docs\n/// item docs\n/** block docs */\nfn f() {}\n",
+		},
+		{
+			name:     "swift nested comments and raw strings",
+			path:     "a.swift",
+			input:    "/* /* */ */\nlet s = #\"/* \"# // c\n",
+			expected: "let s = #\"/* \"#\n",
+		},
+		{
+			name:     "haskell nested comments and operators",
+			path:     "Main.hs",
+			input:    "{- a {- b -} -}\nx' = y --> z -- note\nc = '\"'\n",
+			expected: "x' = y --> z\nc = '\"'\n",
+		},
+		{
+			name:     "javascript templates and regexps",
+			path:     "a.js",
+			input:    "const u = `http://${host /* h */}/${`//`}`; // c\nconst re = /\\/\\/[/*]/g; /* c */\nconst d = a / b / c; // c\n",
+			expected: "const u = `http://${host }/${`//`}`;\nconst re = /\\/\\/[/*]/g;\nconst d = a / b / c;\n",
+		},
+		{
+			name:     "cpp raw strings",
+			path:     "a.cpp",
+			input:    "auto s = R\"x(/* \" // )x\"; // c\n",
+			expected: "auto s = R\"x(/* \" // )x\";\n",
+		},
+		{
+			name:     "python strings",
+			path:     "a.py",
+			input:    "#!/usr/bin/env python\nx = f\"{a}#{b}\"  # c\ny = \"\"\"\n# doc\n\"\"\"\n",
+			expected: "#!/usr/bin/env python\nx = f\"{a}#{b}\"\ny = \"\"\"\n# doc\n\"\"\"\n",
+		},
+		{
+			name:     "shell words and heredocs",
+			path:     "run.sh",
+			input:    "echo $# ${#a} a#b \\# 'x # y' # c\ncat <<-EOF\n\t# kept\n\tEOF\n# gone\n",
+			expected: "echo $# ${#a} a#b \\# 'x # y'\ncat <<-EOF\n\t# kept\n\tEOF\n",
+		},
+		{
+			name:     "ruby heredocs and block comments",
+			path:     "a.rb",
+			input:    "=begin\nnotes\n=end\ns = <<~SQL # c\n  # kept\nSQL\na << b # c\n",
+			expected: "s = <<~SQL\n  # kept\nSQL\na << b\n",
+		},
+		{
+			name:     "php heredocs and attributes",
+			path:     "a.php",
+			input:    "#[Attr]\n$s = <<<EOT\n// kept\nEOT; # c\n",
+			expected: "#[Attr]\n$s = <<<EOT\n// kept\nEOT;\n",
+		},
+		{
+			name:     "yaml quotes and block scalars",
+			path:     "a.yml",
+			input:    "it: it's # c\nurl: \"a#b\" # c\nrun: |\n  echo # kept\n\n  done\nnext: x#y\n",
+			expected: "it: it's\nurl: \"a#b\"\nrun: |\n  echo # kept\n\n  done\nnext: x#y\n",
+		},
+		{
+			name:     "lua long brackets",
+			path:     "a.lua",
+			input:    "--[[ a\nb ]]\ns = [==[ -- ]==] -- c\n",
+			expected: "s = [==[ -- ]==]\n",
+		},
+		{
+			name:     "css urls",
+			path:     "a.scss",
+			input:    "a { background: url(http://x/y.png); } // c\n",
+			expected: "a { background: url(http://x/y.png); }\n",
+		},
+		{
+			name:     "crlf line endings",
+			path:     "a.py",
+			input:    "x = 1  # c\r\n# gone\r\ny = 2\r\n",
+			expected: "x = 1\r\ny = 2\r\n",
+		},
+		{
+			name:     "unknown language",
+			path:     "notes.txt",
+			input:    "# heading\n// text\n",
+			expected: "# heading\n// text\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output := DeleteComments([]byte(tt.input), tt.path, tt.keepDocs)
+			if string(output) != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, output)
+			}
+		})
+	}
+}
@@ -12,7 +12,14 @@ import (
 	"unicode/utf8"
 
 	"github.com/magicdrive/ark/internal/chardetect"
+	"github.com/magicdrive/ark/internal/commandline"
+	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
 	"golang.org/x/text/transform"
 )
 
@@ -76,17 +83,8 @@ func ConvertToUTF8(r io.Reader) (io.Reader, error) {
 		return buf, nil
 	}
 
-	// Select appropriate decoder based on detected encoding
-	var decoder transform.Transformer
-
-	switch result.Encoding {
-	case chardetect.ShiftJIS, chardetect.CP932:
-		decoder = japanese.ShiftJIS.NewDecoder()
-	case chardetect.EUCJP:
-		decoder = japanese.EUCJP.NewDecoder()
-	case chardetect.ISO2022JP:
-		decoder = japanese.ISO2022JP.NewDecoder()
-	default:
+	decoder := decoderFor(result.Encoding)
+	if decoder == nil {
 		// Unknown or unhandled encoding - return as-is
 		// This is safer than failing, as the caller can decide how to handle it
 		return buf, nil
@@ -95,10 +93,77 @@ func ConvertToUTF8(r io.Reader) (io.Reader, error) {
 	return transform.NewReader(buf, decoder), nil
 }
 
-func DeleteComments(data []byte, fpath string) []byte {
-	lang := detectLanguageTag(fpath)
-	pattern := getCommentDelimiters(lang)
-	result := stripComments(data, pattern)
-	return result
+// decoderFor returns the decoder to UTF-8 for a detected encoding, or nil
+// when the data is used as-is.
+func decoderFor(enc chardetect.Encoding) transform.Transformer {
+	switch enc {
+	case chardetect.ShiftJIS, chardetect.CP932:
+		return japanese.ShiftJIS.NewDecoder()
+	case chardetect.EUCJP:
+		return japanese.EUCJP.NewDecoder()
+	case chardetect.ISO2022JP:
+		return japanese.ISO2022JP.NewDecoder()
+	case chardetect.UTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case chardetect.UTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	case chardetect.UTF32LE:
+		return utf32.UTF32(utf32.LittleEndian, utf32.UseBOM).NewDecoder()
+	case chardetect.UTF32BE:
+		return utf32.UTF32(utf32.BigEndian, utf32.UseBOM).NewDecoder()
+	case chardetect.Windows1252:
+		return charmap.Windows1252.NewDecoder()
+	case chardetect.GB18030:
+		return simplifiedchinese.GB18030.NewDecoder()
+	case chardetect.Big5:
+		return traditionalchinese.Big5.NewDecoder()
+	case chardetect.EUCKR:
+		return korean.EUCKR.NewDecoder()
+	}
+	return nil
+}
 
+// DecodeText converts data to UTF-8 from its detected encoding, so that
+// IsBinary judges the text rather than its encoding. Undetected data is
+// returned as-is. Empty data is ASCII, which the detector leaves undetected.
+func DecodeText(data []byte) ([]byte, *chardetect.Result, error) {
+	if len(data) == 0 {
+		return data, &chardetect.Result{Encoding: chardetect.ASCII, Confidence: 1.0}, nil
+	}
+	result := chardetect.Detect(data[:min(len(data), 8192)])
+	decoder := decoderFor(result.Encoding)
+	if decoder == nil {
+		return data, result, nil
+	}
+	decoded, _, err := transform.Bytes(decoder, data)
+	if err != nil {
+		return nil, result, err
+	}
+	return decoded, result, nil
+}
+
+// IsUTF8Encoding reports whether a detected encoding needs no conversion.
+func IsUTF8Encoding(enc chardetect.Encoding) bool {
+	return enc == chardetect.UTF8 || enc == chardetect.ASCII
+}
+
+// loadFileContent reads a file for dumping and applies UTF-8 conversion,
+// comment deletion and secret masking. ok is false when the file must be skipped.
+func loadFileContent(fpath string, opt *commandline.Option) (content string, ok bool, err error) {
+	f, ok, err := newFileStage(opt).load(fpath)
+	if err != nil || !ok {
+		return "", ok, err
+	}
+	return f.Content, true, nil
+}
+
+// DeleteComments removes the comments of a source file, found by a lexer for
+// the language of fpath that skips strings. Doc comments stay with
+// keepDocComments. Languages the lexer does not know are returned as is.
+func DeleteComments(data []byte, fpath string, keepDocComments bool) []byte {
+	syntax, ok := commentSyntaxes[detectLanguageTag(fpath)]
+	if !ok {
+		return data
+	}
+	return stripComments(data, syntax, keepDocComments)
 }
@@ -5,7 +5,15 @@ import (
 	"io"
 	"testing"
 
+	"github.com/magicdrive/ark/internal/chardetect"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
 	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
 	"golang.org/x/text/transform"
 )
 
@@ -255,3 +263,52 @@ func BenchmarkConvertToUTF8_ShiftJIS(b *testing.B) {
 		io.ReadAll(result)
 	}
 }
+
+func TestDecodeText_ExtendedEncodings(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		enc      encoding.Encoding
+		want     chardetect.Encoding
+	}{
+		{"UTF-16LE with BOM", "Hello, 世界!\n", unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), chardetect.UTF16LE},
+		{"UTF-16BE without BOM", "package main\n\nfunc main() {}\n", unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), chardetect.UTF16BE},
+		{"UTF-32LE with BOM", "Hello, World!\n", utf32.UTF32(utf32.LittleEndian, utf32.UseBOM), chardetect.UTF32LE},
+		{"Windows-1252", "Le cœur a ses raisons que la raison ne connaît point.\nÄrger über die Größe.", charmap.Windows1252, chardetect.Windows1252},
+		{"GB18030", "我们的国家有很多人。这是一个关于中国文化的文件，大家都可以看到。", simplifiedchinese.GB18030, chardetect.GB18030},
+		{"Big5", "我們的國家有很多人。這是一個關於中國文化的文件，大家都可以看到。", traditionalchinese.Big5, chardetect.Big5},
+		{"EUC-KR", "대한민국은 민주공화국이다. 이것은 한국어 문서입니다. 우리는 함께 공부를 합니다.", korean.EUCKR, chardetect.EUCKR},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _, err := transform.Bytes(tt.enc.NewEncoder(), []byte(tt.original))
+			if err != nil {
+				t.Fatalf("Failed to encode: %v", err)
+			}
+
+			decoded, detected, err := DecodeText(data)
+			if err != nil {
+				t.Fatalf("DecodeText() error = %v", err)
+			}
+			if detected.Encoding != tt.want {
+				t.Errorf("DecodeText() encoding = %v, want %v", detected.Encoding, tt.want)
+			}
+			if string(decoded) != tt.original {
+				t.Errorf("DecodeText() = %q, want %q", decoded, tt.original)
+			}
+			if IsBinary(decoded) {
+				t.Errorf("decoded text must not be binary")
+			}
+
+			result, err := ConvertToUTF8(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ConvertToUTF8() error = %v", err)
+			}
+			output, _ := io.ReadAll(result)
+			if string(output) != tt.original {
+				t.Errorf("ConvertToUTF8() = %q, want %q", output, tt.original)
+			}
+		})
+	}
+}
@@ -46,6 +46,7 @@ var knownLanguageTags = map[string]string{
 	".cc":          "cpp",
 	".cpp":         "cpp",
 	".cxx":         "cpp",
+	".hpp":         "cpp",
 	".cs":          "csharp",
 	".clj":         "clojure",
 	".cljs":        "clojure",
@@ -57,6 +58,8 @@ var knownLanguageTags = map[string]string{
 	".dockerfile":  "dockerfile",
 	".el":          "emacs-lisp",
 	".erl":         "erlang",
+	".ex":          "elixir",
+	".exs":         "elixir",
 	".go":          "go",
 	".groovy":      "groovy",
 	".hs":          "haskell",
@@ -64,6 +67,8 @@ var knownLanguageTags = map[string]string{
 	".ini":         "ini",
 	".java":        "java",
 	".js":          "javascript",
+	".mjs":         "javascript",
+	".cjs":         "javascript",
 	".jsx":         "jsx",
 	".json":        "json",
 	".kt":          "kotlin",
@@ -2,74 +2,33 @@ package core
 
 import (
 	"encoding/json"
-	"os"
-	"path/filepath"
 
 	"github.com/magicdrive/ark/internal/commandline"
-	"github.com/magicdrive/ark/internal/common"
 )
 
 type TreeEntry struct {
 	Name     string       `json:"name"`
-	Type     string       `json:"type"` // "file" or "directory"
+	Type     string       `json:"type"`             // "file", "directory" or "link"
+	Target   string       `json:"target,omitempty"` // target of a symbolic link
+	Status   string       `json:"status,omitempty"` // git status in diff mode
+	Note     string       `json:"note,omitempty"`   // why an entry is skipped or truncated
 	Children []*TreeEntry `json:"children,omitempty"`
 }
 
+// GenerateTreeJSONString renders the filtered tree of rootPath as JSON, and
+// adds its directories and the files the dump includes to allowedFileMap.
 func GenerateTreeJSONString(rootPath string, allowedFileMap map[string]bool, opt *commandline.Option) (string, map[string]bool, error) {
-	tree, allowedFileMap, err := generateTreeJSON(rootPath, allowedFileMap, opt)
+	tree, err := WalkTree(rootPath, opt)
 	if err != nil {
 		return "", nil, err
 	}
-	jsonBytes, _ := json.Marshal(tree)
-	return string(jsonBytes), allowedFileMap, nil
-
-}
-
-// GenerateTreeJSON generates a filtered directory tree in JSON format,
-// along with the allowed file map used for output filtering.
-func generateTreeJSON(path string, allowedFileMap map[string]bool, opt *commandline.Option) (*TreeEntry, map[string]bool, error) {
-	files, err := os.ReadDir(path)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// sort files in consistent order
-	ApplySort(files)
-
-	node := &TreeEntry{
-		Name: filepath.Base(path),
-		Type: "directory",
-	}
-
-	for _, file := range files {
-		if opt.IgnoreDotFileFlag.Bool() && IsHiddenFile(file.Name()) {
-			continue
-		}
-
-		fullPath := filepath.Join(path, file.Name())
-		if IsUnderGitDir(file.Name()) {
-			continue
-		}
-		if !CanBoaded(opt, fullPath) {
-			continue
-		}
-
-		if file.IsDir() {
-			childNode, childMap, err := generateTreeJSON(fullPath, allowedFileMap, opt)
-			if err != nil {
-				continue
-			}
-			node.Children = append(node.Children, childNode)
-			allowedFileMap = common.MergeAllowFileList(childMap, allowedFileMap)
-			allowedFileMap[fullPath] = true
-		} else {
-			node.Children = append(node.Children, &TreeEntry{
-				Name: file.Name(),
-				Type: "file",
-			})
-			allowedFileMap[fullPath] = true
-		}
+	for fpath := range tree.Allowed() {
+		allowedFileMap[fpath] = true
 	}
+	return treeJSONString(tree), allowedFileMap, nil
+}
 
-	return node, allowedFileMap, nil
+func treeJSONString(tree *FileTree) string {
+	jsonBytes, _ := json.Marshal(tree.TreeEntry())
+	return string(jsonBytes)
 }
@@ -0,0 +1,403 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// RestoredFile is a single file read back from a dump.
+type RestoredFile struct {
+	Path    string
+	Content string
+
+	// continued marks a part that resumes a file split across chunks
+	continued bool
+}
+
+// outlineDumpMarker ends the header of an outline dump.
+const outlineDumpMarker = "--- BEGIN OUTLINE ---"
+
+// arkliteContinued follows the @path of an arklite file part that resumes a
+// file split across chunks.
+const arkliteContinued = " (continued)"
+
+var (
+	txtSectionRegexp     = regexp.MustCompile(`^=== (.+?)` + textMetadataPattern + `( \(continued\))? ===$`)
+	mdSectionRegexp      = regexp.MustCompile(`^# File: (.+?)` + textMetadataPattern + `( \(continued\))?$`)
+	lineNumberLineRegexp = regexp.MustCompile(`^ *(\d+): `)
+)
+
+// DetectDumpFormat guesses which output format produced data.
+func DetectDumpFormat(data []byte) (string, error) {
+	trimmed := bytes.TrimLeft(data, " \t\r\n")
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<?xml")), bytes.HasPrefix(trimmed, []byte("<ProjectDump")):
+		return model.XML, nil
+	case bytes.HasPrefix(trimmed, []byte("# Arklite Format Overview")):
+		return model.Arklite, nil
+	case bytes.HasPrefix(trimmed, []byte("# Project: ")):
+		return model.Markdown, nil
+	case bytes.HasPrefix(trimmed, []byte("Project: ")):
+		if bytes.Contains(trimmed[:min(len(trimmed), 4096)], []byte(outlineDumpMarker)) {
+			return model.Outline, nil
+		}
+		return model.PlainText, nil
+	case bytes.HasPrefix(trimmed, []byte("{\n")), bytes.HasPrefix(trimmed, []byte("{\r\n")):
+		return model.JSON, nil
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return model.JSONL, nil
+	}
+	return "", errors.New("unknown dump format")
+}
+
+// DecodeDump parses a dump written by any output format back into files.
+// Paths are relative to the dumped root and use forward slashes.
+func DecodeDump(data []byte) ([]*RestoredFile, error) {
+	format, err := DetectDumpFormat(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case model.XML:
+		return decodeXMLDump(data)
+	case model.Arklite:
+		return decodeArkliteDump(data)
+	case model.Markdown:
+		return decodeMarkdownDump(data)
+	case model.PlainText:
+		return decodeTextDump(data)
+	case model.JSON:
+		return decodeJSONDump(data)
+	case model.Outline:
+		return nil, errors.New("an outline dump holds no file contents to restore")
+	default:
+		return decodeJSONLDump(data)
+	}
+}
+
+// mergeRestoredFiles joins the parts marked as continued to the part of the
+// same file before them, which is how a file split across chunks comes back.
+// Only the first part may be continued, as its file starts in an earlier
+// chunk; any other path that comes twice is an error.
+func mergeRestoredFiles(files []*RestoredFile) ([]*RestoredFile, error) {
+	var merged []*RestoredFile
+	seen := map[string]bool{}
+	for i, f := range files {
+		if f.continued {
+			if n := len(merged); n > 0 && merged[n-1].Path == f.Path {
+				merged[n-1].Content += f.Content
+				continue
+			}
+			if i > 0 {
+				return nil, fmt.Errorf("%s is continued but does not follow its previous part", f.Path)
+			}
+		} else if seen[f.Path] {
+			return nil, fmt.Errorf("%s is dumped more than once", f.Path)
+		}
+		seen[f.Path] = true
+		merged = append(merged, f)
+	}
+	return merged, nil
+}
+
+func splitDumpLines(data []byte) []string {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// dumpRootFrom returns the value of the first line starting with prefix.
+func dumpRootFrom(lines []string, prefix string) string {
+	for _, line := range lines {
+		if after, ok := strings.CutPrefix(line, prefix); ok {
+			return strings.TrimSpace(after)
+		}
+	}
+	return ""
+}
+
+// relativeDumpPath turns a path as written by the txt and md dumpers, which
+// include the dumped root, into a path relative to that root.
+func relativeDumpPath(fpath string, root string) string {
+	fpath = filepath.ToSlash(fpath)
+	root = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(root)), "/")
+	if root != "" && root != "." {
+		if rel, ok := strings.CutPrefix(fpath, root+"/"); ok {
+			return rel
+		}
+	}
+	return strings.TrimPrefix(path.Clean(fpath), "./")
+}
+
+func decodeTextDump(data []byte) ([]*RestoredFile, error) {
+	lines := splitDumpLines(data)
+	root := dumpRootFrom(lines, "Root: ")
+
+	var files []*RestoredFile
+	var current *RestoredFile
+	var body []string
+	flush := func() {
+		if current != nil {
+			current.Content = joinDumpLines(stripLineNumbers(body))
+			// a --binary-metadata stub has nothing to restore
+			if !isBinaryStub(current.Content) {
+				files = append(files, current)
+			}
+		}
+	}
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		// every section starts with a blank line and a "=== path ===" header
+		if m := txtSectionRegexp.FindStringSubmatch(line); m != nil && i > 0 && lines[i-1] == "" {
+			if current != nil && len(body) > 0 && body[len(body)-1] == "" {
+				body = body[:len(body)-1]
+			}
+			flush()
+			current = &RestoredFile{Path: relativeDumpPath(m[1], root), continued: m[2] != ""}
+			body = nil
+			continue
+		}
+		if current != nil {
+			body = append(body, line)
+		}
+	}
+	flush()
+
+	return mergeRestoredFiles(files)
+}
+
+// stripLineNumbers removes the "%6d: " prefixes of --with-line-number, but
+// only when every line carries the expected number. A continued section does
+// not start at line 1, so numbering may start anywhere.
+func stripLineNumbers(lines []string) []string {
+	if len(lines) == 0 {
+		return lines
+	}
+	first := lineNumberLineRegexp.FindStringSubmatch(lines[0])
+	if first == nil {
+		return lines
+	}
+	start, err := strconv.Atoi(first[1])
+	if err != nil {
+		return lines
+	}
+	for i, line := range lines {
+		m := lineNumberLineRegexp.FindStringSubmatch(line)
+		if m == nil || m[1] != strconv.Itoa(start+i) {
+			return lines
+		}
+	}
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		stripped[i] = line[len(lineNumberLineRegexp.FindString(line)):]
+	}
+	return stripped
+}
+
+func joinDumpLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func decodeMarkdownDump(data []byte) ([]*RestoredFile, error) {
+	lines := splitDumpLines(data)
+	root := dumpRootFrom(lines, "**Root Directory:** ")
+
+	var files []*RestoredFile
+	for i := 0; i < len(lines); i++ {
+		m := mdSectionRegexp.FindStringSubmatch(lines[i])
+		if m == nil || i+1 >= len(lines) || !strings.HasPrefix(lines[i+1], "```") {
+			continue
+		}
+
+		// the closing fence is the one followed by the next section or the end of the chunk
+		start := i + 2
+		end := -1
+		for j := start; j < len(lines); j++ {
+			if lines[j] != "```" {
+				continue
+			}
+			if j+1 == len(lines) || (j+4 < len(lines) && lines[j+1] == "" && lines[j+2] == "---" && lines[j+3] == "" && mdSectionRegexp.MatchString(lines[j+4])) {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated code block for %s", m[1])
+		}
+
+		files = append(files, &RestoredFile{
+			Path:      relativeDumpPath(m[1], root),
+			Content:   joinDumpLines(lines[start:end]),
+			continued: m[2] != "",
+		})
+		i = end
+	}
+
+	return mergeRestoredFiles(files)
+}
+
+// decodeXMLDump reads the files of an xml dump. Like every XML parser,
+// encoding/xml turns CR LF into LF, so CRLF files come back with LF endings.
+func decodeXMLDump(data []byte) ([]*RestoredFile, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+
+	var files []*RestoredFile
+	var dirs []string
+	var current *RestoredFile
+	var text strings.Builder
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "directory":
+				dirs = append(dirs, xmlAttr(t, "name"))
+			case "file":
+				current = &RestoredFile{
+					Path:      path.Join(append(append([]string{}, dirs...), xmlAttr(t, "name"))...),
+					continued: xmlAttr(t, "continued") == "true",
+				}
+				text.Reset()
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "directory":
+				if len(dirs) > 0 {
+					dirs = dirs[:len(dirs)-1]
+				}
+			case "file":
+				if current != nil {
+					// the dumper wraps the content in "\n<![CDATA[\n" and "\n]]>\n"
+					content := strings.TrimPrefix(text.String(), "\n\n")
+					current.Content = strings.TrimSuffix(content, "\n\n")
+					files = append(files, current)
+					current = nil
+				}
+			}
+		case xml.CharData:
+			if current != nil {
+				text.Write(t)
+			}
+		}
+	}
+
+	return mergeRestoredFiles(files)
+}
+
+func xmlAttr(e xml.StartElement, name string) string {
+	for _, a := range e.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+func decodeArkliteDump(data []byte) ([]*RestoredFile, error) {
+	lines := splitDumpLines(data)
+
+	start, lossless := -1, false
+	for i, line := range lines {
+		if line == arkliteFileDumpHeading || line == arkliteLosslessFileDumpHeading {
+			start, lossless = i+1, line == arkliteLosslessFileDumpHeading
+			break
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("arklite file dump section not found")
+	}
+
+	var files []*RestoredFile
+	for i := start; i < len(lines); i++ {
+		rel, ok := strings.CutPrefix(lines[i], "@")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected @path, got %q", i+1, lines[i])
+		}
+		rel, continued := strings.CutSuffix(rel, arkliteContinued)
+		encoded := ""
+		if i+1 < len(lines) {
+			encoded = lines[i+1]
+			i++
+		}
+
+		var content string
+		if lossless {
+			content = DecodeArkliteLossless(encoded)
+		} else {
+			content = strings.ReplaceAll(encoded, newlineToken, "\n")
+			if content != "" && !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+		}
+		if isBinaryStub(content) {
+			continue
+		}
+		files = append(files, &RestoredFile{Path: rel, Content: content, continued: continued})
+	}
+
+	return mergeRestoredFiles(files)
+}
+
+func decodeJSONDump(data []byte) ([]*RestoredFile, error) {
+	var dump JSONDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		return nil, err
+	}
+	var files []*RestoredFile
+	for _, f := range dump.Files {
+		if f.Binary {
+			continue
+		}
+		files = append(files, &RestoredFile{Path: f.Path, Content: f.Content})
+	}
+	return mergeRestoredFiles(files)
+}
+
+func decodeJSONLDump(data []byte) ([]*RestoredFile, error) {
+	var files []*RestoredFile
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var entry JSONFileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		if entry.Binary {
+			continue
+		}
+		files = append(files, &RestoredFile{Path: entry.Path, Content: entry.Content})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return mergeRestoredFiles(files)
+}
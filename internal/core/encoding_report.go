@@ -0,0 +1,111 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// Issues reported by ScanEncodings.
+const (
+	EncodingIssueNonUTF8  = "non-UTF-8"
+	EncodingIssueMixedEOL = "mixed line endings"
+)
+
+// EncodingFinding is a file that ScanEncodings reports for cleanup.
+type EncodingFinding struct {
+	File            string   `json:"file"`
+	Encoding        string   `json:"encoding"`
+	Confidence      float64  `json:"confidence"`
+	BOM             bool     `json:"bom"`
+	EOL             string   `json:"eol"`
+	TrailingNewline bool     `json:"trailingNewline"`
+	Issues          []string `json:"issues"`
+}
+
+// ScanEncodings reports the non-UTF-8 and mixed line ending text files a dump
+// of opt.TargetDirname would include, so the same gitignore rules and filters
+// apply. Paths are relative to the target directory.
+func ScanEncodings(opt *commandline.Option) ([]EncodingFinding, error) {
+	root := opt.TargetDirname
+	paths, err := dumpPaths(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	findings := []EncodingFinding{}
+	for _, fpath := range paths {
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return nil, err
+		}
+		decoded, detected, err := DecodeText(data)
+		if err != nil || IsBinary(decoded) || IsImage(fpath) {
+			continue
+		}
+		meta := newTextMetadata(detected, string(decoded))
+
+		var issues []string
+		if !IsUTF8Encoding(detected.Encoding) {
+			issues = append(issues, EncodingIssueNonUTF8)
+		}
+		if meta.EOL == EOLMixed {
+			issues = append(issues, EncodingIssueMixedEOL)
+		}
+		if len(issues) == 0 {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			rel = fpath
+		}
+		findings = append(findings, EncodingFinding{
+			File:            filepath.ToSlash(rel),
+			Encoding:        meta.Encoding,
+			Confidence:      meta.Confidence,
+			BOM:             meta.BOM,
+			EOL:             meta.EOL,
+			TrailingNewline: meta.TrailingNewline,
+			Issues:          issues,
+		})
+	}
+	return findings, nil
+}
+
+// WriteEncodingReport writes findings as text or JSON.
+func WriteEncodingReport(w io.Writer, findings []EncodingFinding, format model.EncodingReportFormat) error {
+	if format.String() == model.EncodingReportJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Count    int               `json:"count"`
+			Findings []EncodingFinding `json:"findings"`
+		}{len(findings), findings})
+	}
+
+	for _, f := range findings {
+		meta := textMetadata{
+			Encoding:        f.Encoding,
+			Confidence:      f.Confidence,
+			BOM:             f.BOM,
+			EOL:             f.EOL,
+			TrailingNewline: f.TrailingNewline,
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s\n    %s\n", f.File, strings.Join(f.Issues, ", "), meta.summary()); err != nil {
+			return err
+		}
+	}
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "All files are UTF-8 with consistent line endings.")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d file(s) need cleanup.\n", len(findings))
+	return err
+}
@@ -0,0 +1,212 @@
+package core_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createEncodingsTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "utf8.txt"), "héllo\n")
+	mustWriteFile(t, filepath.Join(root, "crlf.txt"), "a\r\nb\r\n")
+	mustWriteFile(t, filepath.Join(root, "mixed.txt"), "a\r\nb\nc")
+	mustWriteFile(t, filepath.Join(root, "latin.txt"), "caf\xe9 na\xefve r\xe9sum\xe9\n")
+	if err := os.WriteFile(filepath.Join(root, "blob.bin"), []byte{0x00, 0x01, 0x02}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func createEncodingsOption(root string) *commandline.Option {
+	return &commandline.Option{
+		TargetDirname:      root,
+		OutputFormat:       model.OutputFormat(model.PlainText),
+		WithLineNumberFlag: model.OnOffSwitch("off"),
+		IgnoreDotFileFlag:  model.OnOffSwitch("off"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+	}
+}
+
+func TestDetectEOL(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+	}{
+		{"a\nb\n", core.EOLLF},
+		{"a\r\nb\r\n", core.EOLCRLF},
+		{"a\r\nb\n", core.EOLMixed},
+		{"no line break", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := core.DetectEOL(tt.text); got != tt.expected {
+			t.Errorf("DetectEOL(%q) = %q, want %q", tt.text, got, tt.expected)
+		}
+	}
+}
+
+func TestScanEncodings(t *testing.T) {
+	root := createEncodingsTree(t)
+
+	findings, err := core.ScanEncodings(createEncodingsOption(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	issues := map[string]string{}
+	for _, f := range findings {
+		issues[f.File] = strings.Join(f.Issues, ", ")
+	}
+	expected := map[string]string{
+		"latin.txt": core.EncodingIssueNonUTF8,
+		"mixed.txt": core.EncodingIssueMixedEOL,
+	}
+	if len(issues) != len(expected) {
+		t.Errorf("expected %v, got %v", expected, issues)
+	}
+	for file, issue := range expected {
+		if issues[file] != issue {
+			t.Errorf("%s: expected %q, got %q", file, issue, issues[file])
+		}
+	}
+
+	var text bytes.Buffer
+	if err := core.WriteEncodingReport(&text, findings, model.EncodingReportFormat(model.EncodingReportText)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text.String(), "mixed.txt: mixed line endings\n    ASCII 1.00, mixed EOL, no trailing newline\n") {
+		t.Errorf("unexpected text report:\n%s", text.String())
+	}
+	if !strings.HasSuffix(text.String(), "\n2 file(s) need cleanup.\n") {
+		t.Errorf("missing summary:\n%s", text.String())
+	}
+
+	var report struct {
+		Count    int                    `json:"count"`
+		Findings []core.EncodingFinding `json:"findings"`
+	}
+	var js bytes.Buffer
+	if err := core.WriteEncodingReport(&js, findings, model.EncodingReportFormat(model.EncodingReportJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(js.Bytes(), &report); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if report.Count != 2 || report.Findings[0].File != "latin.txt" || report.Findings[0].Encoding != "Windows-1252" {
+		t.Errorf("unexpected JSON report: %s", js.String())
+	}
+}
+
+func TestScanEncodings_Clean(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "main.go"), "package main\n")
+
+	findings, err := core.ScanEncodings(createEncodingsOption(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text bytes.Buffer
+	if err := core.WriteEncodingReport(&text, findings, model.EncodingReportFormat(model.EncodingReportText)); err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 || text.String() != "All files are UTF-8 with consistent line endings.\n" {
+		t.Errorf("expected a clean report, got:\n%s", text.String())
+	}
+}
+
+func TestScanEncodings_EmptyFile(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "empty.txt"), "")
+
+	findings, err := core.ScanEncodings(createEncodingsOption(root))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("an empty file must not be reported, got %+v", findings)
+	}
+
+	// nor skipped as non-UTF-8, and its header names an encoding
+	opt := createEncodingsOption(root)
+	opt.SkipNonUTF8Flag = true
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if err := core.WriteAllFiles("tree", root, out, nil, opt); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if header := "=== " + filepath.Join(root, "empty.txt") + " (ASCII 1.00, no trailing newline) ===\n"; !strings.Contains(string(data), header) {
+		t.Errorf("missing header %q in:\n%s", header, data)
+	}
+}
+
+func TestWriteAllFiles_TextMetadata(t *testing.T) {
+	root := createEncodingsTree(t)
+
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if err := core.WriteAllFiles("tree", root, out, nil, createEncodingsOption(root)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	for _, header := range []string{
+		"=== " + filepath.Join(root, "crlf.txt") + " (ASCII 1.00, CRLF) ===\n",
+		"=== " + filepath.Join(root, "mixed.txt") + " (ASCII 1.00, mixed EOL, no trailing newline) ===\n",
+		"=== " + filepath.Join(root, "latin.txt") + " (Windows-1252 0.90, LF) ===\n",
+	} {
+		if !strings.Contains(string(data), header) {
+			t.Errorf("missing header %q in:\n%s", header, data)
+		}
+	}
+
+	// the headers must not get in the way of restoring the dump
+	files, err := core.DecodeDump(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		if f.Path == filepath.Join(root, "latin.txt") && f.Content != "café naïve résumé\n" {
+			t.Errorf("unexpected restored content: %q", f.Content)
+		}
+	}
+	if len(files) != 4 {
+		t.Errorf("expected 4 restored files, got %d", len(files))
+	}
+
+	out = filepath.Join(t.TempDir(), "out.xml")
+	if err := core.WriteAllFilesAsXML("tree", root, out, nil, createEncodingsOption(root)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	expected := `<file name="crlf.txt" language="text" encoding="ASCII" confidence="1.00" bom="false" eol="CRLF" trailing-newline="true">`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("missing %s in:\n%s", expected, data)
+	}
+
+	out = filepath.Join(t.TempDir(), "out.jsonl")
+	if err := core.WriteAllFilesAsJSONL(root, out, nil, createEncodingsOption(root)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ = os.ReadFile(out)
+	entries := map[string]core.JSONFileEntry{}
+	for line := range strings.SplitSeq(strings.TrimSpace(string(data)), "\n") {
+		var entry core.JSONFileEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		entries[entry.Path] = entry
+	}
+	if e := entries["mixed.txt"]; e.EOL != core.EOLMixed || e.TrailingNewline || e.Confidence != 1 {
+		t.Errorf("unexpected entry for mixed.txt: %+v", e)
+	}
+	if e := entries["latin.txt"]; e.Encoding != "Windows-1252" || e.EOL != core.EOLLF || !e.TrailingNewline {
+		t.Errorf("unexpected entry for latin.txt: %+v", e)
+	}
+}
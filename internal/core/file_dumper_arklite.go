@@ -1,93 +1,178 @@
 package core
 
 import (
-	"bufio"
-	"bytes"
 	"fmt"
-	"log"
-	"os"
 	"path/filepath"
-	"unicode/utf8"
+	"strings"
 
 	"github.com/magicdrive/ark/internal/commandline"
-	"github.com/magicdrive/ark/internal/secrets"
 	"github.com/magicdrive/ark/internal/textbank"
 )
 
 const newlineToken = "␤"
 
+const (
+	arkliteFileDumpHeading         = "## File Dump"
+	arkliteLosslessFileDumpHeading = "## File Dump (lossless)"
+)
+
 func WriteAllFilesAsArklite(treeStr, root, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
-	outFile, err := os.Create(outputPath)
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
 	if err != nil {
 		return err
 	}
-	defer outFile.Close()
-
-	writer := bufio.NewWriter(outFile)
-	defer writer.Flush()
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFilesAsArklite(treeStr, tree, outputPath, opt, budget)
+	return err
+}
 
+func writeAllFilesAsArklite(treeStr string, tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	root := tree.Root
 	abspath, _ := filepath.Abs(root)
 	projectName := filepath.Base(abspath)
 
-	fmt.Fprintf(writer, textbank.DescriptionTemplateArklite, projectName, abspath)
-	writer.WriteString("## Directory Tree (JSON)\n")
-	writer.WriteString(treeStr)
-	writer.WriteString("\n")
-	writer.WriteString("\n")
-	writer.WriteString("## File Dump\n")
+	template, heading := textbank.DescriptionTemplateArklite, arkliteFileDumpHeading
+	if opt.ArkliteLosslessFlag {
+		template, heading = textbank.DescriptionTemplateArkliteLossless, arkliteLosslessFileDumpHeading
+	}
+	header := fmt.Sprintf(template, projectName, abspath)
+
+	var treeSection strings.Builder
+	treeSection.WriteString("## Directory Tree (JSON)\n")
+	treeSection.WriteString(treeStr)
+	treeSection.WriteString("\n")
+	treeSection.WriteString("\n")
+	treeSection.WriteString(heading + "\n")
+	budget.Reserve(header + treeSection.String())
+
+	stage := newFileStage(opt)
+	if !opt.ArkliteLosslessFlag {
+		// plain arklite always strips block and line comments
+		stage.deleteComments = true
+	}
+	files, err := budget.plan(stage.run(tree.Files()), processedContent)
+	if err != nil {
+		return nil, err
+	}
 
-	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+	writer, err := newChunkWriter(outputPath, root, header, opt)
+	if err != nil {
+		return nil, err
+	}
+	writer.reopen = func() string {
+		return heading + "\n"
+	}
+	// arklite keeps a file on one line, so oversized files are split after a newline token
+	writer.splitAfter = newlineToken
+	writer.continuation = func(fpath string) (string, string) {
+		rel, _ := filepath.Rel(root, fpath)
+		return "\n", "@" + filepath.ToSlash(rel) + arkliteContinued + "\n"
+	}
+	writer.WriteString(treeSection.String())
 
-		if err != nil || d.IsDir() {
-			return err
+	for r := range files {
+		if err = r.Err; err != nil {
+			break
+		}
+		if r.File == nil {
+			continue
+		}
+		content, ok := budget.Fit(r.Path, r.File.Content)
+		if !ok {
+			continue
 		}
 
-		rel, _ := filepath.Rel(root, path)
+		rel, _ := filepath.Rel(root, r.Path)
 		rel = filepath.ToSlash(rel)
-		if allowedFileListMap != nil {
-			if _, ok := allowedFileListMap[path]; !ok {
-				return nil
-			}
-		}
 
-		data, err := os.ReadFile(path)
-		if err != nil {
-			log.Println(err.Error())
-			return err
+		var encoded string
+		if r.File.Binary != nil {
+			encoded = r.File.Binary.Stub
+		} else if opt.ArkliteLosslessFlag {
+			encoded = EncodeArkliteLossless(content)
+		} else {
+			encoded = compactArklite(content)
 		}
-		if !utf8.Valid(data) {
-			return nil // skip non-UTF-8 or binary
+		if err = writer.WriteSection(r.Path, "@"+rel+"\n"+encoded+"\n"); err != nil {
+			break
 		}
+	}
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
 
-		// Strip block and line comments
-		lang := detectLanguageTag(path)
-		pattern := getCommentDelimiters(lang)
-		data = stripComments(data, pattern)
+	return writer.Close()
+}
 
-		if opt.MaskSecretsFlag.Bool() {
-			content := secrets.MaskAll(string(data))
-			data = []byte(content)
+// compactArklite trims every line, drops blank lines and joins the rest with ␤.
+func compactArklite(content string) string {
+	var compact strings.Builder
+	for line := range strings.SplitSeq(content, "\n") {
+		trim := strings.TrimSpace(line)
+		if len(trim) == 0 {
+			continue
 		}
-		lines := bytes.Split(data, []byte("\n"))
-
-		var compact bytes.Buffer
-		for _, line := range lines {
-			trim := bytes.TrimSpace(line)
-			if len(trim) == 0 {
-				continue
-			}
-			if compact.Len() > 0 {
-				compact.WriteString(newlineToken)
-			}
-			compact.Write(trim)
+		if compact.Len() > 0 {
+			compact.WriteString(newlineToken)
 		}
+		compact.WriteString(trim)
+	}
+	return compact.String()
+}
 
-		writer.WriteString("@" + rel + "\n")
-		writer.Write(compact.Bytes())
-		writer.WriteByte('\n')
-
-		return nil
-	})
+// EncodeArkliteLossless puts content on a single line so that DecodeArkliteLossless
+// restores it byte-exact. Newlines become ␤, a literal ␤ becomes \␤ and
+// backslashes directly before either are doubled.
+func EncodeArkliteLossless(content string) string {
+	var b strings.Builder
+	backslashes := 0
+	for _, r := range content {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '\n':
+			b.WriteString(strings.Repeat(`\`, backslashes*2))
+			b.WriteString(newlineToken)
+		case '␤':
+			b.WriteString(strings.Repeat(`\`, backslashes*2+1))
+			b.WriteString(newlineToken)
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteRune(r)
+		}
+		backslashes = 0
+	}
+	b.WriteString(strings.Repeat(`\`, backslashes))
+	return b.String()
+}
 
-	return err
+// DecodeArkliteLossless reverses EncodeArkliteLossless.
+func DecodeArkliteLossless(line string) string {
+	var b strings.Builder
+	backslashes := 0
+	for _, r := range line {
+		switch r {
+		case '\\':
+			backslashes++
+			continue
+		case '␤':
+			b.WriteString(strings.Repeat(`\`, backslashes/2))
+			if backslashes%2 == 0 {
+				b.WriteByte('\n')
+			} else {
+				b.WriteString(newlineToken)
+			}
+		default:
+			b.WriteString(strings.Repeat(`\`, backslashes))
+			b.WriteRune(r)
+		}
+		backslashes = 0
+	}
+	b.WriteString(strings.Repeat(`\`, backslashes))
+	return b.String()
 }
@@ -82,3 +82,57 @@ func writeFile(t *testing.T, path, content string) {
 		t.Fatalf("failed to write %s: %v", path, err)
 	}
 }
+
+func TestArkliteLossless_RoundTrip(t *testing.T) {
+	cases := []string{
+		"",
+		"plain",
+		"a\nb\n",
+		"\n\n  indented\n\n",
+		"literal ␤ token\n",
+		`ends with backslash\`,
+		"backslash before newline\\\nnext",
+		"two backslashes\\\\\nnext",
+		`escaped \␤ literal`,
+		"\\\\␤\\",
+		"crlf\r\nline\r\n",
+	}
+	for _, c := range cases {
+		encoded := core.EncodeArkliteLossless(c)
+		if strings.Contains(encoded, "\n") {
+			t.Errorf("encoded %q contains a newline: %q", c, encoded)
+		}
+		if got := core.DecodeArkliteLossless(encoded); got != c {
+			t.Errorf("round trip of %q = %q (encoded %q)", c, got, encoded)
+		}
+	}
+}
+
+func TestWriteAllFilesAsArklite_Lossless(t *testing.T) {
+	root := t.TempDir()
+	content := "package main\n\n// keep me\nfunc main() {\n\tprintln(\"␤\")\n}\n"
+	writeFile(t, filepath.Join(root, "main.go"), content)
+	allowed := map[string]bool{filepath.Join(root, "main.go"): true}
+	outputFile := filepath.Join(t.TempDir(), "output.arklite")
+
+	opt := &commandline.Option{
+		IgnoreDotFileFlag:   model.OnOffSwitch("off"),
+		MaskSecretsFlag:     model.OnOffSwitch("off"),
+		ArkliteLosslessFlag: true,
+	}
+	if err := core.WriteAllFilesAsArklite("{}", root, outputFile, allowed, opt); err != nil {
+		t.Fatalf("WriteAllFilesAsArklite failed: %v", err)
+	}
+
+	data, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := string(data)
+	if !strings.Contains(out, "## File Dump (lossless)\n@main.go\n") {
+		t.Errorf("missing lossless file dump section:\n%s", out)
+	}
+	if !strings.Contains(out, "// keep me") {
+		t.Errorf("lossless arklite must keep comments")
+	}
+}
@@ -0,0 +1,203 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"iter"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// JSONFileEntry is a single file of a json or jsonl dump.
+type JSONFileEntry struct {
+	Path     string `json:"path"`
+	Language string `json:"language"`
+	Size     int    `json:"size"`
+	Encoding string `json:"encoding"`
+	Lines    int    `json:"lines"`
+	Content  string `json:"content"`
+	// how the file is stored on disk, zero for binary files
+	Confidence      float64 `json:"confidence"`
+	BOM             bool    `json:"bom"`
+	EOL             string  `json:"eol"`
+	TrailingNewline bool    `json:"trailingNewline"`
+	// set for the metadata stubs of --binary-metadata
+	Binary bool   `json:"binary,omitempty"`
+	MIME   string `json:"mime,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// JSONDump is the document written by the json output format.
+type JSONDump struct {
+	Project string           `json:"project"`
+	Root    string           `json:"root"`
+	Tree    *TreeEntry       `json:"tree"`
+	Files   []*JSONFileEntry `json:"files"`
+}
+
+// WriteAllFilesAsJSON writes a json document with the tree and a files array.
+// treeStr is the JSON tree from GenerateTreeJSONString.
+func WriteAllFilesAsJSON(treeStr string, root string, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
+	if err != nil {
+		return err
+	}
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFilesAsJSON(treeStr, tree, outputPath, opt, budget)
+	return err
+}
+
+// WriteAllFilesAsJSONL writes one JSONFileEntry object per line.
+func WriteAllFilesAsJSONL(root string, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
+	if err != nil {
+		return err
+	}
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFilesAsJSONL(tree, outputPath, opt, budget)
+	return err
+}
+
+func writeAllFilesAsJSON(treeStr string, tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	root := tree.Root
+	abspath, err := filepath.Abs(root)
+	if err != nil {
+		abspath = root
+	}
+	project, _ := json.Marshal(filepath.Base(abspath))
+	rootPath, _ := json.Marshal(abspath)
+
+	header := fmt.Sprintf("{\n  \"project\": %s,\n  \"root\": %s,\n", project, rootPath)
+	treeSection := fmt.Sprintf("  \"tree\": %s,\n  \"files\": [\n", treeStr)
+	budget.Reserve(header + treeSection)
+
+	files, err := budget.plan(newFileStage(opt).run(tree.Files()), processedContent)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := newChunkWriter(outputPath, root, header, opt)
+	if err != nil {
+		return nil, err
+	}
+	// every chunk is a complete document; the tree is only in the first one
+	writer.reopen = func() string {
+		return "  \"files\": [\n"
+	}
+	writer.footer = func() string {
+		return "\n  ]\n}\n"
+	}
+	writer.separator = ",\n"
+	writer.WriteString(treeSection)
+
+	err = walkJSONFileEntries(root, files, budget, func(fpath string, entry *JSONFileEntry) error {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return writer.WriteSection(fpath, "    "+string(line))
+	})
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return writer.Close()
+}
+
+func writeAllFilesAsJSONL(tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	files, err := budget.plan(newFileStage(opt).run(tree.Files()), processedContent)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := newChunkWriter(outputPath, tree.Root, "", opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = walkJSONFileEntries(tree.Root, files, budget, func(fpath string, entry *JSONFileEntry) error {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return writer.WriteSection(fpath, string(line)+"\n")
+	})
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return writer.Close()
+}
+
+// walkJSONFileEntries calls fn for every dumped file of files, in tree order.
+func walkJSONFileEntries(root string, files iter.Seq[*processedFile], budget *TokenBudget, fn func(fpath string, entry *JSONFileEntry) error) error {
+	for r := range files {
+		if r.Err != nil {
+			return r.Err
+		}
+		if r.File == nil {
+			continue
+		}
+		content, ok := budget.Fit(r.Path, r.File.Content)
+		if !ok {
+			continue
+		}
+
+		rel, err := filepath.Rel(root, r.Path)
+		if err != nil {
+			rel = r.Path
+		}
+		entry := &JSONFileEntry{
+			Path:     filepath.ToSlash(rel),
+			Language: detectLanguageTag(r.Path),
+			Size:     r.File.Size,
+			Encoding: r.File.Encoding,
+			Lines:    countLines(content),
+			Content:  content,
+
+			Confidence:      r.File.Confidence,
+			BOM:             r.File.BOM,
+			EOL:             r.File.EOL,
+			TrailingNewline: r.File.TrailingNewline,
+		}
+		if binary := r.File.Binary; binary != nil {
+			entry.Language, entry.Lines, entry.Content = "binary", 0, ""
+			entry.Binary, entry.MIME, entry.SHA256 = true, binary.MIME, binary.SHA256
+			entry.Width, entry.Height = binary.Width, binary.Height
+		}
+		err = fn(r.Path, entry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func countLines(content string) int {
+	if content == "" {
+		return 0
+	}
+	n := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") {
+		n++
+	}
+	return n
+}
+
+// isJSONFormat reports whether the format is json or jsonl.
+func isJSONFormat(format model.OutputFormat) bool {
+	return format.String() == model.JSON || format.String() == model.JSONL
+}
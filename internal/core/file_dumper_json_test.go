@@ -0,0 +1,167 @@
+package core_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createJSONTestTree(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "subdir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "subdir", "sub.txt"), []byte("sub content"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, map[string]bool{
+		filepath.Join(dir, "main.go"):           true,
+		filepath.Join(dir, "subdir"):            true,
+		filepath.Join(dir, "subdir", "sub.txt"): true,
+	}
+}
+
+func createJSONTestOption(format string) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(format),
+		WithLineNumberFlag: model.OnOffSwitch("on"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+		IgnoreDotFileFlag:  model.OnOffSwitch("off"),
+		AllowGitignoreFlag: model.OnOffSwitch("off"),
+	}
+}
+
+func TestWriteAllFilesAsJSON_Basic(t *testing.T) {
+	dir, fileList := createJSONTestTree(t)
+	outFile := filepath.Join(t.TempDir(), "out.json")
+	opt := createJSONTestOption(model.JSON)
+
+	treeStr, _, err := core.GenerateTreeJSONString(dir, map[string]bool{}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.WriteAllFilesAsJSON(treeStr, dir, outFile, fileList, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dump core.JSONDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, data)
+	}
+
+	if dump.Tree == nil || dump.Tree.Type != "directory" || len(dump.Tree.Children) != 2 {
+		t.Errorf("unexpected tree: %+v", dump.Tree)
+	}
+	if len(dump.Files) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(dump.Files))
+	}
+	main := dump.Files[0]
+	if main.Path != "main.go" || main.Language != "go" || main.Lines != 2 || main.Size != 28 || main.Encoding != "ASCII" {
+		t.Errorf("unexpected entry: %+v", main)
+	}
+	if main.Content != "package main\nfunc main() {}\n" {
+		t.Errorf("content must be raw, without line numbers: %q", main.Content)
+	}
+	if dump.Files[1].Path != "subdir/sub.txt" || dump.Files[1].Lines != 1 {
+		t.Errorf("unexpected entry: %+v", dump.Files[1])
+	}
+}
+
+func TestWriteAllFilesAsJSON_Empty(t *testing.T) {
+	dir := t.TempDir()
+	outFile := filepath.Join(t.TempDir(), "out.json")
+	if err := core.WriteAllFilesAsJSON(`{"name":"x","type":"directory"}`, dir, outFile, map[string]bool{}, createJSONTestOption(model.JSON)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(outFile)
+	var dump core.JSONDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		t.Fatalf("output is not valid JSON: %v\n%s", err, data)
+	}
+	if len(dump.Files) != 0 {
+		t.Errorf("expected no files, got %d", len(dump.Files))
+	}
+}
+
+func TestWriteAllFilesAsJSONL_Basic(t *testing.T) {
+	dir, fileList := createJSONTestTree(t)
+	outFile := filepath.Join(t.TempDir(), "out.jsonl")
+
+	if err := core.WriteAllFilesAsJSONL(dir, outFile, fileList, createJSONTestOption(model.JSONL)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := os.Open(outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var paths []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var entry core.JSONFileEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("line is not valid JSON: %v\n%s", err, scanner.Text())
+		}
+		paths = append(paths, entry.Path)
+	}
+	if got := strings.Join(paths, ","); got != "main.go,subdir/sub.txt" {
+		t.Errorf("unexpected paths: %s", got)
+	}
+}
+
+func TestWriteAllFilesAsJSON_SplitChunksAreValid(t *testing.T) {
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(strings.Repeat("some line of text\n", 30)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	outFile := filepath.Join(t.TempDir(), "out.json")
+	opt := createJSONTestOption(model.JSON)
+	opt.SplitSizeValue = "1k"
+	opt.SplitSize = model.ByteString("1k")
+
+	if err := core.WriteAllFilesAsJSON(`{"name":"x","type":"directory"}`, root, outFile, allowed, opt); err != nil {
+		t.Fatal(err)
+	}
+	m := readManifest(t, outFile)
+	if len(m.Chunks) < 2 {
+		t.Fatalf("expected several chunks, got %d", len(m.Chunks))
+	}
+	total := 0
+	for _, c := range m.Chunks {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(outFile), c.Filename))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var dump core.JSONDump
+		if err := json.Unmarshal(data, &dump); err != nil {
+			t.Fatalf("chunk %s is not valid JSON: %v\n%s", c.Filename, err, data)
+		}
+		total += len(dump.Files)
+	}
+	if total != 4 {
+		t.Errorf("expected 4 files across chunks, got %d", total)
+	}
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/textbank"
+)
+
+func WriteAllFilesAsOutline(treeStr string, root string, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
+	if err != nil {
+		return err
+	}
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFilesAsOutline(treeStr, tree, outputPath, opt, budget)
+	return err
+}
+
+// writeAllFilesAsOutline writes the declarations of every file instead of
+// its content, as a table of contents of the project.
+func writeAllFilesAsOutline(treeStr string, tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	root := tree.Root
+	abspath, err := filepath.Abs(root)
+	if err != nil {
+		abspath = root
+	}
+	projectName := filepath.Base(abspath)
+
+	header := fmt.Sprintf(textbank.DescriptionTemplateOutline, projectName, root)
+	treeSection := root + "\n" + treeStr + "\n"
+	budget.Reserve(header + treeSection)
+
+	// line numbers must match the files on disk
+	stage := newFileStage(opt)
+	stage.deleteComments = false
+	stage.compactGo = model.CompactGoOff
+	stage.maxLines = 0
+	stage.binaryMetadata = false
+
+	files, err := budget.plan(stage.run(tree.Files()), func(r *processedFile) string {
+		return OutlineFile(r.Path, r.File.Content)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := newChunkWriter(outputPath, root, header, opt)
+	if err != nil {
+		return nil, err
+	}
+	writer.continuation = func(fpath string) (string, string) {
+		return "", fmt.Sprintf("\n=== %s (continued) ===\n", fpath)
+	}
+	writer.WriteString(treeSection)
+
+	for r := range files {
+		if err = r.Err; err != nil {
+			break
+		}
+		if r.File == nil {
+			continue
+		}
+		outline, ok := budget.Fit(r.Path, OutlineFile(r.Path, r.File.Content))
+		if !ok {
+			continue
+		}
+		if err = writer.WriteSection(r.Path, renderOutlineSection(r.Path, r.File.Content, outline)); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
+
+	return writer.Close()
+}
+
+// OutlineSection renders the header and the outline of a single file.
+func OutlineSection(fpath string, content string) string {
+	return renderOutlineSection(fpath, content, OutlineFile(fpath, content))
+}
+
+// renderOutlineSection renders the header and the outline of a single file.
+func renderOutlineSection(fpath string, content string, outline string) string {
+	lines := strings.Count(content, "\n")
+	if len(content) > 0 && !strings.HasSuffix(content, "\n") {
+		lines++
+	}
+	return fmt.Sprintf("\n=== %s (%d lines) ===\n%s", fpath, lines, outline)
+}
@@ -2,131 +2,157 @@ package core
 
 import (
 	"bufio"
-	"bytes"
 	"fmt"
-	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/magicdrive/ark/internal/commandline"
 	"github.com/magicdrive/ark/internal/model"
-	"github.com/magicdrive/ark/internal/secrets"
 	"github.com/magicdrive/ark/internal/textbank"
 )
 
 func WriteAllFiles(treeStr string, root string, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
-	outFile, err := os.Create(outputPath)
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
 	if err != nil {
 		return err
 	}
-	defer outFile.Close()
-
-	writer := bufio.NewWriter(outFile)
-	defer writer.Flush()
-
-	var abspath string
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFiles(treeStr, tree, outputPath, opt, budget)
+	return err
+}
 
-	abspath, err = filepath.Abs(root)
+func writeAllFiles(treeStr string, tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	root := tree.Root
+	abspath, err := filepath.Abs(root)
 	if err != nil {
 		abspath = root
 	}
 	projectName := filepath.Base(abspath)
 
-	writer.WriteString(PrependDescriptionWithFormat(projectName, root, opt.OutputFormat))
+	header := PrependDescriptionWithFormat(projectName, root, opt.OutputFormat, binaryNote(opt.OutputFormat.String(), opt))
 
+	var treeSection string
 	if opt.OutputFormat == "markdown" {
-		writer.WriteString("# Project Tree\n\n```\n" + root + "\n" + treeStr + "\n```\n")
+		treeSection = "# Project Tree\n\n```\n" + root + "\n" + treeStr + "\n```\n"
 	} else {
-		writer.WriteString(root + "\n" + treeStr + "\n")
+		treeSection = root + "\n" + treeStr + "\n"
 	}
+	budget.Reserve(header + treeSection)
 
-	err = filepath.WalkDir(root, func(fpath string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
-		}
+	files, err := budget.plan(newFileStage(opt).run(tree.Files()), processedContent)
+	if err != nil {
+		return nil, err
+	}
 
-		if IsUnderGitDir(fpath) {
-			return nil
+	writer, err := newChunkWriter(outputPath, root, header, opt)
+	if err != nil {
+		return nil, err
+	}
+	writer.continuation = func(fpath string) (string, string) {
+		if opt.OutputFormat == "markdown" {
+			return "```\n", fmt.Sprintf("\n---\n\n# File: %s (continued)\n```%s\n", fpath, detectLanguageTag(fpath))
 		}
+		return "", fmt.Sprintf("\n=== %s (continued) ===\n", fpath)
+	}
+	writer.WriteString(treeSection)
 
-		if d.IsDir() {
-			return nil
+	for r := range files {
+		if err = r.Err; err != nil {
+			break
 		}
-
-		if _, ok := allowedFileListMap[fpath]; !ok {
-			return nil
+		if r.File == nil {
+			continue
 		}
-
-		data, err := os.ReadFile(fpath)
-		if err != nil {
-			return err
+		content, ok := budget.Fit(r.Path, r.File.Content)
+		if !ok {
+			continue
 		}
-		if IsBinary(data) || IsImage(fpath) {
-			return nil
+		var section string
+		if r.File.Binary != nil {
+			section = renderBinaryTextSection(r.Path, r.File.Binary, content, opt)
+		} else if section, err = renderTextSection(r.Path, content, r.File.textMetadata, opt); err != nil {
+			break
 		}
-
-		decoded, err := ConvertToUTF8(bytes.NewReader(data))
-		if err != nil {
-			if opt.SkipNonUTF8Flag {
-				return nil
-			}
-			return fmt.Errorf("failed to convert %s: %w", fpath, err)
+		if err = writer.WriteSection(r.Path, section); err != nil {
+			break
 		}
+	}
+	if err != nil {
+		writer.Close()
+		return nil, err
+	}
 
-		decodedBytes, err := io.ReadAll(decoded)
-		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", fpath, err)
-		}
+	return writer.Close()
+}
 
-		if opt.DeleteCommentsFlag {
-			decodedBytes = DeleteComments(decodedBytes, fpath)
-		}
+// renderTextSection renders the txt or markdown section of a single file.
+// The header carries the encoding and line endings of the file on disk.
+func renderTextSection(fpath string, content string, meta textMetadata, opt *commandline.Option) (string, error) {
+	var section strings.Builder
 
-		var content = string(decodedBytes)
+	if opt.OutputFormat == "markdown" {
+		section.WriteString("\n---\n\n")
+		fmt.Fprintf(&section, "# File: %s (%s)\n", fpath, meta.summary())
+		fmt.Fprintf(&section, "```%s\n", detectLanguageTag(fpath))
+	} else {
+		fmt.Fprintf(&section, "\n=== %s (%s) ===\n", fpath, meta.summary())
+	}
 
-		if opt.MaskSecretsFlag.Bool() {
-			content = secrets.MaskAll(content)
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	maxCapacity, _ := opt.ScanBuffer.Bytes()
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, maxCapacity)
+
+	lineNumber := 1
+	for scanner.Scan() {
+		line := scanner.Text()
+		if n, ok := TruncatedLines(line); ok && opt.WithLineNumberFlag.Bool() && opt.OutputFormat != "markdown" {
+			// the tail keeps the line numbers it has on disk
+			section.WriteString(line + "\n")
+			lineNumber += n
+			continue
 		}
-
-		if opt.OutputFormat == "markdown" {
-			writer.WriteString("\n---\n\n")
-			fmt.Fprintf(writer, "# File: %s\n", fpath)
-			fmt.Fprintf(writer, "```%s\n", detectLanguageTag(fpath))
+		if opt.WithLineNumberFlag.Bool() && opt.OutputFormat != "markdown" {
+			fmt.Fprintf(&section, "%6d: %s\n", lineNumber, line)
 		} else {
-			fmt.Fprintf(writer, "\n=== %s ===\n", fpath)
+			section.WriteString(line + "\n")
 		}
+		lineNumber++
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
 
-		scanner := bufio.NewScanner(strings.NewReader(content))
-		maxCapacity, _ := opt.ScanBuffer.Bytes()
-		buf := make([]byte, 0, 64*1024)
-		scanner.Buffer(buf, maxCapacity)
-
-		lineNumber := 1
-		for scanner.Scan() {
-			line := scanner.Text()
-			if opt.WithLineNumberFlag.Bool() && opt.OutputFormat != "markdown" {
-				fmt.Fprintf(writer, "%6d: %s\n", lineNumber, line)
-			} else {
-				writer.WriteString(line + "\n")
-			}
-			lineNumber++
-		}
-		if err := scanner.Err(); err != nil {
-			return err
-		}
+	if opt.OutputFormat == "markdown" {
+		section.WriteString("```\n")
+	}
+	return section.String(), nil
+}
 
-		if opt.OutputFormat == "markdown" {
-			writer.WriteString("```\n")
+// renderBinaryTextSection renders the metadata stub of a binary file. Markdown
+// shows an embedded image below the stub.
+func renderBinaryTextSection(fpath string, binary *binaryFile, content string, opt *commandline.Option) string {
+	var section strings.Builder
+	if opt.OutputFormat == "markdown" {
+		section.WriteString("\n---\n\n")
+		fmt.Fprintf(&section, "# File: %s\n", fpath)
+		section.WriteString(binary.Stub + "\n")
+		if uri := binary.embedded(content); uri != "" {
+			fmt.Fprintf(&section, "\n![%s](%s)\n", filepath.Base(fpath), uri)
 		}
-		return nil
-	})
-
-	return err
+	} else {
+		fmt.Fprintf(&section, "\n=== %s ===\n", fpath)
+		section.WriteString(binary.Stub + "\n")
+	}
+	return section.String()
 }
 
 // PrependDescriptionWithFormat prepends a descriptive header suitable for AI processing in either plain text or markdown format.
-func PrependDescriptionWithFormat(projectName, root string, format model.OutputFormat) string {
+// note is the line on how binary files are dumped.
+func PrependDescriptionWithFormat(projectName, root string, format model.OutputFormat, note string) string {
 	projectName = strings.TrimSpace(projectName)
 	root = strings.TrimSpace(root)
 
@@ -134,10 +160,10 @@ func PrependDescriptionWithFormat(projectName, root string, format model.OutputF
 
 	switch format.String() {
 	case model.Markdown:
-		header = fmt.Sprintf(textbank.DescriptionTemplateMarkdown, projectName, root)
+		header = fmt.Sprintf(textbank.DescriptionTemplateMarkdown, projectName, root, note)
 
 	case model.PlainText:
-		header = fmt.Sprintf(textbank.DescriptionTemplateText, projectName, root)
+		header = fmt.Sprintf(textbank.DescriptionTemplateText, projectName, root, note)
 	default:
 		header = "" // fallback to no header
 	}
@@ -56,7 +56,7 @@ func main() {
 	}
 	content := string(data)
 
-	if !strings.Contains(content, "=== "+testFile+" ===") {
+	if !strings.Contains(content, "=== "+testFile+" (") {
 		t.Errorf("Expected file header not found")
 	}
 	if !strings.Contains(content, "1: package main") {
@@ -95,7 +95,7 @@ func TestReadAndWriteAllFiles_CreatesOutputFile(t *testing.T) {
 	if err != nil {
 		t.Fatalf("could not read output: %v", err)
 	}
-	if !strings.Contains(string(content), "=== "+dummyInput+" ===") {
+	if !strings.Contains(string(content), "=== "+dummyInput+" (") {
 		t.Errorf("expected file header not found in output:\n%s", content)
 	}
 }
@@ -257,6 +257,50 @@ func TestReadAndWriteAllFiles_SkipNonUTF8File(t *testing.T) {
 	}
 }
 
+func TestReadAndWriteAllFiles_ExtendedEncodings(t *testing.T) {
+	dir := t.TempDir()
+	// "héllo wörld" in UTF-16LE with a BOM
+	utf16 := []byte{0xFF, 0xFE}
+	for _, r := range "héllo wörld\n" {
+		utf16 = append(utf16, byte(r), 0x00)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "utf16.txt"), utf16, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// "Größe café" in Windows-1252
+	latin := []byte("Gr\xf6\xdfe caf\xe9\n")
+	if err := os.WriteFile(filepath.Join(dir, "latin.txt"), latin, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opt := &commandline.Option{
+		OutputFormat:       model.OutputFormat("plaintext"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		WithLineNumberFlag: model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+	}
+	outputFile := filepath.Join(t.TempDir(), "output.txt")
+	if err := core.WriteAllFiles("tree", dir, outputFile, nil, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outData, _ := os.ReadFile(outputFile)
+	for _, want := range []string{"héllo wörld\n", "Größe café\n"} {
+		if !strings.Contains(string(outData), want) {
+			t.Errorf("expected %q decoded to UTF-8, got:\n%s", want, outData)
+		}
+	}
+
+	// --skip-non-utf8 still skips them
+	opt.SkipNonUTF8Flag = true
+	if err := core.WriteAllFiles("tree", dir, outputFile, nil, opt); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	outData, _ = os.ReadFile(outputFile)
+	if strings.Contains(string(outData), "wörld") || strings.Contains(string(outData), "Größe") {
+		t.Errorf("non-UTF-8 files must be skipped with --skip-non-utf8:\n%s", outData)
+	}
+}
+
 func createTempDirWithTree(t *testing.T) (string, string, map[string]bool) {
 	t.Helper()
 	dir := t.TempDir()
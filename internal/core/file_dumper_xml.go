@@ -1,128 +1,155 @@
 package core
 
 import (
-	"bufio"
-	"bytes"
 	"encoding/xml"
 	"fmt"
-	"io"
-	"os"
+	"iter"
 	"path/filepath"
-	"sort"
 	"strings"
 
 	"github.com/magicdrive/ark/internal/commandline"
-	"github.com/magicdrive/ark/internal/secrets"
+	"github.com/magicdrive/ark/internal/model"
 	"github.com/magicdrive/ark/internal/textbank"
 )
 
 func WriteAllFilesAsXML(treeStr string, root string, outputPath string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
-	outFile, err := os.Create(outputPath)
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
 	if err != nil {
 		return err
 	}
-	defer outFile.Close()
-	writer := bufio.NewWriter(outFile)
-	defer writer.Flush()
+	budget, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return err
+	}
+	_, err = writeAllFilesAsXML(treeStr, tree, outputPath, opt, budget)
+	return err
+}
 
+func writeAllFilesAsXML(treeStr string, tree *FileTree, outputPath string, opt *commandline.Option, budget *TokenBudget) ([]string, error) {
+	root := tree.Root
 	abspath, _ := filepath.Abs(root)
 	projectName := filepath.Base(abspath)
 
-	writer.WriteString(xml.Header)
-	writer.WriteString("<ProjectDump>\n")
-	fmt.Fprintf(writer, textbank.DescriptionTemplateXML, xmlEscape(projectName), xmlEscape(abspath))
-	writer.WriteString("\n")
-	writer.WriteString("<Tree>")
-	writer.WriteString("\n")
-	writer.WriteString("<![CDATA[")
-	writer.WriteString("\n")
-	writer.WriteString(treeStr)
-	writer.WriteString("]]>")
-	writer.WriteString("\n")
-	writer.WriteString("</Tree>")
-	writer.WriteString("\n")
+	var header strings.Builder
+	header.WriteString(xml.Header)
+	header.WriteString("<ProjectDump>\n")
+	fmt.Fprintf(&header, textbank.DescriptionTemplateXML, xmlEscape(projectName), xmlEscape(abspath), xmlEscape(binaryNote(model.XML, opt)))
+	header.WriteString("\n")
+	headerStr := header.String()
+
+	var treeSection strings.Builder
+	treeSection.WriteString("<Tree>")
+	treeSection.WriteString("\n")
+	treeSection.WriteString("<![CDATA[")
+	treeSection.WriteString("\n")
+	treeSection.WriteString(treeStr)
+	treeSection.WriteString("]]>")
+	treeSection.WriteString("\n")
+	treeSection.WriteString("</Tree>")
+	treeSection.WriteString("\n")
+	budget.Reserve(headerStr + treeSection.String())
 
-	err = writeXMLDirectory(writer, root, allowedFileListMap, opt)
+	files, err := budget.plan(newFileStage(opt).run(tree.Files()), processedContent)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	writer.WriteString("</ProjectDump>\n")
-	return nil
-}
-
-func writeXMLDirectory(writer *bufio.Writer, dir string, allowedFileListMap map[string]bool, opt *commandline.Option) error {
-	entries, err := os.ReadDir(dir)
+	writer, err := newChunkWriter(outputPath, root, headerStr, opt)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	sort.Slice(entries, func(i, j int) bool {
-		if entries[i].IsDir() && !entries[j].IsDir() {
-			return true
-		}
-		if !entries[i].IsDir() && entries[j].IsDir() {
-			return false
+
+	// every chunk must stay well-formed, so open <directory> elements are
+	// closed at the end of a chunk and reopened at the start of the next one
+	var openDirs []string
+	writer.reopen = func() string {
+		var b strings.Builder
+		for _, name := range openDirs {
+			fmt.Fprintf(&b, `<directory name="%s">`, xmlEscape(name))
+			b.WriteString("\n")
 		}
-		return strings.ToLower(entries[i].Name()) < strings.ToLower(entries[j].Name())
-	})
+		return b.String()
+	}
+	writer.footer = func() string {
+		return strings.Repeat("</directory>\n", len(openDirs)) + "</ProjectDump>\n"
+	}
+	writer.continuation = func(fpath string) (string, string) {
+		name := filepath.Base(fpath)
+		lang := detectLanguageTag(fpath)
+		return "\n]]>\n</file>\n", fmt.Sprintf(`<file name="%s" language="%s" continued="true">`, xmlEscape(name), xmlEscape(lang)) + "\n<![CDATA[\n"
+	}
+	writer.WriteString(treeSection.String())
 
-	for _, entry := range entries {
-		name := entry.Name()
-		fpath := filepath.Join(dir, name)
+	next, stop := iter.Pull(files)
+	defer stop()
 
-		if IsUnderGitDir(fpath) {
-			continue
+	closeDirs := func(depth int) {
+		for len(openDirs) > depth {
+			openDirs = openDirs[:len(openDirs)-1]
+			writer.WriteString("</directory>\n")
 		}
-		if allowedFileListMap != nil {
-			if _, ok := allowedFileListMap[fpath]; !ok {
-				continue
+	}
+	for _, node := range tree.Nodes {
+		closeDirs(node.Depth)
+		if node.IsDir {
+			if err = writer.WriteOpening(fmt.Sprintf(`<directory name="%s">`, xmlEscape(node.Name))+"\n", "</directory>\n"); err != nil {
+				writer.Close()
+				return nil, err
 			}
+			openDirs = append(openDirs, node.Name)
+			continue
 		}
-		if entry.IsDir() {
-			fmt.Fprintf(writer, `<directory name="%s">`, xmlEscape(name))
-			writer.WriteString("\n")
-			err := writeXMLDirectory(writer, fpath, allowedFileListMap, opt)
-			writer.WriteString("</directory>\n")
-			if err != nil {
-				return err
-			}
-		} else {
-			data, err := os.ReadFile(fpath)
-			if err != nil {
-				return err
-			}
-			if IsBinary(data) || IsImage(fpath) {
-				continue
-			}
-			decoded, err := ConvertToUTF8(bytes.NewReader(data))
-			if err != nil {
-				if opt.SkipNonUTF8Flag {
-					continue
-				}
-				return fmt.Errorf("failed to convert %s: %w", fpath, err)
+		r, _ := next()
+		if err = r.Err; err != nil {
+			writer.Close()
+			return nil, err
+		}
+		if r.File == nil {
+			continue
+		}
+		content, ok := budget.Fit(r.Path, r.File.Content)
+		if !ok {
+			continue
+		}
+		if r.File.Binary != nil {
+			if err = writer.WriteSection(r.Path, renderBinaryXMLElement(node.Name, r.File, content)); err != nil {
+				writer.Close()
+				return nil, err
 			}
+			continue
+		}
+		lang := detectLanguageTag(r.Path)
+		var section strings.Builder
+		fmt.Fprintf(&section, `<file name="%s" language="%s"%s>`, xmlEscape(node.Name), xmlEscape(lang), r.File.xmlAttrs())
+		section.WriteString("\n<![CDATA[\n")
+		section.WriteString(xmlEscapeForCDATA(content))
+		section.WriteString("\n]]>\n")
+		section.WriteString("</file>\n")
+		if err = writer.WriteSection(r.Path, section.String()); err != nil {
+			writer.Close()
+			return nil, err
+		}
+	}
+	closeDirs(0)
 
-			decodedBytes, err := io.ReadAll(decoded)
-			if opt.DeleteCommentsFlag {
-				decodedBytes = DeleteComments(decodedBytes, fpath)
-			}
+	return writer.Close()
+}
 
-			if err != nil {
-				return fmt.Errorf("failed to read %s: %w", fpath, err)
-			}
-			content := string(decodedBytes)
-			if opt.MaskSecretsFlag.Bool() {
-				content = secrets.MaskAll(content)
-			}
-			lang := detectLanguageTag(fpath)
-			fmt.Fprintf(writer, `<file name="%s" language="%s">`, xmlEscape(name), xmlEscape(lang))
-			writer.WriteString("\n<![CDATA[\n")
-			writer.WriteString(xmlEscapeForCDATA(content))
-			writer.WriteString("\n]]>\n")
-			writer.WriteString("</file>\n")
-		}
+// renderBinaryXMLElement renders the metadata stub of a binary file as a
+// <binary> element holding the data URI of an embedded image.
+func renderBinaryXMLElement(name string, file *dumpFile, content string) string {
+	binary := file.Binary
+	var b strings.Builder
+	fmt.Fprintf(&b, `<binary name="%s" mime="%s" size="%d" sha256="%s"`, xmlEscape(name), xmlEscape(binary.MIME), file.Size, binary.SHA256)
+	if binary.Width > 0 && binary.Height > 0 {
+		fmt.Fprintf(&b, ` width="%d" height="%d"`, binary.Width, binary.Height)
+	}
+	if uri := binary.embedded(content); uri != "" {
+		b.WriteString(">" + uri + "</binary>\n")
+	} else {
+		b.WriteString("/>\n")
 	}
-	return nil
+	return b.String()
 }
 
 func xmlEscape(s string) string {
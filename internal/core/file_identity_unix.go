@@ -0,0 +1,18 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns the device and inode of info.
+func fileIdentity(info os.FileInfo) (fileID, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
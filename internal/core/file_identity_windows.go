@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package core
+
+import (
+	"os"
+)
+
+// fileIdentity is not available from a FileInfo on windows, where
+// os.SameFile compares the volume and file index instead.
+func fileIdentity(info os.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
@@ -0,0 +1,190 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/chardetect"
+	"github.com/magicdrive/ark/internal/commandline"
+	"golang.org/x/text/transform"
+)
+
+var truncatedLinesMarkerRegexp = regexp.MustCompile(`^\.\.\. \[truncated ([\d,]+) lines\] \.\.\.$`)
+
+// maxFileSize returns the --max-file-size limit in bytes, 0 when unlimited.
+func maxFileSize(opt *commandline.Option) int {
+	if opt.MaxFileSizeValue == "" {
+		return 0
+	}
+	limit, err := opt.MaxFileSize.Bytes()
+	if err != nil {
+		return 0
+	}
+	return limit
+}
+
+// ExceedsMaxFileSize reports whether a file of size bytes is skipped by --max-file-size.
+func ExceedsMaxFileSize(size int64, opt *commandline.Option) bool {
+	limit := maxFileSize(opt)
+	return limit > 0 && size > int64(limit)
+}
+
+// fileLimitNote tells what --max-file-size or --max-lines-per-file does to
+// fpath of size bytes, for the tree to show. skip is true when the file is
+// left out of the dump.
+func fileLimitNote(fpath string, size int64, opt *commandline.Option) (note string, skip bool) {
+	if maxFileSize(opt) <= 0 && opt.MaxLinesPerFile <= 0 {
+		return "", false
+	}
+	if ExceedsMaxFileSize(size, opt) {
+		return fmt.Sprintf("skipped: %s > --max-file-size %s", formatByteSize(size), opt.MaxFileSizeValue), true
+	}
+	if opt.MaxLinesPerFile <= 0 || IsImage(fpath) {
+		return "", false
+	}
+	lines, more, ok := countLinesUpTo(fpath, opt.MaxLinesPerFile)
+	if !ok || lines <= opt.MaxLinesPerFile {
+		return "", false
+	}
+	count := groupDigits(lines)
+	if more {
+		count += "+"
+	}
+	return fmt.Sprintf("truncated: %s lines > --max-lines-per-file %s", count, groupDigits(opt.MaxLinesPerFile)), false
+}
+
+// countLinesUpTo counts the lines of the text file fpath, decoded like
+// DecodeText, but stops reading once it has seen more than limit of them;
+// it then returns limit+1 with more set, as the rest is not counted. ok is false when
+// the file cannot be read or looks binary.
+func countLinesUpTo(fpath string, limit int) (lines int, more bool, ok bool) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return 0, false, false
+	}
+	defer f.Close()
+
+	sample := make([]byte, 8192)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return 0, false, false
+	}
+	sample = sample[:n]
+	var r io.Reader = io.MultiReader(bytes.NewReader(sample), f)
+	if decoder := decoderFor(chardetect.Detect(sample).Encoding); decoder != nil {
+		r = transform.NewReader(r, decoder)
+	}
+
+	buf := make([]byte, 32*1024)
+	last := byte('\n')
+	for first := true; ; first = false {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			// the dump checks the whole file, the start is enough to leave
+			// most binary files out here
+			if first && IsBinary(buf[:n]) {
+				return 0, false, false
+			}
+			lines += bytes.Count(buf[:n], []byte{'\n'})
+			last = buf[n-1]
+			if lines > limit && err == nil {
+				return limit + 1, true, true
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return 0, false, false
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	return lines, false, true
+}
+
+// fileLimitMarker renders the note of a node, such as a fileLimitNote, for
+// the text tree.
+func fileLimitMarker(note string) string {
+	if note == "" {
+		return ""
+	}
+	return " [" + note + "]"
+}
+
+// TruncateLines keeps the first and last lines of content when it has more
+// than maxLines lines, with a marker telling how many were cut in between.
+// A maxLines of 0 or less keeps content untouched.
+func TruncateLines(content string, maxLines int) string {
+	if maxLines <= 0 {
+		return content
+	}
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) <= maxLines {
+		return content
+	}
+
+	head := (maxLines + 1) / 2
+	tail := maxLines - head
+	var b strings.Builder
+	for _, line := range lines[:head] {
+		b.WriteString(line)
+	}
+	b.WriteString(truncatedLinesMarker(len(lines) - maxLines))
+	b.WriteString("\n")
+	for _, line := range lines[len(lines)-tail:] {
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func truncatedLinesMarker(n int) string {
+	return fmt.Sprintf("... [truncated %s lines] ...", groupDigits(n))
+}
+
+// TruncatedLines returns the number of lines a TruncateLines marker stands for.
+func TruncatedLines(line string) (int, bool) {
+	m := truncatedLinesMarkerRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+	return n, err == nil
+}
+
+// groupDigits formats n with thousands separators, e.g. 12,345.
+func groupDigits(n int) string {
+	if n < 0 {
+		return "-" + groupDigits(-n)
+	}
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// formatByteSize formats size with a binary unit, e.g. 40.0 MB.
+func formatByteSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value, suffix := float64(size)/unit, "KB"
+	for _, next := range []string{"MB", "GB", "TB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,198 @@
+package core_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func numberedLines(from, to int) string {
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		fmt.Fprintf(&b, "line %d\n", i)
+	}
+	return b.String()
+}
+
+func TestTruncateLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxLines int
+		expected string
+	}{
+		{"unlimited", "a\nb\nc\n", 0, "a\nb\nc\n"},
+		{"within limit", "a\nb\nc\n", 3, "a\nb\nc\n"},
+		{"odd limit keeps the longer head", "a\nb\nc\nd\ne\nf\n", 3, "a\nb\n... [truncated 3 lines] ...\nf\n"},
+		{"even limit", "a\nb\nc\nd\ne\nf\n", 4, "a\nb\n... [truncated 2 lines] ...\ne\nf\n"},
+		{"no final newline", "a\nb\nc\nd", 2, "a\n... [truncated 2 lines] ...\nd"},
+		{"head only", "a\nb\nc\n", 1, "a\n... [truncated 2 lines] ...\n"},
+		{"thousands separator", strings.Repeat("x\n", 12347), 2, "x\n... [truncated 12,345 lines] ...\nx\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := core.TruncateLines(tt.content, tt.maxLines); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTruncatedLines(t *testing.T) {
+	if n, ok := core.TruncatedLines("... [truncated 12,345 lines] ..."); !ok || n != 12345 {
+		t.Errorf("expected 12345, got %d (%v)", n, ok)
+	}
+	if _, ok := core.TruncatedLines("  ... [truncated 3 lines] ..."); ok {
+		t.Errorf("an indented line is not a marker")
+	}
+}
+
+func createFileLimitsTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "big.txt"), strings.Repeat("a", 3000))
+	mustWriteFile(t, filepath.Join(root, "long.txt"), numberedLines(1, 20))
+	mustWriteFile(t, filepath.Join(root, "small.txt"), "hi\n")
+	return root
+}
+
+func createFileLimitsOption(format string) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(format),
+		WithLineNumberFlag: model.OnOffSwitch("on"),
+		IgnoreDotFileFlag:  model.OnOffSwitch("off"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+		MaxFileSizeValue:   "2K",
+		MaxFileSize:        model.ByteString("2K"),
+		MaxLinesPerFile:    6,
+	}
+}
+
+func TestGenerateTreeString_FileLimits(t *testing.T) {
+	root := createFileLimitsTree(t)
+	opt := createFileLimitsOption(model.PlainText)
+
+	tree, allowed, err := core.GenerateTreeString(root, "", map[string]bool{}, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := "├── big.txt [skipped: 2.9 KB > --max-file-size 2K]\n" +
+		"├── long.txt [truncated: 20 lines > --max-lines-per-file 6]\n" +
+		"└── small.txt\n"
+	if tree != expected {
+		t.Errorf("expected tree:\n%s\ngot:\n%s", expected, tree)
+	}
+	if allowed[filepath.Join(root, "big.txt")] {
+		t.Errorf("a skipped file must not be dumped")
+	}
+	if !allowed[filepath.Join(root, "long.txt")] {
+		t.Errorf("a truncated file must still be dumped")
+	}
+}
+
+func TestGenerateTreeString_LineCountStopsPastLimit(t *testing.T) {
+	root := t.TempDir()
+	// larger than a read block, so the count stops before the end
+	mustWriteFile(t, filepath.Join(root, "huge.txt"), strings.Repeat("x\n", 100000))
+	opt := createFileLimitsOption(model.PlainText)
+	opt.MaxFileSizeValue = ""
+
+	tree, _, err := core.GenerateTreeString(root, "", map[string]bool{}, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "└── huge.txt [truncated: 7+ lines > --max-lines-per-file 6]\n"
+	if tree != expected {
+		t.Errorf("expected tree:\n%s\ngot:\n%s", expected, tree)
+	}
+}
+
+func TestGenerateTreeJSONString_FileLimits(t *testing.T) {
+	root := createFileLimitsTree(t)
+	opt := createFileLimitsOption(model.Arklite)
+
+	jsonStr, allowed, err := core.GenerateTreeJSONString(root, map[string]bool{}, opt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var node core.TreeEntry
+	if err := json.Unmarshal([]byte(jsonStr), &node); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	notes := map[string]string{}
+	for _, child := range node.Children {
+		notes[child.Name] = child.Note
+	}
+	if notes["big.txt"] != "skipped: 2.9 KB > --max-file-size 2K" {
+		t.Errorf("unexpected note for big.txt: %q", notes["big.txt"])
+	}
+	if notes["long.txt"] != "truncated: 20 lines > --max-lines-per-file 6" {
+		t.Errorf("unexpected note for long.txt: %q", notes["long.txt"])
+	}
+	if notes["small.txt"] != "" {
+		t.Errorf("unexpected note for small.txt: %q", notes["small.txt"])
+	}
+	if allowed[filepath.Join(root, "big.txt")] {
+		t.Errorf("a skipped file must not be dumped")
+	}
+}
+
+func TestWriteAllFiles_FileLimits(t *testing.T) {
+	root := createFileLimitsTree(t)
+	long := filepath.Join(root, "long.txt")
+
+	writers := map[string]func(out string, opt *commandline.Option) error{
+		model.PlainText: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, nil, opt)
+		},
+		model.XML: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsXML("tree", root, out, nil, opt)
+		},
+		model.Arklite: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsArklite("{}", root, out, nil, opt)
+		},
+	}
+
+	for format, write := range writers {
+		out := filepath.Join(t.TempDir(), "out")
+		if err := write(out, createFileLimitsOption(format)); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		data, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output := string(data)
+
+		if strings.Contains(output, "aaaa") {
+			t.Errorf("%s: a file over --max-file-size must be skipped", format)
+		}
+		if !strings.Contains(output, "... [truncated 14 lines] ...") {
+			t.Errorf("%s: missing truncation marker:\n%s", format, output)
+		}
+		if !strings.Contains(output, "hi") {
+			t.Errorf("%s: small files must be dumped", format)
+		}
+	}
+
+	out := filepath.Join(t.TempDir(), "out")
+	if err := core.WriteAllFiles("tree", root, out, map[string]bool{long: true}, createFileLimitsOption(model.PlainText)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	expected := "     3: line 3\n... [truncated 14 lines] ...\n    18: line 18\n"
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("the tail must keep its line numbers on disk, got:\n%s", data)
+	}
+}
@@ -0,0 +1,232 @@
+package core
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/gitdiff"
+	"github.com/magicdrive/ark/internal/libgitignore"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// orderDumpTree puts the files of tree in the order --order asks for. The
+// tree string is rendered from the walk and keeps its own order.
+func orderDumpTree(tree *FileTree, opt *commandline.Option) (*FileTree, error) {
+	if opt == nil {
+		return tree, nil
+	}
+	switch opt.Order.String() {
+	case model.FileOrderImportance:
+		return tree.Reorder(func(a, b *TreeNode) bool {
+			ra, rb := importanceRank(tree.Root, a.Path), importanceRank(tree.Root, b.Path)
+			if ra != rb {
+				return ra < rb
+			}
+			return a.Depth < b.Depth
+		}), nil
+	case model.FileOrderRecent:
+		recency := recencyTimes(tree)
+		return tree.Reorder(func(a, b *TreeNode) bool {
+			return recency[a].After(recency[b])
+		}), nil
+	case model.FileOrderSize:
+		return tree.Reorder(func(a, b *TreeNode) bool {
+			return a.Size < b.Size
+		}), nil
+	case model.FileOrderCustom:
+		rank, err := globRanker(tree.Root, opt.OrderGlobList)
+		if err != nil {
+			return nil, err
+		}
+		return tree.Reorder(func(a, b *TreeNode) bool {
+			return rank(a.Path) < rank(b.Path)
+		}), nil
+	default:
+		return tree, nil
+	}
+}
+
+// Reorder returns the files of the tree sorted by less, ties kept in tree
+// order. Each file is preceded by the directories it is in that the previous
+// file was not, so a directory shows up again wherever the order comes back
+// to it, and the nested formats still find every file under its directory.
+func (t *FileTree) Reorder(less func(a, b *TreeNode) bool) *FileTree {
+	type orderedFile struct {
+		node *TreeNode
+		dirs []*TreeNode
+	}
+	var files []orderedFile
+	var dirs []*TreeNode
+	for _, node := range t.Nodes {
+		dirs = dirs[:node.Depth]
+		if node.IsDir {
+			dirs = append(dirs, node)
+			continue
+		}
+		files = append(files, orderedFile{node: node, dirs: slices.Clone(dirs)})
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		return less(files[i].node, files[j].node)
+	})
+
+	ordered := &FileTree{Root: t.Root}
+	var open []*TreeNode
+	for _, file := range files {
+		shared := 0
+		for shared < len(open) && shared < len(file.dirs) && open[shared] == file.dirs[shared] {
+			shared++
+		}
+		open = append(open[:shared], file.dirs[shared:]...)
+		ordered.Nodes = append(ordered.Nodes, file.dirs[shared:]...)
+		ordered.Nodes = append(ordered.Nodes, file.node)
+	}
+	return ordered
+}
+
+// The importance ranks, most important first.
+const (
+	rankReadme = iota
+	rankEntryPoint
+	rankManifest
+	rankSource
+	rankOther
+	rankTest
+	rankGenerated
+)
+
+var entryPointNames = []string{
+	"main.go", "main.py", "__main__.py", "app.py", "manage.py", "main.rs", "lib.rs",
+	"index.js", "index.ts", "main.js", "main.ts", "app.js", "app.ts", "server.js", "server.ts",
+	"main.c", "main.cc", "main.cpp", "program.cs", "main.java", "main.kt", "main.swift",
+}
+
+var manifestNames = []string{
+	"go.mod", "package.json", "cargo.toml", "pyproject.toml", "setup.py", "setup.cfg",
+	"requirements.txt", "gemfile", "pom.xml", "build.gradle", "build.gradle.kts", "settings.gradle",
+	"composer.json", "makefile", "dockerfile", "cmakelists.txt", "deno.json", "tsconfig.json",
+	"mix.exs", "pubspec.yaml",
+}
+
+var manifestExts = []string{".csproj", ".gemspec", ".cabal"}
+
+// buildDirs are output directories, ranked with the vendored ones.
+var buildDirs = []string{"dist", "build"}
+
+var testDirs = []string{"test", "tests", "__tests__", "spec", "testdata"}
+
+// dataLanguages are the language tags of docs and data rather than code.
+var dataLanguages = []string{"markdown", "text", "json", "yaml", "toml", "xml", "ini", "diff", "latex"}
+
+// importanceRank scores fpath by its name and place in the tree: README,
+// entry points, manifests, source, other files, tests, then generated code.
+func importanceRank(root string, fpath string) int {
+	rel, err := filepath.Rel(root, fpath)
+	if err != nil {
+		rel = fpath
+	}
+	rel = strings.ToLower(filepath.ToSlash(rel))
+	base := filepath.Base(rel)
+	components := strings.Split(rel, "/")
+	dirs := components[:len(components)-1]
+
+	switch {
+	case generatedByName(base) != "" || containsAny(dirs, vendoredDirs) || containsAny(dirs, buildDirs):
+		return rankGenerated
+	case isTestFile(base) || containsAny(dirs, testDirs):
+		return rankTest
+	case strings.HasPrefix(base, "readme"):
+		return rankReadme
+	case slices.Contains(entryPointNames, base) || (len(dirs) > 0 && dirs[0] == "cmd"):
+		return rankEntryPoint
+	case slices.Contains(manifestNames, base) || slices.Contains(manifestExts, filepath.Ext(base)):
+		return rankManifest
+	}
+	if tag := detectLanguageTag(base); tag != "" && !slices.Contains(dataLanguages, tag) {
+		return rankSource
+	}
+	return rankOther
+}
+
+func isTestFile(base string) bool {
+	stem := strings.TrimSuffix(base, filepath.Ext(base))
+	return strings.HasSuffix(stem, "_test") ||
+		strings.HasPrefix(stem, "test_") ||
+		strings.HasSuffix(stem, ".test") ||
+		strings.HasSuffix(stem, ".spec") ||
+		strings.HasSuffix(stem, "test") && filepath.Ext(base) == ".java" ||
+		strings.HasSuffix(stem, "tests") && filepath.Ext(base) == ".cs"
+}
+
+func containsAny(list []string, values []string) bool {
+	for _, v := range list {
+		if slices.Contains(values, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// recencyTimes dates each file by its last commit, or by its modification
+// time when it is changed, untracked, or not in a git repository.
+func recencyTimes(tree *FileTree) map[*TreeNode]time.Time {
+	changes, err := gitdiff.Collect(tree.Root, "HEAD", false)
+	if err != nil {
+		changes = nil
+	}
+
+	recency := map[*TreeNode]time.Time{}
+	committed := map[*TreeNode]string{}
+	var paths []string
+	for _, node := range tree.Nodes {
+		if node.IsDir {
+			continue
+		}
+		recency[node] = node.ModTime
+		if changes.Status(node.Path) != "" {
+			continue
+		}
+		committed[node] = resolvePath(node.Path)
+		paths = append(paths, committed[node])
+	}
+
+	commits, err := gitdiff.CommitTimes(tree.Root, paths)
+	if err != nil {
+		return recency
+	}
+	for node, path := range committed {
+		if t, ok := commits[path]; ok {
+			recency[node] = t
+		}
+	}
+	return recency
+}
+
+// globRanker returns the index of the first glob a file matches, or the
+// number of globs for a file no glob matches. Globs follow .gitignore syntax
+// relative to root: "README*" matches at any depth, "cmd/" everything in cmd.
+func globRanker(root string, globs []string) (func(fpath string) int, error) {
+	var matchers []*libgitignore.GitIgnore
+	for _, glob := range globs {
+		gi, err := libgitignore.CompileIgnoreLines([]string{glob}, root, 1, root)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, gi)
+	}
+	return func(fpath string) int {
+		abs, err := filepath.Abs(fpath)
+		if err != nil {
+			abs = fpath
+		}
+		for i, gi := range matchers {
+			if gi.MatchesPath(abs) {
+				return i
+			}
+		}
+		return len(matchers)
+	}, nil
+}
@@ -0,0 +1,136 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+var orderTestFiles = []string{
+	"README.md",
+	"a_test.go",
+	"cmd/app/run.go",
+	"docs/guide.md",
+	"go.mod",
+	"go.sum",
+	"internal/x.go",
+	"main.go",
+	"vendor/lib.go",
+}
+
+func createOrderTestTree(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for i, rel := range orderTestFiles {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mustWriteFile(t, p, strings.Repeat("x", len(orderTestFiles)-i))
+		allowed[p] = true
+	}
+	return root, allowed
+}
+
+// orderedFiles dumps the allowed files as plain text and returns the files in
+// the order of their sections.
+func orderedFiles(t *testing.T, root string, allowed map[string]bool, opt *commandline.Option) []string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "dump.txt")
+	if err := core.WriteAllFiles("tree", root, out, allowed, opt); err != nil {
+		t.Fatalf("WriteAllFiles failed: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for _, m := range sectionHeader.FindAllStringSubmatch(string(data), -1) {
+		rel, _ := filepath.Rel(root, m[1])
+		rels = append(rels, filepath.ToSlash(rel))
+	}
+	return rels
+}
+
+var sectionHeader = regexp.MustCompile(`(?m)^=== (.+) \(.*\) ===$`)
+
+func createOrderTestOption(order string, globs ...string) *commandline.Option {
+	opt := createRestoreTestOption(model.PlainText)
+	opt.Order = model.FileOrder(order)
+	opt.OrderGlobList = globs
+	return opt
+}
+
+func TestFileOrder_Strategies(t *testing.T) {
+	root, allowed := createOrderTestTree(t)
+	reversed := slices.Clone(orderTestFiles)
+	slices.Reverse(reversed)
+
+	tests := []struct {
+		order string
+		globs []string
+		want  []string
+	}{
+		{"tree", nil, orderTestFiles},
+		{"importance", nil, []string{
+			"README.md", "main.go", "cmd/app/run.go", "go.mod", "internal/x.go",
+			"docs/guide.md", "a_test.go", "go.sum", "vendor/lib.go",
+		}},
+		{"size", nil, reversed},
+		{"custom", []string{"*.mod", "docs/", "main.go"}, []string{
+			"go.mod", "docs/guide.md", "main.go",
+			"README.md", "a_test.go", "cmd/app/run.go", "go.sum", "internal/x.go", "vendor/lib.go",
+		}},
+	}
+	for _, tt := range tests {
+		got := orderedFiles(t, root, allowed, createOrderTestOption(tt.order, tt.globs...))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: order = %v\nwant %v", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestFileOrder_RecentByModTime(t *testing.T) {
+	root, allowed := createOrderTestTree(t)
+	base := time.Now().Add(-time.Hour)
+	for i, rel := range orderTestFiles {
+		// the files later in the tree are older
+		mtime := base.Add(-time.Duration(i) * time.Minute)
+		if rel == "go.mod" {
+			mtime = base.Add(time.Minute)
+		}
+		if err := os.Chtimes(filepath.Join(root, filepath.FromSlash(rel)), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got := orderedFiles(t, root, allowed, createOrderTestOption("recent"))
+	if len(got) != len(orderTestFiles) || got[0] != "go.mod" || got[1] != "README.md" || got[len(got)-1] != "vendor/lib.go" {
+		t.Errorf("recent order = %v", got)
+	}
+}
+
+func TestFileOrder_XMLRoundTrip(t *testing.T) {
+	root, allowed := createRestoreTestTree(t)
+	opt := createRestoreTestOption(model.XML)
+	opt.Order = model.FileOrderSize
+
+	dumpFile := filepath.Join(t.TempDir(), "dump.xml")
+	if err := core.WriteAllFilesAsXML("tree", root, dumpFile, allowed, opt); err != nil {
+		t.Fatalf("dump failed: %v", err)
+	}
+	outDir := t.TempDir()
+	if _, err := core.RestoreDump([]string{dumpFile}, outDir, false); err != nil {
+		t.Fatalf("restore failed: %v", err)
+	}
+	assertRestored(t, model.XML, outDir)
+}
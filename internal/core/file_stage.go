@@ -0,0 +1,181 @@
+package core
+
+import (
+	"fmt"
+	"iter"
+	"os"
+	"runtime"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/secrets"
+)
+
+// dumpFile is a file loaded for dumping.
+type dumpFile struct {
+	Content string
+	// textMetadata is empty for binary files
+	textMetadata
+	Size int
+	// Binary is set when the file is dumped as a --binary-metadata stub
+	Binary *binaryFile
+}
+
+// processedFile is the result of the file stage for a single path.
+// File is nil when the file must be skipped.
+type processedFile struct {
+	Path string
+	File *dumpFile
+	Err  error
+}
+
+// fileStage is the file-processing stage shared by every dumper: it reads,
+// decodes, truncates, strips comments, applies diff content and masks secrets.
+type fileStage struct {
+	opt            *commandline.Option
+	deleteComments bool
+	compactGo      model.CompactGo
+	maxLines       int
+	binaryMetadata bool
+	embedImages    int
+	secretRules    *secrets.RuleSet
+	jobs           int
+}
+
+func newFileStage(opt *commandline.Option) *fileStage {
+	jobs := opt.Jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+	return &fileStage{
+		opt: opt,
+		// plain arklite always strips comments
+		deleteComments: opt.DeleteCommentsFlag || (opt.OutputFormat.String() == model.Arklite && !opt.ArkliteLosslessFlag),
+		compactGo:      opt.CompactGo,
+		maxLines:       opt.MaxLinesPerFile,
+		binaryMetadata: opt.BinaryMetadataFlag,
+		embedImages:    embedImagesLimit(opt),
+		secretRules:    opt.SecretRuleSet(),
+		jobs:           jobs,
+	}
+}
+
+// load processes a single file. ok is false when the file must be skipped.
+func (st *fileStage) load(fpath string) (*dumpFile, bool, error) {
+	opt := st.opt
+
+	info, err := os.Stat(fpath)
+	if err != nil {
+		return nil, false, err
+	}
+	if ExceedsMaxFileSize(info.Size(), opt) {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return nil, false, err
+	}
+	decodedBytes, detected, err := DecodeText(data)
+	if err != nil {
+		if opt.SkipNonUTF8Flag {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to convert %s: %w", fpath, err)
+	}
+	if IsBinary(decodedBytes) || IsImage(fpath) {
+		if !st.binaryMetadata {
+			return nil, false, nil
+		}
+		binary := newBinaryFile(fpath, data, st.embedImages)
+		return &dumpFile{Content: binary.content(), Size: len(data), Binary: binary}, true, nil
+	}
+	if opt.SkipNonUTF8Flag && !IsUTF8Encoding(detected.Encoding) {
+		return nil, false, nil
+	}
+	meta := newTextMetadata(detected, string(decodedBytes))
+
+	// --max-lines-per-file counts the lines on disk, as the tree does
+	decodedBytes = []byte(TruncateLines(string(decodedBytes), st.maxLines))
+
+	if st.deleteComments {
+		decodedBytes = DeleteComments(decodedBytes, fpath, opt.KeepDocCommentsFlag)
+	}
+	decodedBytes = CompactGo(decodedBytes, fpath, st.compactGo)
+
+	content, err := applyDiffContent(fpath, string(decodedBytes), opt)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if opt.MaskSecretsFlag.Bool() {
+		content = st.secretRules.MaskFile(fpath, content)
+	}
+	return &dumpFile{
+		Content:      content,
+		textMetadata: meta,
+		Size:         len(data),
+	}, true, nil
+}
+
+// run processes paths on a bounded pool of workers and yields the results in
+// the order of paths. At most a few results per worker are held ahead of the
+// consumer, so memory stays bounded on large trees.
+func (st *fileStage) run(paths []string) iter.Seq[*processedFile] {
+	return func(yield func(*processedFile) bool) {
+		if len(paths) == 0 {
+			return
+		}
+		if st.jobs == 1 {
+			for _, fpath := range paths {
+				file, _, err := st.load(fpath)
+				if !yield(&processedFile{Path: fpath, File: file, Err: err}) {
+					return
+				}
+			}
+			return
+		}
+
+		results := make([]chan *processedFile, len(paths))
+		for i := range results {
+			results[i] = make(chan *processedFile, 1)
+		}
+		window := make(chan struct{}, st.jobs*4)
+		indexes := make(chan int)
+		done := make(chan struct{})
+		defer close(done)
+
+		go func() {
+			defer close(indexes)
+			for i := range paths {
+				select {
+				case window <- struct{}{}:
+				case <-done:
+					return
+				}
+				select {
+				case indexes <- i:
+				case <-done:
+					return
+				}
+			}
+		}()
+
+		for range min(st.jobs, len(paths)) {
+			go func() {
+				for i := range indexes {
+					file, _, err := st.load(paths[i])
+					results[i] <- &processedFile{Path: paths[i], File: file, Err: err}
+				}
+			}()
+		}
+
+		for i := range paths {
+			r := <-results[i]
+			<-window
+			if !yield(r) {
+				return
+			}
+		}
+	}
+}
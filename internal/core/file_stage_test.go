@@ -0,0 +1,138 @@
+package core_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createManyFiles(t testing.TB, n int) (string, map[string]bool) {
+	t.Helper()
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for i := range n {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%02d", i%7))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		allowed[dir] = true
+		p := filepath.Join(dir, fmt.Sprintf("file%03d.go", i))
+		content := fmt.Sprintf("package pkg\n\n// file %d\nvar X%d = %q\n", i, i, strings.Repeat("x", i))
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	return root, allowed
+}
+
+func createJobsTestOption(format string, jobs int) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(format),
+		WithLineNumberFlag: model.OnOffSwitch("on"),
+		MaskSecretsFlag:    model.OnOffSwitch("on"),
+		ScanBuffer:         model.ByteString("1M"),
+		DeleteCommentsFlag: true,
+		Jobs:               jobs,
+	}
+}
+
+func TestWriteAllFiles_JobsAreDeterministic(t *testing.T) {
+	root, allowed := createManyFiles(t, 200)
+
+	writers := map[string]func(out string, opt *commandline.Option) error{
+		model.PlainText: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, allowed, opt)
+		},
+		model.XML: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsXML("tree", root, out, allowed, opt)
+		},
+		model.Arklite: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsArklite("{}", root, out, allowed, opt)
+		},
+		model.JSONL: func(out string, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSONL(root, out, allowed, opt)
+		},
+	}
+
+	for format, write := range writers {
+		var outputs []string
+		for _, jobs := range []int{1, 4, 16} {
+			out := filepath.Join(t.TempDir(), "out")
+			if err := write(out, createJobsTestOption(format, jobs)); err != nil {
+				t.Fatalf("%s with %d jobs: %v", format, jobs, err)
+			}
+			data, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outputs = append(outputs, string(data))
+		}
+		for i := 1; i < len(outputs); i++ {
+			if outputs[i] != outputs[0] {
+				t.Errorf("%s: output with parallel jobs differs from the sequential output", format)
+			}
+		}
+		if strings.Contains(outputs[0], "// file") {
+			t.Errorf("%s: comments were not stripped", format)
+		}
+	}
+}
+
+func TestWriteAllFiles_TreeOrder(t *testing.T) {
+	root, allowed := createManyFiles(t, 30)
+	out := filepath.Join(t.TempDir(), "out.txt")
+	if err := core.WriteAllFiles("tree", root, out, allowed, createJobsTestOption(model.PlainText, 8)); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+
+	last := ""
+	for line := range strings.SplitSeq(string(data), "\n") {
+		if !strings.HasPrefix(line, "=== ") {
+			continue
+		}
+		if line < last {
+			t.Errorf("%s is written after %s", line, last)
+		}
+		last = line
+	}
+}
+
+func TestWriteAllFiles_ErrorStopsPipeline(t *testing.T) {
+	root, allowed := createManyFiles(t, 50)
+	// a dangling symlink is listed in the tree but cannot be read
+	broken := filepath.Join(root, "pkg00", "broken.go")
+	if err := os.Symlink(filepath.Join(root, "nowhere"), broken); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	allowed[broken] = true
+
+	for _, jobs := range []int{1, 4} {
+		out := filepath.Join(t.TempDir(), "out.txt")
+		if err := core.WriteAllFiles("tree", root, out, allowed, createJobsTestOption(model.PlainText, jobs)); err == nil {
+			t.Errorf("expected an error for an unreadable file with %d jobs", jobs)
+		}
+	}
+}
+
+func BenchmarkWriteAllFiles_Jobs(b *testing.B) {
+	root, allowed := createManyFiles(b, 500)
+	for _, jobs := range []int{1, 4, 0} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			opt := createJobsTestOption(model.PlainText, jobs)
+			out := filepath.Join(b.TempDir(), "out.txt")
+			for b.Loop() {
+				if err := core.WriteAllFiles("tree", root, out, allowed, opt); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,289 @@
+package core
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/libgitignore"
+)
+
+// vendoredDirs are directory names that hold third party code.
+var vendoredDirs = []string{"vendor", "node_modules", "third_party", "bower_components"}
+
+// lockfileNames are the dependency lock files of package managers.
+var lockfileNames = []string{
+	"go.sum", "package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+	"cargo.lock", "poetry.lock", "pipfile.lock", "gemfile.lock", "composer.lock",
+}
+
+// generatedSuffixes are the name endings of generated and minified files.
+var generatedSuffixes = []string{
+	".pb.go", ".pb.cc", ".pb.h", "_pb2.py", "_pb2_grpc.py", ".gen.go", "_gen.go", "_generated.go",
+	".min.js", ".min.css", ".js.map", ".css.map",
+}
+
+// generatedHeaders are markers generators write in a comment at the top of a
+// file. Each must start a comment line, so a file that merely mentions one
+// is not taken as generated.
+var generatedHeaders = []*regexp.Regexp{
+	regexp.MustCompile(`(?m)^` + commentLead + `Code generated .* DO NOT EDIT\.`),
+	regexp.MustCompile(`(?m)^` + commentLead + `@generated\b`),
+	regexp.MustCompile(`(?m)^` + commentLead + `<auto-generated`),
+	regexp.MustCompile(`(?m)^` + commentLead + `Generated by the protocol buffer compiler\.\s+DO NOT EDIT!`),
+	regexp.MustCompile(`(?mi)^` + commentLead + `auto-?generated file\b.*\bdo not (edit|modify)\b`),
+}
+
+// commentLead matches the start of a line comment, or of a line inside a
+// block comment.
+const commentLead = `[ \t]*(//|#|--|;|/?\*+|<!--)[ \t]*`
+
+// minifiableExts are the extensions the line length heuristic applies to.
+var minifiableExts = []string{".js", ".mjs", ".cjs", ".css", ".json", ".svg"}
+
+const (
+	// generatedHeaderLines is how many lines are searched for a marker, as
+	// many as linguist looks at
+	generatedHeaderLines = 5
+	// minifiedSampleBytes is how much of a file the line lengths are taken from
+	minifiedSampleBytes = 64 * 1024
+	// minifiedLineLength is the average line length above which a file of
+	// at least minifiedMinSize bytes counts as minified
+	minifiedLineLength = 200
+	minifiedMinSize    = 1024
+)
+
+// generatedDetector tells generated and vendored files apart, by path
+// conventions, the linguist attributes of .gitattributes files, header
+// markers and line lengths. The .gitattributes of a directory is read once,
+// on first use.
+type generatedDetector struct {
+	// top is the directory the .gitattributes files are read from, down to
+	// each file: the work tree of the git repository of the target, or the
+	// target itself outside one
+	top        string
+	attributes map[string][]attributeRule
+}
+
+// attributeRule is a line of a .gitattributes file that sets or unsets
+// linguist-generated or linguist-vendored.
+type attributeRule struct {
+	pattern   *libgitignore.IgnorePattern
+	generated *bool
+	vendored  *bool
+}
+
+func newGeneratedDetector(root string) *generatedDetector {
+	return &generatedDetector{top: workTreeTop(libgitignore.ToAbsDir(root)), attributes: map[string][]attributeRule{}}
+}
+
+// workTreeTop returns the closest directory from dir up that holds a .git
+// entry, or dir when there is none.
+func workTreeTop(dir string) string {
+	for current := dir; ; {
+		if _, err := os.Lstat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+// dirNote tells why the dump leaves the directory dir out, "" if it does not.
+func (d *generatedDetector) dirNote(dir string) string {
+	if name := strings.ToLower(filepath.Base(dir)); slices.Contains(vendoredDirs, name) {
+		return "skipped: vendored (" + name + "/)"
+	}
+	return ""
+}
+
+// fileNote tells why the dump leaves the file fpath out, "" if it does not.
+func (d *generatedDetector) fileNote(fpath string) string {
+	if reason := generatedByName(filepath.Base(fpath)); reason != "" {
+		return "skipped: generated (" + reason + ")"
+	}
+	generated, vendored := d.attributesOf(libgitignore.ToAbsDir(fpath))
+	if vendored != nil && *vendored {
+		return "skipped: vendored (linguist-vendored)"
+	}
+	if generated != nil {
+		if *generated {
+			return "skipped: generated (linguist-generated)"
+		}
+		// linguist-generated=false overrides the content heuristics
+		return ""
+	}
+	if reason := generatedByContent(fpath); reason != "" {
+		return "skipped: generated (" + reason + ")"
+	}
+	return ""
+}
+
+// generatedByName returns the naming convention that marks a file as
+// generated, "" if there is none.
+func generatedByName(name string) string {
+	name = strings.ToLower(name)
+	if slices.Contains(lockfileNames, name) {
+		return "lockfile"
+	}
+	for _, suffix := range generatedSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return "*" + suffix
+		}
+	}
+	return ""
+}
+
+// generatedByContent looks for a generator marker in the first lines of
+// fpath, and for the long lines of a minified file.
+func generatedByContent(fpath string) string {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, minifiedSampleBytes))
+	if err != nil || IsBinary(head) {
+		return ""
+	}
+
+	leading := head
+	if lines := bytes.SplitAfterN(head, []byte("\n"), generatedHeaderLines+1); len(lines) > generatedHeaderLines {
+		leading = bytes.Join(lines[:generatedHeaderLines], nil)
+	}
+	for _, re := range generatedHeaders {
+		if re.Match(leading) {
+			return "header marker"
+		}
+	}
+	if slices.Contains(minifiableExts, strings.ToLower(filepath.Ext(fpath))) && len(head) >= minifiedMinSize {
+		lines := bytes.Count(head, []byte("\n")) + 1
+		if len(head)/lines > minifiedLineLength {
+			return "minified"
+		}
+	}
+	return ""
+}
+
+// attributesOf returns the linguist-generated and linguist-vendored states
+// of path, nil when no .gitattributes line decides them. As in git, a
+// deeper .gitattributes wins over a shallower one, and a later line over an
+// earlier one.
+func (d *generatedDetector) attributesOf(path string) (generated *bool, vendored *bool) {
+	rel, err := filepath.Rel(d.top, filepath.Dir(path))
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, nil
+	}
+	dirs := []string{d.top}
+	if rel != "." {
+		for name := range strings.SplitSeq(rel, string(filepath.Separator)) {
+			dirs = append(dirs, filepath.Join(dirs[len(dirs)-1], name))
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0 && (generated == nil || vendored == nil); i-- {
+		rules := d.rulesOf(dirs[i])
+		for j := len(rules) - 1; j >= 0 && (generated == nil || vendored == nil); j-- {
+			rule := rules[j]
+			if (generated != nil || rule.generated == nil) && (vendored != nil || rule.vendored == nil) {
+				continue
+			}
+			if !rule.pattern.Matches(path) {
+				continue
+			}
+			if generated == nil {
+				generated = rule.generated
+			}
+			if vendored == nil {
+				vendored = rule.vendored
+			}
+		}
+	}
+	return generated, vendored
+}
+
+// rulesOf returns the linguist rules of the .gitattributes file of dir.
+func (d *generatedDetector) rulesOf(dir string) []attributeRule {
+	if rules, ok := d.attributes[dir]; ok {
+		return rules
+	}
+	var rules []attributeRule
+	if data, err := os.ReadFile(filepath.Join(dir, ".gitattributes")); err == nil {
+		rules = parseAttributeRules(string(data), dir)
+	}
+	d.attributes[dir] = rules
+	return rules
+}
+
+// parseAttributeRules reads the lines of a .gitattributes file in dir that
+// set linguist-generated or linguist-vendored. Negative patterns and macro
+// definitions are skipped, as git does.
+func parseAttributeRules(data string, dir string) []attributeRule {
+	var rules []attributeRule
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[attr]") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		var pattern string
+		var attrs []string
+		if strings.HasPrefix(line, `"`) {
+			end := strings.Index(line[1:], `"`)
+			if end < 0 {
+				continue
+			}
+			unquoted, err := strconv.Unquote(line[:end+2])
+			if err != nil {
+				continue
+			}
+			pattern, attrs = unquoted, strings.Fields(line[end+2:])
+		} else {
+			fields := strings.Fields(line)
+			pattern, attrs = fields[0], fields[1:]
+		}
+
+		rule := attributeRule{}
+		for _, attr := range attrs {
+			name, state := attributeState(attr)
+			switch name {
+			case "linguist-generated":
+				rule.generated = &state
+			case "linguist-vendored":
+				rule.vendored = &state
+			}
+		}
+		if rule.generated == nil && rule.vendored == nil {
+			continue
+		}
+		p, err := libgitignore.CompileIgnoreLine(pattern, dir, i+1)
+		if err != nil || p == nil {
+			continue
+		}
+		rule.pattern = p
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// attributeState returns the name of an attribute of a .gitattributes line
+// and whether it is set: "name" and "name=true" set it, "-name",
+// "name=false" and "!name" do not.
+func attributeState(attr string) (string, bool) {
+	switch {
+	case strings.HasPrefix(attr, "-"), strings.HasPrefix(attr, "!"):
+		return attr[1:], false
+	}
+	name, value, ok := strings.Cut(attr, "=")
+	if !ok {
+		return name, true
+	}
+	return name, value != "false"
+}
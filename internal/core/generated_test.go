@@ -0,0 +1,124 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createGeneratedTestTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		".git/HEAD":           "ref",
+		".gitattributes":      "gen/** linguist-generated\ngen/keep.go -linguist-generated\nthirdparty/** linguist-vendored\n*.txt linguist-generated\n",
+		"api.pb.go":           "package api",
+		"app.js":              strings.Repeat("var a=1;", 300),
+		"docs/.gitattributes": "*.txt -linguist-generated\n",
+		"docs/a.txt":          "docs",
+		"gen/api.go":          "package gen",
+		"gen/keep.go":         "// Code generated by hand. DO NOT EDIT.\npackage gen",
+		"go.sum":              "sum",
+		"main.go":             "package main",
+		"models.go":           "// Code generated by sqlc. DO NOT EDIT.\n\npackage models",
+		"notes.txt":           "notes",
+		"thirdparty/lib.c":    "int lib;",
+		"vendor/x/y.go":       "package x",
+	}
+	for rel, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		mustWriteFile(t, p, content)
+	}
+	return root
+}
+
+func walkSkippingGenerated(t *testing.T, root string) *core.FileTree {
+	t.Helper()
+	opt := &commandline.Option{IgnoreDotFileFlag: model.OnOffSwitch("off"), SkipGeneratedFlag: true}
+	tree, err := core.WalkTree(root, opt)
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+	return tree
+}
+
+func TestWalkTree_SkipGenerated(t *testing.T) {
+	root := createGeneratedTestTree(t)
+	tree := walkSkippingGenerated(t, root)
+
+	want := "├── .gitattributes\n" +
+		"├── api.pb.go [skipped: generated (*.pb.go)]\n" +
+		"├── app.js [skipped: generated (minified)]\n" +
+		"├── docs/\n│   ├── .gitattributes\n│   └── a.txt\n" +
+		"├── gen/\n│   ├── api.go [skipped: generated (linguist-generated)]\n│   └── keep.go\n" +
+		"├── go.sum [skipped: generated (lockfile)]\n" +
+		"├── main.go\n" +
+		"├── models.go [skipped: generated (header marker)]\n" +
+		"├── notes.txt [skipped: generated (linguist-generated)]\n" +
+		"├── thirdparty/\n│   └── lib.c [skipped: vendored (linguist-vendored)]\n" +
+		"└── vendor/ [skipped: vendored (vendor/)]\n"
+	if got := tree.TreeString(""); got != want {
+		t.Errorf("TreeString() =\n%s\nwant\n%s", got, want)
+	}
+
+	var dumped []string
+	for _, fpath := range tree.Restrict(tree.Allowed()).Files() {
+		rel, _ := filepath.Rel(root, fpath)
+		dumped = append(dumped, filepath.ToSlash(rel))
+	}
+	if want := []string{".gitattributes", "docs/.gitattributes", "docs/a.txt", "gen/keep.go", "main.go"}; !slices.Equal(dumped, want) {
+		t.Errorf("dumped %v; want %v", dumped, want)
+	}
+
+	entry := tree.TreeEntry()
+	if vendor := entry.Children[len(entry.Children)-1]; vendor.Name != "vendor" || vendor.Note != "skipped: vendored (vendor/)" || len(vendor.Children) != 0 {
+		t.Errorf("vendor entry = %+v; want a skipped directory without children", vendor)
+	}
+}
+
+func TestWalkTree_GeneratedKeptByDefault(t *testing.T) {
+	root := createGeneratedTestTree(t)
+	tree, err := core.WalkTree(root, &commandline.Option{IgnoreDotFileFlag: model.OnOffSwitch("off")})
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+	if files := tree.Restrict(tree.Allowed()).Files(); len(files) != 13 {
+		t.Errorf("dumped %d files; want all 13", len(files))
+	}
+}
+
+func TestWalkTree_GeneratedMarkersOnlyInLeadingComments(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"generated.go": "// Code generated by stringer. DO NOT EDIT.\n\npackage x",
+		"gql.js":       "/**\n * @generated\n */\nexport const q = 1;",
+		"Form.cs":      "// <auto-generated>\n//   by a tool\n// </auto-generated>\nclass Form {}",
+		"detect.go":    "package x\n\nvar markers = []string{\"@generated\", \"<auto-generated\"}\n",
+		"NOTES.md":     "Tools mark their output with `@generated`, `<auto-generated>` or\n\"Code generated by x. DO NOT EDIT.\"\n",
+		"late.go":      "package x\n\n\n\n\n\n// Code generated by hand. DO NOT EDIT.\n",
+	}
+	for name, content := range files {
+		mustWriteFile(t, filepath.Join(root, name), content)
+	}
+
+	tree := walkSkippingGenerated(t, root)
+	var skipped []string
+	for _, node := range tree.Nodes {
+		if node.Skip {
+			skipped = append(skipped, node.Name)
+		}
+	}
+	slices.Sort(skipped)
+	if want := []string{"Form.cs", "generated.go", "gql.js"}; !slices.Equal(skipped, want) {
+		t.Errorf("skipped %v; want %v", skipped, want)
+	}
+}
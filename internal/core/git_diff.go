@@ -0,0 +1,72 @@
+package core
+
+import (
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/gitdiff"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+const gitDiffSeparator = "--- git diff ---\n"
+
+// loadGitChanges collects the changed files once when --since or --staged is set.
+func loadGitChanges(opt *commandline.Option) error {
+	if !opt.DiffModeEnabled() || opt.GitChanges != nil {
+		return nil
+	}
+	changes, err := gitdiff.Collect(opt.TargetDirname, opt.SinceRef, opt.StagedFlag)
+	if err != nil {
+		return err
+	}
+	opt.GitChanges = changes
+	return nil
+}
+
+// filterChangedFiles narrows the allowed file list to changed files and the
+// directories containing them. The tree itself is still rendered in full.
+func filterChangedFiles(allowedFileListMap map[string]bool, opt *commandline.Option) map[string]bool {
+	if opt.GitChanges == nil {
+		return allowedFileListMap
+	}
+	filtered := map[string]bool{}
+	for fpath := range allowedFileListMap {
+		if opt.GitChanges.Status(fpath) != "" || opt.GitChanges.HasChangesUnder(fpath) {
+			filtered[fpath] = true
+		}
+	}
+	return filtered
+}
+
+// gitStatusMarker renders the git status of a changed file for the text tree.
+func gitStatusMarker(status string) string {
+	if status == "" {
+		return ""
+	}
+	return " [" + status + "]"
+}
+
+// applyDiffContent replaces or extends the content of a changed file with its
+// unified diff according to --diff-content.
+func applyDiffContent(fpath string, content string, opt *commandline.Option) (string, error) {
+	change := opt.GitChanges.Get(fpath)
+	if change == nil {
+		return content, nil
+	}
+	mode := opt.DiffContent.String()
+	if mode == "" || mode == model.DiffContentFull {
+		return content, nil
+	}
+
+	diff, err := change.Diff()
+	if err != nil {
+		return "", err
+	}
+	if mode == model.DiffContentDiff {
+		return diff, nil
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	return content + gitDiffSeparator + diff, nil
+}
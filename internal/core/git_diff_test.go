@@ -0,0 +1,103 @@
+package core_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+)
+
+func runGitForTest(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func createGitDiffTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	runGitForTest(t, dir, "init", "-q")
+	writeFile(t, filepath.Join(dir, "keep.txt"), "unchanged\n")
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n")
+	runGitForTest(t, dir, "add", "-A")
+	runGitForTest(t, dir, "commit", "-q", "-m", "initial")
+
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+	if err := os.Mkdir(filepath.Join(dir, "pkg"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, "pkg", "new.go"), "package pkg\n")
+	return dir
+}
+
+func applyForTest(t *testing.T, args ...string) string {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out")
+	_, opt, err := commandline.GeneralOptParse(append([]string{"-S", "-o", out}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := core.Apply(opt); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	data, err := os.ReadFile(opt.OutputFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestApply_Since(t *testing.T) {
+	dir := createGitDiffTestRepo(t)
+	out := applyForTest(t, "-f", "txt", "-n", "off", "--since", "HEAD", dir)
+
+	if !strings.Contains(out, "main.go [M]") || !strings.Contains(out, "new.go [?]") {
+		t.Errorf("changed files must be marked in the tree:\n%s", out)
+	}
+	if !strings.Contains(out, "keep.txt\n") {
+		t.Errorf("unchanged files must still be in the tree:\n%s", out)
+	}
+	if strings.Contains(out, "keep.txt (") {
+		t.Errorf("unchanged files must not be dumped:\n%s", out)
+	}
+	if !strings.Contains(out, "main.go (ASCII 1.00, LF) ===\npackage main\n\nfunc main() {}\n") {
+		t.Errorf("changed file content missing:\n%s", out)
+	}
+}
+
+func TestApply_SinceWithDiffHunks(t *testing.T) {
+	dir := createGitDiffTestRepo(t)
+
+	out := applyForTest(t, "-f", "md", "--since", "HEAD", "--diff-content", "diff", dir)
+	if !strings.Contains(out, "+func main() {}") || strings.Contains(out, "--- git diff ---") {
+		t.Errorf("expected only diff hunks:\n%s", out)
+	}
+
+	out = applyForTest(t, "-f", "md", "--since", "HEAD", "--diff-content", "both", dir)
+	if !strings.Contains(out, "package main\n\nfunc main() {}\n--- git diff ---\n") {
+		t.Errorf("expected content followed by its diff:\n%s", out)
+	}
+}
+
+func TestApply_Staged(t *testing.T) {
+	dir := createGitDiffTestRepo(t)
+	runGitForTest(t, dir, "add", "pkg/new.go")
+
+	out := applyForTest(t, "-f", "json", "--staged", dir)
+	if !strings.Contains(out, `"status":"A"`) || !strings.Contains(out, `"path":"pkg/new.go"`) {
+		t.Errorf("expected the staged file:\n%s", out)
+	}
+	if strings.Contains(out, `"path":"main.go"`) {
+		t.Errorf("unstaged changes must not be dumped:\n%s", out)
+	}
+}
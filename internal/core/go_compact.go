@@ -0,0 +1,136 @@
+package core
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// CompactGo reduces a .go file to its API surface: function bodies are
+// elided, and with the exported mode only the exported declarations stay.
+// Doc comments of what is left are kept. Other files and files that do not
+// parse are returned as is.
+func CompactGo(data []byte, fpath string, mode model.CompactGo) []byte {
+	if mode == model.CompactGoOff || mode == "" || filepath.Ext(fpath) != ".go" {
+		return data
+	}
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fpath, data, parser.ParseComments)
+	if err != nil {
+		return data
+	}
+
+	if mode == model.CompactGoExported {
+		// FileExports drops the imports the exported signatures still refer to
+		var decls []ast.Decl
+		for _, decl := range f.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.IMPORT {
+				imports := *gen
+				imports.Specs = append([]ast.Spec(nil), gen.Specs...)
+				decls = append(decls, &imports)
+			}
+		}
+		ast.FileExports(f)
+		for _, decl := range f.Decls {
+			if fn, ok := decl.(*ast.FuncDecl); ok && !exportedReceiver(fn) {
+				continue
+			}
+			decls = append(decls, decl)
+		}
+		f.Decls = decls
+	}
+	for _, decl := range f.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			fn.Body = nil
+		}
+	}
+
+	// only the comments still attached to the tree survive, along with the
+	// build constraints and license headers above the package clause
+	attached := map[*ast.CommentGroup]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if cg, ok := n.(*ast.CommentGroup); ok {
+			attached[cg] = true
+		}
+		return true
+	})
+	comments := f.Comments[:0]
+	for _, cg := range f.Comments {
+		if attached[cg] || cg.End() < f.Package {
+			comments = append(comments, cg)
+		}
+	}
+	f.Comments = comments
+	markFilteredFields(fset, f)
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, f); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
+
+// markFilteredFields writes the note go/printer loses on files with comments
+// into the structs and interfaces that had unexported members removed.
+func markFilteredFields(fset *token.FileSet, f *ast.File) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		var fields *ast.FieldList
+		switch x := n.(type) {
+		case *ast.StructType:
+			if x.Incomplete {
+				fields = x.Fields
+			}
+		case *ast.InterfaceType:
+			if x.Incomplete {
+				fields = x.Methods
+			}
+		}
+		// a one-line struct keeps the printer's own /* ... */ note
+		if fields == nil || fset.Position(fields.Opening).Line == fset.Position(fields.Closing).Line {
+			return true
+		}
+		switch x := n.(type) {
+		case *ast.StructType:
+			x.Incomplete = false
+		case *ast.InterfaceType:
+			x.Incomplete = false
+		}
+		f.Comments = append(f.Comments, &ast.CommentGroup{List: []*ast.Comment{
+			{Slash: fields.Closing - 1, Text: "// contains filtered or unexported fields"},
+		}})
+		return true
+	})
+	sort.Slice(f.Comments, func(i, j int) bool { return f.Comments[i].Pos() < f.Comments[j].Pos() })
+}
+
+// exportedReceiver reports whether fn is a function or a method of an
+// exported type.
+func exportedReceiver(fn *ast.FuncDecl) bool {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return true
+	}
+	t := fn.Recv.List[0].Type
+	for {
+		switch x := t.(type) {
+		case *ast.StarExpr:
+			t = x.X
+		case *ast.IndexExpr:
+			t = x.X
+		case *ast.IndexListExpr:
+			t = x.X
+		case *ast.ParenExpr:
+			t = x.X
+		case *ast.Ident:
+			return x.IsExported()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+const compactGoSource = `//go:build linux
+
+// Package shop sells things.
+package shop
+
+import "fmt"
+
+// Item is sold.
+type Item struct {
+	// Name is shown.
+	Name  string
+	price int
+}
+
+type cart struct{ items []Item }
+
+// Store sells items.
+type Store interface {
+	Buy(Item) error
+}
+
+// Price returns the price.
+func (i Item) Price() int {
+	// cents
+	return i.price
+}
+
+func (c *cart) Add(i Item) { c.items = append(c.items, i) }
+
+// New returns a store.
+func New() Store {
+	fmt.Println("new")
+	return nil
+}
+
+func helper() {}
+`
+
+func TestCompactGo_Bodies(t *testing.T) {
+	out := string(core.CompactGo([]byte(compactGoSource), "shop.go", model.CompactGoBodies))
+
+	for _, want := range []string{
+		"//go:build linux",
+		"// Package shop sells things.",
+		"// Price returns the price.\nfunc (i Item) Price() int\n",
+		"func (c *cart) Add(i Item)\n",
+		"func New() Store\n",
+		"func helper()\n",
+		"type cart struct{ items []Item }",
+		"price int",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"return i.price", "// cents", "fmt.Println"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q in:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestCompactGo_Exported(t *testing.T) {
+	out := string(core.CompactGo([]byte(compactGoSource), "shop.go", model.CompactGoExported))
+
+	for _, want := range []string{
+		"// Package shop sells things.",
+		"import \"fmt\"",
+		"// Name is shown.\n\tName string",
+		"// contains filtered or unexported fields",
+		"Buy(Item) error",
+		"func (i Item) Price() int\n",
+		"func New() Store\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"cart", "helper", "price int", "return nil"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %q in:\n%s", unwanted, out)
+		}
+	}
+}
+
+func TestCompactGo_Passthrough(t *testing.T) {
+	tests := []struct {
+		name  string
+		path  string
+		input string
+		mode  model.CompactGo
+	}{
+		{"off", "a.go", "package a\n\nfunc f() { return }\n", model.CompactGoOff},
+		{"not go", "a.py", "def f():\n    return 1\n", model.CompactGoBodies},
+		{"parse error", "a.go", "package a\n\nfunc f() {\n", model.CompactGoBodies},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if out := string(core.CompactGo([]byte(tt.input), tt.path, tt.mode)); out != tt.input {
+				t.Errorf("expected the input back, got %q", out)
+			}
+		})
+	}
+}
+
+func TestApply_CompactGo(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "shop.go"), []byte(compactGoSource), 0644)
+	os.WriteFile(filepath.Join(root, "shop_test.go"), []byte("package shop\n\nfunc TestNew(t *testing.T) {\n\tNew()\n}\n"), 0644)
+	t.Chdir(t.TempDir())
+
+	_, opt, err := commandline.GeneralOptParse([]string{"-S", "-o", "-", "--compact-go", "exported", root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := core.Apply(opt); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	})
+	if !strings.Contains(out, "func New() Store") || !strings.Contains(out, "func TestNew(t *testing.T)") {
+		t.Errorf("expected the compacted signatures:\n%s", out)
+	}
+	if strings.Contains(out, "fmt.Println") || strings.Contains(out, "\tNew()") {
+		t.Errorf("function bodies must be elided:\n%s", out)
+	}
+}
@@ -14,8 +14,14 @@ import (
 )
 
 func Apply(opt *commandline.Option) error {
-	if opt.SilentFlag {
-		return createDumpFile(opt)
+	// a dump streamed to stdout only shows progress when stderr is a terminal
+	if opt.SilentFlag || (opt.IsStdout() && !spinner.IsTerminal(os.Stderr)) {
+		result, err := createDumpFile(opt)
+		if err != nil {
+			return err
+		}
+		printTokenReport(result.budget, opt)
+		return nil
 	} else {
 		return withSpinner(opt)
 	}
@@ -34,50 +40,98 @@ func withSpinner(opt *commandline.Option) error {
 	}()
 
 	s.Start()
-	createDumpFile(opt)
+	result, err := createDumpFile(opt)
+	if err != nil {
+		s.Stop(fmt.Sprintf("%s  Archiving failed: %s", textbank.EmojiInterrupted, opt.OutputFilename))
+		return err
+	}
 
-	s.SetMessage(fmt.Sprintf("%s  Finalizing record...", textbank.EmojiAlmost))
-	if opt.ComplessFlag && opt.OutputFormat.CanCompless() {
-		Compless(opt.OutputFilename, opt.OutputFormat)
+	if opt.IsStdout() {
+		s.Stop()
+		printTokenReport(result.budget, opt)
+		return nil
+	}
 
+	if len(result.outputFiles) > 1 {
+		s.Stop(fmt.Sprintf("%s  Archive completed: %d chunks (%s)", textbank.EmojiDone, len(result.outputFiles), ManifestFilename(opt.OutputFilename)))
 	} else {
-		time.Sleep(1 * time.Second)
+		s.Stop(fmt.Sprintf("%s  Archive completed: %s", textbank.EmojiDone, opt.OutputFilename))
 	}
-
-	s.Stop(fmt.Sprintf("%s  Archive completed: %s", textbank.EmojiDone, opt.OutputFilename))
+	printTokenReport(result.budget, opt)
 	return nil
 
 }
 
-func createDumpFile(opt *commandline.Option) error {
-	firstIndent := ""
-	var firstAllowdFileListMap = map[string]bool{}
-
-	// arklite
-	if opt.OutputFormat.String() == model.Arklite {
-		if treeStr, allowdFileList, err := GenerateTreeJSONString(opt.TargetDirname, firstAllowdFileListMap, opt); err != nil {
-			return err
+func printTokenReport(budget *TokenBudget, opt *commandline.Option) {
+	if budget == nil {
+		return
+	}
+	if opt.TokenReportFlag || (opt.MaxTokens > 0 && !opt.SilentFlag) {
+		// keep stdout clean when the dump itself goes there
+		if opt.IsStdout() {
+			budget.WriteReport(os.Stderr)
 		} else {
-			if err := WriteAllFilesAsArklite(treeStr, opt.TargetDirname, opt.OutputFilename, allowdFileList, opt); err != nil {
-				return err
-			}
+			budget.WriteReport(os.Stdout)
 		}
-		return nil
 	}
+}
 
-	// text, markdown, xml
-	if treeStr, allowdFileList, err := GenerateTreeString(opt.TargetDirname, firstIndent, firstAllowdFileListMap, opt); err != nil {
-		return err
-	} else {
-		if opt.OutputFormat.String() == model.XML {
-			if err := WriteAllFilesAsXML(treeStr, opt.TargetDirname, opt.OutputFilename, allowdFileList, opt); err != nil {
-				return err
-			}
-		} else {
-			if err := WriteAllFiles(treeStr, opt.TargetDirname, opt.OutputFilename, allowdFileList, opt); err != nil {
-				return err
-			}
+// dumpResult is what a single dump run produced.
+type dumpResult struct {
+	budget      *TokenBudget
+	outputFiles []string
+}
+
+func createDumpFile(opt *commandline.Option) (*dumpResult, error) {
+	result, err := writeDump(opt)
+	if err != nil {
+		return nil, err
+	}
+	// the placeholders in the dump are only reversible once the vault is saved
+	if vault := opt.SecretRuleSet().Vault; vault != nil {
+		if err := vault.Save(); err != nil {
+			return nil, fmt.Errorf("failed to save the secret vault: %w", err)
 		}
-		return nil
 	}
+	return result, nil
+}
+
+func writeDump(opt *commandline.Option) (*dumpResult, error) {
+	if err := loadGitChanges(opt); err != nil {
+		return nil, err
+	}
+
+	// the target is walked once; the tree and every dumper work from it
+	tree, err := WalkTree(opt.TargetDirname, opt)
+	if err != nil {
+		return nil, err
+	}
+	dump, err := orderDumpTree(tree.Restrict(filterChangedFiles(tree.Allowed(), opt)), opt)
+	if err != nil {
+		return nil, err
+	}
+	budget, err := newTokenBudget(dump.Root, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	var outputFiles []string
+	switch opt.OutputFormat.String() {
+	case model.JSON:
+		outputFiles, err = writeAllFilesAsJSON(treeJSONString(tree), dump, opt.OutputFilename, opt, budget)
+	case model.JSONL:
+		outputFiles, err = writeAllFilesAsJSONL(dump, opt.OutputFilename, opt, budget)
+	case model.Arklite:
+		outputFiles, err = writeAllFilesAsArklite(treeJSONString(tree), dump, opt.OutputFilename, opt, budget)
+	case model.XML:
+		outputFiles, err = writeAllFilesAsXML(tree.TreeString(""), dump, opt.OutputFilename, opt, budget)
+	case model.Outline:
+		outputFiles, err = writeAllFilesAsOutline(tree.TreeString(""), dump, opt.OutputFilename, opt, budget)
+	default:
+		outputFiles, err = writeAllFiles(tree.TreeString(""), dump, opt.OutputFilename, opt, budget)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dumpResult{budget: budget, outputFiles: outputFiles}, nil
 }
@@ -0,0 +1,115 @@
+package core_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+)
+
+// captureStdout runs f with os.Stdout redirected and returns what it wrote.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-done
+}
+
+func TestApply_Stdout(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n"), 0644)
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+
+	for _, format := range []string{"txt", "md", "xml", "arklite", "json", "jsonl"} {
+		t.Run(format, func(t *testing.T) {
+			_, opt, err := commandline.GeneralOptParse([]string{"-S", "-o", "-", "-f", format, root})
+			if err != nil {
+				t.Fatal(err)
+			}
+			out := captureStdout(t, func() {
+				if err := core.Apply(opt); err != nil {
+					t.Fatalf("Apply failed: %v", err)
+				}
+			})
+			if !strings.Contains(out, "package main") {
+				t.Errorf("dump not streamed to stdout:\n%s", out)
+			}
+			files, err := core.DecodeDump([]byte(out))
+			if err != nil {
+				t.Fatalf("streamed dump does not decode: %v", err)
+			}
+			if len(files) != 1 || files[0].Content != "package main\n" {
+				t.Errorf("unexpected decoded files: %+v", files)
+			}
+		})
+	}
+
+	entries, _ := os.ReadDir(cwd)
+	if len(entries) != 0 {
+		t.Errorf("no file must be written when streaming, found %d", len(entries))
+	}
+}
+
+func TestApply_StdoutCompless(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644)
+	t.Chdir(t.TempDir())
+
+	_, opt, err := commandline.GeneralOptParse([]string{"-S", "-o", "-", "-f", "txt", "-c", root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := core.Apply(opt); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	})
+	if !strings.HasPrefix(out, "# Arklite Format Overview") {
+		t.Errorf("compless header missing:\n%s", out)
+	}
+	if !strings.Contains(out, "@ark-output.txt\n") {
+		t.Errorf("streamed compless dump must expand to ark-output.txt:\n%s", out)
+	}
+	if !strings.Contains(out, "package main␤func main() {}") {
+		t.Errorf("content not compacted:\n%s", out)
+	}
+}
+
+func TestApply_SecretRules(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "main.go"), []byte("const token = \"ACME_0123456789ABCDEF\"\n"), 0644)
+	os.WriteFile(filepath.Join(root, ".arksecrets.toml"), []byte("[mask]\nacme-token = 'ACME_[0-9A-F]{16}'\n"), 0644)
+	t.Chdir(t.TempDir())
+
+	_, opt, err := commandline.GeneralOptParse([]string{"-S", "-o", "-", "-f", "json", root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := core.Apply(opt); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	})
+	if strings.Contains(out, "ACME_0123456789ABCDEF") || !strings.Contains(out, "const token") {
+		t.Errorf("the token matched by .arksecrets.toml must be masked:\n%s", out)
+	}
+}
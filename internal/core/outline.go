@@ -0,0 +1,537 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// outlineSymbol is a declaration of the outline with its 1-based line range.
+// Members of classes, interfaces and modules have depth 1.
+type outlineSymbol struct {
+	Signature  string
+	Start, End int
+	Depth      int
+}
+
+// outlineGrammar is the lightweight parser of a language: regexps matched
+// against trimmed lines, with the extent of a declaration taken from its
+// indentation and closing line.
+type outlineGrammar struct {
+	// decls start a declaration at the top level
+	decls []*regexp.Regexp
+	// containers are the decls whose members are listed too
+	containers []*regexp.Regexp
+	// members start a declaration inside a container
+	members []*regexp.Regexp
+	// prototypes are top-level signatures ending in ; that have no body
+	skipPrototypes bool
+	// forms are lisp declarations, which run to their closing paren
+	forms bool
+}
+
+const outlineMaxSignature = 240
+
+var outlineControlWords = map[string]bool{
+	"if": true, "for": true, "foreach": true, "while": true, "switch": true, "catch": true,
+	"return": true, "else": true, "do": true, "try": true, "new": true, "throw": true,
+	"using": true, "lock": true, "synchronized": true, "sizeof": true, "case": true,
+	"await": true, "yield": true, "delete": true, "typeof": true, "super": true, "this": true,
+}
+
+func outlineRegexps(patterns ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		res[i] = regexp.MustCompile(p)
+	}
+	return res
+}
+
+const (
+	jvmModifiers   = `(?:(?:public|private|protected|internal|static|final|abstract|sealed|open|override|data|inline|async|virtual|partial|readonly|unsafe|extern|new|suspend|inner|enum|annotation|companion|const|default|synchronized|native|strictfp|fileprivate|mutating|nonmutating|convenience|required|lazy|weak|factory|external|implicit|case|@\w+(?:\([^)]*\))?)\s+)*`
+	jvmTypes       = `(?:class|interface|enum|record|struct|object|trait|protocol|extension|actor|namespace|mixin|@interface)\b`
+	jsExport       = `(?:export\s+(?:default\s+)?)?(?:declare\s+)?`
+	rustVisibility = `(?:pub(?:\([^)]*\))?\s+)?`
+	cFunction      = `^(?:template\s*<.*>\s*)?(?:(?:static|inline|extern|const|unsigned|signed|virtual|constexpr|explicit|friend)\s+)*[A-Za-z_][\w:<>,\s\*&]*?[\s\*&]+(?:[\w:~]+|operator\S+)\s*\(`
+)
+
+var (
+	pythonGrammar = &outlineGrammar{
+		decls:      outlineRegexps(`^(?:async\s+)?def\s+\w+`, `^class\s+\w+`),
+		containers: outlineRegexps(`^class\s`),
+		members:    outlineRegexps(`^(?:async\s+)?def\s+\w+`, `^class\s+\w+`),
+	}
+	rubyGrammar = &outlineGrammar{
+		decls:      outlineRegexps(`^(?:class|module)\s+\S`, `^def\s+\S`),
+		containers: outlineRegexps(`^(?:class|module)\s`),
+		members:    outlineRegexps(`^def\s+\S`, `^(?:class|module)\s+\S`),
+	}
+	javaScriptGrammar = &outlineGrammar{
+		decls: outlineRegexps(
+			`^`+jsExport+`(?:async\s+)?function\b`,
+			`^`+jsExport+`(?:abstract\s+)?class\b`,
+			`^`+jsExport+`(?:const\s+)?(?:interface|type|enum|namespace|module)\s+[\w$]+`,
+			`^(?:export\s+)?(?:const|let|var)\s+[\w$]+\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\(|[\w$]+\s*=>)`,
+		),
+		containers: outlineRegexps(`^`+jsExport+`(?:abstract\s+)?class\b`, `^`+jsExport+`(?:interface|namespace|module)\b`),
+		members:    outlineRegexps(`^(?:(?:public|private|protected|static|readonly|abstract|override|async|get|set|declare)\s+)*\*?[#\w$]+\??\s*(?:<[^>]*>)?\s*\(`),
+	}
+	jvmGrammar = &outlineGrammar{
+		decls: outlineRegexps(
+			`^`+jvmModifiers+jvmTypes,
+			`^`+jvmModifiers+`(?:fun|func|def)\b`,
+		),
+		containers: outlineRegexps(`^` + jvmModifiers + jvmTypes),
+		members: outlineRegexps(
+			`^`+jvmModifiers+jvmTypes,
+			`^`+jvmModifiers+`(?:fun|func|def|init|deinit|constructor|subscript)\b`,
+			`^`+jvmModifiers+`(?:<[^>]*>\s*)?[\w<>\[\],.?]+(?:<[^>]*>)?[\]?>]?\s+\w+\s*(?:<[^>]*>)?\s*\(`,
+		),
+	}
+	rustGrammar = &outlineGrammar{
+		decls: outlineRegexps(
+			`^`+rustVisibility+`(?:(?:const|async|unsafe|extern(?:\s+"[^"]*")?)\s+)*fn\s+\w+`,
+			`^`+rustVisibility+`(?:unsafe\s+)?(?:struct|enum|union|trait|type|mod)\s+\w+`,
+			`^(?:unsafe\s+)?impl\b`,
+			`^macro_rules!`,
+		),
+		containers: outlineRegexps(`^`+rustVisibility+`(?:unsafe\s+)?(?:trait|mod)\s`, `^(?:unsafe\s+)?impl\b`),
+		members: outlineRegexps(
+			`^`+rustVisibility+`(?:default\s+)?(?:(?:const|async|unsafe|extern(?:\s+"[^"]*")?)\s+)*fn\s+\w+`,
+			`^`+rustVisibility+`(?:struct|enum|trait|type)\s+\w+`,
+		),
+	}
+	cGrammar = &outlineGrammar{
+		decls: outlineRegexps(
+			`^(?:typedef\s+)?(?:struct|union|enum|class)\s+\w+[^;]*$`,
+			`^namespace\b[^;]*$`,
+			cFunction,
+			`^\w[\w:]*::~?\w+\s*\(`,
+			`^[-+]\s*\(`,
+		),
+		containers:     outlineRegexps(`^(?:typedef\s+)?(?:struct|class)\s`, `^namespace\b`),
+		members:        outlineRegexps(cFunction, `^(?:virtual\s+|explicit\s+)?~?\w+\s*\(`, `^(?:struct|union|enum|class)\s+\w+[^;]*$`),
+		skipPrototypes: true,
+	}
+	phpGrammar = &outlineGrammar{
+		decls: outlineRegexps(
+			`^(?:(?:abstract|final|readonly)\s+)*(?:class|interface|trait|enum)\s+\w+`,
+			`^function\s+&?\w+`,
+		),
+		containers: outlineRegexps(`^(?:(?:abstract|final|readonly)\s+)*(?:class|interface|trait|enum)\s`),
+		members:    outlineRegexps(`^(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+&?\w+`),
+	}
+	lispGrammar = &outlineGrammar{
+		decls: outlineRegexps(`^\((?:defn-?|defmacro|defun|defmethod|defgeneric|defclass|defstruct|defprotocol|defrecord|deftype|defmulti|defvar|defcustom|defconst|ns)\s`),
+		forms: true,
+	}
+)
+
+var outlineGrammars = map[string]*outlineGrammar{
+	"python":     pythonGrammar,
+	"ruby":       rubyGrammar,
+	"javascript": javaScriptGrammar,
+	"typescript": javaScriptGrammar,
+	"jsx":        javaScriptGrammar,
+	"tsx":        javaScriptGrammar,
+	"java":       jvmGrammar,
+	"csharp":     jvmGrammar,
+	"kotlin":     jvmGrammar,
+	"scala":      jvmGrammar,
+	"groovy":     jvmGrammar,
+	"dart":       jvmGrammar,
+	"swift":      jvmGrammar,
+	"rust":       rustGrammar,
+	"c":          cGrammar,
+	"cpp":        cGrammar,
+	"objectivec": cGrammar,
+	"php":        phpGrammar,
+	"clojure":    lispGrammar,
+	"lisp":       lispGrammar,
+	"emacs-lisp": lispGrammar,
+	"bash":       {decls: outlineRegexps(`^(?:function\s+[\w:.-]+|[\w:.-]+\s*\(\s*\))`)},
+	"lua":        {decls: outlineRegexps(`^(?:local\s+)?function\s+[\w.:]+`, `^(?:local\s+)?[\w.:]+\s*=\s*function\b`)},
+	"perl":       {decls: outlineRegexps(`^sub\s+\w+`, `^package\s+[\w:]+`)},
+	"r":          {decls: outlineRegexps(`^[\w.]+\s*(?:<-|=)\s*function\b`)},
+	"haskell":    {decls: outlineRegexps(`^(?:data|newtype|type|class|instance)\s`, `^[a-z_][\w']*\s*::`)},
+	"sql":        {decls: outlineRegexps(`(?i)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:TEMP(?:ORARY)?\s+)?(?:TABLE|VIEW|FUNCTION|PROCEDURE|INDEX|UNIQUE\s+INDEX|TRIGGER|TYPE|SCHEMA|MATERIALIZED\s+VIEW)\b`)},
+	"elixir": {
+		decls:      outlineRegexps(`^(?:defmodule|defprotocol|defimpl)\s`, `^(?:def|defp|defmacro)\s`),
+		containers: outlineRegexps(`^(?:defmodule|defprotocol|defimpl)\s`),
+		members:    outlineRegexps(`^(?:def|defp|defmacro|defmacrop|defguard|defdelegate|defstruct|defmodule)\b`),
+	},
+}
+
+// outlineFile returns the top-level declarations of a file. Languages
+// without a parser have none.
+func outlineFile(fpath string, content string) []outlineSymbol {
+	switch lang := detectLanguageTag(fpath); lang {
+	case "go":
+		return outlineGo(fpath, content)
+	case "markdown":
+		return outlineMarkdown(content)
+	default:
+		if g, ok := outlineGrammars[lang]; ok {
+			return g.outline(outlineLines(lang, content))
+		}
+	}
+	return nil
+}
+
+// outlineLines splits content into lines twice: as it is, for the
+// signatures, and with its strings and comments blanked out, for the scan of
+// declarations, indentation and brackets.
+func outlineLines(lang string, content string) (lines []string, masked []string) {
+	lines = strings.Split(content, "\n")
+	syntax, ok := commentSyntaxes[lang]
+	if !ok {
+		return lines, lines
+	}
+	return lines, strings.Split(string(maskLiterals([]byte(content), syntax)), "\n")
+}
+
+// OutlineFile renders the outline of a file, one "start-end: signature" line
+// per declaration, with members indented under their container.
+func OutlineFile(fpath string, content string) string {
+	var b strings.Builder
+	for _, sym := range outlineFile(fpath, content) {
+		b.WriteString(strings.Repeat("  ", sym.Depth))
+		if sym.Start == sym.End {
+			fmt.Fprintf(&b, "%d: %s\n", sym.Start, sym.Signature)
+		} else {
+			fmt.Fprintf(&b, "%d-%d: %s\n", sym.Start, sym.End, sym.Signature)
+		}
+	}
+	return b.String()
+}
+
+// outlineGo lists the functions, methods and types of a Go file with go/ast.
+func outlineGo(fpath string, content string) []outlineSymbol {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, fpath, content, parser.SkipObjectResolution)
+	if err != nil {
+		return outlineGoFallback.outline(outlineLines("go", content))
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+	node := func(n ast.Node) string {
+		var buf bytes.Buffer
+		if err := format.Node(&buf, fset, n); err != nil {
+			return ""
+		}
+		return cleanSignature(buf.String())
+	}
+
+	var symbols []outlineSymbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			sig := *d
+			sig.Doc, sig.Body = nil, nil
+			symbols = append(symbols, outlineSymbol{Signature: node(&sig), Start: line(d.Pos()), End: line(d.End())})
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				start := line(ts.Pos())
+				if !d.Lparen.IsValid() {
+					start = line(d.Pos())
+				}
+				head := *ts
+				head.Doc, head.Comment = nil, nil
+				switch ts.Type.(type) {
+				case *ast.StructType:
+					head.Type = &ast.StructType{Fields: &ast.FieldList{}}
+				case *ast.InterfaceType:
+					head.Type = &ast.InterfaceType{Methods: &ast.FieldList{}}
+				}
+				sig := strings.TrimSuffix(node(&head), "{}")
+				sig = strings.TrimSpace(strings.TrimSuffix(sig, "{ }"))
+				symbols = append(symbols, outlineSymbol{Signature: "type " + sig, Start: start, End: line(ts.End())})
+				if it, ok := ts.Type.(*ast.InterfaceType); ok {
+					for _, m := range it.Methods.List {
+						// methods print as func types, embedded interfaces as they are
+						sig := node(m.Type)
+						if len(m.Names) > 0 {
+							sig = m.Names[0].Name + strings.TrimPrefix(sig, "func")
+						}
+						symbols = append(symbols, outlineSymbol{Signature: sig, Start: line(m.Pos()), End: line(m.End()), Depth: 1})
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// outlineGoFallback reads Go files that do not parse line by line.
+var outlineGoFallback = &outlineGrammar{
+	decls: outlineRegexps(`^func\b`, `^type\s+\w+`),
+}
+
+var markdownHeadingRegexp = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// outlineMarkdown lists the headings of a Markdown file. A section runs to
+// the next heading of the same or a higher level.
+func outlineMarkdown(content string) []outlineSymbol {
+	lines := strings.Split(content, "\n")
+	var symbols []outlineSymbol
+	var levels []int
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			switch {
+			case fence == "":
+				fence = trimmed[:3]
+			case strings.HasPrefix(trimmed, fence):
+				fence = ""
+			}
+			continue
+		}
+		m := markdownHeadingRegexp.FindStringSubmatch(line)
+		if fence != "" || m == nil {
+			continue
+		}
+		level := len(m[1])
+		for j := range symbols {
+			if symbols[j].End == 0 && levels[j] >= level {
+				symbols[j].End = i
+			}
+		}
+		symbols = append(symbols, outlineSymbol{Signature: m[1] + " " + m[2], Start: i + 1, Depth: level - 1})
+		levels = append(levels, level)
+	}
+	last := lastNonBlankLine(lines, len(lines)-1)
+	for j := range symbols {
+		if symbols[j].End == 0 {
+			symbols[j].End = last + 1
+		}
+		// sections end before the blank lines that precede the next heading
+		symbols[j].End = max(lastNonBlankLine(lines, symbols[j].End-1)+1, symbols[j].Start)
+	}
+	return symbols
+}
+
+func lastNonBlankLine(lines []string, i int) int {
+	for i > 0 && strings.TrimSpace(lines[i]) == "" {
+		i--
+	}
+	return i
+}
+
+func matchAny(res []*regexp.Regexp, s string) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// outline lists the declarations that start at column 0 and, for containers,
+// the declarations at the indentation of their body. masked holds lines with
+// their strings and comments blanked out, which the scan reads instead.
+func (g *outlineGrammar) outline(lines []string, masked []string) []outlineSymbol {
+	var symbols []outlineSymbol
+	for i := 0; i < len(masked); i++ {
+		if indentOf(masked[i]) != 0 {
+			continue
+		}
+		sym, end, ok := g.declaration(lines, masked, i, 0, g.decls, true)
+		if !ok {
+			continue
+		}
+		symbols = append(symbols, sym)
+		if matchAny(g.containers, strings.TrimSpace(masked[i])) {
+			symbols = append(symbols, g.membersOf(lines, masked, i+1, end)...)
+		}
+		i = end
+	}
+	return symbols
+}
+
+// declaration reads the declaration at line i when one of res matches it.
+// end is the last line of its body.
+func (g *outlineGrammar) declaration(lines []string, masked []string, i int, indent int, res []*regexp.Regexp, topLevel bool) (outlineSymbol, int, bool) {
+	trimmed := strings.TrimSpace(masked[i])
+	if !matchAny(res, trimmed) || outlineControlWords[firstWord(trimmed)] {
+		return outlineSymbol{}, 0, false
+	}
+	sigEnd := signatureEnd(masked, i)
+	sig := joinSignature(dropOneLineBody(lines[i:sigEnd+1], masked[i:sigEnd+1]))
+	if g.forms {
+		sig = strings.TrimSpace(lines[i])
+	} else if topLevel && g.skipPrototypes && strings.HasSuffix(sig, ";") {
+		return outlineSymbol{}, 0, false
+	}
+	end := sigEnd
+	if !g.forms {
+		end = blockEnd(masked, sigEnd, indent)
+	}
+	return outlineSymbol{Signature: cleanSignature(sig), Start: i + 1, End: end + 1}, end, true
+}
+
+// membersOf lists the member declarations of the container body lines[from:to+1].
+func (g *outlineGrammar) membersOf(lines []string, masked []string, from int, to int) []outlineSymbol {
+	indent := -1
+	for i := from; i <= to && i < len(masked); i++ {
+		if t := strings.TrimSpace(masked[i]); t != "" && t != "{" && indentOf(masked[i]) > 0 {
+			indent = indentOf(masked[i])
+			break
+		}
+	}
+	if indent < 0 {
+		return nil
+	}
+
+	var members []outlineSymbol
+	for i := from; i <= to && i < len(masked); i++ {
+		if indentOf(masked[i]) != indent {
+			continue
+		}
+		sym, end, ok := g.declaration(lines, masked, i, indent, g.members, false)
+		if !ok {
+			continue
+		}
+		sym.Depth = 1
+		sym.End = min(sym.End, to+1)
+		members = append(members, sym)
+		i = end
+	}
+	return members
+}
+
+// signatureEnd returns the line on which the parens opened at line i close.
+func signatureEnd(lines []string, i int) int {
+	depth := 0
+	for j := i; j < len(lines) && j < i+20; j++ {
+		depth += strings.Count(lines[j], "(") - strings.Count(lines[j], ")")
+		if depth <= 0 {
+			return j
+		}
+	}
+	return i
+}
+
+// blockEnd returns the last line of the body that follows a signature ending
+// at line sigEnd: the lines indented deeper than the declaration, and the
+// closing brace or end at its own indentation.
+func blockEnd(lines []string, sigEnd int, indent int) int {
+	end := sigEnd
+	for j := sigEnd + 1; j < len(lines); j++ {
+		t := strings.TrimSpace(lines[j])
+		if t == "" {
+			continue
+		}
+		ind := indentOf(lines[j])
+		if ind > indent || (ind == indent && t == "{") {
+			end = j
+			continue
+		}
+		if ind == indent && isBlockCloser(t) {
+			end = j
+		}
+		break
+	}
+	return end
+}
+
+func isBlockCloser(t string) bool {
+	switch {
+	case strings.HasPrefix(t, "}"), strings.HasPrefix(t, ")"), strings.HasPrefix(t, "]"):
+		return true
+	case t == "end", strings.HasPrefix(t, "end "), strings.HasPrefix(t, "end;"), strings.HasPrefix(t, "end)"), t == "@end":
+		return true
+	}
+	return false
+}
+
+func indentOf(line string) int {
+	n := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			n++
+		case '\t':
+			n += 4
+		default:
+			return n
+		}
+	}
+	return n
+}
+
+func firstWord(s string) string {
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	if end < 0 {
+		return s
+	}
+	return s[:end]
+}
+
+// dropOneLineBody cuts the body off signature lines that hold all of it, as
+// in function f() { return 1 }. The braces are matched on the masked lines,
+// so that a brace in a string or comment of the body does not count.
+func dropOneLineBody(lines []string, masked []string) []string {
+	text := strings.Join(masked, "\n")
+	if !strings.HasSuffix(strings.TrimRight(text, " \t\r\n"), "}") {
+		return lines
+	}
+	depth := 0
+	for i := len(strings.TrimRight(text, " \t\r\n")) - 1; i >= 0; i-- {
+		switch text[i] {
+		case '}':
+			depth++
+		case '{':
+			depth--
+		}
+		if depth == 0 {
+			if strings.TrimSpace(text[:i]) == "" {
+				return lines
+			}
+			return strings.Split(strings.Join(lines, "\n")[:i], "\n")
+		}
+	}
+	return lines
+}
+
+func joinSignature(lines []string) string {
+	parts := make([]string, len(lines))
+	for i, line := range lines {
+		parts[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(parts, " ")
+}
+
+var (
+	spaceRunRegexp  = regexp.MustCompile(`\s+`)
+	openParenRegexp = regexp.MustCompile(`([(\[]) `)
+	closeParenRegex = regexp.MustCompile(`,? ([)\]])`)
+)
+
+// cleanSignature puts a signature on one line and drops the brace or colon
+// that opens its body.
+func cleanSignature(sig string) string {
+	sig = spaceRunRegexp.ReplaceAllString(strings.TrimSpace(sig), " ")
+	sig = openParenRegexp.ReplaceAllString(sig, "$1")
+	sig = closeParenRegex.ReplaceAllString(sig, "$1")
+
+	sig = strings.TrimRight(sig, " {;")
+	sig = strings.TrimSuffix(sig, " do")
+	if strings.HasSuffix(sig, ":") && !strings.HasSuffix(sig, "::") {
+		sig = strings.TrimSuffix(sig, ":")
+	}
+	if runes := []rune(sig); len(runes) > outlineMaxSignature {
+		sig = string(runes[:outlineMaxSignature]) + "…"
+	}
+	return strings.TrimSpace(sig)
+}
@@ -0,0 +1,301 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+)
+
+func TestOutlineFile(t *testing.T) {
+	tests := []struct {
+		path     string
+		input    string
+		expected string
+	}{
+		{
+			path: "main.go",
+			input: `package main
+
+type Store interface {
+	Buy(item string) error
+}
+
+type (
+	ID int
+	Alias = string
+)
+
+func main() {}
+`,
+			expected: `3-5: type Store interface
+  4: Buy(item string) error
+8: type ID int
+9: type Alias = string
+12: func main()
+`,
+		},
+		{
+			path: "a.py",
+			input: `import os
+
+
+@dataclass
+class Shop:
+    """A shop."""
+
+    def __init__(self, name):
+        self.name = name
+
+    async def buy(
+        self,
+        item: str,
+    ) -> bool:
+        if item:
+            return True
+        return False
+
+
+def main():
+    Shop("x").buy("y")
+`,
+			expected: `5-17: class Shop
+  8-9: def __init__(self, name)
+  11-17: async def buy(self, item: str) -> bool
+20-21: def main()
+`,
+		},
+		{
+			path: "a.ts",
+			input: `import { x } from "y";
+
+export interface Store {
+  buy(item: Item): Promise<void>;
+  name: string;
+}
+
+export default class Shop implements Store {
+  private items: Item[] = [];
+
+  constructor(private readonly name: string) {}
+
+  async buy(item: Item): Promise<void> {
+    if (item) {
+      this.items.push(item);
+    }
+  }
+}
+
+export const add = (a: number, b: number): number => a + b;
+
+export function main() {
+  return new Shop("x");
+}
+
+type Item = { id: string };
+`,
+			expected: `3-6: export interface Store
+  4: buy(item: Item): Promise<void>
+8-18: export default class Shop implements Store
+  11: constructor(private readonly name: string)
+  13-17: async buy(item: Item): Promise<void>
+20: export const add = (a: number, b: number): number => a + b
+22-24: export function main()
+26: type Item = { id: string }
+`,
+		},
+		{
+			path: "A.java",
+			input: `package shop;
+
+import java.util.List;
+
+@Service
+public class Shop implements Store {
+    private final List<Item> items = new ArrayList<>();
+
+    public Shop(String name) {
+        this.name = name;
+    }
+
+    @Override
+    public Map<String, List<Item>> group(List<Item> items,
+                                         int n) throws IOException {
+        for (Item i : items) {
+            foo(i);
+        }
+        return null;
+    }
+
+    static class Inner {
+    }
+}
+`,
+			expected: `6-24: public class Shop implements Store
+  9-11: public Shop(String name)
+  14-20: public Map<String, List<Item>> group(List<Item> items, int n) throws IOException
+  22-23: static class Inner
+`,
+		},
+		{
+			path: "a.rs",
+			input: `use std::io;
+
+pub struct Shop {
+    items: Vec<Item>,
+}
+
+impl Shop {
+    pub fn new() -> Self {
+        Shop { items: vec![] }
+    }
+
+    pub async fn buy(&mut self, item: Item) -> Result<(), Error> {
+        Ok(())
+    }
+}
+
+pub trait Store {
+    fn buy(&mut self, item: Item) -> Result<(), Error>;
+}
+
+fn main() {}
+`,
+			expected: `3-5: pub struct Shop
+7-15: impl Shop
+  8-10: pub fn new() -> Self
+  12-14: pub async fn buy(&mut self, item: Item) -> Result<(), Error>
+17-19: pub trait Store
+  18: fn buy(&mut self, item: Item) -> Result<(), Error>
+21: fn main()
+`,
+		},
+		{
+			path: "a.c",
+			input: `#include <stdio.h>
+
+static int helper(int a);
+
+typedef struct shop {
+    int n;
+} shop_t;
+
+static int helper(int a)
+{
+    return a;
+}
+
+int main(int argc, char **argv) {
+    if (argc) {
+        return helper(1);
+    }
+    return 0;
+}
+`,
+			expected: `5-7: typedef struct shop
+9-12: static int helper(int a)
+14-19: int main(int argc, char **argv)
+`,
+		},
+		{
+			path: "a.rb",
+			input: `module Shop
+  class Store
+    def buy(item)
+      item
+    end
+
+    def self.open
+    end
+  end
+end
+`,
+			expected: `1-10: module Shop
+  2-9: class Store
+`,
+		},
+		{
+			path:  "a.md",
+			input: "# Title\n\nintro\n\n## Install\n\n```sh\n# not a heading\n```\n\n## Usage\n\n### Flags\n\ntext\n",
+			expected: `1-15: # Title
+  5-9: ## Install
+  11-15: ## Usage
+    13-15: ### Flags
+`,
+		},
+		{
+			// code inside strings is neither listed nor read for the extent
+			path: "docstring.py",
+			input: `import os
+
+class Shop:
+    """Sell things.
+
+def fake():
+    pass
+"""
+
+    def buy(self, item):
+        text = """
+done"""
+        return text
+`,
+			expected: `3-13: class Shop
+  10-13: def buy(self, item)
+`,
+		},
+		{
+			path:  "template.ts",
+			input: "export function close() { return `}` }\n\nexport function open() { return \"{\" }\n",
+			expected: `1: export function close()
+3: export function open()
+`,
+		},
+		{
+			path:     "notes.txt",
+			input:    "def f():\n    pass\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := core.OutlineFile(tt.path, tt.input); got != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApply_Outline(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "shop.py"), []byte("# shop\n\ndef buy(item):\n    return item\n"), 0644)
+	os.WriteFile(filepath.Join(root, "README.md"), []byte("# Shop\n"), 0644)
+	t.Chdir(t.TempDir())
+
+	// comment deletion must not shift the line numbers
+	_, opt, err := commandline.GeneralOptParse([]string{"-S", "-D", "-o", "-", "-f", "outline", root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := captureStdout(t, func() {
+		if err := core.Apply(opt); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+	})
+	for _, want := range []string{
+		"--- BEGIN OUTLINE ---",
+		"shop.py (4 lines) ===\n3-4: def buy(item)\n",
+		"README.md (1 lines) ===\n1: # Shop\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "return item") {
+		t.Errorf("the outline must not contain bodies:\n%s", out)
+	}
+}
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RestoreDump rebuilds the files of one or more dumps under outputDir and
+// returns the restored paths. A chunk manifest expands to its chunk files.
+// Only the parts of a file split across chunks are joined; a file found in
+// two dumps is an error. Existing files are only overwritten when force is set.
+func RestoreDump(dumpPaths []string, outputDir string, force bool) ([]string, error) {
+	var files []*RestoredFile
+	for _, dumpPath := range dumpPaths {
+		chunks, err := expandDumpPath(dumpPath)
+		if err != nil {
+			return nil, err
+		}
+		for _, chunk := range chunks {
+			data, err := os.ReadFile(chunk)
+			if err != nil {
+				return nil, err
+			}
+			decoded, err := DecodeDump(data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", chunk, err)
+			}
+			files = append(files, decoded...)
+		}
+	}
+	files, err := mergeRestoredFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) > 0 && files[0].continued {
+		return nil, fmt.Errorf("%s continues a file from a chunk that is not given", files[0].Path)
+	}
+
+	// validate everything before writing anything
+	targets := make([]string, len(files))
+	for i, f := range files {
+		target, err := restoreTarget(outputDir, f.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !force {
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("file already exists: %s (use --force to overwrite)", target)
+			}
+		}
+		targets[i] = target
+	}
+
+	for i, f := range files {
+		if err := os.MkdirAll(filepath.Dir(targets[i]), 0755); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(targets[i], []byte(f.Content), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return targets, nil
+}
+
+// expandDumpPath returns the chunk files listed in a manifest, or the path itself.
+func expandDumpPath(dumpPath string) ([]string, error) {
+	if !strings.HasSuffix(dumpPath, ".manifest.json") {
+		return []string{dumpPath}, nil
+	}
+	data, err := os.ReadFile(dumpPath)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", dumpPath, err)
+	}
+	var chunks []string
+	for _, c := range manifest.Chunks {
+		chunks = append(chunks, filepath.Join(filepath.Dir(dumpPath), c.Filename))
+	}
+	return chunks, nil
+}
+
+// restoreTarget joins a dumped path to outputDir, refusing paths that escape it.
+func restoreTarget(outputDir string, rel string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(rel))
+	if rel == "" || filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing to restore unsafe path: %q", rel)
+	}
+	return filepath.Join(outputDir, clean), nil
+}
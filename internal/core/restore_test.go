@@ -0,0 +1,313 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+var restoreTestFiles = map[string]string{
+	"main.go":         "package main\n\nfunc main() {\n\tprintln(\"hi\")\n}\n",
+	"README.md":       "# Title\n\n```go\nfmt.Println()\n```\n\ntext\n",
+	"sub/notes.txt":   "=== not a header ===\n\n  indented\n",
+	"sub/deep/x.yaml": "key: value\n",
+}
+
+func createRestoreTestTree(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	root := t.TempDir()
+	allowed := map[string]bool{}
+	for rel, content := range restoreTestFiles {
+		p := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	for _, dir := range []string{"sub", "sub/deep"} {
+		allowed[filepath.Join(root, filepath.FromSlash(dir))] = true
+	}
+	return root, allowed
+}
+
+func createRestoreTestOption(format string) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(format),
+		WithLineNumberFlag: model.OnOffSwitch("on"),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+		IgnoreDotFileFlag:  model.OnOffSwitch("off"),
+		AllowGitignoreFlag: model.OnOffSwitch("off"),
+	}
+}
+
+func assertRestored(t *testing.T, format string, outDir string) {
+	t.Helper()
+	for rel, want := range restoreTestFiles {
+		got, err := os.ReadFile(filepath.Join(outDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Errorf("%s: %s was not restored: %v", format, rel, err)
+			continue
+		}
+		if string(got) != want {
+			t.Errorf("%s: %s = %q, want %q", format, rel, got, want)
+		}
+	}
+}
+
+func TestRestoreDump_RoundTrip(t *testing.T) {
+	cases := []struct {
+		format string
+		ext    string
+		write  func(root, out string, allowed map[string]bool, opt *commandline.Option) error
+	}{
+		{model.PlainText, "txt", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, allowed, opt)
+		}},
+		{model.Markdown, "md", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFiles("tree", root, out, allowed, opt)
+		}},
+		{model.XML, "xml", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsXML("tree", root, out, allowed, opt)
+		}},
+		{model.Arklite, "arklite.txt", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			opt.ArkliteLosslessFlag = true
+			return core.WriteAllFilesAsArklite("{}", root, out, allowed, opt)
+		}},
+		{model.JSON, "json", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSON(`{"name":"x","type":"directory"}`, root, out, allowed, opt)
+		}},
+		{model.JSONL, "jsonl", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSONL(root, out, allowed, opt)
+		}},
+	}
+
+	for _, c := range cases {
+		root, allowed := createRestoreTestTree(t)
+		dumpFile := filepath.Join(t.TempDir(), "dump."+c.ext)
+		if err := c.write(root, dumpFile, allowed, createRestoreTestOption(c.format)); err != nil {
+			t.Fatalf("%s: dump failed: %v", c.format, err)
+		}
+
+		outDir := t.TempDir()
+		restored, err := core.RestoreDump([]string{dumpFile}, outDir, false)
+		if err != nil {
+			t.Fatalf("%s: restore failed: %v", c.format, err)
+		}
+		if len(restored) != len(restoreTestFiles) {
+			t.Errorf("%s: restored %d files, want %d", c.format, len(restored), len(restoreTestFiles))
+		}
+		assertRestored(t, c.format, outDir)
+	}
+}
+
+func TestRestoreDump_SplitChunks(t *testing.T) {
+	for _, format := range []string{model.PlainText, model.Markdown, model.XML, model.Arklite} {
+		root := t.TempDir()
+		big := strings.Repeat("line of a big file\n", 200)
+		if err := os.WriteFile(filepath.Join(root, "big.txt"), []byte(big), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, "small.txt"), []byte("small\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed := map[string]bool{filepath.Join(root, "big.txt"): true, filepath.Join(root, "small.txt"): true}
+
+		opt := createRestoreTestOption(format)
+		opt.SplitSizeValue = "2k"
+		if format == model.Arklite {
+			// the arklite header alone takes 2k
+			opt.SplitSizeValue = "3k"
+		}
+		opt.SplitSize = model.ByteString(opt.SplitSizeValue)
+		dumpFile := filepath.Join(t.TempDir(), "dump.out")
+		var err error
+		switch format {
+		case model.XML:
+			err = core.WriteAllFilesAsXML("tree", root, dumpFile, allowed, opt)
+		case model.Arklite:
+			err = core.WriteAllFilesAsArklite("{}", root, dumpFile, allowed, opt)
+		default:
+			err = core.WriteAllFiles("tree", root, dumpFile, allowed, opt)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if m := readManifest(t, dumpFile); len(m.Chunks) < 2 {
+			t.Fatalf("%s: expected big.txt to be split, got %d chunks", format, len(m.Chunks))
+		}
+		outDir := t.TempDir()
+		if _, err := core.RestoreDump([]string{core.ManifestFilename(dumpFile)}, outDir, false); err != nil {
+			t.Fatalf("%s: restore failed: %v", format, err)
+		}
+		got, _ := os.ReadFile(filepath.Join(outDir, "big.txt"))
+		if string(got) != big {
+			t.Errorf("%s: big.txt was not restored from its chunks (got %d bytes, want %d)", format, len(got), len(big))
+		}
+		got, _ = os.ReadFile(filepath.Join(outDir, "small.txt"))
+		if string(got) != "small\n" {
+			t.Errorf("%s: small.txt = %q", format, got)
+		}
+	}
+}
+
+func TestRestoreDump_JoinsOnlyContinuedParts(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "big.txt"), []byte(strings.Repeat("line of a big file\n", 200)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opt := createRestoreTestOption(model.PlainText)
+	opt.SplitSizeValue = "2k"
+	opt.SplitSize = model.ByteString("2k")
+	dumpFile := filepath.Join(t.TempDir(), "dump.txt")
+	if err := core.WriteAllFiles("tree", root, dumpFile, map[string]bool{filepath.Join(root, "big.txt"): true}, opt); err != nil {
+		t.Fatal(err)
+	}
+
+	first, second := core.ChunkFilename(dumpFile, 1), core.ChunkFilename(dumpFile, 2)
+	for _, dumps := range [][]string{{second}, {second, first}} {
+		if _, err := core.RestoreDump(dumps, t.TempDir(), false); err == nil {
+			t.Errorf("%v: expected an error for a continued part that does not follow its file", dumps)
+		}
+	}
+	if _, err := core.RestoreDump([]string{first, first}, t.TempDir(), false); err == nil {
+		t.Error("expected an error for a file found in two dumps")
+	}
+	if _, err := core.RestoreDump([]string{first, second}, t.TempDir(), false); err != nil {
+		t.Errorf("chunk files given one by one must be joined: %v", err)
+	}
+}
+
+func TestDecodeDump_Duplicates(t *testing.T) {
+	for _, dump := range []string{
+		`{"path":"a.txt","content":"x"}` + "\n" + `{"path":"a.txt","content":"y"}` + "\n",
+		"Project: x\nRoot: /r\n\n=== /r/a.txt ===\nx\n\n=== /r/a.txt ===\ny\n",
+	} {
+		if _, err := core.DecodeDump([]byte(dump)); err == nil {
+			t.Errorf("expected an error for a file dumped twice in %q", dump)
+		}
+	}
+
+	// only a part marked as continued is joined
+	files, err := core.DecodeDump([]byte("Project: x\nRoot: /r\n\n=== /r/a.txt ===\nx\n\n=== /r/a.txt (continued) ===\ny\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Content != "x\ny\n" {
+		t.Errorf("unexpected files: %+v", files)
+	}
+}
+
+func TestRestoreDump_RefusesOverwrite(t *testing.T) {
+	root, allowed := createRestoreTestTree(t)
+	dumpFile := filepath.Join(t.TempDir(), "dump.jsonl")
+	if err := core.WriteAllFilesAsJSONL(root, dumpFile, allowed, createRestoreTestOption(model.JSONL)); err != nil {
+		t.Fatal(err)
+	}
+
+	outDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outDir, "main.go"), []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := core.RestoreDump([]string{dumpFile}, outDir, false); err == nil {
+		t.Fatal("expected an error for an existing file")
+	}
+	if _, err := os.Stat(filepath.Join(outDir, "README.md")); !os.IsNotExist(err) {
+		t.Errorf("nothing must be written when restore is refused")
+	}
+	if _, err := core.RestoreDump([]string{dumpFile}, outDir, true); err != nil {
+		t.Fatalf("force restore failed: %v", err)
+	}
+	assertRestored(t, "jsonl", outDir)
+}
+
+func TestRestoreDump_UnsafePath(t *testing.T) {
+	dumpFile := filepath.Join(t.TempDir(), "evil.jsonl")
+	if err := os.WriteFile(dumpFile, []byte(`{"path":"../escape.txt","content":"x"}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := core.RestoreDump([]string{dumpFile}, t.TempDir(), false); err == nil {
+		t.Error("expected an error for a path escaping the output directory")
+	}
+}
+
+func TestDecodeDump_LossyArklite(t *testing.T) {
+	dump := "# Arklite Format Overview\n\n## Directory Tree (JSON)\n{}\n\n## File Dump\n@a.go\npackage a␤func A() {}\n@b.txt\nhello\n"
+	files, err := core.DecodeDump([]byte(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Path != "a.go" || files[0].Content != "package a\nfunc A() {}\n" || files[1].Content != "hello\n" {
+		t.Errorf("unexpected files: %+v %+v", files[0], files[1])
+	}
+}
+
+func TestDecodeDump_UnknownFormat(t *testing.T) {
+	if _, err := core.DecodeDump([]byte("random text")); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestDecodeDump_Outline(t *testing.T) {
+	dump := "Project: shop\nRoot: .\n\n--- BEGIN OUTLINE ---\n\n.\n└── a.py\n\n=== a.py (2 lines) ===\n1-2: def f()\n"
+	if format, _ := core.DetectDumpFormat([]byte(dump)); format != model.Outline {
+		t.Errorf("expected outline, got %q", format)
+	}
+	if _, err := core.DecodeDump([]byte(dump)); err == nil {
+		t.Error("expected an error for an outline dump")
+	}
+}
+
+func TestRestoreDump_CRLF(t *testing.T) {
+	const crlf = "a\r\nb\r\n"
+	cases := []struct {
+		format string
+		ext    string
+		want   string
+		write  func(root, out string, allowed map[string]bool, opt *commandline.Option) error
+	}{
+		{model.Arklite, "arklite.txt", crlf, func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			opt.ArkliteLosslessFlag = true
+			return core.WriteAllFilesAsArklite("{}", root, out, allowed, opt)
+		}},
+		{model.JSON, "json", crlf, func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSON(`{"name":"x","type":"directory"}`, root, out, allowed, opt)
+		}},
+		{model.JSONL, "jsonl", crlf, func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsJSONL(root, out, allowed, opt)
+		}},
+		// XML parsers turn CR LF into LF, as the README says
+		{model.XML, "xml", "a\nb\n", func(root, out string, allowed map[string]bool, opt *commandline.Option) error {
+			return core.WriteAllFilesAsXML("tree", root, out, allowed, opt)
+		}},
+	}
+
+	for _, c := range cases {
+		root := t.TempDir()
+		p := filepath.Join(root, "win.txt")
+		if err := os.WriteFile(p, []byte(crlf), 0644); err != nil {
+			t.Fatal(err)
+		}
+		dumpFile := filepath.Join(t.TempDir(), "dump."+c.ext)
+		if err := c.write(root, dumpFile, map[string]bool{p: true}, createRestoreTestOption(c.format)); err != nil {
+			t.Fatalf("%s: dump failed: %v", c.format, err)
+		}
+		outDir := t.TempDir()
+		if _, err := core.RestoreDump([]string{dumpFile}, outDir, false); err != nil {
+			t.Fatalf("%s: restore failed: %v", c.format, err)
+		}
+		got, err := os.ReadFile(filepath.Join(outDir, "win.txt"))
+		if err != nil || string(got) != c.want {
+			t.Errorf("%s: restored %q (%v), want %q", c.format, got, err, c.want)
+		}
+	}
+}
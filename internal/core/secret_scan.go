@@ -0,0 +1,203 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/secrets"
+)
+
+// ScanSecrets reports the secrets in every file a dump of opt.TargetDirname
+// would include, so the same gitignore rules and filters apply. Paths in the
+// matches are relative to the target directory, and their lines are masked
+// so that a report does not leak the secret itself.
+func ScanSecrets(opt *commandline.Option) ([]secrets.SecretMatch, error) {
+	root := opt.TargetDirname
+	paths, err := dumpPaths(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	// a copy, as the rule set is shared with everything else using opt
+	rules := *opt.SecretRuleSet()
+	rules.MaxLineSize, _ = opt.ScanBuffer.Bytes()
+
+	var matches []secrets.SecretMatch
+	for _, fpath := range paths {
+		data, err := os.ReadFile(fpath)
+		if err != nil {
+			return nil, err
+		}
+		if data, _, err = DecodeText(data); err != nil || IsBinary(data) || IsImage(fpath) {
+			continue
+		}
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			rel = fpath
+		}
+		m, err := rules.ScanReader(bytes.NewReader(data), filepath.ToSlash(rel))
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", fpath, err)
+		}
+		for _, match := range m {
+			match.Text = rules.MaskFile(fpath, match.Text)
+			matches = append(matches, match)
+		}
+	}
+	return matches, nil
+}
+
+// secretFinding is a match as reported.
+type secretFinding struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Rule    string `json:"rule"`
+	Pattern string `json:"pattern"`
+	Text    string `json:"text"`
+}
+
+func secretFindings(matches []secrets.SecretMatch) []secretFinding {
+	findings := make([]secretFinding, 0, len(matches))
+	for _, m := range matches {
+		rule := m.RuleID
+		if rule == "" {
+			rule = m.Pattern
+		}
+		findings = append(findings, secretFinding{
+			File:    m.File,
+			Line:    m.Line,
+			Rule:    rule,
+			Pattern: m.Pattern,
+			Text:    m.Text,
+		})
+	}
+	return findings
+}
+
+// WriteSecretReport writes matches as text, JSON or SARIF 2.1.0.
+func WriteSecretReport(w io.Writer, matches []secrets.SecretMatch, format model.ScanReportFormat) error {
+	findings := secretFindings(matches)
+
+	switch format.String() {
+	case model.ScanReportJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Count    int             `json:"count"`
+			Findings []secretFinding `json:"findings"`
+		}{len(findings), findings})
+	case model.ScanReportSARIF:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(sarifReport(findings))
+	}
+
+	for _, f := range findings {
+		if _, err := fmt.Fprintf(w, "%s:%d: %s\n    %s\n", f.File, f.Line, f.Rule, f.Text); err != nil {
+			return err
+		}
+	}
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "No secrets found.")
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d secret(s) found.\n", len(findings))
+	return err
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+func sarifReport(findings []secretFinding) *sarifLog {
+	rules := []sarifRule{}
+	results := []sarifResult{}
+	for _, f := range findings {
+		if !slices.ContainsFunc(rules, func(r sarifRule) bool { return r.ID == f.Rule }) {
+			rules = append(rules, sarifRule{
+				ID:               f.Rule,
+				ShortDescription: sarifMessage{Text: "Possible secret matching " + f.Pattern},
+			})
+		}
+		results = append(results, sarifResult{
+			RuleID:  f.Rule,
+			Level:   "error",
+			Message: sarifMessage{Text: "Possible secret: " + f.Text},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: f.File},
+					Region:           sarifRegion{StartLine: f.Line},
+				},
+			}},
+		})
+	}
+	return &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "ark",
+				InformationURI: "https://github.com/magicdrive/ark",
+				Rules:          rules,
+			}},
+			Results: results,
+		}},
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/chardetect"
+)
+
+// Line ending styles of a text file.
+const (
+	EOLLF    = "LF"
+	EOLCRLF  = "CRLF"
+	EOLMixed = "mixed"
+)
+
+// textMetadata describes how a text file is stored on disk.
+type textMetadata struct {
+	Encoding   string
+	Confidence float64
+	BOM        bool
+	// EOL is EOLLF, EOLCRLF, EOLMixed, or "" for a file without line breaks
+	EOL             string
+	TrailingNewline bool
+}
+
+// newTextMetadata describes a file from its detection result and its text
+// decoded to UTF-8.
+func newTextMetadata(result *chardetect.Result, text string) textMetadata {
+	return textMetadata{
+		Encoding:        result.Encoding.String(),
+		Confidence:      result.Confidence,
+		BOM:             result.BOM,
+		EOL:             DetectEOL(text),
+		TrailingNewline: strings.HasSuffix(text, "\n"),
+	}
+}
+
+// DetectEOL returns the line ending style of text: EOLLF, EOLCRLF, EOLMixed
+// when both appear, or "" without line breaks.
+func DetectEOL(text string) string {
+	crlf := strings.Count(text, "\r\n")
+	lf := strings.Count(text, "\n") - crlf
+	switch {
+	case crlf > 0 && lf > 0:
+		return EOLMixed
+	case crlf > 0:
+		return EOLCRLF
+	case lf > 0:
+		return EOLLF
+	}
+	return ""
+}
+
+// summary renders the metadata for a txt or markdown header, e.g.
+// "Shift-JIS 0.95, CRLF, no trailing newline".
+func (m textMetadata) summary() string {
+	parts := []string{fmt.Sprintf("%s %.2f", m.Encoding, m.Confidence)}
+	if m.BOM {
+		parts = append(parts, "BOM")
+	}
+	switch m.EOL {
+	case EOLMixed:
+		parts = append(parts, "mixed EOL")
+	case "":
+	default:
+		parts = append(parts, m.EOL)
+	}
+	if !m.TrailingNewline {
+		parts = append(parts, "no trailing newline")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// xmlAttrs renders the metadata as attributes of an XML <file> element.
+func (m textMetadata) xmlAttrs() string {
+	attrs := fmt.Sprintf(` encoding="%s" confidence="%.2f" bom="%t"`, xmlEscape(m.Encoding), m.Confidence, m.BOM)
+	if m.EOL != "" {
+		attrs += fmt.Sprintf(` eol="%s"`, m.EOL)
+	}
+	return attrs + fmt.Sprintf(` trailing-newline="%t"`, m.TrailingNewline)
+}
+
+// textMetadataPattern matches the summary in a txt or markdown header, so
+// that the dump decoder finds the path in front of it.
+const textMetadataPattern = `(?: \([A-Za-z0-9_-]+ [01]\.\d\d(?:, (?:BOM|LF|CRLF|mixed EOL|no trailing newline))*\))?`
@@ -0,0 +1,292 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+	"github.com/magicdrive/ark/internal/tokenizer"
+)
+
+const (
+	TokenStatusKept      = "kept"
+	TokenStatusTruncated = "truncated"
+	TokenStatusDropped   = "dropped"
+)
+
+// TokenReportEntry records how many tokens a single file costs in the output.
+type TokenReportEntry struct {
+	Path           string
+	Tokens         int
+	OriginalTokens int
+	Status         string
+}
+
+// TokenBudget counts the tokens of every dumped file and, when a maximum is
+// set, decides which files are kept, truncated or dropped.
+// A nil *TokenBudget keeps every file untouched.
+type TokenBudget struct {
+	Tokenizer tokenizer.Tokenizer
+	MaxTokens int
+	Policy    string
+
+	root      string
+	order     []string
+	planned   map[string]int
+	allowance map[string]int
+	reserved  int
+	used      int
+	entries   []*TokenReportEntry
+}
+
+// PlanTokenBudget returns nil when neither --max-tokens nor --token-report is set.
+// Otherwise it measures every allowed file so that the budget can be split
+// according to the configured policy before anything is written.
+func PlanTokenBudget(root string, allowedFileListMap map[string]bool, opt *commandline.Option) (*TokenBudget, error) {
+	if opt.MaxTokens <= 0 && !opt.TokenReportFlag {
+		return nil, nil
+	}
+	tree, err := listDumpTree(root, allowedFileListMap, opt)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newTokenBudget(tree.Root, opt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.plan(newFileStage(opt).run(tree.Files()), processedContent); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// newTokenBudget returns the budget of a dump of root, or nil when neither
+// --max-tokens nor --token-report is set. The dumper plans it with the files
+// it reads.
+func newTokenBudget(root string, opt *commandline.Option) (*TokenBudget, error) {
+	if opt.MaxTokens <= 0 && !opt.TokenReportFlag {
+		return nil, nil
+	}
+
+	tk, err := tokenizer.New(opt.Tokenizer.String(), opt.TokenizerFile)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := opt.BudgetPolicy.String()
+	if policy == "" {
+		policy = model.BudgetPolicyTruncate
+	}
+
+	return &TokenBudget{
+		Tokenizer: tk,
+		MaxTokens: opt.MaxTokens,
+		Policy:    policy,
+		root:      root,
+		planned:   map[string]int{},
+	}, nil
+}
+
+// plan measures every file a dumper is about to write, with text giving what
+// the dumper counts of it, so that the budget is split according to the
+// policy before anything is written. The files are read once: plan returns
+// them for the dumper to write. Without --max-tokens they stream through.
+func (b *TokenBudget) plan(files iter.Seq[*processedFile], text func(*processedFile) string) (iter.Seq[*processedFile], error) {
+	if b == nil || b.MaxTokens <= 0 {
+		return files, nil
+	}
+	var read []*processedFile
+	for r := range files {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		read = append(read, r)
+		if r.File == nil {
+			continue
+		}
+		b.order = append(b.order, r.Path)
+		b.planned[r.Path] = b.Tokenizer.Count(text(r))
+	}
+	return slices.Values(read), nil
+}
+
+// processedContent is what a dumper writes of a file and the budget counts.
+func processedContent(r *processedFile) string {
+	return r.File.Content
+}
+
+// Reserve accounts for output that is not file content, such as headers and the tree.
+func (b *TokenBudget) Reserve(text string) {
+	if b == nil {
+		return
+	}
+	b.reserved += b.Tokenizer.Count(text)
+	b.allowance = nil
+}
+
+// Fit returns the content to write for fpath and false when the file must be dropped.
+func (b *TokenBudget) Fit(fpath string, content string) (string, bool) {
+	if b == nil {
+		return content, true
+	}
+
+	tokens := b.Tokenizer.Count(content)
+	entry := &TokenReportEntry{
+		Path:           fpath,
+		Tokens:         tokens,
+		OriginalTokens: tokens,
+		Status:         TokenStatusKept,
+	}
+	b.entries = append(b.entries, entry)
+
+	if b.MaxTokens <= 0 {
+		b.used += tokens
+		return content, true
+	}
+
+	if b.allowance == nil {
+		b.allocate()
+	}
+	allowance, ok := b.allowance[fpath]
+	if !ok {
+		// not measured beforehand: take whatever is left
+		allowance = tokens
+	}
+	allowance = min(allowance, b.remaining())
+
+	switch {
+	case tokens <= allowance:
+		b.used += tokens
+		return content, true
+	case allowance > 0 && b.Policy == model.BudgetPolicyTruncate:
+		truncated := truncateToTokens(b.Tokenizer, content, allowance)
+		entry.Tokens = b.Tokenizer.Count(truncated)
+		entry.Status = TokenStatusTruncated
+		b.used += entry.Tokens
+		return truncated, true
+	default:
+		entry.Tokens = 0
+		entry.Status = TokenStatusDropped
+		return "", false
+	}
+}
+
+func (b *TokenBudget) remaining() int {
+	return max(b.MaxTokens-b.reserved-b.used, 0)
+}
+
+// allocate splits the budget left after Reserve among the measured files.
+func (b *TokenBudget) allocate() {
+	b.allowance = map[string]int{}
+	available := max(b.MaxTokens-b.reserved, 0)
+
+	order := b.order
+	if b.Policy == model.BudgetPolicySmallFirst {
+		order = append([]string(nil), b.order...)
+		sort.SliceStable(order, func(i, j int) bool {
+			return b.planned[order[i]] < b.planned[order[j]]
+		})
+	}
+
+	exhausted := false
+	for _, fpath := range order {
+		tokens := b.planned[fpath]
+		switch {
+		case exhausted:
+			b.allowance[fpath] = 0
+		case tokens <= available:
+			b.allowance[fpath] = tokens
+			available -= tokens
+		case b.Policy == model.BudgetPolicyTruncate:
+			// the first file that overflows takes the rest, later files are dropped
+			b.allowance[fpath] = available
+			available = 0
+			exhausted = true
+		default:
+			b.allowance[fpath] = 0
+		}
+	}
+}
+
+// truncateToTokens keeps whole leading lines of content within limit tokens
+// and appends a marker telling how much was cut.
+func truncateToTokens(tk tokenizer.Tokenizer, content string, limit int) string {
+	total := tk.Count(content)
+	marker := fmt.Sprintf("... [truncated by token budget: %d of %d tokens omitted] ...", total, total)
+	limit -= tk.Count(marker) + 1
+	if limit <= 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	used := 0
+	for line := range strings.SplitAfterSeq(content, "\n") {
+		n := tk.Count(line)
+		if used+n > limit {
+			break
+		}
+		b.WriteString(line)
+		used += n
+	}
+	kept := b.String()
+	if !strings.HasSuffix(kept, "\n") && kept != "" {
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "... [truncated by token budget: %d of %d tokens omitted] ...", total-used, total)
+	return b.String()
+}
+
+// Entries returns the per-file token report in output order.
+func (b *TokenBudget) Entries() []*TokenReportEntry {
+	if b == nil {
+		return nil
+	}
+	return b.entries
+}
+
+// TotalTokens returns the number of tokens written, including reserved output.
+func (b *TokenBudget) TotalTokens() int {
+	if b == nil {
+		return 0
+	}
+	return b.reserved + b.used
+}
+
+// WriteReport prints the per-file and total token report.
+func (b *TokenBudget) WriteReport(w io.Writer) {
+	if b == nil {
+		return
+	}
+
+	if b.MaxTokens > 0 {
+		fmt.Fprintf(w, "Token report (tokenizer: %s, budget: %d, policy: %s)\n", b.Tokenizer.Name(), b.MaxTokens, b.Policy)
+	} else {
+		fmt.Fprintf(w, "Token report (tokenizer: %s)\n", b.Tokenizer.Name())
+	}
+	fmt.Fprintf(w, "%10s %10s  %-9s %s\n", "tokens", "original", "status", "path")
+
+	var kept, truncated, dropped int
+	for _, e := range b.entries {
+		switch e.Status {
+		case TokenStatusKept:
+			kept++
+		case TokenStatusTruncated:
+			truncated++
+		case TokenStatusDropped:
+			dropped++
+		}
+		rel, err := filepath.Rel(b.root, e.Path)
+		if err != nil {
+			rel = e.Path
+		}
+		fmt.Fprintf(w, "%10d %10d  %-9s %s\n", e.Tokens, e.OriginalTokens, e.Status, filepath.ToSlash(rel))
+	}
+	fmt.Fprintf(w, "%10d %10s  %-9s %s\n", b.reserved, "", "", "(header and tree)")
+	fmt.Fprintf(w, "total: %d tokens (%d kept, %d truncated, %d dropped)\n", b.TotalTokens(), kept, truncated, dropped)
+}
@@ -0,0 +1,177 @@
+package core_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func createBudgetTestFiles(t *testing.T) (string, map[string]bool) {
+	t.Helper()
+	root := t.TempDir()
+	files := map[string]string{
+		"a.txt": strings.Repeat("a", 400) + "\n",      // 101 tokens with chars
+		"b.txt": strings.Repeat("bbbb\n", 40),         // 50 tokens
+		"c.txt": strings.Repeat("c", 40) + "\n",       // 11 tokens
+		"d.txt": strings.Repeat("dddddddddddd\n", 30), // 98 tokens
+	}
+	allowed := map[string]bool{}
+	for name, content := range files {
+		p := filepath.Join(root, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		allowed[p] = true
+	}
+	return root, allowed
+}
+
+func createBudgetTestOption(maxTokens int, policy string) *commandline.Option {
+	return &commandline.Option{
+		OutputFormat:       model.OutputFormat(model.PlainText),
+		MaskSecretsFlag:    model.OnOffSwitch("off"),
+		WithLineNumberFlag: model.OnOffSwitch("off"),
+		ScanBuffer:         model.ByteString("1M"),
+		MaxTokens:          maxTokens,
+		Tokenizer:          model.TokenizerType(model.TokenizerChars),
+		BudgetPolicy:       model.BudgetPolicy(policy),
+	}
+}
+
+func TestPlanTokenBudget_Disabled(t *testing.T) {
+	root, allowed := createBudgetTestFiles(t)
+	budget, err := core.PlanTokenBudget(root, allowed, createBudgetTestOption(0, model.BudgetPolicyTruncate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if budget != nil {
+		t.Fatalf("expected nil budget when disabled")
+	}
+	content, ok := budget.Fit("x", "hello")
+	if !ok || content != "hello" {
+		t.Errorf("nil budget must keep content untouched")
+	}
+}
+
+func fitAll(budget *core.TokenBudget, root string) map[string]string {
+	statuses := map[string]string{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		p := filepath.Join(root, name)
+		data, _ := os.ReadFile(p)
+		budget.Fit(p, string(data))
+	}
+	for _, e := range budget.Entries() {
+		statuses[filepath.Base(e.Path)] = e.Status
+	}
+	return statuses
+}
+
+func TestTokenBudget_Policies(t *testing.T) {
+	cases := []struct {
+		policy   string
+		expected map[string]string
+	}{
+		{model.BudgetPolicyTruncate, map[string]string{"a.txt": "kept", "b.txt": "truncated", "c.txt": "dropped", "d.txt": "dropped"}},
+		{model.BudgetPolicyDrop, map[string]string{"a.txt": "kept", "b.txt": "dropped", "c.txt": "kept", "d.txt": "dropped"}},
+		{model.BudgetPolicySmallFirst, map[string]string{"a.txt": "dropped", "b.txt": "kept", "c.txt": "kept", "d.txt": "dropped"}},
+	}
+
+	for _, c := range cases {
+		root, allowed := createBudgetTestFiles(t)
+		budget, err := core.PlanTokenBudget(root, allowed, createBudgetTestOption(130, c.policy))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fitAll(budget, root)
+		for name, want := range c.expected {
+			if got[name] != want {
+				t.Errorf("policy %s: %s = %s, want %s", c.policy, name, got[name], want)
+			}
+		}
+		if budget.TotalTokens() > 130 {
+			t.Errorf("policy %s: total %d exceeds budget", c.policy, budget.TotalTokens())
+		}
+	}
+}
+
+func TestTokenBudget_TruncateMarker(t *testing.T) {
+	root, allowed := createBudgetTestFiles(t)
+	budget, err := core.PlanTokenBudget(root, allowed, createBudgetTestOption(130, model.BudgetPolicyTruncate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget.Fit(filepath.Join(root, "a.txt"), strings.Repeat("a", 400)+"\n")
+	content, ok := budget.Fit(filepath.Join(root, "b.txt"), strings.Repeat("bbbb\n", 40))
+	if !ok {
+		t.Fatal("expected b.txt to be truncated, not dropped")
+	}
+	if !strings.HasPrefix(content, "bbbb\n") || !strings.Contains(content, "[truncated by token budget:") {
+		t.Errorf("unexpected truncated content: %q", content)
+	}
+}
+
+func TestWriteAllFiles_MaxTokens(t *testing.T) {
+	root, allowed := createBudgetTestFiles(t)
+	outDir := t.TempDir()
+	outFile := filepath.Join(outDir, "out.txt")
+
+	opt := createBudgetTestOption(300, model.BudgetPolicyDrop)
+	if err := core.WriteAllFiles("tree", root, outFile, allowed, opt); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(outFile)
+	out := string(data)
+	if !strings.Contains(out, "c.txt (") {
+		t.Errorf("expected small file to be kept")
+	}
+	if strings.Contains(out, "d.txt (") {
+		t.Errorf("expected d.txt to be dropped by the budget")
+	}
+}
+
+func TestTokenBudget_WriteReport(t *testing.T) {
+	root, allowed := createBudgetTestFiles(t)
+	opt := createBudgetTestOption(0, model.BudgetPolicyTruncate)
+	opt.TokenReportFlag = true
+	budget, err := core.PlanTokenBudget(root, allowed, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	budget.Reserve("header")
+	fitAll(budget, root)
+
+	var buf bytes.Buffer
+	budget.WriteReport(&buf)
+	report := buf.String()
+	for _, want := range []string{"tokenizer: chars", "a.txt", "d.txt", "total: 262 tokens (4 kept, 0 truncated, 0 dropped)"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report does not contain %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestWriteAllFilesAsOutline_MaxTokensCountsTheOutline(t *testing.T) {
+	root := t.TempDir()
+	p := filepath.Join(root, "a.py")
+	if err := os.WriteFile(p, []byte("def f():\n"+strings.Repeat("    x = 1\n", 2000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outFile := filepath.Join(t.TempDir(), "out.txt")
+
+	// the file is far over the budget, its outline is not
+	opt := createBudgetTestOption(1000, model.BudgetPolicyDrop)
+	opt.OutputFormat = model.OutputFormat(model.Outline)
+	if err := core.WriteAllFilesAsOutline("tree", root, outFile, map[string]bool{p: true}, opt); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(outFile)
+	if !strings.Contains(string(data), "1-2001: def f()") {
+		t.Errorf("expected the outline of a.py to be kept:\n%s", data)
+	}
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/magicdrive/ark/internal/secrets"
+)
+
+// UnmaskStdin is the filename that unmasks stdin to stdout.
+const UnmaskStdin = "-"
+
+// UnmaskResult is what UnmaskFiles restored.
+type UnmaskResult struct {
+	Files        []string // files that were rewritten
+	Placeholders int      // placeholders replaced
+	Unknown      []string // placeholders the vault does not know
+}
+
+// UnmaskFiles replaces the vault placeholders in the given files, and in the
+// text files under the given directories, with their secrets. Files are only
+// rewritten when they contain a known placeholder. "-" unmasks stdin to stdout.
+func UnmaskFiles(paths []string, vault *secrets.Vault, stdin io.Reader, stdout io.Writer) (*UnmaskResult, error) {
+	result := &UnmaskResult{}
+	for _, p := range paths {
+		if p == UnmaskStdin {
+			data, err := io.ReadAll(stdin)
+			if err != nil {
+				return nil, err
+			}
+			content, n, unknown := vault.Unmask(string(data))
+			result.add("", n, unknown)
+			if _, err := io.WriteString(stdout, content); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		err := filepath.WalkDir(p, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			return unmaskFile(fpath, vault, result)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func unmaskFile(fpath string, vault *secrets.Vault, result *UnmaskResult) error {
+	data, err := os.ReadFile(fpath)
+	if err != nil {
+		return err
+	}
+	if IsBinary(data) {
+		return nil
+	}
+	content, n, unknown := vault.Unmask(string(data))
+	if n > 0 {
+		info, err := os.Stat(fpath)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(fpath, []byte(content), info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	result.add(fpath, n, unknown)
+	return nil
+}
+
+func (r *UnmaskResult) add(fpath string, n int, unknown []string) {
+	if n > 0 && fpath != "" {
+		r.Files = append(r.Files, fpath)
+	}
+	r.Placeholders += n
+	for _, u := range unknown {
+		if !slices.Contains(r.Unknown, u) {
+			r.Unknown = append(r.Unknown, u)
+		}
+	}
+}
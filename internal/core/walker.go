@@ -0,0 +1,443 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+// TreeNode is a file or directory listed by WalkTree.
+type TreeNode struct {
+	// Path is joined from the root given to WalkTree
+	Path  string
+	Name  string
+	Depth int
+	IsDir bool
+	// Last is set for the last listed entry of its directory
+	Last    bool
+	Size    int64
+	ModTime time.Time
+	// Link is the target of a symbolic link, as written in the link
+	Link string
+	// Followed is set for a link walked or dumped as its target
+	Followed bool
+	// Status is the git status in diff mode
+	Status string
+	// Note tells why a file or directory is skipped, or a file truncated
+	Note string
+	// Skip is set for an entry the tree shows but the dump leaves out
+	Skip bool
+}
+
+// FileTree is the filtered tree under Root in tree order: every directory is
+// followed by its entries, sorted by ApplySort. The tree, the dumpers and the
+// MCP tools all work from this list, so they agree on what is included.
+type FileTree struct {
+	Root  string
+	Nodes []*TreeNode
+}
+
+// fileID is the device and inode of a file.
+type fileID struct {
+	dev uint64
+	ino uint64
+}
+
+// treeWalker holds the state of one WalkTree.
+type treeWalker struct {
+	tree *FileTree
+	// opt filters the entries; nil lists every entry but .git
+	opt      *commandline.Option
+	symlinks string
+	// boundary is the resolved directory no followed link may leave
+	boundary string
+	// ancestors are the directories the walk is inside, to detect link cycles
+	ancestors []os.FileInfo
+	// generated is set by --skip-generated
+	generated *generatedDetector
+}
+
+// WalkTree lists root once, applying the ignore rules, filters and symlink
+// policy of opt. A nil opt lists every entry but .git, and no link is followed.
+func WalkTree(root string, opt *commandline.Option) (*FileTree, error) {
+	return walkTree(root, opt, opt)
+}
+
+// walkTree lists root with the filters of opt and the symlink policy of
+// links, either of which may be nil.
+func walkTree(root string, opt *commandline.Option, links *commandline.Option) (*FileTree, error) {
+	w := &treeWalker{tree: &FileTree{Root: root}, opt: opt, symlinks: model.SymlinkList}
+	if links != nil && links.Symlinks != "" {
+		w.symlinks = links.Symlinks.String()
+	}
+	if links != nil && links.SymlinkRoot != "" {
+		w.boundary = resolvePath(links.SymlinkRoot)
+	}
+	if opt != nil && opt.SkipGeneratedFlag {
+		w.generated = newGeneratedDetector(root)
+	}
+	if err := w.walk(root, 0); err != nil {
+		return nil, err
+	}
+	return w.tree, nil
+}
+
+func (w *treeWalker) walk(dir string, depth int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("Error reading directory %s: %v", dir, err)
+	}
+	ApplySort(entries)
+	if info, err := os.Stat(dir); err == nil {
+		w.ancestors = append(w.ancestors, info)
+		defer func() { w.ancestors = w.ancestors[:len(w.ancestors)-1] }()
+	}
+
+	opt := w.opt
+	var listed []*TreeNode
+	for _, entry := range entries {
+		name := entry.Name()
+		fpath := filepath.Join(dir, name)
+		if IsUnderGitDir(name) {
+			continue
+		}
+		isLink := entry.Type()&os.ModeSymlink != 0
+		if isLink && w.symlinks == model.SymlinkSkip {
+			continue
+		}
+		if opt != nil {
+			if opt.IgnoreDotFileFlag.Bool() && IsHiddenFile(name) {
+				continue
+			}
+			if !CanBoaded(opt, fpath) {
+				continue
+			}
+		}
+
+		node := &TreeNode{Path: fpath, Name: name, Depth: depth, IsDir: entry.IsDir()}
+		info, err := entry.Info()
+		if isLink {
+			node.Link, _ = os.Readlink(fpath)
+			var target os.FileInfo
+			if target, node.Note = w.followLink(fpath); target != nil {
+				info, err = target, nil
+				node.IsDir = target.IsDir()
+				node.Followed = true
+			} else {
+				node.Skip = true
+			}
+		}
+		if err == nil {
+			node.Size = info.Size()
+			node.ModTime = info.ModTime()
+		}
+		if node.IsDir && !node.Skip && w.generated != nil {
+			if node.Note = w.generated.dirNote(fpath); node.Note != "" {
+				node.Skip = true
+			}
+		}
+		if !node.IsDir && !node.Skip && opt != nil {
+			node.Status = opt.GitChanges.Status(fpath)
+			if w.generated != nil {
+				node.Note = w.generated.fileNote(fpath)
+				node.Skip = node.Note != ""
+			}
+			if !node.Skip {
+				node.Note, node.Skip = fileLimitNote(fpath, node.Size, opt)
+			}
+		}
+		w.tree.Nodes = append(w.tree.Nodes, node)
+		listed = append(listed, node)
+
+		if node.IsDir && !node.Skip {
+			// an unreadable subdirectory is listed empty
+			_ = w.walk(fpath, depth+1)
+		}
+	}
+	if len(listed) > 0 {
+		listed[len(listed)-1].Last = true
+	}
+	return nil
+}
+
+// followLink returns the file info of the target of the link at fpath when
+// the policy follows it, or a note telling why it is only listed.
+func (w *treeWalker) followLink(fpath string) (os.FileInfo, string) {
+	if w.symlinks != model.SymlinkFollow {
+		return nil, ""
+	}
+	target, err := os.Stat(fpath)
+	if err != nil {
+		return nil, "broken link"
+	}
+	if w.boundary != "" && !isWithin(w.boundary, resolvePath(fpath)) {
+		return nil, "outside root"
+	}
+	if target.IsDir() {
+		for _, dir := range w.ancestors {
+			if sameFile(dir, target) {
+				return nil, "symlink cycle"
+			}
+		}
+	}
+	return target, ""
+}
+
+// sameFile compares the device and inode of a and b where they are known.
+func sameFile(a os.FileInfo, b os.FileInfo) bool {
+	idA, okA := fileIdentity(a)
+	idB, okB := fileIdentity(b)
+	if okA && okB {
+		return idA == idB
+	}
+	return os.SameFile(a, b)
+}
+
+// resolvePath returns the absolute path of path with its links resolved, or
+// the absolute path alone when it cannot be resolved.
+func resolvePath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
+
+// IsWithin reports whether path is dir or below it, comparing the absolute
+// paths as written, without resolving links.
+func IsWithin(dir string, path string) bool {
+	dirAbs, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	return isWithin(dirAbs, pathAbs)
+}
+
+// isWithin reports whether path is dir or below it; both must be clean.
+func isWithin(dir string, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// LinkEscapes returns the first link on the way from root to path that
+// resolves outside root, or "" when there is none. A path that is not below
+// root, or does not exist, is only checked as far as it goes.
+func LinkEscapes(root string, path string) string {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return ""
+	}
+	pathAbs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(rootAbs, pathAbs)
+	if err != nil || rel == "." || !isWithin(rootAbs, pathAbs) {
+		return ""
+	}
+
+	boundary := resolvePath(rootAbs)
+	current := rootAbs
+	for name := range strings.SplitSeq(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, name)
+		info, err := os.Lstat(current)
+		if err != nil {
+			return ""
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		resolved, err := filepath.EvalSymlinks(current)
+		if err != nil {
+			return ""
+		}
+		if !isWithin(boundary, resolved) {
+			return current
+		}
+	}
+	return ""
+}
+
+// Allowed returns the listed directories and the files the dump includes.
+func (t *FileTree) Allowed() map[string]bool {
+	allowed := make(map[string]bool, len(t.Nodes))
+	for _, node := range t.Nodes {
+		if !node.Skip {
+			allowed[node.Path] = true
+		}
+	}
+	return allowed
+}
+
+// Restrict returns the tree of the files in allowed, and the directories that
+// lead to them. A nil allowed keeps every file.
+func (t *FileTree) Restrict(allowed map[string]bool) *FileTree {
+	keep := make([]bool, len(t.Nodes))
+	// dirs holds the indexes of the directories the current node is in
+	var dirs []int
+	for i, node := range t.Nodes {
+		dirs = dirs[:node.Depth]
+		if node.IsDir {
+			dirs = append(dirs, i)
+			continue
+		}
+		if allowed != nil && !allowed[node.Path] {
+			continue
+		}
+		keep[i] = true
+		for j := len(dirs) - 1; j >= 0 && !keep[dirs[j]]; j-- {
+			keep[dirs[j]] = true
+		}
+	}
+
+	restricted := &FileTree{Root: t.Root}
+	for i, node := range t.Nodes {
+		if keep[i] {
+			kept := *node
+			restricted.Nodes = append(restricted.Nodes, &kept)
+		}
+	}
+	restricted.markLast()
+	return restricted
+}
+
+// markLast sets Last on the last entry of every directory.
+func (t *FileTree) markLast() {
+	// seen[d] tells whether a later entry at depth d shares the parent
+	var seen []bool
+	for i := len(t.Nodes) - 1; i >= 0; i-- {
+		node := t.Nodes[i]
+		for len(seen) <= node.Depth {
+			seen = append(seen, false)
+		}
+		seen = seen[:node.Depth+1]
+		node.Last = !seen[node.Depth]
+		seen[node.Depth] = true
+	}
+}
+
+// Files returns the paths of the files in tree order.
+func (t *FileTree) Files() []string {
+	var paths []string
+	for _, node := range t.Nodes {
+		if !node.IsDir {
+			paths = append(paths, node.Path)
+		}
+	}
+	return paths
+}
+
+// ReadableFiles returns the paths of the files whose content is read, in
+// tree order: the files and the followed links, but not the listed links.
+func (t *FileTree) ReadableFiles() []string {
+	var paths []string
+	for _, node := range t.Nodes {
+		if !node.IsDir && (node.Link == "" || node.Followed) {
+			paths = append(paths, node.Path)
+		}
+	}
+	return paths
+}
+
+// TreeString renders the tree with box-drawing connectors, each line
+// starting with indent.
+func (t *FileTree) TreeString(indent string) string {
+	var b strings.Builder
+	// lastAt[d] tells whether the open directory at depth d is the last entry
+	var lastAt []bool
+	for _, node := range t.Nodes {
+		lastAt = lastAt[:node.Depth]
+		b.WriteString(indent)
+		for _, last := range lastAt {
+			if last {
+				b.WriteString("    ")
+			} else {
+				b.WriteString("│   ")
+			}
+		}
+		if node.Last {
+			b.WriteString("└── ")
+		} else {
+			b.WriteString("├── ")
+		}
+		b.WriteString(node.Name)
+		if node.IsDir {
+			b.WriteString("/")
+		}
+		if node.Link != "" {
+			b.WriteString(" -> " + node.Link)
+		}
+		if node.IsDir {
+			b.WriteString(fileLimitMarker(node.Note))
+			b.WriteString("\n")
+			lastAt = append(lastAt, node.Last)
+			continue
+		}
+		b.WriteString(gitStatusMarker(node.Status))
+		b.WriteString(fileLimitMarker(node.Note))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// TreeEntry renders the tree as nested entries, rooted at the target directory.
+func (t *FileTree) TreeEntry() *TreeEntry {
+	root := &TreeEntry{Name: filepath.Base(t.Root), Type: "directory"}
+	// parents[d] is the directory entries at depth d are added to
+	parents := []*TreeEntry{root}
+	for _, node := range t.Nodes {
+		parents = parents[:node.Depth+1]
+		parent := parents[node.Depth]
+		if node.IsDir {
+			entry := &TreeEntry{Name: node.Name, Type: "directory", Target: node.Link, Note: node.Note}
+			parent.Children = append(parent.Children, entry)
+			parents = append(parents, entry)
+			continue
+		}
+		entryType := "file"
+		if node.Link != "" && !node.Followed {
+			entryType = "link"
+		}
+		parent.Children = append(parent.Children, &TreeEntry{
+			Name:   node.Name,
+			Type:   entryType,
+			Target: node.Link,
+			Status: node.Status,
+			Note:   node.Note,
+		})
+	}
+	return root
+}
+
+// listDumpTree lists the files in allowed under root, in the order of
+// --order, for the dumpers that are given an allowed file map instead of a
+// walked tree. Links are walked as the symlink policy of opt says, so that the
+// files below a followed link are found again.
+func listDumpTree(root string, allowed map[string]bool, opt *commandline.Option) (*FileTree, error) {
+	tree, err := walkTree(root, nil, opt)
+	if err != nil {
+		return nil, err
+	}
+	return orderDumpTree(tree.Restrict(allowed), opt)
+}
+
+// dumpPaths returns the files a dump of opt.TargetDirname includes, in tree order.
+func dumpPaths(opt *commandline.Option) ([]string, error) {
+	tree, err := WalkTree(opt.TargetDirname, opt)
+	if err != nil {
+		return nil, err
+	}
+	return tree.Restrict(tree.Allowed()).Files(), nil
+}
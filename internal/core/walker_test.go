@@ -0,0 +1,181 @@
+package core_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestWalkTree_TreeOrder(t *testing.T) {
+	root := t.TempDir()
+	mustWriteFile(t, filepath.Join(root, "b.txt"), "b")
+	mustMkdir(t, filepath.Join(root, "a"))
+	mustWriteFile(t, filepath.Join(root, "a", "x.go"), "x")
+	mustMkdir(t, filepath.Join(root, ".git"))
+	mustWriteFile(t, filepath.Join(root, ".git", "HEAD"), "ref")
+
+	tree, err := core.WalkTree(root, nil)
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+
+	var names []string
+	for _, node := range tree.Nodes {
+		names = append(names, node.Name)
+	}
+	if want := []string{"a", "x.go", "b.txt"}; !slices.Equal(names, want) {
+		t.Errorf("nodes = %v; want %v", names, want)
+	}
+
+	files := tree.Files()
+	want := []string{filepath.Join(root, "a", "x.go"), filepath.Join(root, "b.txt")}
+	if !slices.Equal(files, want) {
+		t.Errorf("Files() = %v; want %v", files, want)
+	}
+}
+
+func TestFileTree_RestrictConnectors(t *testing.T) {
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, "a"))
+	mustWriteFile(t, filepath.Join(root, "a", "keep.go"), "k")
+	mustWriteFile(t, filepath.Join(root, "a", "drop.go"), "d")
+	mustMkdir(t, filepath.Join(root, "empty"))
+	mustWriteFile(t, filepath.Join(root, "empty", "drop.txt"), "d")
+
+	tree, err := core.WalkTree(root, nil)
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+	restricted := tree.Restrict(map[string]bool{filepath.Join(root, "a", "keep.go"): true})
+
+	got := restricted.TreeString("")
+	want := "└── a/\n    └── keep.go\n"
+	if got != want {
+		t.Errorf("TreeString() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func createLinkTree(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	mustMkdir(t, filepath.Join(root, "pkg"))
+	mustWriteFile(t, filepath.Join(root, "pkg", "a.go"), "package pkg")
+	if err := os.Symlink("pkg", filepath.Join(root, "alias")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	mustSymlink(t, "..", filepath.Join(root, "pkg", "loop"))
+	mustSymlink(t, "missing.go", filepath.Join(root, "dangling.go"))
+	return root
+}
+
+func mustSymlink(t *testing.T, target string, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("failed to create symlink %s: %v", link, err)
+	}
+}
+
+func walkWithSymlinks(t *testing.T, root string, policy string) *core.FileTree {
+	t.Helper()
+	opt := &commandline.Option{IgnoreDotFileFlag: model.OnOffSwitch("off")}
+	if err := opt.Symlinks.Set(policy); err != nil {
+		t.Fatal(err)
+	}
+	tree, err := core.WalkTree(root, opt)
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+	return tree
+}
+
+func TestWalkTree_Symlinks(t *testing.T) {
+	root := createLinkTree(t)
+
+	tests := []struct {
+		policy string
+		tree   string
+		dumped []string
+	}{
+		{
+			policy: "skip",
+			tree:   "└── pkg/\n    └── a.go\n",
+			dumped: []string{"pkg/a.go"},
+		},
+		{
+			policy: "list",
+			tree:   "├── alias -> pkg\n├── dangling.go -> missing.go\n└── pkg/\n    ├── a.go\n    └── loop -> ..\n",
+			dumped: []string{"pkg/a.go"},
+		},
+		{
+			policy: "follow",
+			tree: "├── alias/ -> pkg\n│   ├── a.go\n│   └── loop -> .. [symlink cycle]\n" +
+				"├── dangling.go -> missing.go [broken link]\n" +
+				"└── pkg/\n    ├── a.go\n    └── loop -> .. [symlink cycle]\n",
+			dumped: []string{"alias/a.go", "pkg/a.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		tree := walkWithSymlinks(t, root, tt.policy)
+		if got := tree.TreeString(""); got != tt.tree {
+			t.Errorf("%s: TreeString() =\n%s\nwant\n%s", tt.policy, got, tt.tree)
+		}
+
+		var dumped []string
+		for _, fpath := range tree.Restrict(tree.Allowed()).Files() {
+			rel, _ := filepath.Rel(root, fpath)
+			dumped = append(dumped, filepath.ToSlash(rel))
+		}
+		if !slices.Equal(dumped, tt.dumped) {
+			t.Errorf("%s: dumped %v; want %v", tt.policy, dumped, tt.dumped)
+		}
+	}
+}
+
+func TestWalkTree_SymlinkEntries(t *testing.T) {
+	root := createLinkTree(t)
+
+	entry := walkWithSymlinks(t, root, "list").TreeEntry()
+	if alias := entry.Children[0]; alias.Type != "link" || alias.Target != "pkg" {
+		t.Errorf("listed link = %+v; want type link and target pkg", alias)
+	}
+
+	entry = walkWithSymlinks(t, root, "follow").TreeEntry()
+	if alias := entry.Children[0]; alias.Type != "directory" || alias.Target != "pkg" || len(alias.Children) != 2 {
+		t.Errorf("followed link = %+v; want a directory with target pkg and 2 children", alias)
+	}
+}
+
+func TestWalkTree_SymlinkRoot(t *testing.T) {
+	outside := t.TempDir()
+	mustWriteFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	root := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	opt := &commandline.Option{IgnoreDotFileFlag: model.OnOffSwitch("off"), Symlinks: "follow", SymlinkRoot: root}
+	tree, err := core.WalkTree(root, opt)
+	if err != nil {
+		t.Fatalf("WalkTree failed: %v", err)
+	}
+	want := "└── escape -> " + outside + " [outside root]\n"
+	if got := tree.TreeString(""); got != want {
+		t.Errorf("TreeString() = %q; want %q", got, want)
+	}
+	if files := tree.ReadableFiles(); len(files) != 0 {
+		t.Errorf("ReadableFiles() = %v; want none", files)
+	}
+
+	if link := core.LinkEscapes(root, filepath.Join(root, "escape", "secret.txt")); link != filepath.Join(root, "escape") {
+		t.Errorf("LinkEscapes() = %q; want the escape link", link)
+	}
+	if link := core.LinkEscapes(root, filepath.Join(root, "missing.txt")); link != "" {
+		t.Errorf("LinkEscapes() = %q for a plain path", link)
+	}
+}
@@ -0,0 +1,317 @@
+package gitdiff
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusAdded     = "A"
+	StatusModified  = "M"
+	StatusUntracked = "?"
+)
+
+// Change is a single file changed in the local repository.
+type Change struct {
+	Path   string // absolute path
+	Status string
+
+	set  *ChangeSet
+	once sync.Once
+	diff string
+	err  error
+}
+
+// ChangeSet is the set of files changed since a ref and/or in the index.
+// A nil *ChangeSet means diff mode is off.
+type ChangeSet struct {
+	TopLevel string
+	Since    string
+	Staged   bool
+
+	// changes is keyed by the absolute path, with links resolved
+	changes map[string]*Change
+	// dirs holds the directories with a change below them, keyed the same way
+	dirs map[string]bool
+	// dir is the absolute directory Collect was given, and resolvedDir the
+	// same with its links resolved, so a lookup needs no syscall
+	dir         string
+	resolvedDir string
+}
+
+// Collect asks the local git repository containing dir for the files changed
+// since ref (working tree against ref) and/or staged in the index. Deleted files
+// are left out, since there is nothing to dump. Untracked files count as
+// changed in --since mode unless only the index is compared.
+func Collect(dir string, since string, staged bool) (*ChangeSet, error) {
+	if since == "" && !staged {
+		return nil, errors.New("either a ref or staged mode is required")
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(absDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository: %w", dir, err)
+	}
+	topLevel := strings.TrimSpace(string(out))
+	if resolved, err := filepath.EvalSymlinks(topLevel); err == nil {
+		topLevel = resolved
+	}
+
+	if since != "" {
+		if _, err := runGit(topLevel, "rev-parse", "--verify", "--quiet", since+"^{commit}"); err != nil {
+			return nil, fmt.Errorf("unknown git ref: %s", since)
+		}
+	}
+
+	cs := &ChangeSet{
+		TopLevel:    topLevel,
+		Since:       since,
+		Staged:      staged,
+		changes:     map[string]*Change{},
+		dirs:        map[string]bool{},
+		dir:         filepath.Clean(absDir),
+		resolvedDir: filepath.Clean(absDir),
+	}
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		cs.resolvedDir = resolved
+	}
+
+	args := append([]string{"diff", "--name-status", "-z", "--no-renames", "--diff-filter=d"}, cs.diffArgs()...)
+	out, err = runGit(topLevel, args...)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		cs.add(fields[i+1], fields[i][:1])
+	}
+
+	if !staged {
+		out, err = runGit(topLevel, "ls-files", "--others", "--exclude-standard", "-z")
+		if err != nil {
+			return nil, err
+		}
+		for rel := range strings.SplitSeq(strings.TrimSuffix(string(out), "\x00"), "\x00") {
+			if rel != "" {
+				cs.add(rel, StatusUntracked)
+			}
+		}
+	}
+
+	return cs, nil
+}
+
+func (cs *ChangeSet) add(rel string, status string) {
+	abs := filepath.Join(cs.TopLevel, filepath.FromSlash(rel))
+	cs.changes[abs] = &Change{Path: abs, Status: status, set: cs}
+	for dir := filepath.Dir(abs); !cs.dirs[dir]; dir = filepath.Dir(dir) {
+		cs.dirs[dir] = true
+		if dir == cs.TopLevel || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+}
+
+func (cs *ChangeSet) diffArgs() []string {
+	var args []string
+	if cs.Staged {
+		args = append(args, "--cached")
+	}
+	if cs.Since != "" {
+		args = append(args, cs.Since)
+	}
+	return args
+}
+
+// Len returns the number of changed files.
+func (cs *ChangeSet) Len() int {
+	if cs == nil {
+		return 0
+	}
+	return len(cs.changes)
+}
+
+// Get returns the change of fpath, or nil when it is unchanged.
+func (cs *ChangeSet) Get(fpath string) *Change {
+	if cs == nil {
+		return nil
+	}
+	return cs.changes[cs.abs(fpath)]
+}
+
+// Status returns the git status letter of fpath, or "" when it is unchanged.
+func (cs *ChangeSet) Status(fpath string) string {
+	if c := cs.Get(fpath); c != nil {
+		return c.Status
+	}
+	return ""
+}
+
+// HasChangesUnder reports whether a changed file lives in the directory dir.
+func (cs *ChangeSet) HasChangesUnder(dir string) bool {
+	if cs == nil {
+		return false
+	}
+	return cs.dirs[cs.abs(dir)]
+}
+
+// abs returns the key of fpath: its absolute path, with the links of the
+// directory given to Collect resolved. Links below it are not resolved, as
+// git records a link itself rather than what it points to.
+func (cs *ChangeSet) abs(fpath string) string {
+	abs, err := filepath.Abs(fpath)
+	if err != nil {
+		return fpath
+	}
+	if cs.dir == cs.resolvedDir {
+		return abs
+	}
+	if abs == cs.dir {
+		return cs.resolvedDir
+	}
+	if rest, ok := strings.CutPrefix(abs, cs.dir+string(filepath.Separator)); ok {
+		return filepath.Join(cs.resolvedDir, rest)
+	}
+	return abs
+}
+
+// Diff returns the unified diff of the change. Untracked files have no diff in
+// git, so their whole content is rendered as an added hunk.
+func (c *Change) Diff() (string, error) {
+	c.once.Do(func() {
+		rel, err := filepath.Rel(c.set.TopLevel, c.Path)
+		if err != nil {
+			c.err = err
+			return
+		}
+		rel = filepath.ToSlash(rel)
+
+		if c.Status == StatusUntracked {
+			out, err := runGit(c.set.TopLevel, "diff", "--no-color", "--no-ext-diff", "--no-index", "--", "/dev/null", rel)
+			// --no-index exits with 1 when the files differ
+			var exitErr *exec.ExitError
+			if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitCode() == 1) {
+				c.err = err
+				return
+			}
+			c.diff = string(out)
+			return
+		}
+
+		args := append([]string{"diff", "--no-color", "--no-ext-diff", "--no-renames"}, c.set.diffArgs()...)
+		out, err := runGit(c.set.TopLevel, append(args, "--", rel)...)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.diff = string(out)
+	})
+	return c.diff, c.err
+}
+
+// CommitTimes returns the time of the last commit touching each of paths,
+// absolute files with their links resolved, keyed the same way. Only the
+// history of dir is read, and only as far back as it takes to date every
+// path. Paths that were never committed are missing from it.
+func CommitTimes(dir string, paths []string) (map[string]time.Time, error) {
+	times := map[string]time.Time{}
+	if len(paths) == 0 {
+		return times, nil
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		absDir = resolved
+	}
+	out, err := runGit(absDir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not inside a git repository: %w", dir, err)
+	}
+	topLevel := strings.TrimSpace(string(out))
+	if resolved, err := filepath.EvalSymlinks(topLevel); err == nil {
+		topLevel = resolved
+	}
+	pending := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		pending[p] = true
+	}
+
+	// the log is newest first, so the first time a file shows up is its last
+	// commit; names are relative to the top level even with the "." pathspec
+	cmd := exec.Command("git", "-C", absDir, "-c", "core.quotePath=false", "log", "--no-renames", "--format=@%ct", "--name-only", "--", ".")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var current time.Time
+	for len(pending) > 0 && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "@"):
+			if sec, err := strconv.ParseInt(line[1:], 10, 64); err == nil {
+				current = time.Unix(sec, 0)
+			}
+		default:
+			path := filepath.Join(topLevel, filepath.FromSlash(line))
+			if pending[path] {
+				times[path] = current
+				delete(pending, path)
+			}
+		}
+	}
+	if len(pending) == 0 {
+		// every path is dated, the rest of the history is not needed
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return times, nil
+	}
+	if err := scanner.Err(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git log: %w: %s", err, msg)
+		}
+		return nil, err
+	}
+	return times, nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return out, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return out, err
+	}
+	return out, nil
+}
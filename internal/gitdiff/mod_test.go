@@ -0,0 +1,194 @@
+package gitdiff_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/gitdiff"
+)
+
+func git(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com", "GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// createTestRepo makes a repository with one commit and returns its path.
+func createTestRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "-q")
+	writeFile(t, filepath.Join(dir, "keep.txt"), "unchanged\n")
+	writeFile(t, filepath.Join(dir, "src", "main.go"), "package main\n")
+	writeFile(t, filepath.Join(dir, "gone.txt"), "bye\n")
+	git(t, dir, "add", "-A")
+	git(t, dir, "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func TestCollect_Since(t *testing.T) {
+	dir := createTestRepo(t)
+	writeFile(t, filepath.Join(dir, "src", "main.go"), "package main\n\nfunc main() {}\n")
+	writeFile(t, filepath.Join(dir, "new.txt"), "new\n")
+	os.Remove(filepath.Join(dir, "gone.txt"))
+
+	cs, err := gitdiff.Collect(dir, "HEAD", false)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if cs.Len() != 2 {
+		t.Errorf("expected 2 changes, got %d", cs.Len())
+	}
+	if got := cs.Status(filepath.Join(dir, "src", "main.go")); got != gitdiff.StatusModified {
+		t.Errorf("main.go status = %q", got)
+	}
+	if got := cs.Status(filepath.Join(dir, "new.txt")); got != gitdiff.StatusUntracked {
+		t.Errorf("new.txt status = %q", got)
+	}
+	if got := cs.Status(filepath.Join(dir, "keep.txt")); got != "" {
+		t.Errorf("keep.txt must be unchanged, got %q", got)
+	}
+	if !cs.HasChangesUnder(filepath.Join(dir, "src")) {
+		t.Errorf("src should contain changes")
+	}
+
+	diff, err := cs.Get(filepath.Join(dir, "src", "main.go")).Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+func main() {}") || !strings.Contains(diff, "@@") {
+		t.Errorf("unexpected diff:\n%s", diff)
+	}
+	diff, err = cs.Get(filepath.Join(dir, "new.txt")).Diff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(diff, "+new") {
+		t.Errorf("untracked file should render as an added hunk:\n%s", diff)
+	}
+}
+
+func TestCollect_Staged(t *testing.T) {
+	dir := createTestRepo(t)
+	writeFile(t, filepath.Join(dir, "staged.txt"), "staged\n")
+	writeFile(t, filepath.Join(dir, "untracked.txt"), "untracked\n")
+	writeFile(t, filepath.Join(dir, "keep.txt"), "modified but not staged\n")
+	git(t, dir, "add", "staged.txt")
+
+	cs, err := gitdiff.Collect(dir, "", true)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if cs.Len() != 1 || cs.Status(filepath.Join(dir, "staged.txt")) != gitdiff.StatusAdded {
+		t.Errorf("expected only staged.txt, got %d changes", cs.Len())
+	}
+}
+
+func TestCollect_Errors(t *testing.T) {
+	dir := createTestRepo(t)
+	if _, err := gitdiff.Collect(dir, "no-such-ref", false); err == nil {
+		t.Error("expected an error for an unknown ref")
+	}
+	if _, err := gitdiff.Collect(dir, "", false); err == nil {
+		t.Error("expected an error without a ref or staged mode")
+	}
+	if _, err := gitdiff.Collect(t.TempDir(), "HEAD", false); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+}
+
+func TestChangeSet_ThroughLink(t *testing.T) {
+	dir := createTestRepo(t)
+	writeFile(t, filepath.Join(dir, "src", "deep", "x.go"), "package deep\n")
+	os.Mkdir(filepath.Join(dir, "docs"), 0755)
+	writeFile(t, filepath.Join(dir, "docs", "keep.md"), "docs\n")
+	git(t, dir, "add", "docs")
+	git(t, dir, "commit", "-q", "-m", "docs")
+	link := filepath.Join(t.TempDir(), "repo")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+
+	cs, err := gitdiff.Collect(link, "HEAD", false)
+	if err != nil {
+		t.Fatalf("Collect failed: %v", err)
+	}
+	if got := cs.Status(filepath.Join(link, "src", "deep", "x.go")); got != gitdiff.StatusUntracked {
+		t.Errorf("x.go status through the link = %q", got)
+	}
+	for _, d := range []string{link, filepath.Join(link, "src"), filepath.Join(link, "src", "deep")} {
+		if !cs.HasChangesUnder(d) {
+			t.Errorf("%s should contain changes", d)
+		}
+	}
+	if cs.HasChangesUnder(filepath.Join(link, "docs")) || cs.HasChangesUnder(filepath.Join(link, "src", "deep", "x.go")) {
+		t.Error("docs and a file must not contain changes")
+	}
+}
+
+func TestChangeSet_Nil(t *testing.T) {
+	var cs *gitdiff.ChangeSet
+	if cs.Len() != 0 || cs.Status("x") != "" || cs.Get("x") != nil || cs.HasChangesUnder("x") {
+		t.Error("nil ChangeSet must report no changes")
+	}
+}
+
+func TestCommitTimes(t *testing.T) {
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-01T00:00:00Z")
+	dir := createTestRepo(t)
+	t.Setenv("GIT_COMMITTER_DATE", "2024-06-01T00:00:00Z")
+	writeFile(t, filepath.Join(dir, "src", "main.go"), "package main\n\nfunc main() {}\n")
+	git(t, dir, "commit", "-q", "-am", "update")
+	writeFile(t, filepath.Join(dir, "untracked.txt"), "new\n")
+
+	top, _ := filepath.EvalSymlinks(dir)
+	mainGo := filepath.Join(top, "src", "main.go")
+	keep := filepath.Join(top, "keep.txt")
+	untracked := filepath.Join(top, "untracked.txt")
+
+	times, err := gitdiff.CommitTimes(dir, []string{mainGo, keep, untracked})
+	if err != nil {
+		t.Fatalf("CommitTimes failed: %v", err)
+	}
+	if got := times[mainGo].UTC().Format("2006-01-02"); got != "2024-06-01" {
+		t.Errorf("main.go committed %s, want 2024-06-01", got)
+	}
+	if got := times[keep].UTC().Format("2006-01-02"); got != "2024-01-01" {
+		t.Errorf("keep.txt committed %s, want 2024-01-01", got)
+	}
+	if _, ok := times[untracked]; ok {
+		t.Error("untracked.txt was never committed")
+	}
+
+	// only the history of the directory is read
+	times, err = gitdiff.CommitTimes(filepath.Join(dir, "src"), []string{mainGo, keep})
+	if err != nil {
+		t.Fatalf("CommitTimes failed: %v", err)
+	}
+	if _, ok := times[keep]; ok || len(times) != 1 {
+		t.Errorf("CommitTimes(src) = %v; want main.go only", times)
+	}
+
+	if _, err := gitdiff.CommitTimes(t.TempDir(), []string{keep}); err == nil {
+		t.Error("expected an error outside a repository")
+	}
+}
@@ -0,0 +1,234 @@
+package libgitignore_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/libgitignore"
+)
+
+// syntheticTree describes a monorepo-like tree: packages with ignore files
+// of their own, and an ignored node_modules full of files.
+type syntheticTree struct {
+	Packages    int
+	Subdirs     int
+	Files       int
+	NodeModules int
+	// RootRules and PackageRules are the number of filler patterns of the
+	// .gitignore files, none of which match
+	RootRules    int
+	PackageRules int
+}
+
+// entry is a path of the tree in walk order.
+type entry struct {
+	Path  string
+	IsDir bool
+}
+
+func (s syntheticTree) create(tb testing.TB, root string) []entry {
+	tb.Helper()
+	var entries []entry
+	mkdir := func(path string) {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			tb.Fatal(err)
+		}
+		entries = append(entries, entry{path, true})
+	}
+	write := func(path string, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			tb.Fatal(err)
+		}
+		entries = append(entries, entry{path, false})
+	}
+
+	var rules strings.Builder
+	rules.WriteString("node_modules/\n*.log\n/dist/\n**/tmp/**\n")
+	for i := range s.RootRules {
+		fmt.Fprintf(&rules, "cache-%d\n*.ext%d\n/gen%d/\nbuild-%d/**/*.o\n", i, i, i, i)
+	}
+	write(filepath.Join(root, ".gitignore"), rules.String())
+
+	mkdir(filepath.Join(root, "node_modules"))
+	for i := range s.NodeModules {
+		write(filepath.Join(root, "node_modules", fmt.Sprintf("mod%05d.js", i)), "")
+	}
+
+	for p := range s.Packages {
+		pkg := filepath.Join(root, fmt.Sprintf("pkg%03d", p))
+		mkdir(pkg)
+		var rules strings.Builder
+		rules.WriteString("*.gen.go\n!keep.gen.go\nout/\n")
+		for i := range s.PackageRules {
+			fmt.Fprintf(&rules, "local-%d-%d\n/only-%d.txt\n", p, i, i)
+		}
+		write(filepath.Join(pkg, ".gitignore"), rules.String())
+		for d := range s.Subdirs {
+			dir := filepath.Join(pkg, fmt.Sprintf("sub%02d", d))
+			mkdir(dir)
+			for f := range s.Files {
+				if f%10 == 2 && f > 2 {
+					continue
+				}
+				var name string
+				switch f % 10 {
+				case 0:
+					name = fmt.Sprintf("file%03d.log", f)
+				case 1:
+					name = fmt.Sprintf("file%03d.gen.go", f)
+				case 2:
+					name = "keep.gen.go"
+				default:
+					name = fmt.Sprintf("file%03d.go", f)
+				}
+				write(filepath.Join(dir, name), "")
+			}
+		}
+	}
+	return entries
+}
+
+// walk matches entries in order and skips the contents of ignored
+// directories, as the tree builders do. It returns the number of kept paths.
+func walk(entries []entry, ignored func(entry) bool) int {
+	kept := 0
+	skip := ""
+	for _, e := range entries {
+		if skip != "" && strings.HasPrefix(e.Path, skip) {
+			continue
+		}
+		skip = ""
+		if ignored(e) {
+			if e.IsDir {
+				skip = e.Path + string(filepath.Separator)
+			}
+			continue
+		}
+		kept++
+	}
+	return kept
+}
+
+// linearGitIgnore is the matcher before the ignore rules were indexed: it
+// walks the whole tree for ignore files up front, and checks every path
+// against every pattern with filepath.Rel and a regexp.
+type linearGitIgnore struct {
+	patterns []*libgitignore.IgnorePattern
+}
+
+func newLinearGitIgnore(root string) (*linearGitIgnore, error) {
+	gi := &linearGitIgnore{}
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(filepath.Join(path, ".gitignore"))
+		if err != nil {
+			return nil
+		}
+		for i, line := range strings.Split(string(data), "\n") {
+			p, err := libgitignore.CompileIgnoreLine(line, path, i+1)
+			if err != nil {
+				return err
+			}
+			if p != nil {
+				gi.patterns = append(gi.patterns, p)
+			}
+		}
+		return nil
+	})
+	return gi, err
+}
+
+func (gi *linearGitIgnore) lastMatch(absPath string, isDir bool) *libgitignore.IgnorePattern {
+	var last *libgitignore.IgnorePattern
+	for _, p := range gi.patterns {
+		if p.DirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(p.Dir, absPath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if p.Regexp.MatchString(filepath.ToSlash(rel)) {
+			last = p
+		}
+	}
+	return last
+}
+
+func (gi *linearGitIgnore) matches(root string, e entry) bool {
+	absPath, _ := filepath.Abs(e.Path)
+	rel, _ := filepath.Rel(root, absPath)
+	parent := root
+	components := strings.Split(filepath.ToSlash(rel), "/")
+	for _, name := range components[:len(components)-1] {
+		parent = filepath.Join(parent, name)
+		if p := gi.lastMatch(parent, true); p != nil && !p.Negate {
+			return true
+		}
+	}
+	p := gi.lastMatch(absPath, e.IsDir)
+	return p != nil && !p.Negate
+}
+
+// isolateGitConfig keeps the global git ignore file of the user out of a test.
+func isolateGitConfig(tb testing.TB) {
+	tb.Setenv("HOME", tb.TempDir())
+	tb.Setenv("XDG_CONFIG_HOME", tb.TempDir())
+	tb.Setenv("GIT_CONFIG_GLOBAL", "")
+}
+
+func TestGitIgnore_IndexedMatchesLinear(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	entries := syntheticTree{Packages: 4, Subdirs: 3, Files: 20, NodeModules: 10, RootRules: 5, PackageRules: 5}.create(t, root)
+
+	linear, err := newLinearGitIgnore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := libgitignore.GenerateIntegratedGitIgnore(true, root, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		want := linear.matches(root, e)
+		if got := indexed.MatchesPath(e.Path); got != want {
+			t.Errorf("MatchesPath(%q) = %v; want %v", e.Path, got, want)
+		}
+	}
+}
+
+// The benchmark runs on a 100k-file tree, 10k of them in node_modules. The
+// linear matcher takes over a minute per run:
+//
+//	go test -run '^$' -bench . -benchtime 1x ./internal/libgitignore
+func BenchmarkGitIgnore_Walk(b *testing.B) {
+	isolateGitConfig(b)
+	root := b.TempDir()
+	entries := syntheticTree{Packages: 90, Subdirs: 10, Files: 100, NodeModules: 10000, RootRules: 10, PackageRules: 1}.create(b, root)
+
+	b.Run("linear", func(b *testing.B) {
+		for b.Loop() {
+			gi, err := newLinearGitIgnore(root)
+			if err != nil {
+				b.Fatal(err)
+			}
+			walk(entries, func(e entry) bool { return gi.matches(root, e) })
+		}
+	})
+	b.Run("indexed", func(b *testing.B) {
+		for b.Loop() {
+			gi, err := libgitignore.GenerateIntegratedGitIgnore(true, root, []string{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			walk(entries, func(e entry) bool { return gi.MatchesPath(e.Path) })
+		}
+	})
+}
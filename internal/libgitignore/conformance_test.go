@@ -0,0 +1,251 @@
+package libgitignore_test
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/libgitignore"
+)
+
+var update = flag.Bool("update", false, "record the expected results of testdata/conformance with git check-ignore")
+
+// conformanceCase is a file of testdata/conformance. It is a list of
+// sections headed by "-- name --":
+//
+//   - "root": the directory ark is run on, relative to the repository (optional)
+//   - "paths": the paths to check, relative to the repository; a trailing
+//     slash makes a directory, which is checked without the slash as the
+//     walk sees it
+//   - "expected": the output of git check-ignore --no-index -v -n for paths
+//   - any other name is a file to create; "~/" is the home directory, and
+//     .git/config is appended to the config created by git init
+type conformanceCase struct {
+	Name     string
+	Root     string
+	Files    []conformanceFile
+	Paths    []string
+	Expected []string
+}
+
+type conformanceFile struct {
+	Name    string
+	Content string
+}
+
+func loadConformanceCase(path string) (*conformanceCase, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	c := &conformanceCase{Name: filepath.Base(path)}
+	section := ""
+	var lines []string
+	flush := func() {
+		switch section {
+		case "":
+		case "root":
+			if len(lines) > 0 {
+				c.Root = lines[0]
+			}
+		case "paths":
+			c.Paths = lines
+		case "expected":
+			c.Expected = lines
+		default:
+			content := ""
+			if len(lines) > 0 {
+				content = strings.Join(lines, "\n") + "\n"
+			}
+			c.Files = append(c.Files, conformanceFile{Name: section, Content: content})
+		}
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "-- "); ok && strings.HasSuffix(name, " --") {
+			flush()
+			section = strings.TrimSuffix(name, " --")
+			continue
+		}
+		if section == "" {
+			// the description in front of the first section
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return c, nil
+}
+
+// setup creates the repository of c and an empty home directory, and points
+// HOME and XDG_CONFIG_HOME at it.
+func (c *conformanceCase) setup(t *testing.T) (repo string, home string) {
+	t.Helper()
+	tmp := t.TempDir()
+	repo = filepath.Join(tmp, "repo")
+	home = filepath.Join(tmp, "home")
+	for _, dir := range []string{filepath.Join(repo, ".git", "info"), home} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	// t.Setenv restores the variable after the test
+	t.Setenv("GIT_CONFIG_GLOBAL", "")
+	os.Unsetenv("GIT_CONFIG_GLOBAL")
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+
+	if *update {
+		cmd := exec.Command("git", "init", "-q", repo)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v\n%s", err, out)
+		}
+	}
+
+	for _, p := range c.Paths {
+		target := filepath.Join(repo, p)
+		if strings.HasSuffix(p, "/") {
+			if err := os.MkdirAll(target, 0755); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		writeConformanceFile(t, target, "", false)
+	}
+	for _, f := range c.Files {
+		if rest, ok := strings.CutPrefix(f.Name, "~/"); ok {
+			writeConformanceFile(t, filepath.Join(home, rest), f.Content, false)
+			continue
+		}
+		writeConformanceFile(t, filepath.Join(repo, f.Name), f.Content, f.Name == ".git/config")
+	}
+	return repo, home
+}
+
+func writeConformanceFile(t *testing.T, path string, content string, appendTo bool) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recordGit runs git check-ignore on the paths of c, with the home
+// directory in the sources written as "~/".
+func (c *conformanceCase) recordGit(t *testing.T, repo string, home string) []string {
+	t.Helper()
+	cmd := exec.Command("git", "check-ignore", "--no-index", "-v", "-n", "--stdin")
+	cmd.Dir = repo
+	var stdin strings.Builder
+	for _, path := range c.Paths {
+		stdin.WriteString(strings.TrimSuffix(path, "/") + "\n")
+	}
+	cmd.Stdin = strings.NewReader(stdin.String())
+	out, err := cmd.Output()
+	if err != nil {
+		// exit status 1 means that no path is ignored
+		if exitErr, ok := err.(*exec.ExitError); !ok || exitErr.ExitCode() != 1 {
+			t.Fatalf("git check-ignore: %v", err)
+		}
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(string(out), home+"/", "~/"), "\n"), "\n")
+}
+
+// describe renders the result of gi for path in the format of git
+// check-ignore --no-index -v -n.
+func describe(gi *libgitignore.GitIgnore, root string, repo string, home string, path string) string {
+	path = strings.TrimSuffix(path, "/")
+	rel, err := filepath.Rel(root, filepath.Join(repo, path))
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	_, p := gi.MatchesPathHow(rel)
+	if p == nil {
+		return "::\t" + path
+	}
+	source := p.Source
+	if s, err := filepath.Rel(repo, source); err == nil && !strings.HasPrefix(s, "..") {
+		source = filepath.ToSlash(s)
+	} else if s, ok := strings.CutPrefix(source, home+"/"); ok {
+		source = "~/" + s
+	}
+	return fmt.Sprintf("%s:%d:%s\t%s", source, p.LineNo, p.Raw, path)
+}
+
+func TestGitIgnore_Conformance(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "conformance", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no conformance cases found")
+	}
+
+	for _, file := range files {
+		c, err := loadConformanceCase(file)
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		t.Run(c.Name, func(t *testing.T) {
+			repo, home := c.setup(t)
+
+			if *update {
+				recordExpected(t, file, c.recordGit(t, repo, home))
+				return
+			}
+			if len(c.Expected) != len(c.Paths) {
+				t.Fatalf("%d expected results for %d paths: run go test -update", len(c.Expected), len(c.Paths))
+			}
+
+			root := filepath.Join(repo, c.Root)
+			gi, err := libgitignore.GenerateIntegratedGitIgnore(true, root, []string{})
+			if err != nil {
+				t.Fatalf("GenerateIntegratedGitIgnore: %v", err)
+			}
+			for i, path := range c.Paths {
+				if got := describe(gi, root, repo, home, path); got != c.Expected[i] {
+					t.Errorf("%s:\n got %q\nwant %q", path, got, c.Expected[i])
+				}
+			}
+		})
+	}
+}
+
+// recordExpected replaces the expected section of a case file.
+func recordExpected(t *testing.T, file string, expected []string) {
+	t.Helper()
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _, _ := strings.Cut(string(data), "-- expected --\n")
+	content = strings.TrimRight(content, "\n") + "\n-- expected --\n" + strings.Join(expected, "\n") + "\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,184 @@
+package libgitignore
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepository is the repository a directory belongs to.
+type gitRepository struct {
+	// TopLevel is the root of the work tree
+	TopLevel string
+	// CommonDir holds the config and info/exclude, shared by worktrees
+	CommonDir string
+}
+
+// findGitRepository walks up from dir to the nearest .git directory or
+// gitfile. It returns nil outside a repository.
+func findGitRepository(dir string) *gitRepository {
+	for cur := dir; ; cur = filepath.Dir(cur) {
+		dotGit := filepath.Join(cur, ".git")
+		if info, err := os.Stat(dotGit); err == nil {
+			gitDir := dotGit
+			if !info.IsDir() {
+				gitDir = readGitFile(dotGit)
+			}
+			if gitDir != "" {
+				return &gitRepository{TopLevel: cur, CommonDir: commonDir(gitDir)}
+			}
+		}
+		if cur == filepath.Dir(cur) {
+			return nil
+		}
+	}
+}
+
+// readGitFile reads the "gitdir: <path>" line of a worktree or submodule
+// .git file.
+func readGitFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	gitDir = strings.TrimSpace(gitDir)
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(path), gitDir)
+	}
+	return gitDir
+}
+
+// commonDir follows the commondir file of a linked worktree.
+func commonDir(gitDir string) string {
+	data, err := os.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	dir := strings.TrimSpace(string(data))
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(gitDir, dir)
+	}
+	return filepath.Clean(dir)
+}
+
+// globalExcludesFile returns the path of git's global ignore file:
+// core.excludesFile from the global or repository config, or
+// $XDG_CONFIG_HOME/git/ignore by default. It returns "" when neither is known.
+func globalExcludesFile(repo *gitRepository) string {
+	home, _ := os.UserHomeDir()
+	xdg := os.Getenv("XDG_CONFIG_HOME")
+	if xdg == "" && home != "" {
+		xdg = filepath.Join(home, ".config")
+	}
+
+	var configs []string
+	if global := os.Getenv("GIT_CONFIG_GLOBAL"); global != "" {
+		configs = append(configs, global)
+	} else {
+		if xdg != "" {
+			configs = append(configs, filepath.Join(xdg, "git", "config"))
+		}
+		if home != "" {
+			configs = append(configs, filepath.Join(home, ".gitconfig"))
+		}
+	}
+	if repo != nil {
+		configs = append(configs, filepath.Join(repo.CommonDir, "config"))
+	}
+
+	// later files override earlier ones, as in git
+	excludesFile := ""
+	for _, config := range configs {
+		if value, ok := readConfigValue(config, "core", "excludesfile"); ok {
+			excludesFile = value
+		}
+	}
+	if excludesFile == "" {
+		if xdg == "" {
+			return ""
+		}
+		return filepath.Join(xdg, "git", "ignore")
+	}
+	if rest, ok := strings.CutPrefix(excludesFile, "~/"); ok && home != "" {
+		excludesFile = filepath.Join(home, rest)
+	}
+	return excludesFile
+}
+
+// readConfigValue returns the last value of section.key in a git config
+// file. Section and key names are case-insensitive; subsections and includes
+// are not supported.
+func readConfigValue(path string, section string, key string) (string, bool) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+
+	value, found := "", false
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			end := strings.Index(line, "]")
+			if end < 0 {
+				continue
+			}
+			current = strings.ToLower(strings.TrimSpace(line[1:end]))
+			line = strings.TrimSpace(line[end+1:])
+			if line == "" {
+				continue
+			}
+		}
+		if current != section {
+			continue
+		}
+		name, rest, hasValue := strings.Cut(line, "=")
+		if !strings.EqualFold(strings.TrimSpace(name), key) {
+			continue
+		}
+		if !hasValue {
+			// a bare key is a boolean, which is not a path
+			continue
+		}
+		value, found = parseConfigValue(rest), true
+	}
+	return value, found
+}
+
+// parseConfigValue unquotes a config value and strips its comment.
+func parseConfigValue(raw string) string {
+	var b strings.Builder
+	quoted := false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case c == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(raw[i])
+			}
+		case (c == '#' || c == ';') && !quoted:
+			return strings.TrimSpace(b.String())
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
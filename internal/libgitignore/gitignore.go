@@ -16,101 +16,247 @@ type IgnorePattern struct {
 	Raw         string
 	Dir         string
 	AnchorSlash bool
-}
+	// DirOnly is set for a pattern with a trailing slash, which only matches directories
+	DirOnly bool
+	// Source is the ignore file the pattern was read from, "" for lines given directly
+	Source string
 
-type GitIgnore struct {
-	Root     string
-	patterns []*IgnorePattern
+	anchored bool
+	kind     matchKind
+	// literal is the name or path a matchLiteral or matchSuffix pattern compares
+	literal string
 }
 
-func NewGitIgnore() *GitIgnore {
-	return &GitIgnore{}
+// matchKind selects how a pattern is matched. Most patterns in the wild are
+// plain names or "*.ext", which need no regexp.
+type matchKind int
+
+const (
+	matchRegexp matchKind = iota
+	// matchLiteral compares the name, or the path when anchored
+	matchLiteral
+	// matchSuffix compares the end of the name, for "*.ext"
+	matchSuffix
+)
+
+// classify picks the cheapest way to match the glob pattern.
+func classify(pattern string, anchored bool) (matchKind, string) {
+	const special = `*?[\`
+	if !strings.ContainsAny(pattern, special) {
+		return matchLiteral, pattern
+	}
+	if suffix, ok := strings.CutPrefix(pattern, "*"); ok && !anchored && !strings.ContainsAny(suffix, special) {
+		return matchSuffix, suffix
+	}
+	return matchRegexp, ""
 }
 
-func gitignorePatternToRegex(pattern, anchorDir, root string, anchorSlash bool) string {
-	rootAbs, _ := filepath.Abs(root)
-	anchorAbs, _ := filepath.Abs(anchorDir)
-	relDir, err := filepath.Rel(rootAbs, anchorAbs)
-	if err != nil {
-		relDir = ""
-	}
-	relDir = filepath.ToSlash(relDir)
-
-	pat := regexp.QuoteMeta(pattern)
-	pat = strings.ReplaceAll(pat, `/\*\*/`, `(?:/[^/]*)*/`)
-	pat = strings.ReplaceAll(pat, `\*\*/`, `(?:.*/)?`)
-	pat = strings.ReplaceAll(pat, `/\*\*`, `(?:/.*)?`)
-	pat = strings.ReplaceAll(pat, `\*\*`, `.*`)
-	pat = strings.ReplaceAll(pat, `\*`, `[^/]*`)
-	pat = strings.ReplaceAll(pat, `\?`, `[^/]`)
-
-	var regex string
-	if anchorSlash {
-		if relDir != "" && relDir != "." {
-			regex = "^" + relDir + "/" + pat + `(/|$)`
+// matches reports whether the pattern matches rel, the slash separated path
+// relative to p.Dir, whose last component is name.
+func (p *IgnorePattern) matches(rel string, name string, kind *pathKind) bool {
+	var ok bool
+	switch p.kind {
+	case matchLiteral:
+		if p.anchored {
+			ok = rel == p.literal
 		} else {
-			regex = "^" + pat + `(/|$)`
+			ok = name == p.literal
 		}
+	case matchSuffix:
+		ok = strings.HasSuffix(name, p.literal)
+	default:
+		ok = p.Regexp.MatchString(rel)
+	}
+	// the file system is only asked when a directory pattern matches
+	return ok && (!p.DirOnly || kind.isDir())
+}
+
+// Matches reports whether the pattern matches path, a file or directory below
+// the directory of the pattern. Patterns of .gitattributes files, which share
+// the glob syntax of ignore files, are matched this way.
+func (p *IgnorePattern) Matches(path string) bool {
+	absPath := filepath.Clean(ToAbsDir(path))
+	rel, ok := relPath(p.Dir, absPath)
+	if !ok {
+		return false
+	}
+	return p.matches(rel, filepath.Base(absPath), &pathKind{path: absPath})
+}
+
+// gitignorePatternToRegex translates a gitignore glob into a regexp matched
+// against a slash separated path relative to the directory of the pattern.
+// An anchored pattern matches from that directory, any other pattern matches
+// the last components of the path at any depth.
+func gitignorePatternToRegex(pattern string, anchored bool) string {
+	var b strings.Builder
+	if anchored {
+		b.WriteString("^")
 	} else {
-		regex = `(^|/)` + pat + `(/|$)`
+		b.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		switch c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				leading := i == 0 || pattern[i-1] == '/'
+				end := i + 2
+				switch {
+				case leading && end == len(pattern):
+					// "**" as the last component matches everything inside
+					b.WriteString(".*")
+					i = end - 1
+					continue
+				case leading && pattern[end] == '/':
+					// "**/" matches zero or more directories
+					b.WriteString("(?:.*/)?")
+					i = end
+					continue
+				}
+				// any other "**" is a regular "*"
+				i = end - 1
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			if class, n := bracketToRegex(pattern[i:]); n > 0 {
+				b.WriteString(class)
+				i += n - 1
+			} else {
+				b.WriteString(`\[`)
+			}
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			} else {
+				b.WriteString(`\\`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// bracketToRegex translates the bracket expression at the start of pattern.
+// n is the length consumed, 0 when the bracket is never closed.
+func bracketToRegex(pattern string) (class string, n int) {
+	var b strings.Builder
+	b.WriteString("[")
+	i := 1
+	if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+		// a bracket never matches the separator, negated or not
+		b.WriteString("^/")
+		i++
 	}
+	first := true
+	for ; i < len(pattern); i++ {
+		c := pattern[i]
+		switch {
+		case c == ']' && !first:
+			b.WriteString("]")
+			return b.String(), i + 1
+		case c == '[' && strings.HasPrefix(pattern[i:], "[:"):
+			end := strings.Index(pattern[i+2:], ":]")
+			if end < 0 {
+				return "", 0
+			}
+			b.WriteString(pattern[i : i+2+end+2])
+			i += 2 + end + 1
+		case c == '\\' && i+1 < len(pattern):
+			i++
+			b.WriteString(`\` + string(pattern[i]))
+		case c == '-':
+			b.WriteString("-")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+		first = false
+	}
+	return "", 0
+}
 
-	return regex
+// trimTrailingSpaces removes the trailing spaces of a line that are not
+// escaped with a backslash.
+func trimTrailingSpaces(line string) string {
+	end := len(line)
+	for end > 0 && line[end-1] == ' ' {
+		backslashes := 0
+		for j := end - 2; j >= 0 && line[j] == '\\'; j-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			break
+		}
+		end--
+	}
+	return line[:end]
 }
 
-func CompileIgnoreLine(raw string, dir string, lineno int, root string) (*IgnorePattern, error) {
+// CompileIgnoreLine compiles one line of an ignore file in dir. It returns
+// nil for blank lines and comments.
+func CompileIgnoreLine(raw string, dir string, lineno int) (*IgnorePattern, error) {
 	absDir := ToAbsDir(dir)
-	absRoot := ToAbsDir(root)
-	line := strings.TrimSpace(strings.TrimRight(raw, "\r"))
+	line := trimTrailingSpaces(strings.TrimRight(raw, "\r"))
 	if line == "" || strings.HasPrefix(line, "#") {
 		return nil, nil
 	}
+	// Raw is the pattern as git reports it, without the trailing spaces
+	pattern := line
 
-	escaped := strings.HasPrefix(raw, `\!`) || strings.HasPrefix(raw, `\#`)
 	negate := false
-	if strings.HasPrefix(line, "!") && !escaped {
+	if strings.HasPrefix(line, "!") {
 		negate = true
 		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
 	}
-	line = unescapeGitignore(line)
 
-	anchorSlash := false
-	if strings.HasPrefix(line, "/") {
-		anchorSlash = true
-		line = line[1:]
+	dirOnly := false
+	if strings.HasSuffix(line, "/") && !strings.HasSuffix(line, `\/`) {
+		dirOnly = true
+		line = strings.TrimRight(line, "/")
 	}
-	if strings.HasSuffix(line, "/") {
-		line += "**"
+	if line == "" {
+		return nil, nil
 	}
-	reStr := gitignorePatternToRegex(line, absDir, absRoot, anchorSlash)
-	re, err := regexp.Compile(reStr)
+
+	// a slash at the beginning or in the middle anchors the pattern to dir
+	anchorSlash := strings.HasPrefix(line, "/")
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	re, err := regexp.Compile(gitignorePatternToRegex(line, anchored))
 	if err != nil {
 		return nil, fmt.Errorf("invalid pattern on line %d: %w", lineno, err)
 	}
+	kind, literal := classify(line, anchored)
 	return &IgnorePattern{
 		Regexp:      re,
 		Negate:      negate,
 		LineNo:      lineno,
-		Raw:         raw,
+		Raw:         pattern,
 		Dir:         absDir,
 		AnchorSlash: anchorSlash,
+		DirOnly:     dirOnly,
+		anchored:    anchored,
+		kind:        kind,
+		literal:     literal,
 	}, nil
 }
 
 func CompileIgnoreLines(lines []string, dir string, startLine int, root string) (*GitIgnore, error) {
 	gi := NewGitIgnore()
 	gi.Root = ToAbsDir(root)
-	var patterns []*IgnorePattern
-	for i, raw := range lines {
-		p, err := CompileIgnoreLine(raw, dir, startLine+i, gi.Root)
-		if err != nil {
-			return nil, err
-		}
-		if p != nil {
-			patterns = append(patterns, p)
-		}
+	patterns, err := compileIgnoreLines(lines, dir, startLine, "", true)
+	if err != nil {
+		return nil, err
 	}
-	gi.patterns = patterns
+	gi.addLayer(ToAbsDir(dir), patterns)
 	return gi, nil
 }
 
@@ -123,33 +269,65 @@ func ToAbsDir(dir string) string {
 }
 
 func AppendIgnoreFileWithDir(gi *GitIgnore, path string, dir string) (*GitIgnore, error) {
-	f, err := os.Open(path)
+	lines, err := readLines(path)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	var lines []string
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
+	patterns, err := compileIgnoreLines(lines, dir, 1, path, true)
+	if err != nil {
 		return nil, err
 	}
-	return AppendIgnoreLinesWithDir(gi, dir, lines...)
+	gi.addLayer(ToAbsDir(dir), patterns)
+	return gi, nil
 }
 
 func AppendIgnoreLinesWithDir(gi *GitIgnore, dir string, lines ...string) (*GitIgnore, error) {
+	patterns, err := compileIgnoreLines(lines, dir, 1, "", true)
+	if err != nil {
+		return nil, err
+	}
+	gi.addLayer(ToAbsDir(dir), patterns)
+	return gi, nil
+}
+
+// compileIgnoreLines compiles the lines of source. An invalid pattern is an
+// error when strict is set, and is skipped otherwise, as git does.
+func compileIgnoreLines(lines []string, dir string, startLine int, source string, strict bool) ([]*IgnorePattern, error) {
+	var patterns []*IgnorePattern
 	for i, raw := range lines {
-		p, err := CompileIgnoreLine(raw, dir, i+1, gi.Root)
+		p, err := CompileIgnoreLine(raw, dir, startLine+i)
 		if err != nil {
+			if !strict {
+				continue
+			}
+			if source != "" {
+				return nil, fmt.Errorf("%s: %w", source, err)
+			}
 			return nil, err
 		}
 		if p != nil {
-			gi.patterns = append(gi.patterns, p)
+			p.Source = source
+			patterns = append(patterns, p)
 		}
 	}
-	return gi, nil
+	return patterns, nil
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
 }
 
 func AppendIgnoreLines(gi *GitIgnore, lines ...string) (*GitIgnore, error) {
@@ -165,55 +343,3 @@ func ParseGitIgnore(path string, root string) (*GitIgnore, error) {
 	absdir := filepath.Dir(path)
 	return AppendIgnoreFileWithDir(gi, path, absdir)
 }
-
-func (gi *GitIgnore) MatchesPathHow(path string) (bool, *IgnorePattern) {
-	absPath := path
-	if !filepath.IsAbs(path) {
-		absPath = filepath.Join(gi.Root, path)
-	}
-	targetRel, err := filepath.Rel(gi.Root, absPath)
-	if err != nil {
-		targetRel = absPath
-	}
-	targetRel = filepath.ToSlash(targetRel)
-	matched := false
-	var last *IgnorePattern
-
-	for _, p := range gi.patterns {
-		dirRel, err := filepath.Rel(p.Dir, absPath)
-		// fmt.Printf("[DEBUG] path=%q, targetRel=%q, pattern=%q, pat.Dir=%q, dirRel=%q, regex=%q\n",
-		// 	path, targetRel, p.Raw, p.Dir, dirRel, p.Regexp.String())
-		if err != nil || strings.HasPrefix(dirRel, "..") {
-			continue
-		}
-		if p.Regexp.MatchString(targetRel) {
-			if p.Negate {
-				if matched {
-					matched = false
-					last = p
-				}
-			} else {
-				matched = true
-				last = p
-			}
-		}
-	}
-	return matched, last
-}
-
-func (gi *GitIgnore) MatchesPath(path string) bool {
-	ok, _ := gi.MatchesPathHow(path)
-	return ok
-}
-func (gi *GitIgnore) Patterns() []*IgnorePattern {
-	return gi.patterns
-}
-
-func unescapeGitignore(line string) string {
-	line = strings.ReplaceAll(line, `\\`, `\`)
-	line = strings.ReplaceAll(line, `\ `, ` `)
-	if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
-		line = line[1:]
-	}
-	return line
-}
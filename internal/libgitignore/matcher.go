@@ -0,0 +1,235 @@
+package libgitignore
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// GitIgnore matches paths against layers of ignore rules. Each layer holds
+// the patterns of one ignore file, bucketed with the directory they are
+// relative to, so a path is only checked against the layers of its own
+// ancestors. With ignoreFiles set, the ignore files of the directories under
+// Root are read on first use, and never for a directory that is ignored.
+type GitIgnore struct {
+	Root string
+
+	// layers hold the rules that do not live in the tree under Root, in
+	// increasing precedence. The ignore files of the tree rank between
+	// layers[:dirLayersAt] and layers[dirLayersAt:].
+	layers      []*ruleLayer
+	dirLayersAt int
+	// ignoreFiles are the names of the ignore files read from each directory
+	ignoreFiles []string
+
+	mu   sync.RWMutex
+	dirs map[string]*dirState
+}
+
+// ruleLayer is the patterns of one ignore file, relative to dir.
+type ruleLayer struct {
+	dir      string
+	patterns []*IgnorePattern
+}
+
+// dirState is what a directory under Root passes on to its entries.
+type dirState struct {
+	// layers are the ignore files of the directories from Root down to this one
+	layers []*ruleLayer
+	// excludedBy is the pattern that ignores this directory or a parent
+	excludedBy *IgnorePattern
+}
+
+// pathKind tells whether a path is a directory, asking the file system at
+// most once and only when a directory-only pattern needs it.
+type pathKind struct {
+	path    string
+	checked bool
+	dir     bool
+}
+
+func (k *pathKind) isDir() bool {
+	if !k.checked {
+		k.checked = true
+		info, err := os.Lstat(k.path)
+		// a path that does not exist may be either
+		k.dir = err != nil || info.IsDir()
+	}
+	return k.dir
+}
+
+func NewGitIgnore() *GitIgnore {
+	return &GitIgnore{}
+}
+
+func (gi *GitIgnore) addLayer(dir string, patterns []*IgnorePattern) {
+	if len(patterns) == 0 {
+		return
+	}
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	gi.layers = append(gi.layers, &ruleLayer{dir: dir, patterns: patterns})
+	// the cached directories may be decided differently now
+	gi.dirs = nil
+}
+
+// MatchesPathHow reports whether path is ignored, and the pattern that
+// decided it. As in git, a path inside an ignored directory is ignored by the
+// pattern of that directory, and a negation cannot re-include it. A trailing
+// slash marks path as a directory; otherwise the file system is asked, and a
+// path that does not exist may be either.
+func (gi *GitIgnore) MatchesPathHow(path string) (bool, *IgnorePattern) {
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(gi.Root, path)
+	}
+	absPath = filepath.Clean(absPath)
+	kind := &pathKind{path: absPath}
+	if strings.HasSuffix(path, "/") {
+		kind.checked, kind.dir = true, true
+	}
+
+	var dirLayers []*ruleLayer
+	if parent := filepath.Dir(absPath); gi.Root != "" && parent != absPath {
+		if _, ok := relPath(gi.Root, parent); ok || parent == gi.Root {
+			state := gi.dirState(parent)
+			if state.excludedBy != nil {
+				return true, state.excludedBy
+			}
+			dirLayers = state.layers
+		}
+	}
+
+	p := gi.match(absPath, kind, dirLayers)
+	if p == nil {
+		return false, nil
+	}
+	return !p.Negate, p
+}
+
+func (gi *GitIgnore) MatchesPath(path string) bool {
+	ok, _ := gi.MatchesPathHow(path)
+	return ok
+}
+
+// Patterns returns the patterns that do not come from the ignore files of the
+// tree, which are read lazily, in increasing precedence.
+func (gi *GitIgnore) Patterns() []*IgnorePattern {
+	var patterns []*IgnorePattern
+	for _, layer := range gi.layers {
+		patterns = append(patterns, layer.patterns...)
+	}
+	return patterns
+}
+
+// match returns the pattern of the highest precedence that matches absPath.
+func (gi *GitIgnore) match(absPath string, kind *pathKind, dirLayers []*ruleLayer) *IgnorePattern {
+	name := filepath.Base(absPath)
+	for i := len(gi.layers) - 1; i >= gi.dirLayersAt; i-- {
+		if p := gi.layers[i].match(absPath, name, kind); p != nil {
+			return p
+		}
+	}
+	for i := len(dirLayers) - 1; i >= 0; i-- {
+		if p := dirLayers[i].match(absPath, name, kind); p != nil {
+			return p
+		}
+	}
+	for i := gi.dirLayersAt - 1; i >= 0; i-- {
+		if p := gi.layers[i].match(absPath, name, kind); p != nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// match returns the last pattern of the layer that matches absPath.
+func (l *ruleLayer) match(absPath string, name string, kind *pathKind) *IgnorePattern {
+	rel, ok := relPath(l.dir, absPath)
+	if !ok {
+		return nil
+	}
+	for i := len(l.patterns) - 1; i >= 0; i-- {
+		if p := l.patterns[i]; p.matches(rel, name, kind) {
+			return p
+		}
+	}
+	return nil
+}
+
+// dirState returns the state of dir, which is Root or below it, computing
+// and caching it and its parents on first use.
+func (gi *GitIgnore) dirState(dir string) *dirState {
+	gi.mu.RLock()
+	state, ok := gi.dirs[dir]
+	gi.mu.RUnlock()
+	if ok {
+		return state
+	}
+
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	if gi.dirs == nil {
+		gi.dirs = map[string]*dirState{}
+	}
+	return gi.computeDirState(dir)
+}
+
+// computeDirState must be called with gi.mu held.
+func (gi *GitIgnore) computeDirState(dir string) *dirState {
+	if state, ok := gi.dirs[dir]; ok {
+		return state
+	}
+
+	var state *dirState
+	if dir == gi.Root {
+		state = &dirState{layers: gi.loadIgnoreFiles(dir, nil)}
+	} else {
+		parent := gi.computeDirState(filepath.Dir(dir))
+		if parent.excludedBy != nil {
+			state = &dirState{excludedBy: parent.excludedBy}
+		} else if p := gi.match(dir, &pathKind{checked: true, dir: true}, parent.layers); p != nil && !p.Negate {
+			state = &dirState{excludedBy: p}
+		} else {
+			state = &dirState{layers: gi.loadIgnoreFiles(dir, parent.layers)}
+		}
+	}
+	gi.dirs[dir] = state
+	return state
+}
+
+// loadIgnoreFiles returns parentLayers followed by the ignore files of dir.
+// Invalid patterns are skipped, as git does.
+func (gi *GitIgnore) loadIgnoreFiles(dir string, parentLayers []*ruleLayer) []*ruleLayer {
+	layers := parentLayers
+	for _, name := range gi.ignoreFiles {
+		path := filepath.Join(dir, name)
+		lines, err := readLines(path)
+		if err != nil {
+			continue
+		}
+		patterns, _ := compileIgnoreLines(lines, dir, 1, path, false)
+		if len(patterns) == 0 {
+			continue
+		}
+		// never append to the slice of the parent, which its siblings share
+		layers = append(layers[:len(layers):len(layers)], &ruleLayer{dir: dir, patterns: patterns})
+	}
+	return layers
+}
+
+// relPath returns path relative to dir with slashes, when path is below dir.
+// It compares strings only: both must be clean and absolute.
+func relPath(dir string, path string) (string, bool) {
+	if dir == string(filepath.Separator) {
+		if len(path) <= 1 || path[0] != filepath.Separator {
+			return "", false
+		}
+		return filepath.ToSlash(path[1:]), true
+	}
+	if len(path) <= len(dir) || path[len(dir)] != filepath.Separator || !strings.HasPrefix(path, dir) {
+		return "", false
+	}
+	return filepath.ToSlash(path[len(dir)+1:]), true
+}
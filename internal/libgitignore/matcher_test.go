@@ -0,0 +1,127 @@
+package libgitignore_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/libgitignore"
+)
+
+func TestGitIgnore_LazyIgnoreFiles(t *testing.T) {
+	isolateGitConfig(t)
+	root := t.TempDir()
+	files := map[string]string{
+		".gitignore":                "node_modules/\n",
+		"node_modules/.gitignore":   "!*\n",
+		"node_modules/pkg/index.js": "",
+		"sub/a.txt":                 "",
+		"sub/b.go":                  "",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gi, err := libgitignore.GenerateIntegratedGitIgnore(true, root, []string{})
+	if err != nil {
+		t.Fatalf("GenerateIntegratedGitIgnore: %v", err)
+	}
+
+	// written after the matcher was built, and read on first use
+	if err := os.WriteFile(filepath.Join(root, "sub", ".gitignore"), []byte("*.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		expected bool
+		raw      string
+	}{
+		{"sub/a.txt", true, "*.txt"},
+		{"sub/b.go", false, ""},
+		// the .gitignore of an ignored directory is never read
+		{"node_modules/pkg/index.js", true, "node_modules/"},
+		{"node_modules/.gitignore", true, "node_modules/"},
+	}
+	for _, tc := range tests {
+		matched, pat := gi.MatchesPathHow(tc.path)
+		if matched != tc.expected {
+			t.Errorf("MatchesPathHow(%q) = %v; want %v", tc.path, matched, tc.expected)
+		}
+		if matched && pat.Raw != tc.raw {
+			t.Errorf("MatchesPathHow(%q) matched %q; want %q", tc.path, pat.Raw, tc.raw)
+		}
+	}
+}
+
+func TestGitIgnore_LiteralPatterns(t *testing.T) {
+	patterns := []string{
+		"Makefile",
+		"*.o",
+		"/abs.txt",
+		"docs/NOTES",
+		"*.tar.gz",
+		"!keep.o",
+	}
+	gi, err := libgitignore.CompileIgnoreLines(patterns, "./", 1, "./")
+	if err != nil {
+		t.Fatalf("failed to compile patterns: %v", err)
+	}
+
+	tests := []struct {
+		path   string
+		expect bool
+	}{
+		{"Makefile", true},
+		{"src/Makefile", true},
+		{"Makefile.am", false},
+		{"xMakefile", false},
+		{"main.o", true},
+		{"lib/main.o", true},
+		{".o", true},
+		{"main.oo", false},
+		{"keep.o", false},
+		{"abs.txt", true},
+		{"src/abs.txt", false},
+		{"docs/NOTES", true},
+		{"src/docs/NOTES", false},
+		{"dist/app.tar.gz", true},
+		{"dist/app.tar", false},
+	}
+	for _, tt := range tests {
+		if got := gi.MatchesPath(tt.path); got != tt.expect {
+			t.Errorf("MatchesPath(%q) = %v; want %v", tt.path, got, tt.expect)
+		}
+	}
+}
+
+func TestIgnorePattern_Matches(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.pb.go", "api/v1/service.pb.go", true},
+		{"*.pb.go", "api/v1/service.go", false},
+		{"/gen/*", "gen/a.go", true},
+		{"/gen/*", "sub/gen/a.go", false},
+		{"vendor/**", "vendor/pkg/lib.go", true},
+		{"docs", "../docs", false},
+	}
+	for _, tt := range tests {
+		p, err := libgitignore.CompileIgnoreLine(tt.pattern, root, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Matches(filepath.Join(root, tt.path)); got != tt.want {
+			t.Errorf("%q.Matches(%q) = %v; want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
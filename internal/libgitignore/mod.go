@@ -5,44 +5,83 @@ import (
 	"path/filepath"
 )
 
+// GenerateIntegratedGitIgnore collects the ignore rules that apply under root,
+// from the lowest precedence to the highest:
+//
+//   - git's global excludes file (core.excludesFile or $XDG_CONFIG_HOME/git/ignore)
+//   - .git/info/exclude of the repository root belongs to
+//   - .gitignore files of the parent directories up to the repository root
+//   - .gitignore and .arkignore files of root and its subdirectories, read
+//     on first use
+//   - additionallyFileList, in order
+//
+// The git sources are read only when allowGitignore is set.
 func GenerateIntegratedGitIgnore(allowGitignore bool, root string, additionallyFileList []string) (*GitIgnore, error) {
 	absRoot := ToAbsDir(root)
 	gi := NewGitIgnore()
 	gi.Root = absRoot
 
-	err := filepath.WalkDir(absRoot, func(path string, d os.DirEntry, err error) error {
-		if err != nil {
-			return err
+	if allowGitignore {
+		repo := findGitRepository(absRoot)
+		topLevel := absRoot
+		if repo != nil {
+			topLevel = repo.TopLevel
 		}
-		if d.IsDir() {
-			gitignore := filepath.Join(path, ".gitignore")
-			arkignore := filepath.Join(path, ".arkignore")
-			if _, err := os.Stat(gitignore); allowGitignore && err == nil {
-				_, err := AppendIgnoreFileWithDir(gi, gitignore, path)
-				if err != nil {
-					return err
-				}
-			} else if _, err := os.Stat(arkignore); err == nil {
-				_, err := AppendIgnoreFileWithDir(gi, arkignore, path)
-				if err != nil {
-					return err
+		if excludesFile := globalExcludesFile(repo); excludesFile != "" {
+			if err := appendIgnoreFileIfExists(gi, excludesFile, topLevel); err != nil {
+				return nil, err
+			}
+		}
+		if repo != nil {
+			exclude := filepath.Join(repo.CommonDir, "info", "exclude")
+			if err := appendIgnoreFileIfExists(gi, exclude, topLevel); err != nil {
+				return nil, err
+			}
+			for _, dir := range parentDirs(topLevel, absRoot) {
+				if err := appendIgnoreFileIfExists(gi, filepath.Join(dir, ".gitignore"), dir); err != nil {
+					return nil, err
 				}
 			}
 		}
-		return nil
-	})
-	if err != nil {
-		return nil, err
 	}
 
-	if len(additionallyFileList) != 0 {
-		for _, ignoreFilePath := range additionallyFileList {
-			_, err := AppendIgnoreFileWithDir(gi, ignoreFilePath, absRoot)
-			if err != nil {
-				return nil, err
-			}
+	// the .gitignore and .arkignore files under root are read while matching,
+	// .arkignore after .gitignore so it can override it
+	gi.dirLayersAt = len(gi.layers)
+	if allowGitignore {
+		gi.ignoreFiles = append(gi.ignoreFiles, ".gitignore")
+	}
+	gi.ignoreFiles = append(gi.ignoreFiles, ".arkignore")
+
+	for _, ignoreFilePath := range additionallyFileList {
+		if _, err := AppendIgnoreFileWithDir(gi, ignoreFilePath, absRoot); err != nil {
+			return nil, err
 		}
 	}
 
 	return gi, nil
 }
+
+func appendIgnoreFileIfExists(gi *GitIgnore, path string, dir string) error {
+	if info, err := os.Stat(path); err != nil || info.IsDir() {
+		return nil
+	}
+	_, err := AppendIgnoreFileWithDir(gi, path, dir)
+	return err
+}
+
+// parentDirs lists the directories from top down to the parent of dir, which
+// must be below top.
+func parentDirs(top string, dir string) []string {
+	var dirs []string
+	for cur := dir; cur != top; {
+		next := filepath.Dir(cur)
+		if next == cur {
+			// dir is not below top
+			return nil
+		}
+		cur = next
+		dirs = append([]string{cur}, dirs...)
+	}
+	return dirs
+}
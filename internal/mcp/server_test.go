@@ -153,6 +153,7 @@ func TestHandleListTools(t *testing.T) {
 		"get_file_info",
 		"get_project_stats",
 		"get_files_arklite",
+		"get_outline",
 	}
 
 	if len(result.Tools) != len(expectedTools) {
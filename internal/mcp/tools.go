@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/magicdrive/ark/internal/commandline"
+	"github.com/magicdrive/ark/internal/core"
 )
 
 // ToolsHandler handles all MCP tools
@@ -25,6 +26,30 @@ func NewToolsHandler(rootDir string, opt *commandline.Option) *ToolsHandler {
 	}
 }
 
+// options returns a copy of the base options, with links confined to the
+// served root.
+func (h *ToolsHandler) options() commandline.Option {
+	opt := *h.opt
+	opt.SymlinkRoot = h.rootDir
+	return opt
+}
+
+// resolvePath joins a relative path to the served root, and refuses a path
+// that is outside of it, or goes through a link leading out of it.
+func (h *ToolsHandler) resolvePath(path string) (string, error) {
+	fullPath := filepath.Clean(path)
+	if !filepath.IsAbs(fullPath) {
+		fullPath = filepath.Join(h.rootDir, fullPath)
+	}
+	if !core.IsWithin(h.rootDir, fullPath) {
+		return "", fmt.Errorf("%s is outside the served root", path)
+	}
+	if link := core.LinkEscapes(h.rootDir, fullPath); link != "" {
+		return "", fmt.Errorf("%s links outside the served root", link)
+	}
+	return fullPath, nil
+}
+
 // ListTools returns all available tools
 func (h *ToolsHandler) ListTools() []Tool {
 	return []Tool{
@@ -62,11 +87,26 @@ func (h *ToolsHandler) ListTools() []Tool {
 						"description": "Remove code comments",
 						"default":     false,
 					},
+					"keepDocComments": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Keep doc comments when removing code comments",
+						"default":     false,
+					},
+					"compactGo": map[string]interface{}{
+						"type":        "string",
+						"description": "Compact Go files: 'bodies' elides function bodies, 'exported' keeps only the exported API",
+						"enum":        []string{"off", "bodies", "exported"},
+						"default":     "off",
+					},
 					"withLineNumbers": map[string]interface{}{
 						"type":        "boolean",
 						"description": "Include line numbers",
 						"default":     true,
 					},
+					"maxLines": map[string]interface{}{
+						"type":        "integer",
+						"description": "Keep the first and last lines of longer files with a truncation marker in between (0 = unlimited, defaults to --max-lines-per-file)",
+					},
 				},
 				"required": []string{"path"},
 			},
@@ -235,6 +275,17 @@ func (h *ToolsHandler) ListTools() []Tool {
 						"description": "Remove code comments",
 						"default":     false,
 					},
+					"keepDocComments": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Keep doc comments when removing code comments",
+						"default":     false,
+					},
+					"compactGo": map[string]interface{}{
+						"type":        "string",
+						"description": "Compact Go files: 'bodies' elides function bodies, 'exported' keeps only the exported API",
+						"enum":        []string{"off", "bodies", "exported"},
+						"default":     "off",
+					},
 					"maxFiles": map[string]interface{}{
 						"type":        "integer",
 						"description": "Maximum number of files to process",
@@ -244,6 +295,25 @@ func (h *ToolsHandler) ListTools() []Tool {
 				"required": []string{"paths"},
 			},
 		},
+		{
+			Name:        "get_outline",
+			Description: "Get the top-level declarations (classes, functions, methods) of a file or directory with their line ranges, as a table of contents before reading files",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File or directory path relative to root",
+					},
+					"maxFiles": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of files to outline",
+						"default":     200,
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
 	}
 }
 
@@ -264,6 +334,8 @@ func (h *ToolsHandler) CallTool(name string, arguments map[string]interface{}) (
 		return h.getProjectStats(arguments)
 	case "get_files_arklite":
 		return h.getFilesArklite(arguments)
+	case "get_outline":
+		return h.getOutline(arguments)
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", name)
 	}
@@ -275,7 +347,13 @@ func (h *ToolsHandler) getDirectoryTree(args map[string]interface{}) (*CallToolR
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 	tree, err := GenerateDirectoryTreeJSON(fullPath)
 	if err != nil {
 		return &CallToolResult{
@@ -295,10 +373,16 @@ func (h *ToolsHandler) getFileContent(args map[string]interface{}) (*CallToolRes
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 
 	// Create option based on parameters
-	opt := *h.opt // Copy base options
+	opt := h.options() // Copy base options
 	if maskSecrets, ok := args["maskSecrets"].(bool); ok {
 		if maskSecrets {
 			opt.MaskSecretsFlagValue = "on"
@@ -309,6 +393,14 @@ func (h *ToolsHandler) getFileContent(args map[string]interface{}) (*CallToolRes
 	if deleteComments, ok := args["deleteComments"].(bool); ok {
 		opt.DeleteCommentsFlag = deleteComments
 	}
+	if keepDocComments, ok := args["keepDocComments"].(bool); ok {
+		opt.KeepDocCommentsFlag = keepDocComments
+	}
+	if compactGo, ok := args["compactGo"].(string); ok {
+		if err := opt.CompactGo.Set(compactGo); err != nil {
+			return nil, fmt.Errorf("compactGo %w", err)
+		}
+	}
 	if withLineNumbers, ok := args["withLineNumbers"].(bool); ok {
 		if withLineNumbers {
 			opt.WithLineNumberFlagValue = "on"
@@ -316,6 +408,12 @@ func (h *ToolsHandler) getFileContent(args map[string]interface{}) (*CallToolRes
 			opt.WithLineNumberFlagValue = "off"
 		}
 	}
+	if maxLines, ok := args["maxLines"].(float64); ok {
+		if maxLines < 0 {
+			return nil, fmt.Errorf("maxLines must not be negative: %d", int(maxLines))
+		}
+		opt.MaxLinesPerFile = int(maxLines)
+	}
 
 	content, err := ReadAndProcessFile(fullPath, &opt)
 	if err != nil {
@@ -336,10 +434,16 @@ func (h *ToolsHandler) listFiles(args map[string]interface{}) (*CallToolResult,
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 
 	// Create option based on parameters
-	opt := *h.opt // Copy base options
+	opt := h.options() // Copy base options
 	if includeExt, ok := args["includeExt"].(string); ok {
 		opt.IncludeExt = includeExt
 	}
@@ -400,7 +504,13 @@ func (h *ToolsHandler) searchInFiles(args map[string]interface{}) (*CallToolResu
 		return nil, fmt.Errorf("query parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 
 	isRegex := false
 	if val, ok := args["isRegex"].(bool); ok {
@@ -413,7 +523,7 @@ func (h *ToolsHandler) searchInFiles(args map[string]interface{}) (*CallToolResu
 	}
 
 	// Create option based on parameters
-	opt := *h.opt // Copy base options
+	opt := h.options() // Copy base options
 	if includeExt, ok := args["includeExt"].(string); ok {
 		opt.IncludeExt = includeExt
 	}
@@ -457,7 +567,13 @@ func (h *ToolsHandler) getFileInfo(args map[string]interface{}) (*CallToolResult
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 
 	info, err := os.Stat(fullPath)
 	if err != nil {
@@ -498,10 +614,16 @@ func (h *ToolsHandler) getProjectStats(args map[string]interface{}) (*CallToolRe
 		return nil, fmt.Errorf("path parameter is required")
 	}
 
-	fullPath := filepath.Join(h.rootDir, path)
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
 
 	// Create option based on parameters
-	opt := *h.opt // Copy base options
+	opt := h.options() // Copy base options
 	if ignoreDotfiles, ok := args["ignoreDotfiles"].(bool); ok {
 		if ignoreDotfiles {
 			opt.IgnoreDotFileFlagValue = "on"
@@ -566,7 +688,7 @@ func (h *ToolsHandler) getFilesArklite(args map[string]interface{}) (*CallToolRe
 	}
 
 	// Create option based on parameters
-	opt := *h.opt // Copy base options
+	opt := h.options() // Copy base options
 	opt.OutputFormatValue = "arklite"
 
 	if maskSecrets, ok := args["maskSecrets"].(bool); ok {
@@ -579,11 +701,26 @@ func (h *ToolsHandler) getFilesArklite(args map[string]interface{}) (*CallToolRe
 	if deleteComments, ok := args["deleteComments"].(bool); ok {
 		opt.DeleteCommentsFlag = deleteComments
 	}
+	if keepDocComments, ok := args["keepDocComments"].(bool); ok {
+		opt.KeepDocCommentsFlag = keepDocComments
+	}
+	if compactGo, ok := args["compactGo"].(string); ok {
+		if err := opt.CompactGo.Set(compactGo); err != nil {
+			return nil, fmt.Errorf("compactGo %w", err)
+		}
+	}
 
 	// Convert relative paths to absolute
 	fullPaths := make([]string, len(paths))
 	for i, path := range paths {
-		fullPaths[i] = filepath.Join(h.rootDir, path)
+		fullPath, err := h.resolvePath(path)
+		if err != nil {
+			return &CallToolResult{
+				Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+				IsError: true,
+			}, nil
+		}
+		fullPaths[i] = fullPath
 	}
 
 	content, err := GenerateArkliteForFiles(fullPaths, &opt)
@@ -598,3 +735,35 @@ func (h *ToolsHandler) getFilesArklite(args map[string]interface{}) (*CallToolRe
 		Content: []Content{{Type: "text", Text: content}},
 	}, nil
 }
+
+func (h *ToolsHandler) getOutline(args map[string]interface{}) (*CallToolResult, error) {
+	path, ok := args["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("path parameter is required")
+	}
+
+	maxFiles := 200
+	if val, ok := args["maxFiles"].(float64); ok {
+		maxFiles = int(val)
+	}
+
+	fullPath, err := h.resolvePath(path)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+	opt := h.options()
+	content, err := GenerateOutline(fullPath, &opt, maxFiles)
+	if err != nil {
+		return &CallToolResult{
+			Content: []Content{{Type: "text", Text: fmt.Sprintf("Error: %v", err)}},
+			IsError: true,
+		}, nil
+	}
+
+	return &CallToolResult{
+		Content: []Content{{Type: "text", Text: content}},
+	}, nil
+}
@@ -2,6 +2,7 @@ package mcp
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -53,6 +54,7 @@ func TestListTools(t *testing.T) {
 		"get_file_info",
 		"get_project_stats",
 		"get_files_arklite",
+		"get_outline",
 	}
 
 	if len(tools) != len(expectedTools) {
@@ -230,14 +232,13 @@ func TestSearchInFiles_MissingQuery(t *testing.T) {
 }
 
 func TestGetFilesArklite(t *testing.T) {
-	handler := createTestToolsHandler(t)
-
 	// Create temporary files for testing
 	tmpDir, err := os.MkdirTemp("", "test_arklite_")
 	if err != nil {
 		t.Fatalf("Failed to create temp dir: %v", err)
 	}
 	defer os.RemoveAll(tmpDir)
+	handler := NewToolsHandler(tmpDir, createTestToolsHandler(t).opt)
 
 	// Create test files
 	file1 := filepath.Join(tmpDir, "test1.txt")
@@ -251,9 +252,9 @@ func TestGetFilesArklite(t *testing.T) {
 		t.Fatalf("Failed to create test file 2: %v", err)
 	}
 
-	// Get relative paths
-	relPath1, _ := filepath.Rel(".", file1)
-	relPath2, _ := filepath.Rel(".", file2)
+	// Get paths relative to the served root
+	relPath1, _ := filepath.Rel(tmpDir, file1)
+	relPath2, _ := filepath.Rel(tmpDir, file2)
 
 	args := map[string]interface{}{
 		"paths": []interface{}{relPath1, relPath2},
@@ -345,3 +346,178 @@ func TestGetProjectStatsViaTool(t *testing.T) {
 		}
 	}
 }
+
+func TestGetOutline(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, "shop.py"), []byte("class Shop:\n    def buy(self):\n        pass\n"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "notes.txt"), []byte("notes\n"), 0644)
+	handler := NewToolsHandler(tempDir, createTestToolsHandler(t).opt)
+
+	result, err := handler.CallTool("get_outline", map[string]interface{}{"path": "."})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Fatalf("Result indicates error: %s", result.Content[0].Text)
+	}
+
+	content := result.Content[0].Text
+	if !strings.Contains(content, "=== shop.py (3 lines) ===\n1-3: class Shop\n  2-3: def buy(self)\n") {
+		t.Errorf("Unexpected outline:\n%s", content)
+	}
+	if !strings.Contains(content, "=== notes.txt (1 lines) ===") {
+		t.Errorf("Files without declarations must be listed:\n%s", content)
+	}
+
+	if _, err := handler.CallTool("get_outline", map[string]interface{}{}); err == nil {
+		t.Error("Expected error for a missing path")
+	}
+}
+
+func TestGetFileContent_FileLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	var long strings.Builder
+	for i := 1; i <= 10; i++ {
+		fmt.Fprintf(&long, "line %d\n", i)
+	}
+	os.WriteFile(filepath.Join(tempDir, "long.txt"), []byte(long.String()), 0644)
+	os.WriteFile(filepath.Join(tempDir, "big.txt"), []byte(strings.Repeat("a", 2048)), 0644)
+
+	opt := *createTestToolsHandler(t).opt
+	opt.MaxFileSizeValue = "1K"
+	opt.MaxFileSize = "1K"
+	handler := NewToolsHandler(tempDir, &opt)
+
+	result, err := handler.CallTool("get_file_content", map[string]interface{}{"path": "long.txt", "maxLines": float64(4)})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "line 1\nline 2\n... [truncated 6 lines] ...\nline 9\nline 10\n"
+	if result.IsError || result.Content[0].Text != expected {
+		t.Errorf("Expected %q, got %q", expected, result.Content[0].Text)
+	}
+
+	result, err = handler.CallTool("get_file_content", map[string]interface{}{"path": "big.txt"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].Text, "--max-file-size 1K") {
+		t.Errorf("Expected a --max-file-size error, got %q", result.Content[0].Text)
+	}
+
+	// numbered lines after the marker keep their line numbers on disk
+	opt.MaxLinesPerFile = 4
+	opt.WithLineNumberFlag = "on"
+	content, err := ReadAndProcessFile(filepath.Join(tempDir, "long.txt"), &opt)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = "1: line 1\n2: line 2\n... [truncated 6 lines] ...\n9: line 9\n10: line 10\n11: "
+	if content != expected {
+		t.Errorf("Expected %q, got %q", expected, content)
+	}
+}
+
+func TestTools_LinksOutsideRoot(t *testing.T) {
+	outside := t.TempDir()
+	os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("top secret"), 0644)
+
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "inside.txt"), []byte("visible"), 0644)
+	os.Mkdir(filepath.Join(root, "sub"), 0755)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skipf("symlinks are not supported: %v", err)
+	}
+	os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "secret-link.txt"))
+	os.Symlink(filepath.Join(root, "sub"), filepath.Join(root, "sub-link"))
+
+	opt := *createTestToolsHandler(t).opt
+	opt.SymlinksValue = "follow"
+	opt.Symlinks = "follow"
+	handler := NewToolsHandler(root, &opt)
+
+	for _, path := range []string{"escape/secret.txt", "secret-link.txt"} {
+		result, err := handler.CallTool("get_file_content", map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !result.IsError || !strings.Contains(result.Content[0].Text, "links outside the served root") {
+			t.Errorf("Expected %s to be refused, got %q", path, result.Content[0].Text)
+		}
+	}
+
+	result, err := handler.CallTool("search_in_files", map[string]interface{}{"path": ".", "query": "secret"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(result.Content[0].Text, "top secret") {
+		t.Errorf("Search must not read through a link out of the root: %q", result.Content[0].Text)
+	}
+
+	// a link that stays inside the root is followed
+	result, err = handler.CallTool("get_file_info", map[string]interface{}{"path": "sub-link"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.IsError {
+		t.Errorf("Expected sub-link to be readable, got %q", result.Content[0].Text)
+	}
+
+	bounded := handler.options()
+	files, err := ListFilteredFiles(root, &bounded)
+	if err != nil {
+		t.Fatalf("ListFilteredFiles failed: %v", err)
+	}
+	if strings.Join(files, ",") != "inside.txt" {
+		t.Errorf("Expected only inside.txt, got %v", files)
+	}
+}
+
+func TestTools_PathsOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	outside := filepath.Join(parent, "outside")
+	os.MkdirAll(filepath.Join(root, "sub"), 0755)
+	os.MkdirAll(outside, 0755)
+	os.WriteFile(filepath.Join(root, "inside.txt"), []byte("visible"), 0644)
+	os.WriteFile(filepath.Join(outside, "x.txt"), []byte("top secret"), 0644)
+	handler := NewToolsHandler(root, createTestToolsHandler(t).opt)
+
+	paths := []string{
+		"../outside/x.txt",
+		"sub/../../outside/x.txt",
+		"..",
+		filepath.Join(outside, "x.txt"),
+		outside,
+	}
+	for _, path := range paths {
+		calls := map[string]map[string]interface{}{
+			"get_directory_tree": {"path": path},
+			"get_file_content":   {"path": path},
+			"list_files":         {"path": path},
+			"search_in_files":    {"path": path, "query": "secret"},
+			"get_file_info":      {"path": path},
+			"get_project_stats":  {"path": path},
+			"get_files_arklite":  {"paths": []interface{}{"inside.txt", path}},
+			"get_outline":        {"path": path},
+		}
+		for tool, args := range calls {
+			result, err := handler.CallTool(tool, args)
+			if err != nil {
+				t.Fatalf("%s(%s): unexpected error: %v", tool, path, err)
+			}
+			if !result.IsError || !strings.Contains(result.Content[0].Text, "outside the served root") {
+				t.Errorf("%s(%s): expected to be refused, got %q", tool, path, result.Content[0].Text)
+			}
+		}
+	}
+
+	// an absolute path inside the root is served
+	result, err := handler.CallTool("get_file_content", map[string]interface{}{"path": filepath.Join(root, "inside.txt")})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.IsError || !strings.Contains(result.Content[0].Text, "visible") {
+		t.Errorf("Expected inside.txt to be served, got %q", result.Content[0].Text)
+	}
+}
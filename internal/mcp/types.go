@@ -145,7 +145,10 @@ type GetFileContentParams struct {
 	Path            string `json:"path"`
 	MaskSecrets     bool   `json:"maskSecrets,omitempty"`
 	DeleteComments  bool   `json:"deleteComments,omitempty"`
+	KeepDocComments bool   `json:"keepDocComments,omitempty"`
+	CompactGo       string `json:"compactGo,omitempty"`
 	WithLineNumbers bool   `json:"withLineNumbers,omitempty"`
+	MaxLines        int    `json:"maxLines,omitempty"`
 }
 
 type ListFilesParams struct {
@@ -184,8 +187,15 @@ type GetProjectStatsParams struct {
 }
 
 type GetFilesArkliteParams struct {
-	Paths          []string `json:"paths"`
-	MaskSecrets    bool     `json:"maskSecrets,omitempty"`
-	DeleteComments bool     `json:"deleteComments,omitempty"`
-	MaxFiles       int      `json:"maxFiles,omitempty"`
+	Paths           []string `json:"paths"`
+	MaskSecrets     bool     `json:"maskSecrets,omitempty"`
+	DeleteComments  bool     `json:"deleteComments,omitempty"`
+	KeepDocComments bool     `json:"keepDocComments,omitempty"`
+	CompactGo       string   `json:"compactGo,omitempty"`
+	MaxFiles        int      `json:"maxFiles,omitempty"`
+}
+
+type GetOutlineParams struct {
+	Path     string `json:"path"`
+	MaxFiles int    `json:"maxFiles,omitempty"`
 }
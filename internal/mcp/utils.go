@@ -9,7 +9,6 @@ import (
 
 	"github.com/magicdrive/ark/internal/commandline"
 	"github.com/magicdrive/ark/internal/core"
-	"github.com/magicdrive/ark/internal/secrets"
 )
 
 // GenerateDirectoryTreeJSON wraps core.GenerateTreeJSONString
@@ -47,34 +46,65 @@ func GenerateDirectoryTreeJSON(path string) (string, error) {
 
 // ReadAndProcessFile reads a file and applies processing options
 func ReadAndProcessFile(path string, opt *commandline.Option) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if core.ExceedsMaxFileSize(info.Size(), opt) {
+		return "", fmt.Errorf("file is %d bytes, over --max-file-size %s", info.Size(), opt.MaxFileSizeValue)
+	}
+
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return "", err
 	}
 
+	data, detected, err := core.DecodeText(data)
+	if err != nil {
+		return "", err
+	}
+
 	// Skip non-UTF8 if requested
-	if opt.SkipNonUTF8Flag && core.IsBinary(data) {
+	if opt.SkipNonUTF8Flag && (core.IsBinary(data) || !core.IsUTF8Encoding(detected.Encoding)) {
 		return "", fmt.Errorf("file is binary or non-UTF8")
 	}
 
+	// Truncate long files, counting the lines on disk
+	data = []byte(core.TruncateLines(string(data), opt.MaxLinesPerFile))
+
 	// Delete comments if requested
 	if opt.DeleteCommentsFlag {
-		data = core.DeleteComments(data, path)
+		data = core.DeleteComments(data, path, opt.KeepDocCommentsFlag)
 	}
+	data = core.CompactGo(data, path, opt.CompactGo)
 
 	content := string(data)
 
 	// Mask secrets if requested
 	if opt.MaskSecretsFlag.Bool() {
-		content = secrets.MaskAll(content)
+		rules := opt.SecretRuleSet()
+		content = rules.MaskFile(path, content)
+		if rules.Vault != nil {
+			if err := rules.Vault.Save(); err != nil {
+				return "", err
+			}
+		}
 	}
 
 	// Add line numbers if requested
 	if opt.WithLineNumberFlag.Bool() {
 		lines := strings.Split(content, "\n")
 		var numberedLines []string
-		for i, line := range lines {
-			numberedLines = append(numberedLines, fmt.Sprintf("%d: %s", i+1, line))
+		lineNumber := 1
+		for _, line := range lines {
+			// the tail keeps the line numbers it has on disk
+			if n, ok := core.TruncatedLines(line); ok {
+				numberedLines = append(numberedLines, line)
+				lineNumber += n
+				continue
+			}
+			numberedLines = append(numberedLines, fmt.Sprintf("%d: %s", lineNumber, line))
+			lineNumber++
 		}
 		content = strings.Join(numberedLines, "\n")
 	}
@@ -84,49 +114,29 @@ func ReadAndProcessFile(path string, opt *commandline.Option) (string, error) {
 
 // ListFilteredFiles lists files in a directory with filtering
 func ListFilteredFiles(path string, opt *commandline.Option) ([]string, error) {
-	var files []string
-
-	err := filepath.Walk(path, func(currentPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			if !core.CanBoaded(opt, currentPath) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Apply filtering
-		if !core.CanBoaded(opt, currentPath) {
-			return nil
-		}
-
-		// Skip hidden files if requested
-		if opt.IgnoreDotFileFlag.Bool() && core.IsHiddenFile(info.Name()) {
-			return nil
-		}
+	tree, err := core.WalkTree(path, opt)
+	if err != nil {
+		return nil, err
+	}
 
+	var files []string
+	for _, fpath := range tree.ReadableFiles() {
 		// Skip non-UTF8 files if requested
 		if opt.SkipNonUTF8Flag {
-			data, err := os.ReadFile(currentPath)
+			data, err := os.ReadFile(fpath)
 			if err == nil && core.IsBinary(data) {
-				return nil
+				continue
 			}
 		}
 
 		// Make path relative to the root
-		relPath, err := filepath.Rel(path, currentPath)
+		relPath, err := filepath.Rel(path, fpath)
 		if err == nil {
 			files = append(files, relPath)
 		}
+	}
 
-		return nil
-	})
-
-	return files, err
+	return files, nil
 }
 
 // SearchInFiles searches for text within files
@@ -142,43 +152,21 @@ func SearchInFiles(path, query string, isRegex bool, maxResults int, opt *comman
 		}
 	}
 
-	count := 0
-	err = filepath.Walk(path, func(currentPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-
-		if count >= maxResults {
-			return fmt.Errorf("max results reached")
-		}
-
-		// Skip directories
-		if info.IsDir() {
-			if !core.CanBoaded(opt, currentPath) {
-				return filepath.SkipDir
-			}
-			return nil
-		}
-
-		// Apply filtering
-		if !core.CanBoaded(opt, currentPath) {
-			return nil
-		}
-
-		// Skip hidden files if requested
-		if opt.IgnoreDotFileFlag.Bool() && core.IsHiddenFile(info.Name()) {
-			return nil
-		}
+	tree, err := core.WalkTree(path, opt)
+	if err != nil {
+		return "", err
+	}
 
+	for _, fpath := range tree.ReadableFiles() {
 		// Read file content
-		data, err := os.ReadFile(currentPath)
+		data, err := os.ReadFile(fpath)
 		if err != nil {
-			return nil
+			continue
 		}
 
 		// Skip binary files
 		if core.IsBinary(data) {
-			return nil
+			continue
 		}
 
 		content := string(data)
@@ -194,21 +182,14 @@ func SearchInFiles(path, query string, isRegex bool, maxResults int, opt *comman
 			}
 
 			if match {
-				relPath, _ := filepath.Rel(path, currentPath)
+				relPath, _ := filepath.Rel(path, fpath)
 				result := fmt.Sprintf("%s:%d:%s", relPath, lineNum+1, line)
 				results = append(results, result)
-				count++
-				if count >= maxResults {
-					return fmt.Errorf("max results reached")
+				if len(results) >= maxResults {
+					return strings.Join(results, "\n"), nil
 				}
 			}
 		}
-
-		return nil
-	})
-
-	if err != nil && err.Error() != "max results reached" {
-		return "", err
 	}
 
 	return strings.Join(results, "\n"), nil
@@ -319,52 +300,36 @@ func GetProjectStats(path string, opt *commandline.Option) (map[string]interface
 		"extensionStats":   map[string]int{},
 	}
 
-	err := filepath.Walk(path, func(currentPath string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Skip errors
-		}
-
-		if info.IsDir() {
-			if !core.CanBoaded(opt, currentPath) {
-				return filepath.SkipDir
-			}
-			if currentPath != path { // Don't count root directory
-				stats["totalDirectories"] = stats["totalDirectories"].(int) + 1
-			}
-			return nil
-		}
-
-		// Apply filtering
-		if !core.CanBoaded(opt, currentPath) {
-			return nil
-		}
+	tree, err := core.WalkTree(path, opt)
+	if err != nil {
+		return stats, err
+	}
 
-		// Skip hidden files if requested
-		if opt.IgnoreDotFileFlag.Bool() && core.IsHiddenFile(info.Name()) {
-			return nil
+	for _, node := range tree.Nodes {
+		if node.IsDir {
+			stats["totalDirectories"] = stats["totalDirectories"].(int) + 1
+			continue
 		}
 
 		stats["totalFiles"] = stats["totalFiles"].(int) + 1
-		stats["totalSize"] = stats["totalSize"].(int64) + info.Size()
+		stats["totalSize"] = stats["totalSize"].(int64) + node.Size
 
 		// Language detection
-		language := DetectLanguage(currentPath)
+		language := DetectLanguage(node.Path)
 		if language != "" {
 			langStats := stats["languageStats"].(map[string]int)
 			langStats[language]++
 		}
 
 		// Extension stats
-		ext := filepath.Ext(currentPath)
+		ext := filepath.Ext(node.Path)
 		if ext != "" {
 			extStats := stats["extensionStats"].(map[string]int)
 			extStats[ext]++
 		}
+	}
 
-		return nil
-	})
-
-	return stats, err
+	return stats, nil
 }
 
 // GenerateArkliteForFiles generates arklite format for multiple files
@@ -394,3 +359,54 @@ func GenerateArkliteForFiles(paths []string, opt *commandline.Option) (string, e
 
 	return result.String(), nil
 }
+
+// GenerateOutline outlines a file, or the files of a directory that pass the
+// filters, with line numbers that match the files on disk.
+func GenerateOutline(path string, opt *commandline.Option, maxFiles int) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		rels, err := ListFilteredFiles(path, opt)
+		if err != nil {
+			return "", err
+		}
+		files = files[:0]
+		for _, rel := range rels {
+			files = append(files, filepath.Join(path, rel))
+		}
+	}
+	if len(files) > maxFiles {
+		files = files[:maxFiles]
+	}
+
+	var result strings.Builder
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(&result, "\n=== %s ===\nError: %v\n", file, err)
+			continue
+		}
+		if data, _, err = core.DecodeText(data); err != nil || core.IsBinary(data) {
+			continue
+		}
+		content := string(data)
+		if opt.MaskSecretsFlag.Bool() {
+			content = opt.SecretRuleSet().MaskFile(file, content)
+		}
+		rel, err := filepath.Rel(path, file)
+		if err != nil || rel == "." {
+			rel = filepath.Base(file)
+		}
+		result.WriteString(core.OutlineSection(filepath.ToSlash(rel), content))
+	}
+	if vault := opt.SecretRuleSet().Vault; vault != nil && opt.MaskSecretsFlag.Bool() {
+		if err := vault.Save(); err != nil {
+			return "", err
+		}
+	}
+	return result.String(), nil
+}
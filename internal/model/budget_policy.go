@@ -0,0 +1,34 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	BudgetPolicyTruncate   = "truncate"
+	BudgetPolicyDrop       = "drop"
+	BudgetPolicySmallFirst = "small-first"
+)
+
+var BudgetPolicyUnitMap = map[string]string{
+	"truncate":    BudgetPolicyTruncate,
+	"drop":        BudgetPolicyDrop,
+	"small-first": BudgetPolicySmallFirst,
+	"small_first": BudgetPolicySmallFirst,
+	"smallfirst":  BudgetPolicySmallFirst,
+}
+
+type BudgetPolicy string
+
+func (m *BudgetPolicy) Set(value string) error {
+	if unit, ok := BudgetPolicyUnitMap[value]; ok {
+		*m = BudgetPolicy(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'truncate', 'drop', 'small-first'", value)
+	}
+}
+
+func (m *BudgetPolicy) String() string {
+	return string(*m)
+}
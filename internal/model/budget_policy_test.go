@@ -0,0 +1,33 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestBudgetPolicy_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.BudgetPolicy
+	}{
+		{"truncate", false, model.BudgetPolicy("truncate")},
+		{"drop", false, model.BudgetPolicy("drop")},
+		{"small-first", false, model.BudgetPolicy("small-first")},
+		{"small_first", false, model.BudgetPolicy("small-first")},
+		{"largest", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.BudgetPolicy
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
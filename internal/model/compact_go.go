@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	CompactGoOff      = "off"
+	CompactGoBodies   = "bodies"
+	CompactGoExported = "exported"
+)
+
+var CompactGoUnitMap = map[string]string{
+	"off":        CompactGoOff,
+	"none":       CompactGoOff,
+	"bodies":     CompactGoBodies,
+	"signatures": CompactGoBodies,
+	"exported":   CompactGoExported,
+	"api":        CompactGoExported,
+}
+
+type CompactGo string
+
+func (m *CompactGo) Set(value string) error {
+	if unit, ok := CompactGoUnitMap[value]; ok {
+		*m = CompactGo(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'off', 'bodies', 'exported'", value)
+	}
+}
+
+func (m *CompactGo) String() string {
+	return string(*m)
+}
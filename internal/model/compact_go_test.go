@@ -0,0 +1,42 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestCompactGo_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.CompactGo
+	}{
+		{"off", false, model.CompactGo("off")},
+		{"none", false, model.CompactGo("off")},
+		{"bodies", false, model.CompactGo("bodies")},
+		{"signatures", false, model.CompactGo("bodies")},
+		{"exported", false, model.CompactGo("exported")},
+		{"api", false, model.CompactGo("exported")},
+		{"all", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.CompactGo
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
+
+func TestCompactGo_String(t *testing.T) {
+	s := model.CompactGo("exported")
+	if s.String() != "exported" {
+		t.Errorf("String() = %v, want exported", s.String())
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	DiffContentFull = "full"
+	DiffContentDiff = "diff"
+	DiffContentBoth = "both"
+)
+
+var DiffContentUnitMap = map[string]string{
+	"full":    DiffContentFull,
+	"content": DiffContentFull,
+	"diff":    DiffContentDiff,
+	"hunks":   DiffContentDiff,
+	"both":    DiffContentBoth,
+}
+
+type DiffContent string
+
+func (m *DiffContent) Set(value string) error {
+	if unit, ok := DiffContentUnitMap[value]; ok {
+		*m = DiffContent(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'full', 'diff', 'both'", value)
+	}
+}
+
+func (m *DiffContent) String() string {
+	return string(*m)
+}
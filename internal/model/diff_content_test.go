@@ -0,0 +1,40 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestDiffContent_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.DiffContent
+	}{
+		{"full", false, model.DiffContent("full")},
+		{"diff", false, model.DiffContent("diff")},
+		{"hunks", false, model.DiffContent("diff")},
+		{"both", false, model.DiffContent("both")},
+		{"patch", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.DiffContent
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
+
+func TestDiffContent_String(t *testing.T) {
+	s := model.DiffContent("both")
+	if s.String() != "both" {
+		t.Errorf("String() = %v, want both", s.String())
+	}
+}
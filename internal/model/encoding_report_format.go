@@ -0,0 +1,32 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	EncodingReportText = "text"
+	EncodingReportJSON = "json"
+)
+
+var EncodingReportFormatUnitMap = map[string]string{
+	"text": EncodingReportText,
+	"txt":  EncodingReportText,
+	"json": EncodingReportJSON,
+	"JSON": EncodingReportJSON,
+}
+
+type EncodingReportFormat string
+
+func (m *EncodingReportFormat) Set(value string) error {
+	if unit, ok := EncodingReportFormatUnitMap[value]; ok {
+		*m = EncodingReportFormat(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'text', 'json'", value)
+	}
+}
+
+func (m *EncodingReportFormat) String() string {
+	return string(*m)
+}
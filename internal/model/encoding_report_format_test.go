@@ -0,0 +1,40 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestEncodingReportFormat_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.EncodingReportFormat
+	}{
+		{"text", false, model.EncodingReportFormat("text")},
+		{"txt", false, model.EncodingReportFormat("text")},
+		{"json", false, model.EncodingReportFormat("json")},
+		{"JSON", false, model.EncodingReportFormat("json")},
+		{"sarif", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.EncodingReportFormat
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
+
+func TestEncodingReportFormat_String(t *testing.T) {
+	s := model.EncodingReportFormat("json")
+	if s.String() != "json" {
+		t.Errorf("String() = %v, want json", s.String())
+	}
+}
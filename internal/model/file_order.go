@@ -0,0 +1,36 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	FileOrderTree       = "tree"
+	FileOrderImportance = "importance"
+	FileOrderRecent     = "recent"
+	FileOrderSize       = "size"
+	FileOrderCustom     = "custom"
+)
+
+var FileOrderUnitMap = map[string]string{
+	"tree":       FileOrderTree,
+	"importance": FileOrderImportance,
+	"recent":     FileOrderRecent,
+	"size":       FileOrderSize,
+	"custom":     FileOrderCustom,
+}
+
+type FileOrder string
+
+func (m *FileOrder) Set(value string) error {
+	if unit, ok := FileOrderUnitMap[value]; ok {
+		*m = FileOrder(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'tree', 'importance', 'recent', 'size', 'custom'", value)
+	}
+}
+
+func (m *FileOrder) String() string {
+	return string(*m)
+}
@@ -0,0 +1,34 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestFileOrder_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.FileOrder
+	}{
+		{"tree", false, model.FileOrder("tree")},
+		{"importance", false, model.FileOrder("importance")},
+		{"recent", false, model.FileOrder("recent")},
+		{"size", false, model.FileOrder("size")},
+		{"custom", false, model.FileOrder("custom")},
+		{"random", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.FileOrder
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
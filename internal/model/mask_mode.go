@@ -0,0 +1,32 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	MaskModeRedact     = "redact"
+	MaskModeReversible = "reversible"
+)
+
+var MaskModeUnitMap = map[string]string{
+	"redact":     MaskModeRedact,
+	"mask":       MaskModeRedact,
+	"reversible": MaskModeReversible,
+	"vault":      MaskModeReversible,
+}
+
+type MaskMode string
+
+func (m *MaskMode) Set(value string) error {
+	if unit, ok := MaskModeUnitMap[value]; ok {
+		*m = MaskMode(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'redact', 'reversible'", value)
+	}
+}
+
+func (m *MaskMode) String() string {
+	return string(*m)
+}
@@ -0,0 +1,40 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestMaskMode_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.MaskMode
+	}{
+		{"redact", false, model.MaskMode("redact")},
+		{"mask", false, model.MaskMode("redact")},
+		{"reversible", false, model.MaskMode("reversible")},
+		{"vault", false, model.MaskMode("reversible")},
+		{"hide", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.MaskMode
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
+
+func TestMaskMode_String(t *testing.T) {
+	s := model.MaskMode("reversible")
+	if s.String() != "reversible" {
+		t.Errorf("String() = %v, want reversible", s.String())
+	}
+}
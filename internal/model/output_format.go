@@ -9,6 +9,9 @@ const (
 	PlainText = "plaintext"
 	XML       = "xml"
 	Arklite   = "arklite"
+	JSON      = "json"
+	JSONL     = "jsonl"
+	Outline   = "outline"
 	Auto      = "auto"
 )
 
@@ -35,6 +38,17 @@ var OutputFormatUnitMap = map[string]string{
 	"arkl":       Arklite,
 	"al":         Arklite,
 	"compact":    Arklite,
+	"json":       JSON,
+	"Json":       JSON,
+	"JSON":       JSON,
+	"jsonl":      JSONL,
+	"Jsonl":      JSONL,
+	"JSONL":      JSONL,
+	"ndjson":     JSONL,
+	"outline":    Outline,
+	"Outline":    Outline,
+	"skeleton":   Outline,
+	"toc":        Outline,
 	"auto":       Auto,
 }
 
@@ -43,6 +57,9 @@ var OutputFormatAllowComplessMap = map[string]bool{
 	PlainText: true,
 	XML:       true,
 	Arklite:   false,
+	JSON:      false,
+	JSONL:     false,
+	Outline:   false,
 	Auto:      false,
 }
 
@@ -67,7 +84,7 @@ func (m *OutputFormat) Set(value string) error {
 		*m = OutputFormat(unit)
 		return nil
 	} else {
-		return fmt.Errorf("invalid value: %q. Allowed values are 'markdown', 'plaintext', 'xml', 'auto'", value)
+		return fmt.Errorf("invalid value: %q. Allowed values are 'markdown', 'plaintext', 'xml', 'arklite', 'json', 'jsonl', 'outline', 'auto'", value)
 
 	}
 }
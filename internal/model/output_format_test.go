@@ -19,6 +19,12 @@ func TestOutputFormat_Set_Valid(t *testing.T) {
 		{"PlainText", model.PlainText},
 		{"plain_text", model.PlainText},
 		{"txt", model.PlainText},
+		{"json", model.JSON},
+		{"JSON", model.JSON},
+		{"jsonl", model.JSONL},
+		{"ndjson", model.JSONL},
+		{"outline", model.Outline},
+		{"skeleton", model.Outline},
 	}
 
 	for _, c := range cases {
@@ -74,6 +80,9 @@ func TestExt2OutputFormat(t *testing.T) {
 		{".xml", model.XML},
 		{".mkd", model.Markdown},
 		{".arklite", model.Arklite},
+		{".json", model.JSON},
+		{".jsonl", model.JSONL},
+		{".outline", model.Outline},
 		{".unknown", model.PlainText}, // fallback for unknown extension
 	}
 	for _, c := range cases {
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	ScanReportText  = "text"
+	ScanReportJSON  = "json"
+	ScanReportSARIF = "sarif"
+)
+
+var ScanReportFormatUnitMap = map[string]string{
+	"text":  ScanReportText,
+	"txt":   ScanReportText,
+	"json":  ScanReportJSON,
+	"JSON":  ScanReportJSON,
+	"sarif": ScanReportSARIF,
+	"SARIF": ScanReportSARIF,
+}
+
+type ScanReportFormat string
+
+func (m *ScanReportFormat) Set(value string) error {
+	if unit, ok := ScanReportFormatUnitMap[value]; ok {
+		*m = ScanReportFormat(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'text', 'json', 'sarif'", value)
+	}
+}
+
+func (m *ScanReportFormat) String() string {
+	return string(*m)
+}
@@ -0,0 +1,41 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestScanReportFormat_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.ScanReportFormat
+	}{
+		{"text", false, model.ScanReportFormat("text")},
+		{"txt", false, model.ScanReportFormat("text")},
+		{"json", false, model.ScanReportFormat("json")},
+		{"sarif", false, model.ScanReportFormat("sarif")},
+		{"SARIF", false, model.ScanReportFormat("sarif")},
+		{"xml", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.ScanReportFormat
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
+
+func TestScanReportFormat_String(t *testing.T) {
+	s := model.ScanReportFormat("sarif")
+	if s.String() != "sarif" {
+		t.Errorf("String() = %v, want sarif", s.String())
+	}
+}
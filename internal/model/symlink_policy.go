@@ -0,0 +1,32 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	SymlinkSkip   = "skip"
+	SymlinkList   = "list"
+	SymlinkFollow = "follow"
+)
+
+var SymlinkPolicyUnitMap = map[string]string{
+	"skip":   SymlinkSkip,
+	"list":   SymlinkList,
+	"follow": SymlinkFollow,
+}
+
+type SymlinkPolicy string
+
+func (m *SymlinkPolicy) Set(value string) error {
+	if unit, ok := SymlinkPolicyUnitMap[value]; ok {
+		*m = SymlinkPolicy(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'skip', 'list', 'follow'", value)
+	}
+}
+
+func (m *SymlinkPolicy) String() string {
+	return string(*m)
+}
@@ -0,0 +1,32 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestSymlinkPolicy_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.SymlinkPolicy
+	}{
+		{"skip", false, model.SymlinkPolicy("skip")},
+		{"list", false, model.SymlinkPolicy("list")},
+		{"follow", false, model.SymlinkPolicy("follow")},
+		{"resolve", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.SymlinkPolicy
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
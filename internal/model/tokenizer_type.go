@@ -0,0 +1,35 @@
+package model
+
+import (
+	"fmt"
+)
+
+const (
+	TokenizerCl100k = "cl100k"
+	TokenizerChars  = "chars"
+)
+
+var TokenizerTypeUnitMap = map[string]string{
+	"cl100k":      TokenizerCl100k,
+	"cl100k_base": TokenizerCl100k,
+	"cl100k-base": TokenizerCl100k,
+	"bpe":         TokenizerCl100k,
+	"chars":       TokenizerChars,
+	"char":        TokenizerChars,
+	"estimate":    TokenizerChars,
+}
+
+type TokenizerType string
+
+func (m *TokenizerType) Set(value string) error {
+	if unit, ok := TokenizerTypeUnitMap[value]; ok {
+		*m = TokenizerType(unit)
+		return nil
+	} else {
+		return fmt.Errorf("invalid value: %q. Allowed values are 'cl100k', 'chars'", value)
+	}
+}
+
+func (m *TokenizerType) String() string {
+	return string(*m)
+}
@@ -0,0 +1,33 @@
+package model_test
+
+import (
+	"testing"
+
+	"github.com/magicdrive/ark/internal/model"
+)
+
+func TestTokenizerType_Set(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectError bool
+		expected    model.TokenizerType
+	}{
+		{"cl100k", false, model.TokenizerType("cl100k")},
+		{"cl100k_base", false, model.TokenizerType("cl100k")},
+		{"chars", false, model.TokenizerType("chars")},
+		{"estimate", false, model.TokenizerType("chars")},
+		{"o200k", true, ""},
+		{"", true, ""},
+	}
+
+	for _, tt := range tests {
+		var s model.TokenizerType
+		err := s.Set(tt.input)
+		if (err != nil) != tt.expectError {
+			t.Errorf("Set(%q) error = %v, want error: %v", tt.input, err, tt.expectError)
+		}
+		if !tt.expectError && s != tt.expected {
+			t.Errorf("Set(%q) = %v, want %v", tt.input, s, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const (
+	// EntropyBase64RuleID reports random looking base64 tokens.
+	EntropyBase64RuleID = "high-entropy-base64"
+	// EntropyHexRuleID reports random looking hex tokens.
+	EntropyHexRuleID = "high-entropy-hex"
+)
+
+// EntropyRule finds secrets no pattern knows about: base64 or hex tokens at
+// least MinLength long whose Shannon entropy, in bits per character, reaches
+// the threshold of their alphabet. A threshold of 0 turns that alphabet off.
+type EntropyRule struct {
+	MinLength       int
+	Base64Threshold float64
+	HexThreshold    float64
+}
+
+// DefaultEntropyRule returns the thresholds an [entropy] table starts from.
+func DefaultEntropyRule() *EntropyRule {
+	return &EntropyRule{
+		MinLength:       20,
+		Base64Threshold: 4.5,
+		HexThreshold:    3.0,
+	}
+}
+
+var (
+	entropyTokenRegexp = regexp.MustCompile(`[A-Za-z0-9+/_\-]+={0,2}`)
+	hexTokenRegexp     = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
+
+	// hashTokenRegexp and hashContextRegexp recognize checksums rather than
+	// secrets: sha512-..., h1:..., "integrity": "...", checksum = "...".
+	hashTokenRegexp   = regexp.MustCompile(`(?i)^(?:sha\d*|md5)-`)
+	hashContextRegexp = regexp.MustCompile(`(?i)(?:@|\b(?:sha\d*|md5|h1)[-:]|\b(?:integrity|checksum|digest|hash|sha\d*|commit|revision|rev)["']?\s*[:=]\s*["']?)$`)
+)
+
+// lockfiles are full of package hashes, so entropy is not checked in them.
+var lockfiles = []string{
+	"package-lock.json", "npm-shrinkwrap.json", "yarn.lock", "pnpm-lock.yaml",
+	"bun.lock", "go.sum", "Cargo.lock", "Gemfile.lock", "composer.lock",
+	"poetry.lock", "Pipfile.lock", "uv.lock", "flake.lock", "mix.lock",
+	"pubspec.lock", "packages.lock.json", "gradle.lockfile",
+}
+
+func isLockfile(path string) bool {
+	return path != "" && slices.Contains(lockfiles, filepath.Base(path))
+}
+
+type entropyMatch struct {
+	start, end int
+	id         string
+	pattern    string
+}
+
+// find returns the high-entropy tokens of line. Tokens for which skip
+// returns true are ignored.
+func (e *EntropyRule) find(line string, skip func(string) bool) []entropyMatch {
+	if e == nil || e.MinLength <= 0 {
+		return nil
+	}
+	var found []entropyMatch
+	for _, loc := range entropyTokenRegexp.FindAllStringIndex(line, -1) {
+		token := line[loc[0]:loc[1]]
+		body := strings.TrimRight(token, "=")
+		if len(body) < e.MinLength || !hasLetterAndDigit(body) {
+			continue
+		}
+		if hashTokenRegexp.MatchString(token) || hashContextRegexp.MatchString(line[:loc[0]]) {
+			continue
+		}
+
+		id, threshold := EntropyBase64RuleID, e.Base64Threshold
+		if hexTokenRegexp.MatchString(body) {
+			id, threshold = EntropyHexRuleID, e.HexThreshold
+		}
+		if threshold <= 0 || ShannonEntropy(body) < threshold {
+			continue
+		}
+		if skip != nil && skip(token) {
+			continue
+		}
+		found = append(found, entropyMatch{
+			start:   loc[0],
+			end:     loc[1],
+			id:      id,
+			pattern: fmt.Sprintf("entropy >= %g", threshold),
+		})
+	}
+	return found
+}
+
+// mask replaces the high-entropy tokens of line with replace.
+func (e *EntropyRule) mask(line string, skip func(string) bool, replace func(string) string) string {
+	found := e.find(line, skip)
+	for i := len(found) - 1; i >= 0; i-- {
+		line = line[:found[i].start] + replace(line[found[i].start:found[i].end]) + line[found[i].end:]
+	}
+	return line
+}
+
+// ShannonEntropy returns the entropy of s in bits per byte.
+func ShannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	n := float64(len(s))
+	h := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / n
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
+
+func hasLetterAndDigit(s string) bool {
+	letter, digit := false, false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			digit = true
+		case c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			letter = true
+		}
+	}
+	return letter && digit
+}
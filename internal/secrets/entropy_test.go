@@ -0,0 +1,115 @@
+package secrets_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/secrets"
+)
+
+func TestShannonEntropy(t *testing.T) {
+	cases := map[string]float64{
+		"":                 0,
+		"aaaa":             0,
+		"abab":             1,
+		"0123456789abcdef": 4,
+	}
+	for s, want := range cases {
+		if got := secrets.ShannonEntropy(s); math.Abs(got-want) > 1e-9 {
+			t.Errorf("ShannonEntropy(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+// entropyRuleSet is the built-in rule set with the entropy rules turned on.
+func entropyRuleSet() *secrets.RuleSet {
+	rs := secrets.DefaultRuleSet()
+	rs.Entropy = secrets.DefaultEntropyRule()
+	return rs
+}
+
+func TestEntropy_OffByDefault(t *testing.T) {
+	input := "conn := \"q8Zr2LmP0vXc7TnB4yKd9WsE1hGa\"\nx = 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b\n"
+	rs := secrets.DefaultRuleSet()
+	if rs.Entropy != nil {
+		t.Fatalf("the entropy rules must be off by default, got %+v", rs.Entropy)
+	}
+	if got := rs.MaskFile("main.go", input); got != input {
+		t.Errorf("default rules masked random looking tokens: %q", got)
+	}
+	if matches, _ := rs.ScanReader(strings.NewReader(input), "main.go"); len(matches) != 0 {
+		t.Errorf("default rules reported random looking tokens: %+v", matches)
+	}
+}
+
+func TestEntropy_Mask(t *testing.T) {
+	rs := entropyRuleSet()
+	cases := []struct {
+		line   string
+		masked bool
+	}{
+		{`conn := "q8Zr2LmP0vXc7TnB4yKd9WsE1hGa"`, true},                 // base64
+		{`x = 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b`, true},           // hex
+		{`name := "TestOptParse_SecretRulesErrors"`, false},              // identifier
+		{`path := "internal/commandline/general_option"`, false},         // no digits
+		{`id := "550e8400-e29b-41d4-a716-446655440000"`, false},          // uuid, low entropy
+		{`short := "q8Zr2LmP0vXc"`, false},                               // below min length
+		{`"integrity": "sha512-q8Zr2LmP0vXc7TnB4yKd9WsE1hGaQ=="`, false}, // package hash
+		{`checksum = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b"`, false},
+		{`github.com/x/y v1.0.0 h1:q8Zr2LmP0vXc7TnB4yKd9WsE1hGa=`, false},
+		{`uses: actions/checkout@9f86d081884c7d659a2feaa0c55ad015a3bf4f1b`, false},
+	}
+	for _, c := range cases {
+		got := rs.Mask(c.line)
+		if masked := strings.Contains(got, "*****MASKED*****"); masked != c.masked {
+			t.Errorf("Mask(%q) = %q, masked = %t, want %t", c.line, got, masked, c.masked)
+		}
+	}
+}
+
+func TestEntropy_ScanReader(t *testing.T) {
+	rs := entropyRuleSet()
+	input := "a = 1\nconn := \"q8Zr2LmP0vXc7TnB4yKd9WsE1hGa\"\nx = 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b\n"
+	matches, err := rs.ScanReader(strings.NewReader(input), "main.go")
+	if err != nil {
+		t.Fatalf("ScanReader: %v", err)
+	}
+	if len(matches) != 2 || matches[0].Line != 2 || matches[0].RuleID != secrets.EntropyBase64RuleID ||
+		matches[1].Line != 3 || matches[1].RuleID != secrets.EntropyHexRuleID {
+		t.Errorf("unexpected matches: %+v", matches)
+	}
+
+	// lockfiles are full of hashes
+	for _, name := range []string{"go.sum", "sub/package-lock.json", "Cargo.lock"} {
+		if matches, _ := rs.ScanReader(strings.NewReader(input), name); len(matches) != 0 {
+			t.Errorf("%s: expected no entropy match, got %+v", name, matches)
+		}
+		if got := rs.MaskFile(name, input); got != input {
+			t.Errorf("%s: expected no masking, got %q", name, got)
+		}
+	}
+}
+
+func TestEntropy_AllowAndDisable(t *testing.T) {
+	line := `conn := "q8Zr2LmP0vXc7TnB4yKd9WsE1hGa"`
+
+	rs := entropyRuleSet()
+	rs.AddAllowed(`^q8Zr2`)
+	if got := rs.Mask(line); got != line {
+		t.Errorf("allow-listed token was masked: %q", got)
+	}
+
+	rs = secrets.DefaultRuleSet()
+	on := secrets.DefaultEntropyRule()
+	rs.Entropy = on
+	if err := rs.Disable(secrets.EntropyBase64RuleID); err != nil {
+		t.Fatalf("Disable: %v", err)
+	}
+	if got := rs.Mask(line); got != line {
+		t.Errorf("disabled rule still masks: %q", got)
+	}
+	if on.Base64Threshold == 0 {
+		t.Error("Disable must not change the rule it was given")
+	}
+}
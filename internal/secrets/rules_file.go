@@ -0,0 +1,179 @@
+package secrets
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"github.com/magicdrive/ark/internal/config"
+)
+
+// RulesFilename is the secret rules file looked up in the target directory.
+const RulesFilename = ".arksecrets.toml"
+
+var valueMaskKeyRegexp = regexp.MustCompile(`^[A-Za-z0-9_\-\$]+$`)
+
+// LoadRuleSet returns the built-in rules extended by the rules file at path:
+//
+//	disable = ["jwt", "value-masking"]        # built-in rule ids
+//	allow = ['ACME_TEST_[A-Z0-9]+']            # allow-list regexps
+//	value-mask-keys = ["session_key"]          # extra key names whose values are masked
+//
+//	[mask]
+//	acme-token = 'ACME_[A-Z0-9]{32}'           # id = full-mask regexp
+//
+//	[entropy]                                  # turns the entropy rules on
+//	min-length = 24                            # shortest token checked
+//	base64-threshold = 4.8                     # bits per character, 0 turns it off
+//	hex-threshold = 3.2
+func LoadRuleSet(path string) (*RuleSet, error) {
+	tables, err := readRules(path)
+	if err != nil {
+		return nil, err
+	}
+	r := DefaultRuleSet()
+	if err := r.applyRules(tables); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// weakeningKeys are the top-level keys of a rules file that can mask less
+// than the built-in rules. The [entropy] table only adds, since the entropy
+// rules are off without it.
+var weakeningKeys = []string{"disable", "allow"}
+
+// LoadAdditiveRuleSet is LoadRuleSet for a rules file found in the tree being
+// masked, which must not be able to switch masking off: it applies the rules
+// the file adds, and returns the weakening keys it ignores. The whole file is
+// still checked, so a broken one is an error either way.
+func LoadAdditiveRuleSet(path string) (*RuleSet, []string, error) {
+	tables, err := readRules(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := DefaultRuleSet().applyRules(tables); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var ignored []string
+	root := maps.Clone(tables[""])
+	for _, key := range weakeningKeys {
+		if _, ok := root[key]; ok {
+			ignored = append(ignored, key)
+			delete(root, key)
+		}
+	}
+	tables = maps.Clone(tables)
+	tables[""] = root
+	r := DefaultRuleSet()
+	if err := r.applyRules(tables); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, ignored, nil
+}
+
+func readRules(path string) (map[string]map[string]config.Value, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := config.ParseTOML(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return tables, nil
+}
+
+func (r *RuleSet) applyRules(tables map[string]map[string]config.Value) error {
+	for name := range tables {
+		if name != "" && name != "mask" && name != "entropy" {
+			return fmt.Errorf("unknown table [%s]", name)
+		}
+	}
+
+	root := tables[""]
+	for key := range root {
+		if !slices.Contains([]string{"disable", "allow", "value-mask-keys"}, key) {
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+
+	// thresholds first, so that disable wins over them
+	if entropy, ok := tables["entropy"]; ok {
+		if err := r.applyEntropy(entropy); err != nil {
+			return fmt.Errorf("entropy: %w", err)
+		}
+	}
+
+	for _, id := range root["disable"].Items {
+		if err := r.Disable(id); err != nil {
+			return err
+		}
+	}
+	for _, pattern := range root["allow"].Items {
+		if err := r.AddAllowed(pattern); err != nil {
+			return fmt.Errorf("allow: %w", err)
+		}
+	}
+	if keys := root["value-mask-keys"].Items; len(keys) > 0 {
+		for _, key := range keys {
+			if !valueMaskKeyRegexp.MatchString(key) {
+				return fmt.Errorf("value-mask-keys: invalid key name %q", key)
+			}
+		}
+		r.AddValueMaskKey(keys)
+	}
+
+	masks := tables["mask"]
+	for _, id := range slices.Sorted(maps.Keys(masks)) {
+		if slices.ContainsFunc(r.FullMaskPatterns, func(re *regexp.Regexp) bool { return r.PatternID(re) == id }) {
+			return fmt.Errorf("mask: rule id %q is already defined", id)
+		}
+		if masks[id].Array {
+			return fmt.Errorf("mask: %s must be a single regexp", id)
+		}
+		if err := r.AddNamedPattern(id, masks[id].String()); err != nil {
+			return fmt.Errorf("mask: %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// entropyLimits bounds each threshold by the entropy of its alphabet.
+var entropyLimits = map[string]float64{"base64-threshold": 6, "hex-threshold": 4}
+
+func (r *RuleSet) applyEntropy(values map[string]config.Value) error {
+	if r.Entropy == nil {
+		r.Entropy = DefaultEntropyRule()
+	}
+	entropy := *r.Entropy
+	for _, key := range slices.Sorted(maps.Keys(values)) {
+		value := values[key].String()
+		switch key {
+		case "min-length":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("min-length must be a positive integer: %q", value)
+			}
+			entropy.MinLength = n
+		case "base64-threshold", "hex-threshold":
+			f, err := strconv.ParseFloat(value, 64)
+			if err != nil || f < 0 || f > entropyLimits[key] {
+				return fmt.Errorf("%s must be a number between 0 and %g: %q", key, entropyLimits[key], value)
+			}
+			if key == "base64-threshold" {
+				entropy.Base64Threshold = f
+			} else {
+				entropy.HexThreshold = f
+			}
+		default:
+			return fmt.Errorf("unknown key %q", key)
+		}
+	}
+	r.Entropy = &entropy
+	return nil
+}
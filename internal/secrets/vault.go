@@ -0,0 +1,195 @@
+package secrets
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sync"
+)
+
+// VaultFilename is the vault kept in the ark user config directory.
+const VaultFilename = "vault.json"
+
+// placeholderRegexp matches the placeholders written by Vault.Placeholder.
+var placeholderRegexp = regexp.MustCompile(`<<SECRET_[0-9a-f]{4,64}>>`)
+
+// vaultKeySize is the byte size of the key placeholder ids are derived with.
+const vaultKeySize = 32
+
+// Vault maps the placeholders of reversible masking back to the secrets they
+// replace. The same secret always gets the same placeholder, so a file masked
+// twice, or edited in between, still unmasks. It is safe for concurrent use.
+type Vault struct {
+	Path string
+
+	mu sync.Mutex
+	// key is the random key of the vault, so that a placeholder tells
+	// nothing about its secret to whoever reads the dump
+	key     []byte
+	secrets map[string]string // placeholder -> secret
+	index   map[string]string // secret -> placeholder
+	dirty   bool
+}
+
+type vaultFile struct {
+	Version int               `json:"version"`
+	Key     string            `json:"key,omitempty"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+// DefaultVaultPath returns <user config dir>/ark/vault.json.
+func DefaultVaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "ark", VaultFilename), nil
+}
+
+// OpenVault reads the vault at path; a missing file is an empty vault that
+// Save creates. A vault other users can read is rejected. A vault without a
+// key, written before placeholders were keyed, gets a new one.
+func OpenVault(path string) (*Vault, error) {
+	v := &Vault{Path: path, secrets: map[string]string{}, index: map[string]string{}}
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return v, v.newKey()
+	} else if err != nil {
+		return nil, err
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0077 != 0 {
+		return nil, fmt.Errorf("secret vault %s must only be accessible by its owner (chmod 600)", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to read secret vault %s: %w", path, err)
+	}
+	if f.Key == "" {
+		if err := v.newKey(); err != nil {
+			return nil, err
+		}
+	} else if v.key, err = hex.DecodeString(f.Key); err != nil || len(v.key) != vaultKeySize {
+		return nil, fmt.Errorf("secret vault %s: invalid key", path)
+	}
+	for placeholder, secret := range f.Secrets {
+		if !placeholderRegexp.MatchString(placeholder) {
+			return nil, fmt.Errorf("secret vault %s: invalid placeholder %q", path, placeholder)
+		}
+		v.secrets[placeholder] = secret
+		v.index[secret] = placeholder
+	}
+	return v, nil
+}
+
+// newKey sets a random key.
+func (v *Vault) newKey() error {
+	v.key = make([]byte, vaultKeySize)
+	if _, err := rand.Read(v.key); err != nil {
+		return fmt.Errorf("failed to create the secret vault key: %w", err)
+	}
+	return nil
+}
+
+// Placeholder returns the placeholder of secret, <<SECRET_7f3a>>, adding it
+// to the vault when it is new. The id is a prefix of the HMAC-SHA-256 of the
+// secret under the vault key, lengthened until it is unique. Unlike a plain
+// hash, it cannot be used to confirm a guessed secret without the vault.
+func (v *Vault) Placeholder(secret string) string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if placeholder, ok := v.index[secret]; ok {
+		return placeholder
+	}
+	mac := hmac.New(sha256.New, v.key)
+	mac.Write([]byte(secret))
+	id := hex.EncodeToString(mac.Sum(nil))
+	for n := 4; ; n += 2 {
+		placeholder := "<<SECRET_" + id[:n] + ">>"
+		if _, taken := v.secrets[placeholder]; !taken || n >= len(id) {
+			v.secrets[placeholder] = secret
+			v.index[secret] = placeholder
+			v.dirty = true
+			return placeholder
+		}
+	}
+}
+
+// Len returns the number of secrets in the vault.
+func (v *Vault) Len() int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return len(v.secrets)
+}
+
+// Unmask replaces the placeholders in content with their secrets. It returns
+// the number replaced and the placeholders the vault does not know, which
+// are left as is.
+func (v *Vault) Unmask(content string) (string, int, []string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	replaced := 0
+	var unknown []string
+	result := placeholderRegexp.ReplaceAllStringFunc(content, func(placeholder string) string {
+		if secret, ok := v.secrets[placeholder]; ok {
+			replaced++
+			return secret
+		}
+		unknown = append(unknown, placeholder)
+		return placeholder
+	})
+	return result, replaced, unknown
+}
+
+// Save writes the vault, readable by its owner only, when secrets were added.
+func (v *Vault) Save() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if !v.dirty {
+		return nil
+	}
+	data, err := json.MarshalIndent(vaultFile{Version: 2, Key: hex.EncodeToString(v.key), Secrets: v.secrets}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.Path), 0700); err != nil {
+		return err
+	}
+
+	// write a temporary file and rename it, so a vault is never half written
+	tmp, err := os.CreateTemp(filepath.Dir(v.Path), ".vault-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), v.Path); err != nil {
+		return err
+	}
+	v.dirty = false
+	return nil
+}
@@ -2,6 +2,8 @@ package spinner
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"sync"
 	"time"
 )
@@ -12,21 +14,39 @@ type Spinner struct {
 	frames   []string
 	interval time.Duration
 	msg      string
+	out      io.Writer
 
 	stopCh chan struct{}
 	wg     sync.WaitGroup
 	mu     sync.Mutex
 }
 
+// New creates a spinner that draws on stderr, so that stdout stays free for
+// the dump itself.
 func New(interval time.Duration, msg string) *Spinner {
+	return NewWithWriter(interval, msg, os.Stderr)
+}
+
+// NewWithWriter creates a spinner that draws on out.
+func NewWithWriter(interval time.Duration, msg string, out io.Writer) *Spinner {
 	return &Spinner{
 		frames:   spinnerFrames,
 		interval: interval,
 		msg:      msg,
+		out:      out,
 		stopCh:   make(chan struct{}),
 	}
 }
 
+// IsTerminal reports whether f is attached to a terminal.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
 func (s *Spinner) SetMessage(msg string) {
 	s.mu.Lock()
 	s.msg = msg
@@ -42,14 +62,14 @@ func (s *Spinner) Start() {
 		for {
 			select {
 			case <-s.stopCh:
-				fmt.Print("\r\x1b[K")
+				fmt.Fprint(s.out, "\r\x1b[K")
 				return
 			default:
 				s.mu.Lock()
 				msg := s.msg
 				s.mu.Unlock()
 				frame := s.frames[i%len(s.frames)]
-				fmt.Printf("\r%s %s", frame, msg)
+				fmt.Fprintf(s.out, "\r%s %s", frame, msg)
 				time.Sleep(s.interval)
 				i++
 			}
@@ -61,8 +81,8 @@ func (s *Spinner) Stop(finalMsg ...string) {
 	close(s.stopCh)
 	s.wg.Wait()
 	if len(finalMsg) > 0 {
-		fmt.Printf("\r\x1b[K%s\n", finalMsg[0])
+		fmt.Fprintf(s.out, "\r\x1b[K%s\n", finalMsg[0])
 	} else {
-		fmt.Print("\r\x1b[K")
+		fmt.Fprint(s.out, "\r\x1b[K")
 	}
 }
@@ -1,6 +1,7 @@
 package spinner_test
 
 import (
+	"os"
 	"strings"
 	"testing"
 	"time"
@@ -47,3 +48,30 @@ func TestSpinnerWithLongMessage(t *testing.T) {
 	time.Sleep(60 * time.Millisecond)
 	s.Stop("Done!")
 }
+
+func TestSpinnerWithWriter(t *testing.T) {
+	var buf strings.Builder
+	s := spinner.NewWithWriter(10*time.Millisecond, "Writing", &buf)
+	s.Start()
+	time.Sleep(30 * time.Millisecond)
+	s.Stop("Written!")
+
+	got := buf.String()
+	if !strings.Contains(got, "Writing") {
+		t.Errorf("spinner message not written: %q", got)
+	}
+	if !strings.HasSuffix(got, "Written!\n") {
+		t.Errorf("final message not written: %q", got)
+	}
+}
+
+func TestIsTerminal_RegularFile(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "spinner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if spinner.IsTerminal(f) {
+		t.Errorf("regular file reported as a terminal")
+	}
+}
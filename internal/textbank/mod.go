@@ -14,9 +14,25 @@ var DescriptionTemplateXML string
 //go:embed description_template/description.arklite.txt
 var DescriptionTemplateArklite string
 
+//go:embed description_template/description.arklite_lossless.txt
+var DescriptionTemplateArkliteLossless string
+
+//go:embed description_template/description.outline.txt
+var DescriptionTemplateOutline string
+
 //go:embed description_template/arklite_compless_header.arklite.txt
 var ArkliteComplessHeaderTemplate string
 
+// The notes filled into the description templates on how binary files are
+// dumped, without and with --binary-metadata. The Markdown and XML templates
+// share one wording.
+const (
+	BinaryNoteExcluded     = "Binary and non-UTF-8 files are excluded."
+	BinaryNoteStubs        = "Binary files are listed as metadata stubs. Non-UTF-8 files are excluded."
+	BinaryNoteExcludedText = "Only UTF-8 text files are included. Binary and image files are excluded."
+	BinaryNoteStubsText    = "Only UTF-8 text files are included in full. Binary and image files are listed as metadata stubs."
+)
+
 const (
 	EmojiSuccess     = "✅"
 	EmojiInterrupted = "🛑"
@@ -0,0 +1,290 @@
+package tokenizer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	NameCl100k = "cl100k"
+	NameChars  = "chars"
+)
+
+// Tokenizer counts how many tokens a piece of text costs in an LLM context window.
+type Tokenizer interface {
+	Name() string
+	Count(text string) int
+}
+
+// New returns the tokenizer registered under name.
+// When rankFile is not empty, the cl100k tokenizer performs exact BPE merges
+// using the given tiktoken rank file (e.g. cl100k_base.tiktoken).
+func New(name string, rankFile string) (Tokenizer, error) {
+	switch name {
+	case NameChars:
+		return &CharEstimator{}, nil
+	case NameCl100k, "":
+		if rankFile == "" {
+			return NewCl100k(nil), nil
+		}
+		ranks, err := LoadTiktokenRanksFile(rankFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewCl100k(ranks), nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer: %q", name)
+	}
+}
+
+/*-----------------*/
+/* chars estimator */
+/*-----------------*/
+
+// CharEstimator approximates one token per four characters.
+type CharEstimator struct{}
+
+func (c *CharEstimator) Name() string {
+	return NameChars
+}
+
+func (c *CharEstimator) Count(text string) int {
+	n := utf8.RuneCountInString(text)
+	return (n + 3) / 4
+}
+
+/*------------------*/
+/* cl100k-style BPE */
+/*------------------*/
+
+// Cl100k splits text with the cl100k_base pre-tokenizer rules and then
+// either runs byte-level BPE merges (when ranks are loaded) or estimates
+// the token count of each piece.
+type Cl100k struct {
+	ranks map[string]int
+}
+
+func NewCl100k(ranks map[string]int) *Cl100k {
+	return &Cl100k{ranks: ranks}
+}
+
+func (c *Cl100k) Name() string {
+	return NameCl100k
+}
+
+func (c *Cl100k) Count(text string) int {
+	total := 0
+	for _, piece := range PreTokenize(text) {
+		if c.ranks != nil {
+			total += len(bytePairMerge([]byte(piece), c.ranks))
+		} else {
+			total += estimatePiece(piece)
+		}
+	}
+	return total
+}
+
+// LoadTiktokenRanksFile loads a tiktoken rank file from path.
+func LoadTiktokenRanksFile(path string) (map[string]int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadTiktokenRanks(f)
+}
+
+// LoadTiktokenRanks parses the tiktoken format: one "<base64 token> <rank>" pair per line.
+func LoadTiktokenRanks(r io.Reader) (map[string]int, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid rank line %d: %q", lineNum, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token on line %d: %w", lineNum, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank on line %d: %w", lineNum, err)
+		}
+		ranks[string(token)] = rank
+	}
+	return ranks, scanner.Err()
+}
+
+// bytePairMerge repeatedly merges the adjacent pair with the lowest rank
+// until no mergeable pair remains, and returns the resulting parts.
+func bytePairMerge(piece []byte, ranks map[string]int) []string {
+	if _, ok := ranks[string(piece)]; ok {
+		return []string{string(piece)}
+	}
+	parts := make([]string, 0, len(piece))
+	for _, b := range piece {
+		parts = append(parts, string([]byte{b}))
+	}
+	for len(parts) > 1 {
+		bestIdx := -1
+		bestRank := 0
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := ranks[parts[i]+parts[i+1]]; ok && (bestIdx == -1 || rank < bestRank) {
+				bestIdx = i
+				bestRank = rank
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+		parts[bestIdx] = parts[bestIdx] + parts[bestIdx+1]
+		parts = append(parts[:bestIdx+1], parts[bestIdx+2:]...)
+	}
+	return parts
+}
+
+// estimatePiece approximates the number of cl100k tokens of a single pre-tokenized piece.
+func estimatePiece(piece string) int {
+	if piece == "" {
+		return 0
+	}
+	first, _ := utf8.DecodeRuneInString(strings.TrimLeft(piece, " "))
+	switch {
+	case strings.TrimSpace(piece) == "":
+		return (len(piece) + 7) / 8
+	case unicode.IsDigit(first):
+		return 1
+	case unicode.IsLetter(first) || first == utf8.RuneError:
+		runes := utf8.RuneCountInString(piece)
+		if runes == len(piece) {
+			// ASCII words: most common words are a single token.
+			return (len(piece) + 4) / 5
+		}
+		// CJK and other scripts are mostly one token per character.
+		return runes
+	default:
+		return (utf8.RuneCountInString(piece) + 2) / 3
+	}
+}
+
+// PreTokenize splits text the way the cl100k_base pattern does:
+//
+//	(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+
+//
+// RE2 has no lookahead, so the pattern is evaluated by hand.
+func PreTokenize(text string) []string {
+	var pieces []string
+	rs := []rune(text)
+	i := 0
+	for i < len(rs) {
+		n := matchPiece(rs, i)
+		pieces = append(pieces, string(rs[i:i+n]))
+		i += n
+	}
+	return pieces
+}
+
+func matchPiece(rs []rune, i int) int {
+	// contractions
+	if rs[i] == '\'' && i+1 < len(rs) {
+		for _, c := range []string{"s", "t", "re", "ve", "m", "ll", "d"} {
+			if hasFoldPrefix(rs[i+1:], c) {
+				return 1 + len(c)
+			}
+		}
+	}
+	// [^\r\n\p{L}\p{N}]?\p{L}+
+	j := i
+	if !isNewline(rs[j]) && !isLetter(rs[j]) && !isNumber(rs[j]) && j+1 < len(rs) && isLetter(rs[j+1]) {
+		j++
+	}
+	if isLetter(rs[j]) {
+		for j < len(rs) && isLetter(rs[j]) {
+			j++
+		}
+		return j - i
+	}
+	// \p{N}{1,3}
+	if isNumber(rs[i]) {
+		j = i
+		for j < len(rs) && j-i < 3 && isNumber(rs[j]) {
+			j++
+		}
+		return j - i
+	}
+	// ' ?[^\s\p{L}\p{N}]+[\r\n]*'
+	j = i
+	if rs[j] == ' ' && j+1 < len(rs) && isPunct(rs[j+1]) {
+		j++
+	}
+	if isPunct(rs[j]) {
+		for j < len(rs) && isPunct(rs[j]) {
+			j++
+		}
+		for j < len(rs) && isNewline(rs[j]) {
+			j++
+		}
+		return j - i
+	}
+	// \s*[\r\n]+
+	j = i
+	lastNewlineEnd := -1
+	for j < len(rs) && unicode.IsSpace(rs[j]) {
+		if isNewline(rs[j]) {
+			k := j
+			for k < len(rs) && isNewline(rs[k]) {
+				k++
+			}
+			lastNewlineEnd = k
+			j = k
+			continue
+		}
+		j++
+	}
+	if lastNewlineEnd != -1 {
+		return lastNewlineEnd - i
+	}
+	// \s+(?!\S) | \s+
+	if j < len(rs) && j-i > 1 {
+		// leave the last whitespace for the following word
+		return j - i - 1
+	}
+	return j - i
+}
+
+func hasFoldPrefix(rs []rune, prefix string) bool {
+	if len(rs) < len(prefix) {
+		return false
+	}
+	return strings.EqualFold(string(rs[:len(prefix)]), prefix)
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r)
+}
+
+func isNumber(r rune) bool {
+	return unicode.IsNumber(r)
+}
+
+func isNewline(r rune) bool {
+	return r == '\r' || r == '\n'
+}
+
+func isPunct(r rune) bool {
+	return !unicode.IsSpace(r) && !unicode.IsLetter(r) && !unicode.IsNumber(r)
+}
@@ -0,0 +1,118 @@
+package tokenizer_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/magicdrive/ark/internal/tokenizer"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		name     string
+		expected string
+		wantErr  bool
+	}{
+		{"cl100k", tokenizer.NameCl100k, false},
+		{"", tokenizer.NameCl100k, false},
+		{"chars", tokenizer.NameChars, false},
+		{"unknown", "", true},
+	}
+	for _, c := range cases {
+		tk, err := tokenizer.New(c.name, "")
+		if (err != nil) != c.wantErr {
+			t.Errorf("New(%q) error = %v, wantErr %v", c.name, err, c.wantErr)
+			continue
+		}
+		if !c.wantErr && tk.Name() != c.expected {
+			t.Errorf("New(%q).Name() = %q, want %q", c.name, tk.Name(), c.expected)
+		}
+	}
+}
+
+func TestCharEstimator_Count(t *testing.T) {
+	tk := &tokenizer.CharEstimator{}
+	cases := map[string]int{
+		"":         0,
+		"a":        1,
+		"abcd":     1,
+		"abcde":    2,
+		"こんにちは世界!": 2,
+	}
+	for input, want := range cases {
+		if got := tk.Count(input); got != want {
+			t.Errorf("Count(%q) = %d, want %d", input, got, want)
+		}
+	}
+}
+
+func TestPreTokenize(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected []string
+	}{
+		{"hello world", []string{"hello", " world"}},
+		{"I'll go", []string{"I", "'ll", " go"}},
+		{"12345", []string{"123", "45"}},
+		{"a  b", []string{"a", " ", " b"}},
+		{"x := f(y);\n", []string{"x", " :=", " f", "(y", ");\n"}},
+		{"a\n\n  b", []string{"a", "\n\n", " ", " b"}},
+	}
+	for _, c := range cases {
+		got := tokenizer.PreTokenize(c.input)
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("PreTokenize(%q) = %q, want %q", c.input, got, c.expected)
+		}
+		if strings.Join(got, "") != c.input {
+			t.Errorf("PreTokenize(%q) is not lossless", c.input)
+		}
+	}
+}
+
+func TestCl100k_EstimateIsReasonable(t *testing.T) {
+	tk := tokenizer.NewCl100k(nil)
+	text := "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello, world\")\n}\n"
+	got := tk.Count(text)
+	// tiktoken reports 20 tokens for this snippet.
+	if got < 14 || got > 28 {
+		t.Errorf("Count() = %d, expected roughly 20", got)
+	}
+}
+
+func TestCl100k_WithRanks(t *testing.T) {
+	var b strings.Builder
+	rank := 0
+	for i := range 256 {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(i)}), rank)
+		rank++
+	}
+	for _, tok := range []string{"he", "ll", "hell", "hello", " w", "or", " wor", "ld", " world"} {
+		fmt.Fprintf(&b, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(tok)), rank)
+		rank++
+	}
+
+	ranks, err := tokenizer.LoadTiktokenRanks(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatalf("LoadTiktokenRanks failed: %v", err)
+	}
+	tk := tokenizer.NewCl100k(ranks)
+
+	if got := tk.Count("hello world"); got != 2 {
+		t.Errorf("Count(hello world) = %d, want 2", got)
+	}
+	if got := tk.Count("help"); got != 3 {
+		t.Errorf("Count(help) = %d, want 3", got)
+	}
+}
+
+func TestLoadTiktokenRanks_Invalid(t *testing.T) {
+	if _, err := tokenizer.LoadTiktokenRanks(strings.NewReader("aGVsbG8=\n")); err == nil {
+		t.Error("expected error for missing rank")
+	}
+	if _, err := tokenizer.LoadTiktokenRanks(strings.NewReader("!!! 1\n")); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+}